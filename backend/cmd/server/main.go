@@ -2,7 +2,7 @@
 package main
 
 import (
-	//"context" // Required for context.Background() in Echo's logger setup
+	"context"
 	"fmt"
 	"io"
 	"log/slog" // For slog types
@@ -10,9 +10,12 @@ import (
 	"os"       // For os.Exit, os.Stderr
 	"time"
 
+	"github.com/jjckrbbt/cdms/backend/internal/blobstore"
 	"github.com/jjckrbbt/cdms/backend/internal/cdms_data/api"
 	"github.com/jjckrbbt/cdms/backend/internal/cdms_data/importer"
+	"github.com/jjckrbbt/cdms/backend/internal/cdms_data/jobqueue"
 	"github.com/jjckrbbt/cdms/backend/internal/cdms_data/processor"
+	"github.com/jjckrbbt/cdms/backend/internal/cdms_data/progress"
 	"github.com/jjckrbbt/cdms/backend/internal/config"
 	"github.com/jjckrbbt/cdms/backend/internal/database"
 	"github.com/jjckrbbt/cdms/backend/internal/db"
@@ -66,8 +69,16 @@ func main() {
 	// 5. Initialize Core Application Components.
 	realQuerier := db.New(dbClient.Pool)
 
+	// The importer writes raw reports and the processor reads them back, so both share one store.
+	blobStore, err := blobstore.New(context.Background(), cfg, appLogger.With("service", "blob_storage"))
+	if err != nil {
+		appLogger.Error("Failed to initialize blob storage", slog.Any("error", err))
+		os.Exit(1)
+	}
+	appLogger.Info("Blob storage initialized.", "backend", cfg.StorageBackend)
+
 	importerLogger := appLogger.With("service", "file_importer")
-	fileImporter, err := importer.NewImporter(dbClient, importerLogger, cfg)
+	fileImporter, err := importer.NewImporter(dbClient, importerLogger, cfg, blobStore)
 	if err != nil {
 		appLogger.Error("Failed to initialize file importer service", slog.Any("error", err))
 		os.Exit(1)
@@ -75,21 +86,38 @@ func main() {
 	appLogger.Info("File Importer service initialized.")
 
 	processorLogger := appLogger.With("service", "cdms_data_processor")
-	cdmsProcessor, err := processor.NewProcessor(dbClient, processorLogger, cfg)
+	cdmsProcessor, err := processor.NewProcessor(dbClient, processorLogger, cfg, blobStore)
 	if err != nil {
 		appLogger.Error("Failed to initialize CDMS data processor service", slog.Any("error", err))
 		os.Exit(1)
 	}
 	appLogger.Info("CDMS Data Processor service initialized.")
 
+	// Progress events and cancellation requests reach this instance over LISTEN/NOTIFY,
+	// whichever instance processes the upload.
+	progressHub := progress.NewHub(dbClient.Pool, appLogger.With("service", "upload_progress"))
+
+	// Upload processing runs on queue workers so that work survives restarts and failed attempts are retried.
+	uploadQueue := jobqueue.NewQueue(realQuerier, cdmsProcessor, progressHub, appLogger.With("service", "upload_job_queue"), cfg)
+	queueCtx, stopQueue := context.WithCancel(context.Background())
+	defer func() {
+		stopQueue()
+		uploadQueue.Wait()
+	}()
+	go progressHub.Run(queueCtx)
+	uploadQueue.Start(queueCtx)
+
 	// Initialize your HTTP API handlers.
 	apiLogger := appLogger.With("service", "api_handlers")
 
-	uploadHandler := api.NewUploadHandler(fileImporter, cdmsProcessor, realQuerier, apiLogger)
+	uploadHandler := api.NewUploadHandler(fileImporter, cdmsProcessor, uploadQueue, progressHub, realQuerier, apiLogger)
 	chargebackHandler := api.NewChargebackHandler(realQuerier, apiLogger)
 	delinquencyHandler := api.NewDelinquencyHandler(realQuerier, apiLogger)
 	dashboardHandler := api.NewDashboardHandler(realQuerier, apiLogger)
 	userHandler := api.NewUserHandler(realQuerier, apiLogger)
+	commentHandler := api.NewCommentHandler(realQuerier, apiLogger)
+	reportDefinitionHandler := api.NewReportDefinitionHandler(cdmsProcessor, realQuerier, apiLogger)
+	vendorHandler := api.NewVendorHandler(realQuerier, apiLogger)
 
 	appLogger.Info("API handlers initialized.")
 
@@ -182,7 +210,7 @@ func main() {
 	})
 
 	//Upload group
-	apiGroup.POST("/upload/:reportType", uploadHandler.HandleUpload)
+	apiGroup.POST("/upload/:reportType", uploadHandler.HandleUpload, userHandler.LoadUserContextMiddleware, api.RequirePermission("reports:upload"))
 
 	//User Routes
 	userRoutes := apiGroup.Group("/users")
@@ -197,26 +225,61 @@ func main() {
 	//Upload Reporting Group
 	uploadRoutes := apiGroup.Group("/uploads")
 	uploadRoutes.GET("", uploadHandler.HandleGetUploads)
+	uploadRoutes.POST("/bundles", uploadHandler.HandleUploadBundle, userHandler.LoadUserContextMiddleware, api.RequirePermission("reports:upload"))
+	uploadRoutes.GET("/bundles/:id", uploadHandler.HandleGetUploadBundle)
+	uploadRoutes.GET("/removed_rows/issues", uploadHandler.HandleSummarizeRemovedRowIssues)
 	uploadRoutes.GET("/removed_rows/:id", uploadHandler.HandleGetRemovedRows)
+	uploadRoutes.POST("/removed_rows/:id/resubmit", uploadHandler.HandleResubmitRemovedRow, userHandler.LoadUserContextMiddleware, api.RequirePermission("reports:upload"))
+	uploadRoutes.POST("/removed_rows/:id/dismiss", uploadHandler.HandleDismissRemovedRow, userHandler.LoadUserContextMiddleware, api.RequirePermission("reports:upload"))
+	uploadRoutes.GET("/:id", uploadHandler.HandleGetUpload)
+	uploadRoutes.GET("/:id/events", uploadHandler.HandleUploadEvents)
+	uploadRoutes.POST("/:id/approve", uploadHandler.HandleApproveUpload, userHandler.LoadUserContextMiddleware, api.RequirePermission("reports:approve"))
+	uploadRoutes.POST("/:id/reject", uploadHandler.HandleRejectUpload, userHandler.LoadUserContextMiddleware, api.RequirePermission("reports:approve"))
+	uploadRoutes.POST("/:id/override-sanity-check", uploadHandler.HandleOverrideSanityCheck, userHandler.LoadUserContextMiddleware, api.RequirePermission("reports:override_sanity_check"))
+	uploadRoutes.POST("/:id/reprocess", uploadHandler.HandleReprocessUpload, userHandler.LoadUserContextMiddleware, api.RequirePermission("reports:upload"))
+	uploadRoutes.POST("/:id/cancel", uploadHandler.HandleCancelUpload, userHandler.LoadUserContextMiddleware, api.RequirePermission("reports:upload"))
+	uploadRoutes.POST("/:id/rollback", uploadHandler.HandleRollbackUpload, userHandler.LoadUserContextMiddleware, api.RequirePermission("reports:rollback"))
+
+	//Report definition group
+	reportDefinitionRoutes := apiGroup.Group("/report-definitions")
+	reportDefinitionRoutes.GET("", reportDefinitionHandler.HandleListReportDefinitions)
+	reportDefinitionRoutes.GET("/:reportType", reportDefinitionHandler.HandleGetReportDefinition)
+	reportDefinitionRoutes.POST("", reportDefinitionHandler.HandlePublishReportDefinition, userHandler.LoadUserContextMiddleware, api.RequirePermission("reports:define"))
 
 	//Chargeback group
 	chargebackRoutes := apiGroup.Group("/chargebacks")
+	chargebackRoutes.Use(userHandler.LoadUserContextMiddleware)
 	chargebackRoutes.GET("", chargebackHandler.HandleGetChargebacks)
 	chargebackRoutes.GET("/:id", chargebackHandler.HandleGetByID)
 	chargebackRoutes.GET("/history/:id", chargebackHandler.HandleChargebackStatus)
 	chargebackRoutes.POST("", chargebackHandler.HandleCreate)
 	chargebackRoutes.PATCH("/:id", chargebackHandler.HandleUpdate)
+	chargebackRoutes.GET("/:id/comments", commentHandler.HandleListChargebackComments)
+	chargebackRoutes.POST("/:id/comments", commentHandler.HandleCreateChargebackComment)
+	chargebackRoutes.PATCH("/:id/comments/:commentId", commentHandler.HandleUpdateChargebackComment)
+	chargebackRoutes.DELETE("/:id/comments/:commentId", commentHandler.HandleDeleteChargebackComment)
 
 	//Delinquency group
 	delinquencyRoutes := apiGroup.Group("/delinquencies")
+	delinquencyRoutes.Use(userHandler.LoadUserContextMiddleware)
 	delinquencyRoutes.GET("", delinquencyHandler.HandleGetDelinquencies)
 	delinquencyRoutes.GET("/:id", delinquencyHandler.HandleGetByID)
 	delinquencyRoutes.GET("/history/:id", delinquencyHandler.HandleDelinquencyStatus)
 	delinquencyRoutes.POST("", delinquencyHandler.HandleCreate)
 	delinquencyRoutes.PATCH("/:id", delinquencyHandler.HandleUpdate)
+	delinquencyRoutes.GET("/:id/comments", commentHandler.HandleListDelinquencyComments)
+	delinquencyRoutes.POST("/:id/comments", commentHandler.HandleCreateDelinquencyComment)
+	delinquencyRoutes.PATCH("/:id/comments/:commentId", commentHandler.HandleUpdateDelinquencyComment)
+	delinquencyRoutes.DELETE("/:id/comments/:commentId", commentHandler.HandleDeleteDelinquencyComment)
+
+	//Vendor group
+	vendorRoutes := apiGroup.Group("/vendors")
+	vendorRoutes.Use(userHandler.LoadUserContextMiddleware)
+	vendorRoutes.GET("", vendorHandler.HandleListVendors)
+	vendorRoutes.GET("/:code", vendorHandler.HandleGetVendor)
 
 	//Dashbord group
-	apiGroup.GET("/dashboard", dashboardHandler.HandleGetDashboardStats)
+	apiGroup.GET("/dashboard", dashboardHandler.HandleGetDashboardStats, userHandler.LoadUserContextMiddleware)
 
 	e.GET("/foo", func(ctx echo.Context) error {
 		// sentryecho handler will catch it just fine. Also, because we attached "someRandomTag"
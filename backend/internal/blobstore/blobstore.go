@@ -0,0 +1,50 @@
+package blobstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"time"
+
+	"github.com/jjckrbbt/cdms/backend/internal/config"
+)
+
+// ErrNotExist is returned by Get, Stat and Delete when no object exists under the key.
+var ErrNotExist = errors.New("blobstore: object does not exist")
+
+// ObjectInfo describes a stored object.
+type ObjectInfo struct {
+	Key         string
+	Size        int64
+	ContentType string
+	Updated     time.Time
+}
+
+// Store is the blob storage used for raw report files. Keys are slash-separated
+// paths such as "raw-reports/BC1048/<uuid>-file.csv".
+type Store interface {
+	Put(ctx context.Context, key string, r io.Reader, contentType string) error
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	Delete(ctx context.Context, key string) error
+	List(ctx context.Context, prefix string) ([]ObjectInfo, error)
+	Stat(ctx context.Context, key string) (ObjectInfo, error)
+}
+
+// New builds the Store selected by cfg.StorageBackend.
+func New(ctx context.Context, cfg *config.Config, logger *slog.Logger) (Store, error) {
+	switch cfg.StorageBackend {
+	case config.StorageBackendGCS:
+		logger.Info("Using GCS blob storage", "bucket", cfg.GCSBucketName)
+		return NewGCSStore(ctx, cfg.GCSBucketName, logger)
+	case config.StorageBackendLocal:
+		logger.Info("Using local directory blob storage", "dir", cfg.LocalStorageDir)
+		return NewLocalStore(cfg.LocalStorageDir)
+	case config.StorageBackendMemory:
+		logger.Warn("Using in-memory blob storage; stored files are lost on restart")
+		return NewMemoryStore(), nil
+	default:
+		return nil, fmt.Errorf("unknown storage backend: %q", cfg.StorageBackend)
+	}
+}
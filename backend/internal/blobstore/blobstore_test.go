@@ -0,0 +1,81 @@
+package blobstore
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStores(t *testing.T) {
+	backends := map[string]func(t *testing.T) Store{
+		"memory": func(t *testing.T) Store { return NewMemoryStore() },
+		"local": func(t *testing.T) Store {
+			store, err := NewLocalStore(t.TempDir())
+			require.NoError(t, err)
+			return store
+		},
+	}
+
+	for name, newStore := range backends {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			store := newStore(t)
+
+			key := "raw-reports/BC1048/abc-report.csv"
+			require.NoError(t, store.Put(ctx, key, strings.NewReader("Fund,Business Line\n"), "text/csv"))
+			require.NoError(t, store.Put(ctx, "raw-reports/BC1300/def-report.csv", strings.NewReader("x"), "text/csv"))
+
+			reader, err := store.Get(ctx, key)
+			require.NoError(t, err)
+			content, err := io.ReadAll(reader)
+			reader.Close()
+			require.NoError(t, err)
+			assert.Equal(t, "Fund,Business Line\n", string(content))
+
+			info, err := store.Stat(ctx, key)
+			require.NoError(t, err)
+			assert.Equal(t, key, info.Key)
+			assert.Equal(t, int64(len(content)), info.Size)
+			assert.Equal(t, "text/csv", strings.Split(info.ContentType, ";")[0])
+
+			objects, err := store.List(ctx, "raw-reports/BC1048/")
+			require.NoError(t, err)
+			require.Len(t, objects, 1)
+			assert.Equal(t, key, objects[0].Key)
+
+			require.NoError(t, store.Delete(ctx, key))
+			_, err = store.Get(ctx, key)
+			assert.True(t, errors.Is(err, ErrNotExist))
+			_, err = store.Stat(ctx, key)
+			assert.True(t, errors.Is(err, ErrNotExist))
+			assert.True(t, errors.Is(store.Delete(ctx, key), ErrNotExist))
+		})
+	}
+}
+
+func TestLocalStoreRejectsEscapingKeys(t *testing.T) {
+	root := t.TempDir()
+	store, err := NewLocalStore(root)
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	for _, key := range []string{"../../outside.csv", "raw-reports/../outside.csv", "/outside.csv", "raw-reports//x.csv", "raw-reports/./x.csv", ""} {
+		assert.Error(t, store.Put(ctx, key, strings.NewReader("x"), "text/csv"), key)
+		_, err := store.Get(ctx, key)
+		assert.Error(t, err, key)
+		assert.False(t, errors.Is(err, ErrNotExist), key)
+	}
+
+	objects, err := store.List(ctx, "")
+	require.NoError(t, err)
+	assert.Empty(t, objects)
+	_, err = os.Stat(filepath.Join(root, "..", "..", "outside.csv"))
+	assert.True(t, errors.Is(err, os.ErrNotExist))
+}
@@ -0,0 +1,108 @@
+package blobstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+
+	"cloud.google.com/go/storage"
+	"google.golang.org/api/iterator"
+	"google.golang.org/api/option"
+)
+
+// GCSStore stores objects in a Google Cloud Storage bucket.
+type GCSStore struct {
+	client *storage.Client
+	bucket string
+}
+
+func NewGCSStore(ctx context.Context, bucket string, logger *slog.Logger) (*GCSStore, error) {
+	var clientOptions []option.ClientOption
+	if keyFilePath := os.Getenv("GOOGLE_APPLICATION_CREDENTIALS"); keyFilePath != "" {
+		clientOptions = append(clientOptions, option.WithCredentialsFile(keyFilePath))
+		logger.Info("Using GOOGLE_APPLICATION_CREDENTIALS for GCS client initialization")
+	} else {
+		logger.Info("GOOGLE_APPLICATION_CREDENTIALS not set, relying on default GCP authentication.")
+	}
+
+	client, err := storage.NewClient(ctx, clientOptions...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create GCS client: %w", err)
+	}
+
+	return &GCSStore{client: client, bucket: bucket}, nil
+}
+
+func (s *GCSStore) Put(ctx context.Context, key string, r io.Reader, contentType string) error {
+	wc := s.client.Bucket(s.bucket).Object(key).NewWriter(ctx)
+	wc.ContentType = contentType
+
+	if _, err := io.Copy(wc, r); err != nil {
+		wc.Close()
+		return fmt.Errorf("failed to write object %s to GCS: %w", key, err)
+	}
+	if err := wc.Close(); err != nil {
+		return fmt.Errorf("failed to close GCS writer for %s: %w", key, err)
+	}
+	return nil
+}
+
+func (s *GCSStore) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	reader, err := s.client.Bucket(s.bucket).Object(key).NewReader(ctx)
+	if err != nil {
+		if errors.Is(err, storage.ErrObjectNotExist) {
+			return nil, ErrNotExist
+		}
+		return nil, fmt.Errorf("failed to read object %s from GCS: %w", key, err)
+	}
+	return reader, nil
+}
+
+func (s *GCSStore) Delete(ctx context.Context, key string) error {
+	if err := s.client.Bucket(s.bucket).Object(key).Delete(ctx); err != nil {
+		if errors.Is(err, storage.ErrObjectNotExist) {
+			return ErrNotExist
+		}
+		return fmt.Errorf("failed to delete object %s from GCS: %w", key, err)
+	}
+	return nil
+}
+
+func (s *GCSStore) List(ctx context.Context, prefix string) ([]ObjectInfo, error) {
+	var objects []ObjectInfo
+	it := s.client.Bucket(s.bucket).Objects(ctx, &storage.Query{Prefix: prefix})
+	for {
+		attrs, err := it.Next()
+		if errors.Is(err, iterator.Done) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to list GCS objects under %s: %w", prefix, err)
+		}
+		objects = append(objects, gcsObjectInfo(attrs))
+	}
+	return objects, nil
+}
+
+func (s *GCSStore) Stat(ctx context.Context, key string) (ObjectInfo, error) {
+	attrs, err := s.client.Bucket(s.bucket).Object(key).Attrs(ctx)
+	if err != nil {
+		if errors.Is(err, storage.ErrObjectNotExist) {
+			return ObjectInfo{}, ErrNotExist
+		}
+		return ObjectInfo{}, fmt.Errorf("failed to stat GCS object %s: %w", key, err)
+	}
+	return gcsObjectInfo(attrs), nil
+}
+
+func gcsObjectInfo(attrs *storage.ObjectAttrs) ObjectInfo {
+	return ObjectInfo{
+		Key:         attrs.Name,
+		Size:        attrs.Size,
+		ContentType: attrs.ContentType,
+		Updated:     attrs.Updated,
+	}
+}
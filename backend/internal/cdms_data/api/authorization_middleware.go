@@ -1,11 +1,37 @@
 package api
 
 import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+
 	"github.com/jjckrbbt/cdms/backend/internal/db"
 	"github.com/labstack/echo/v4"
-	"net/http"
 )
 
+// editRole selects which update query, and therefore which set of fields, a caller may use.
+type editRole string
+
+const (
+	editRoleAdmin editRole = "admin"
+	editRolePFS   editRole = "pfs"
+	editRoleUser  editRole = "user"
+)
+
+// globalDataPermission lets a user read records from every business line.
+const globalDataPermission = "data:view_all_business_lines"
+
+// dataScope is the set of business lines whose records a user may read.
+type dataScope struct {
+	AllBusinessLines bool
+	BusinessLines    []db.ChargebackBusinessLine
+}
+
 func RequirePermission(permission string) echo.MiddlewareFunc {
 	return func(next echo.HandlerFunc) echo.HandlerFunc {
 		return func(c echo.Context) error {
@@ -14,15 +40,8 @@ func RequirePermission(permission string) echo.MiddlewareFunc {
 				return echo.NewHTTPError(http.StatusInternalServerError, "User context not available")
 			}
 
-			permissions, ok := user.Permissions.([]string)
-			if !ok {
-				return echo.NewHTTPError(http.StatusForbidden, "Insufficient permissions (could not read permissions)")
-			}
-
-			for _, p := range permissions {
-				if p == permission {
-					return next(c)
-				}
+			if hasPermission(user.Permissions, permission) {
+				return next(c)
 			}
 
 			return echo.NewHTTPError(http.StatusForbidden, "Insufficient permissions")
@@ -46,3 +65,145 @@ func (h *UserHandler) LoadUserContextMiddleware(next echo.HandlerFunc) echo.Hand
 		return next(c)
 	}
 }
+
+// dataScopeFromContext builds the read scope for the user loaded by LoadUserContextMiddleware.
+func dataScopeFromContext(c echo.Context) (dataScope, error) {
+	user, ok := c.Get("user_context").(db.GetUserWithAuthorizationContextRow)
+	if !ok {
+		return dataScope{}, echo.NewHTTPError(http.StatusInternalServerError, "User context not available")
+	}
+	return newDataScope(user), nil
+}
+
+func newDataScope(user db.GetUserWithAuthorizationContextRow) dataScope {
+	if hasPermission(user.Permissions, globalDataPermission) {
+		return dataScope{AllBusinessLines: true, BusinessLines: []db.ChargebackBusinessLine{}}
+	}
+
+	lines := toStringSlice(user.BusinessLines)
+	scope := dataScope{BusinessLines: make([]db.ChargebackBusinessLine, 0, len(lines))}
+	for _, line := range lines {
+		scope.BusinessLines = append(scope.BusinessLines, db.ChargebackBusinessLine(line))
+	}
+	return scope
+}
+
+// allows reports whether a record in the given business line is visible in this scope.
+func (s dataScope) allows(businessLine db.ChargebackBusinessLine) bool {
+	if s.AllBusinessLines {
+		return true
+	}
+	for _, line := range s.BusinessLines {
+		if line == businessLine {
+			return true
+		}
+	}
+	return false
+}
+
+// resolveEditRole picks the update path for a user holding editPermission.
+// Admins get the full field set, PFS users the PFS field set and everyone else
+// the standard user field set. It returns false if the user cannot edit at all.
+func resolveEditRole(user db.GetUserWithAuthorizationContextRow, editPermission string) (editRole, bool) {
+	if !hasPermission(user.Permissions, editPermission) {
+		return "", false
+	}
+
+	for _, role := range toStringSlice(user.Roles) {
+		if role == "super_admin" || role == "admin" {
+			return editRoleAdmin, true
+		}
+	}
+
+	if user.Org == db.UserOrgPFS {
+		return editRolePFS, true
+	}
+
+	return editRoleUser, true
+}
+
+// bindEditRequest decodes the request body into req, a pointer to one of the
+// role-specific update request structs. Any JSON key that is not a field of req
+// is rejected with a 403 listing the offending fields.
+func bindEditRequest(c echo.Context, req interface{}, role editRole) error {
+	body, err := io.ReadAll(c.Request().Body)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Failed to read request body")
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(body, &fields); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request body "+err.Error())
+	}
+
+	allowed := jsonFieldNames(req)
+	var rejected []string
+	for name := range fields {
+		if !allowed[name] {
+			rejected = append(rejected, name)
+		}
+	}
+	if len(rejected) > 0 {
+		sort.Strings(rejected)
+		return echo.NewHTTPError(http.StatusForbidden, map[string]interface{}{
+			"message":         fmt.Sprintf("Fields not editable with %s access: %s", role, strings.Join(rejected, ", ")),
+			"rejected_fields": rejected,
+		})
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	if err := decoder.Decode(req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request body "+err.Error())
+	}
+	return nil
+}
+
+// jsonFieldNames returns the set of JSON keys accepted by the struct v points to.
+func jsonFieldNames(v interface{}) map[string]bool {
+	t := reflect.TypeOf(v)
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	names := make(map[string]bool, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		tag := strings.Split(t.Field(i).Tag.Get("json"), ",")[0]
+		if tag == "" || tag == "-" {
+			continue
+		}
+		names[tag] = true
+	}
+	return names
+}
+
+// toStringSlice normalises the array columns of GetUserWithAuthorizationContextRow.
+// pgx decodes text arrays as []interface{} and unregistered enum arrays as their
+// text form ("{Hotels,Grocery}"), so a plain []string assertion is not enough.
+func toStringSlice(v interface{}) []string {
+	switch vals := v.(type) {
+	case []string:
+		return vals
+	case []interface{}:
+		out := make([]string, 0, len(vals))
+		for _, item := range vals {
+			if s, ok := item.(string); ok {
+				out = append(out, s)
+			}
+		}
+		return out
+	case string:
+		trimmed := strings.TrimSuffix(strings.TrimPrefix(vals, "{"), "}")
+		if trimmed == "" {
+			return []string{}
+		}
+		parts := strings.Split(trimmed, ",")
+		for i, p := range parts {
+			parts[i] = strings.Trim(p, `"`)
+		}
+		return parts
+	case []byte:
+		return toStringSlice(string(vals))
+	default:
+		return []string{}
+	}
+}
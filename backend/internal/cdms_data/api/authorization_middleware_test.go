@@ -0,0 +1,99 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/jjckrbbt/cdms/backend/internal/db"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResolveEditRole(t *testing.T) {
+	testCases := []struct {
+		name         string
+		user         db.GetUserWithAuthorizationContextRow
+		expectedRole editRole
+		expectedOK   bool
+	}{
+		{
+			name: "Admin role gets the admin path",
+			user: db.GetUserWithAuthorizationContextRow{
+				Org:         db.UserOrgGSA,
+				Roles:       []interface{}{"admin"},
+				Permissions: []interface{}{"chargebacks:edit", "data:view"},
+			},
+			expectedRole: editRoleAdmin,
+			expectedOK:   true,
+		},
+		{
+			name: "PFS org gets the PFS path",
+			user: db.GetUserWithAuthorizationContextRow{
+				Org:         db.UserOrgPFS,
+				Roles:       []interface{}{"analyst"},
+				Permissions: []interface{}{"chargebacks:edit", "data:view"},
+			},
+			expectedRole: editRolePFS,
+			expectedOK:   true,
+		},
+		{
+			name: "GSA analyst gets the user path",
+			user: db.GetUserWithAuthorizationContextRow{
+				Org:         db.UserOrgGSA,
+				Roles:       "{analyst}",
+				Permissions: "{chargebacks:edit,data:view}",
+			},
+			expectedRole: editRoleUser,
+			expectedOK:   true,
+		},
+		{
+			name: "Viewer cannot edit",
+			user: db.GetUserWithAuthorizationContextRow{
+				Org:         db.UserOrgGSA,
+				Roles:       []interface{}{"viewer"},
+				Permissions: []interface{}{"data:view"},
+			},
+			expectedOK: false,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			role, ok := resolveEditRole(tc.user, "chargebacks:edit")
+			assert.Equal(t, tc.expectedOK, ok)
+			if tc.expectedOK {
+				assert.Equal(t, tc.expectedRole, role)
+			}
+		})
+	}
+}
+
+func TestBindEditRequest(t *testing.T) {
+	e := echo.New()
+
+	t.Run("Allowed fields are bound", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPatch, "/", strings.NewReader(`{"current_status":"Passed to PFS","pfs_completion_date":"2025-07-01"}`))
+		c := e.NewContext(req, httptest.NewRecorder())
+
+		var body PFSUpdateChargebackRequest
+		err := bindEditRequest(c, &body, editRolePFS)
+
+		assert.NoError(t, err)
+		assert.Equal(t, "Passed to PFS", *body.CurrentStatus)
+		assert.Equal(t, "2025-07-01", *body.PFSCompletionDate)
+	})
+
+	t.Run("Fields outside the role's set are rejected", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPatch, "/", strings.NewReader(`{"current_status":"Open","pfs_completion_date":"2025-07-01","alc_to_rebill":"12345678"}`))
+		c := e.NewContext(req, httptest.NewRecorder())
+
+		var body UserUpdateChargebackRequest
+		httpErr := bindEditRequest(c, &body, editRoleUser).(*echo.HTTPError)
+
+		assert.Equal(t, http.StatusForbidden, httpErr.Code)
+		message := httpErr.Message.(map[string]interface{})
+		assert.Equal(t, []string{"pfs_completion_date"}, message["rejected_fields"])
+	})
+}
@@ -149,6 +149,11 @@ func (h *ChargebackHandler) HandleCreate(c echo.Context) error {
 func (h *ChargebackHandler) HandleGetChargebacks(c echo.Context) error {
 	ctx := c.Request().Context()
 
+	scope, err := dataScopeFromContext(c)
+	if err != nil {
+		return err
+	}
+
 	bdDocNum := c.QueryParam("bd_doc_num")
 	alNumStr := c.QueryParam("al_num")
 
@@ -173,6 +178,9 @@ func (h *ChargebackHandler) HandleGetChargebacks(c echo.Context) error {
 			h.logger.ErrorContext(ctx, "Failed to get chargeback by business key", "error", err)
 			return echo.NewHTTPError(http.StatusInternalServerError, "Failed to retrieve chargeback")
 		}
+		if !scope.allows(chargeback.BusinessLine) {
+			return echo.NewHTTPError(http.StatusNotFound, "Chargeback not found for the given business key")
+		}
 
 		return c.JSON(http.StatusOK, chargeback)
 	}
@@ -190,8 +198,10 @@ func (h *ChargebackHandler) HandleGetChargebacks(c echo.Context) error {
 	offset := (page - 1) * limit
 
 	listParams := db.ListActiveChargebacksParams{
-		Limit:  int32(limit),
-		Offset: int32(offset),
+		AllBusinessLines: scope.AllBusinessLines,
+		BusinessLines:    scope.BusinessLines,
+		PageLimit:        int32(limit),
+		PageOffset:       int32(offset),
 	}
 
 	chargebacks, err := h.queries.ListActiveChargebacks(ctx, listParams)
@@ -214,6 +224,11 @@ func (h *ChargebackHandler) HandleGetChargebacks(c echo.Context) error {
 }
 
 func (h *ChargebackHandler) HandleGetByID(c echo.Context) error {
+	scope, err := dataScopeFromContext(c)
+	if err != nil {
+		return err
+	}
+
 	idParam := c.Param("id")
 	id, err := strconv.ParseInt(idParam, 10, 64)
 	if err != nil {
@@ -228,6 +243,9 @@ func (h *ChargebackHandler) HandleGetByID(c echo.Context) error {
 		h.logger.ErrorContext(c.Request().Context(), "Failed to get chargeback by ID", "error", err, "id", idParam)
 		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to retrieve chargeback")
 	}
+	if !scope.allows(chargeback.BusinessLine) {
+		return echo.NewHTTPError(http.StatusNotFound, "Chargeback not found")
+	}
 
 	return c.JSON(http.StatusOK, chargeback)
 }
@@ -240,9 +258,14 @@ func (h *ChargebackHandler) HandleUpdate(c echo.Context) error {
 		return echo.NewHTTPError(http.StatusBadRequest, "Invalid ID format")
 	}
 
-	userRole := c.Request().Header.Get("X-User-Role")
-	if userRole == "" {
-		userRole = "user"
+	userContext, ok := c.Get("user_context").(db.GetUserWithAuthorizationContextRow)
+	if !ok {
+		return echo.NewHTTPError(http.StatusInternalServerError, "User context not available")
+	}
+
+	userRole, ok := resolveEditRole(userContext, "chargebacks:edit")
+	if !ok {
+		return echo.NewHTTPError(http.StatusForbidden, "Insufficient permissions to edit chargebacks")
 	}
 
 	existing, err := h.queries.GetChargebackForUpdate(ctx, id)
@@ -252,32 +275,33 @@ func (h *ChargebackHandler) HandleUpdate(c echo.Context) error {
 		}
 		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to retrieve chargeback for update")
 	}
+	if !newDataScope(userContext).allows(existing.BusinessLine) {
+		return echo.NewHTTPError(http.StatusNotFound, "Chargeback not found")
+	}
 
 	var updatedChargeback db.Chargeback
 	var updateErr error
 
 	switch userRole {
-	case "admin":
+	case editRoleAdmin:
 		var req AdminUpdateChargebackRequest
-		if err := c.Bind(&req); err != nil {
+		if err := bindEditRequest(c, &req, userRole); err != nil {
 			return err
 		}
 		params := buildAdminUpdateParams(&req, &existing)
 		updatedChargeback, updateErr = h.queries.AdminUpdateChargeback(ctx, params)
 
-	case "pfs":
+	case editRolePFS:
 		var req PFSUpdateChargebackRequest
-		if err := c.Bind(&req); err != nil {
+		if err := bindEditRequest(c, &req, userRole); err != nil {
 			return err
 		}
 		params := buildPFSUpdateParams(&req, &existing)
 		updatedChargeback, updateErr = h.queries.PFSUpdateChargeback(ctx, params)
 
-	case "user":
-		fallthrough
 	default:
 		var req UserUpdateChargebackRequest
-		if err := c.Bind(&req); err != nil {
+		if err := bindEditRequest(c, &req, userRole); err != nil {
 			return err
 		}
 		params := buildUserUpdateParams(&req, &existing)
@@ -302,6 +326,22 @@ func (h *ChargebackHandler) HandleChargebackStatus(c echo.Context) error {
 		return echo.NewHTTPError(http.StatusBadRequest, "Invalid ID format")
 	}
 
+	scope, err := dataScopeFromContext(c)
+	if err != nil {
+		return err
+	}
+	chargeback, err := h.queries.GetChargebackForUpdate(ctx, id)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return echo.NewHTTPError(http.StatusNotFound, "Chargeback not found")
+		}
+		h.logger.ErrorContext(ctx, "Failed to get chargeback for status history", "error", err, "chargeback_id", id)
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to retrieve status history")
+	}
+	if !scope.allows(chargeback.BusinessLine) {
+		return echo.NewHTTPError(http.StatusNotFound, "Chargeback not found")
+	}
+
 	statusHistory, err := h.queries.GetStatusHistoryForChargeback(ctx, id)
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
@@ -0,0 +1,405 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jjckrbbt/cdms/backend/internal/db"
+	"github.com/labstack/echo/v4"
+)
+
+// mentionRegex matches "@user@agency.gov" or "@first.last" handles. The leading group
+// keeps plain email addresses in the comment body from being read as mentions.
+var mentionRegex = regexp.MustCompile(`(?:^|[^A-Za-z0-9._%+\-@])@([A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}|[A-Za-z][A-Za-z'\-]*\.[A-Za-z][A-Za-z'\-]*)`)
+
+type CommentRequest struct {
+	Comment string `json:"comment"`
+}
+
+type CommentResponse struct {
+	db.Comment
+	Mentions []db.GetUsersForMentionsRow `json:"mentions"`
+}
+
+type CommentHandler struct {
+	queries db.Querier
+	logger  *slog.Logger
+}
+
+func NewCommentHandler(q db.Querier, logger *slog.Logger) *CommentHandler {
+	return &CommentHandler{
+		queries: q,
+		logger:  logger.With("component", "comment_handler"),
+	}
+}
+
+// parseMentions extracts the @mention handles from a comment, split into email
+// handles and first.last handles. Both are lowercased and de-duplicated.
+func parseMentions(text string) (emails []string, names []string) {
+	emails = []string{}
+	names = []string{}
+	seen := make(map[string]bool)
+
+	for _, match := range mentionRegex.FindAllStringSubmatch(text, -1) {
+		handle := strings.ToLower(match[1])
+		if seen[handle] {
+			continue
+		}
+		seen[handle] = true
+
+		if strings.Contains(handle, "@") {
+			emails = append(emails, handle)
+		} else {
+			names = append(names, handle)
+		}
+	}
+	return emails, names
+}
+
+func (h *CommentHandler) HandleListChargebackComments(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid ID format")
+	}
+
+	if err := h.checkChargeback(c, id); err != nil {
+		return err
+	}
+
+	comments, err := h.queries.ListChargebackComments(ctx, id)
+	if err != nil {
+		h.logger.ErrorContext(ctx, "Failed to list chargeback comments", "error", err, "chargeback_id", id)
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to retrieve comments")
+	}
+	if comments == nil {
+		comments = []db.ListChargebackCommentsRow{}
+	}
+
+	return c.JSON(http.StatusOK, comments)
+}
+
+func (h *CommentHandler) HandleListDelinquencyComments(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid ID format")
+	}
+
+	if err := h.checkDelinquency(c, id); err != nil {
+		return err
+	}
+
+	comments, err := h.queries.ListDelinquencyComments(ctx, id)
+	if err != nil {
+		h.logger.ErrorContext(ctx, "Failed to list delinquency comments", "error", err, "nonipac_id", id)
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to retrieve comments")
+	}
+	if comments == nil {
+		comments = []db.ListDelinquencyCommentsRow{}
+	}
+
+	return c.JSON(http.StatusOK, comments)
+}
+
+func (h *CommentHandler) HandleCreateChargebackComment(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	user, ok := c.Get("user").(db.CdmsUser)
+	if !ok {
+		return echo.NewHTTPError(http.StatusInternalServerError, "User not found in context")
+	}
+
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid ID format")
+	}
+
+	text, err := bindCommentText(c)
+	if err != nil {
+		return err
+	}
+
+	if err := h.checkChargeback(c, id); err != nil {
+		return err
+	}
+
+	mentions, err := h.resolveMentions(ctx, text)
+	if err != nil {
+		return err
+	}
+
+	comment, err := h.queries.CreateChargebackComment(ctx, db.CreateChargebackCommentParams{
+		UserID:           user.ID,
+		Comment:          text,
+		ChargebackID:     id,
+		MentionedUserIds: mentionedUserIDs(mentions),
+	})
+	if err != nil {
+		h.logger.ErrorContext(ctx, "Failed to create chargeback comment", "error", err, "chargeback_id", id)
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to create comment")
+	}
+
+	return c.JSON(http.StatusCreated, CommentResponse{Comment: comment, Mentions: mentions})
+}
+
+func (h *CommentHandler) HandleCreateDelinquencyComment(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	user, ok := c.Get("user").(db.CdmsUser)
+	if !ok {
+		return echo.NewHTTPError(http.StatusInternalServerError, "User not found in context")
+	}
+
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid ID format")
+	}
+
+	text, err := bindCommentText(c)
+	if err != nil {
+		return err
+	}
+
+	if err := h.checkDelinquency(c, id); err != nil {
+		return err
+	}
+
+	mentions, err := h.resolveMentions(ctx, text)
+	if err != nil {
+		return err
+	}
+
+	comment, err := h.queries.CreateDelinquencyComment(ctx, db.CreateDelinquencyCommentParams{
+		UserID:           user.ID,
+		Comment:          text,
+		NonipacID:        id,
+		MentionedUserIds: mentionedUserIDs(mentions),
+	})
+	if err != nil {
+		h.logger.ErrorContext(ctx, "Failed to create delinquency comment", "error", err, "nonipac_id", id)
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to create comment")
+	}
+
+	return c.JSON(http.StatusCreated, CommentResponse{Comment: comment, Mentions: mentions})
+}
+
+func (h *CommentHandler) HandleUpdateChargebackComment(c echo.Context) error {
+	return h.handleUpdate(c, h.checkChargeback, h.getChargebackComment)
+}
+
+func (h *CommentHandler) HandleUpdateDelinquencyComment(c echo.Context) error {
+	return h.handleUpdate(c, h.checkDelinquency, h.getDelinquencyComment)
+}
+
+func (h *CommentHandler) HandleDeleteChargebackComment(c echo.Context) error {
+	return h.handleDelete(c, h.checkChargeback, h.getChargebackComment)
+}
+
+func (h *CommentHandler) HandleDeleteDelinquencyComment(c echo.Context) error {
+	return h.handleDelete(c, h.checkDelinquency, h.getDelinquencyComment)
+}
+
+// parentCheck makes sure the record a comment hangs off exists and is visible to the caller.
+type parentCheck func(c echo.Context, parentID int64) error
+
+// commentLookup fetches a comment scoped to the record it is attached to.
+type commentLookup func(ctx context.Context, parentID, commentID int64) (db.Comment, error)
+
+func (h *CommentHandler) checkChargeback(c echo.Context, id int64) error {
+	ctx := c.Request().Context()
+
+	scope, err := dataScopeFromContext(c)
+	if err != nil {
+		return err
+	}
+
+	chargeback, err := h.queries.GetChargebackForUpdate(ctx, id)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return echo.NewHTTPError(http.StatusNotFound, "Chargeback not found")
+		}
+		h.logger.ErrorContext(ctx, "Failed to get chargeback for comment", "error", err, "chargeback_id", id)
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to retrieve chargeback")
+	}
+	if !scope.allows(chargeback.BusinessLine) {
+		return echo.NewHTTPError(http.StatusNotFound, "Chargeback not found")
+	}
+	return nil
+}
+
+func (h *CommentHandler) checkDelinquency(c echo.Context, id int64) error {
+	ctx := c.Request().Context()
+
+	scope, err := dataScopeFromContext(c)
+	if err != nil {
+		return err
+	}
+
+	delinquency, err := h.queries.GetDelinquencyForUpdate(ctx, id)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return echo.NewHTTPError(http.StatusNotFound, "Delinquency not found")
+		}
+		h.logger.ErrorContext(ctx, "Failed to get delinquency for comment", "error", err, "nonipac_id", id)
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to retrieve delinquency")
+	}
+	if !scope.allows(delinquency.BusinessLine) {
+		return echo.NewHTTPError(http.StatusNotFound, "Delinquency not found")
+	}
+	return nil
+}
+
+func (h *CommentHandler) getChargebackComment(ctx context.Context, parentID, commentID int64) (db.Comment, error) {
+	return h.queries.GetChargebackComment(ctx, db.GetChargebackCommentParams{ChargebackID: parentID, ID: commentID})
+}
+
+func (h *CommentHandler) getDelinquencyComment(ctx context.Context, parentID, commentID int64) (db.Comment, error) {
+	return h.queries.GetDelinquencyComment(ctx, db.GetDelinquencyCommentParams{NonipacID: parentID, ID: commentID})
+}
+
+func (h *CommentHandler) handleUpdate(c echo.Context, check parentCheck, lookup commentLookup) error {
+	ctx := c.Request().Context()
+
+	existing, user, err := h.loadOwnComment(c, check, lookup)
+	if err != nil {
+		return err
+	}
+
+	text, err := bindCommentText(c)
+	if err != nil {
+		return err
+	}
+
+	mentions, err := h.resolveMentions(ctx, text)
+	if err != nil {
+		return err
+	}
+
+	comment, err := h.queries.UpdateCommentByAuthor(ctx, db.UpdateCommentByAuthorParams{
+		UserID:           user.ID,
+		Comment:          text,
+		ID:               existing.ID,
+		MentionedUserIds: mentionedUserIDs(mentions),
+	})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return echo.NewHTTPError(http.StatusNotFound, "Comment not found")
+		}
+		h.logger.ErrorContext(ctx, "Failed to update comment", "error", err, "comment_id", existing.ID)
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to update comment")
+	}
+
+	return c.JSON(http.StatusOK, CommentResponse{Comment: comment, Mentions: mentions})
+}
+
+func (h *CommentHandler) handleDelete(c echo.Context, check parentCheck, lookup commentLookup) error {
+	ctx := c.Request().Context()
+
+	existing, user, err := h.loadOwnComment(c, check, lookup)
+	if err != nil {
+		return err
+	}
+
+	deleted, err := h.queries.DeleteCommentByAuthor(ctx, db.DeleteCommentByAuthorParams{
+		UserID: user.ID,
+		ID:     existing.ID,
+	})
+	if err != nil {
+		h.logger.ErrorContext(ctx, "Failed to delete comment", "error", err, "comment_id", existing.ID)
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to delete comment")
+	}
+	if deleted == 0 {
+		return echo.NewHTTPError(http.StatusNotFound, "Comment not found")
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// loadOwnComment resolves the :id and :commentId path params and makes sure the
+// comment exists on that record and was written by the current user.
+func (h *CommentHandler) loadOwnComment(c echo.Context, check parentCheck, lookup commentLookup) (db.Comment, db.CdmsUser, error) {
+	ctx := c.Request().Context()
+
+	user, ok := c.Get("user").(db.CdmsUser)
+	if !ok {
+		return db.Comment{}, db.CdmsUser{}, echo.NewHTTPError(http.StatusInternalServerError, "User not found in context")
+	}
+
+	parentID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return db.Comment{}, user, echo.NewHTTPError(http.StatusBadRequest, "Invalid ID format")
+	}
+	commentID, err := strconv.ParseInt(c.Param("commentId"), 10, 64)
+	if err != nil {
+		return db.Comment{}, user, echo.NewHTTPError(http.StatusBadRequest, "Invalid comment ID format")
+	}
+
+	if err := check(c, parentID); err != nil {
+		return db.Comment{}, user, err
+	}
+
+	existing, err := lookup(ctx, parentID, commentID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return db.Comment{}, user, echo.NewHTTPError(http.StatusNotFound, "Comment not found")
+		}
+		h.logger.ErrorContext(ctx, "Failed to get comment", "error", err, "comment_id", commentID)
+		return db.Comment{}, user, echo.NewHTTPError(http.StatusInternalServerError, "Failed to retrieve comment")
+	}
+
+	if existing.UserID != user.ID {
+		return db.Comment{}, user, echo.NewHTTPError(http.StatusForbidden, "Only the author can modify this comment")
+	}
+
+	return existing, user, nil
+}
+
+// resolveMentions resolves the @mentions in a comment's text against cdms_user. The
+// users found are saved with the comment by the same statement that writes it.
+func (h *CommentHandler) resolveMentions(ctx context.Context, text string) ([]db.GetUsersForMentionsRow, error) {
+	emails, names := parseMentions(text)
+
+	mentioned := []db.GetUsersForMentionsRow{}
+	if len(emails) > 0 || len(names) > 0 {
+		users, err := h.queries.GetUsersForMentions(ctx, db.GetUsersForMentionsParams{
+			Emails: emails,
+			Names:  names,
+		})
+		if err != nil {
+			h.logger.ErrorContext(ctx, "Failed to resolve comment mentions", "error", err)
+			return nil, echo.NewHTTPError(http.StatusInternalServerError, "Failed to resolve mentions")
+		}
+		mentioned = append(mentioned, users...)
+	}
+	return mentioned, nil
+}
+
+// mentionedUserIDs lists the IDs of the users a comment mentions.
+func mentionedUserIDs(mentioned []db.GetUsersForMentionsRow) []int64 {
+	userIDs := make([]int64, 0, len(mentioned))
+	for _, u := range mentioned {
+		userIDs = append(userIDs, u.ID)
+	}
+	return userIDs
+}
+
+func bindCommentText(c echo.Context) (string, error) {
+	var req CommentRequest
+	if err := c.Bind(&req); err != nil {
+		return "", echo.NewHTTPError(http.StatusBadRequest, "Invalid request body "+err.Error())
+	}
+	text := strings.TrimSpace(req.Comment)
+	if text == "" {
+		return "", echo.NewHTTPError(http.StatusBadRequest, "Comment text is required")
+	}
+	return text, nil
+}
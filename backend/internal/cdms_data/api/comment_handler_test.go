@@ -0,0 +1,119 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/jjckrbbt/cdms/backend/internal/db"
+	"github.com/jjckrbbt/cdms/backend/internal/logger"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func (m *MockQuerier) GetChargebackForUpdate(ctx context.Context, id int64) (db.Chargeback, error) {
+	args := m.Called(ctx, id)
+	return args.Get(0).(db.Chargeback), args.Error(1)
+}
+
+func (m *MockQuerier) GetUsersForMentions(ctx context.Context, params db.GetUsersForMentionsParams) ([]db.GetUsersForMentionsRow, error) {
+	args := m.Called(ctx, params)
+	return args.Get(0).([]db.GetUsersForMentionsRow), args.Error(1)
+}
+
+func (m *MockQuerier) CreateChargebackComment(ctx context.Context, params db.CreateChargebackCommentParams) (db.Comment, error) {
+	args := m.Called(ctx, params)
+	return args.Get(0).(db.Comment), args.Error(1)
+}
+
+func TestParseMentions(t *testing.T) {
+	testCases := []struct {
+		name           string
+		text           string
+		expectedEmails []string
+		expectedNames  []string
+	}{
+		{
+			name:           "No mentions",
+			text:           "Sent to PFS for rebill.",
+			expectedEmails: []string{},
+			expectedNames:  []string{},
+		},
+		{
+			name:           "Email mention",
+			text:           "@Jane.Doe@gsa.gov please review",
+			expectedEmails: []string{"jane.doe@gsa.gov"},
+			expectedNames:  []string{},
+		},
+		{
+			name:           "First.last mention with trailing punctuation",
+			text:           "Looping in @john.smith, and @Mary.O'Neil.",
+			expectedEmails: []string{},
+			expectedNames:  []string{"john.smith", "mary.o'neil"},
+		},
+		{
+			name:           "Duplicate mentions are collapsed",
+			text:           "@john.smith and again @John.Smith",
+			expectedEmails: []string{},
+			expectedNames:  []string{"john.smith"},
+		},
+		{
+			name:           "Plain email address is not a mention",
+			text:           "Customer contact is billing@agency.gov",
+			expectedEmails: []string{},
+			expectedNames:  []string{},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			emails, names := parseMentions(tc.text)
+			assert.Equal(t, tc.expectedEmails, emails)
+			assert.Equal(t, tc.expectedNames, names)
+		})
+	}
+}
+
+func TestHandleCreateChargebackComment(t *testing.T) {
+	e := echo.New()
+	logger.InitLogger("development")
+	appLogger := logger.L()
+
+	t.Run("mentions are saved by the statement that creates the comment", func(t *testing.T) {
+		mockQ := new(MockQuerier)
+		text := "@john.smith please review"
+		mockQ.On("GetChargebackForUpdate", mock.Anything, int64(42)).Return(db.Chargeback{ID: 42}, nil).Once()
+		mockQ.On("GetUsersForMentions", mock.Anything, db.GetUsersForMentionsParams{Emails: []string{}, Names: []string{"john.smith"}}).
+			Return([]db.GetUsersForMentionsRow{{ID: 5, Email: "john.smith@gsa.gov"}}, nil).
+			Once()
+		// Any separate write for the mentions falls through to the nil Querier and panics.
+		mockQ.On("CreateChargebackComment", mock.Anything, db.CreateChargebackCommentParams{
+			UserID:           7,
+			Comment:          text,
+			ChargebackID:     42,
+			MentionedUserIds: []int64{5},
+		}).
+			Return(db.Comment{ID: 9, Comment: text, UserID: 7}, nil).
+			Once()
+
+		handler := NewCommentHandler(mockQ, appLogger)
+		req := httptest.NewRequest(http.MethodPost, "/api/chargebacks/42/comments", strings.NewReader(`{"comment":"`+text+`"}`))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.SetParamNames("id")
+		c.SetParamValues("42")
+		c.Set("user", db.CdmsUser{ID: 7})
+		c.Set("user_context", globalUserContext)
+
+		err := handler.HandleCreateChargebackComment(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusCreated, rec.Code)
+		assert.Contains(t, rec.Body.String(), `"email":"john.smith@gsa.gov"`)
+		mockQ.AssertExpectations(t)
+	})
+}
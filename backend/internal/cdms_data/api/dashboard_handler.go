@@ -42,7 +42,15 @@ type DashboardStats struct {
 func (h *DashboardHandler) HandleGetDashboardStats(c echo.Context) error {
 	ctx := c.Request().Context()
 
-	chargebackStatusSummary, err := h.queries.GetChargebackStatusSummary(ctx)
+	scope, err := dataScopeFromContext(c)
+	if err != nil {
+		return err
+	}
+
+	chargebackStatusSummary, err := h.queries.GetChargebackStatusSummary(ctx, db.GetChargebackStatusSummaryParams{
+		AllBusinessLines: scope.AllBusinessLines,
+		BusinessLines:    scope.BusinessLines,
+	})
 	if err != nil {
 		h.logger.ErrorContext(ctx, "Failed to get chargeback status summary for dashboard", "error", err)
 		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to retrieve chargeback status summary")
@@ -61,13 +69,23 @@ func (h *DashboardHandler) HandleGetDashboardStats(c echo.Context) error {
 		pgStartDate := pgtype.Date{Time: startDate, Valid: true}
 		pgEndDate := pgtype.Date{Time: endDate, Valid: true}
 
-		newStats, err := h.queries.GetNewChargebackStatsForWindow(ctx, db.GetNewChargebackStatsForWindowParams{CreatedAt: pgStartTimestamp, CreatedAt_2: pgEndTimestamp})
+		newStats, err := h.queries.GetNewChargebackStatsForWindow(ctx, db.GetNewChargebackStatsForWindowParams{
+			WindowStart:      pgStartTimestamp,
+			WindowEnd:        pgEndTimestamp,
+			AllBusinessLines: scope.AllBusinessLines,
+			BusinessLines:    scope.BusinessLines,
+		})
 		if err != nil {
 			h.logger.ErrorContext(ctx, "Failed to get new chargebacks stats for dashboard", "error", err)
 			return echo.NewHTTPError(http.StatusInternalServerError, "Failed to retrieve new chargebacks stats")
 		}
 
-		avgDaysToPFSStr, err := h.queries.GetAverageDaysToPFSForWindow(ctx, db.GetAverageDaysToPFSForWindowParams{PassedToPfsDate: pgStartDate, PassedToPfsDate_2: pgEndDate})
+		avgDaysToPFSStr, err := h.queries.GetAverageDaysToPFSForWindow(ctx, db.GetAverageDaysToPFSForWindowParams{
+			WindowStart:      pgStartDate,
+			WindowEnd:        pgEndDate,
+			AllBusinessLines: scope.AllBusinessLines,
+			BusinessLines:    scope.BusinessLines,
+		})
 		if err != nil {
 			h.logger.ErrorContext(ctx, "Failed to get average days to PFS for dashboard", "error", err)
 			return echo.NewHTTPError(http.StatusInternalServerError, "Failed to retrieve average days to PFS")
@@ -78,7 +96,12 @@ func (h *DashboardHandler) HandleGetDashboardStats(c echo.Context) error {
 			avgDaysToPFSFloat = 0.0 // Default to 0 on parse error
 		}
 
-		avgDaysForPFSCompleteStr, err := h.queries.GetAverageDaysForPFSCompletionForWindow(ctx, db.GetAverageDaysForPFSCompletionForWindowParams{PfsCompletionDate: pgStartDate, PfsCompletionDate_2: pgEndDate})
+		avgDaysForPFSCompleteStr, err := h.queries.GetAverageDaysForPFSCompletionForWindow(ctx, db.GetAverageDaysForPFSCompletionForWindowParams{
+			WindowStart:      pgStartDate,
+			WindowEnd:        pgEndDate,
+			AllBusinessLines: scope.AllBusinessLines,
+			BusinessLines:    scope.BusinessLines,
+		})
 		if err != nil {
 			h.logger.ErrorContext(ctx, "Failed to get average days for PFS completion for dashboard", "error", err)
 			return echo.NewHTTPError(http.StatusInternalServerError, "Failed to retrieve average days for PFS completion")
@@ -89,7 +112,12 @@ func (h *DashboardHandler) HandleGetDashboardStats(c echo.Context) error {
 			avgDaysForPFSCompleteFloat = 0.0
 		}
 
-		pfsCounts, err := h.queries.GetPFSCountsForWindow(ctx, db.GetPFSCountsForWindowParams{PassedToPfsDate: pgStartDate, PassedToPfsDate_2: pgEndDate})
+		pfsCounts, err := h.queries.GetPFSCountsForWindow(ctx, db.GetPFSCountsForWindowParams{
+			WindowStart:      pgStartDate,
+			WindowEnd:        pgEndDate,
+			AllBusinessLines: scope.AllBusinessLines,
+			BusinessLines:    scope.BusinessLines,
+		})
 		if err != nil {
 			h.logger.ErrorContext(ctx, "Failed to get PFS status counts for dashboard", "error", err)
 			return echo.NewHTTPError(http.StatusInternalServerError, "Failed to retrieve PFS status counts")
@@ -107,13 +135,19 @@ func (h *DashboardHandler) HandleGetDashboardStats(c echo.Context) error {
 		}
 	}
 
-	nonipacStatusSummary, err := h.queries.GetNonipacStatusSummary(ctx)
+	nonipacStatusSummary, err := h.queries.GetNonipacStatusSummary(ctx, db.GetNonipacStatusSummaryParams{
+		AllBusinessLines: scope.AllBusinessLines,
+		BusinessLines:    scope.BusinessLines,
+	})
 	if err != nil {
 		h.logger.ErrorContext(ctx, "Failed to get non-ipac status summary for dashboard", "error", err)
 		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to retrieve non-ipac status summary")
 	}
 
-	nonipacAgingSchedule, err := h.queries.GetNonipacAgingScheduleByBusinessLine(ctx)
+	nonipacAgingSchedule, err := h.queries.GetNonipacAgingScheduleByBusinessLine(ctx, db.GetNonipacAgingScheduleByBusinessLineParams{
+		AllBusinessLines: scope.AllBusinessLines,
+		BusinessLines:    scope.BusinessLines,
+	})
 	if err != nil {
 		h.logger.ErrorContext(ctx, "Failed to get non-ipac aging schedule for dashboard", "error", err)
 		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to retrieve non-ipac aging schedule")
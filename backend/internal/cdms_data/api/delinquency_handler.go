@@ -120,6 +120,11 @@ func (h *DelinquencyHandler) HandleCreate(c echo.Context) error {
 func (h *DelinquencyHandler) HandleGetDelinquencies(c echo.Context) error {
 	ctx := c.Request().Context()
 
+	scope, err := dataScopeFromContext(c)
+	if err != nil {
+		return err
+	}
+
 	documentNumber := c.QueryParam("documentNumber")
 
 	if documentNumber != "" {
@@ -133,6 +138,9 @@ func (h *DelinquencyHandler) HandleGetDelinquencies(c echo.Context) error {
 			h.logger.ErrorContext(ctx, "Failedto get delinuquency by business key", "error", err)
 			return echo.NewHTTPError(http.StatusInternalServerError, "Failed to retrieve delinquency")
 		}
+		if !scope.allows(delinquency.BusinessLine) {
+			return echo.NewHTTPError(http.StatusNotFound, "Delinquency not found for given business key")
+		}
 
 		return c.JSON(http.StatusOK, delinquency)
 	}
@@ -150,8 +158,10 @@ func (h *DelinquencyHandler) HandleGetDelinquencies(c echo.Context) error {
 	offset := (page - 1) * limit
 
 	params := db.ListActiveDelinquenciesParams{
-		Limit:  int32(limit),
-		Offset: int32(offset),
+		AllBusinessLines: scope.AllBusinessLines,
+		BusinessLines:    scope.BusinessLines,
+		PageLimit:        int32(limit),
+		PageOffset:       int32(offset),
 	}
 
 	delinquencies, err := h.queries.ListActiveDelinquencies(c.Request().Context(), params)
@@ -172,6 +182,11 @@ func (h *DelinquencyHandler) HandleGetDelinquencies(c echo.Context) error {
 }
 
 func (h *DelinquencyHandler) HandleGetByID(c echo.Context) error {
+	scope, err := dataScopeFromContext(c)
+	if err != nil {
+		return err
+	}
+
 	idParam := c.Param("id")
 	id, err := strconv.ParseInt(idParam, 10, 64)
 	if err != nil {
@@ -186,6 +201,9 @@ func (h *DelinquencyHandler) HandleGetByID(c echo.Context) error {
 		h.logger.ErrorContext(c.Request().Context(), "Failed to get delinquency by ID", "error", err, "id", idParam)
 		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to retrieve delinquency")
 	}
+	if !scope.allows(delinquency.BusinessLine) {
+		return echo.NewHTTPError(http.StatusNotFound, "Delinquency not found")
+	}
 
 	return c.JSON(http.StatusOK, delinquency)
 }
@@ -198,9 +216,14 @@ func (h *DelinquencyHandler) HandleUpdate(c echo.Context) error {
 		return echo.NewHTTPError(http.StatusBadRequest, "Invalid ID format")
 	}
 
-	userRole := c.Request().Header.Get("X-User-Role")
-	if userRole == "" {
-		userRole = "user" // Default to least privileged
+	userContext, ok := c.Get("user_context").(db.GetUserWithAuthorizationContextRow)
+	if !ok {
+		return echo.NewHTTPError(http.StatusInternalServerError, "User context not available")
+	}
+
+	userRole, ok := resolveEditRole(userContext, "delinquencies:edit")
+	if !ok {
+		return echo.NewHTTPError(http.StatusForbidden, "Insufficient permissions to edit delinquencies")
 	}
 
 	existing, err := h.queries.GetDelinquencyForUpdate(ctx, id)
@@ -210,14 +233,17 @@ func (h *DelinquencyHandler) HandleUpdate(c echo.Context) error {
 		}
 		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to retrieve delinquency for update")
 	}
+	if !newDataScope(userContext).allows(existing.BusinessLine) {
+		return echo.NewHTTPError(http.StatusNotFound, "Delinquency not found")
+	}
 
 	var updatedDelinquency db.Nonipac
 	var updateErr error
 
 	switch userRole {
-	case "admin":
+	case editRoleAdmin:
 		var req AdminUpdateDelinquencyRequest
-		if err := c.Bind(&req); err != nil {
+		if err := bindEditRequest(c, &req, userRole); err != nil {
 			return err
 		}
 
@@ -230,9 +256,9 @@ func (h *DelinquencyHandler) HandleUpdate(c echo.Context) error {
 		}
 		updatedDelinquency, updateErr = h.queries.AdminUpdateDelinquency(ctx, params)
 
-	case "pfs":
+	case editRolePFS:
 		var req PFSUpdateDelinquencyRequest
-		if err := c.Bind(&req); err != nil {
+		if err := bindEditRequest(c, &req, userRole); err != nil {
 			return err
 		}
 
@@ -245,11 +271,9 @@ func (h *DelinquencyHandler) HandleUpdate(c echo.Context) error {
 		}
 		updatedDelinquency, updateErr = h.queries.PFSUpdateDelinquency(ctx, params)
 
-	case "user":
-		fallthrough
 	default:
 		var req UserUpdateDelinquencyRequest
-		if err := c.Bind(&req); err != nil {
+		if err := bindEditRequest(c, &req, userRole); err != nil {
 			return err
 		}
 
@@ -281,6 +305,22 @@ func (h *DelinquencyHandler) HandleDelinquencyStatus(c echo.Context) error {
 		return echo.NewHTTPError(http.StatusBadRequest, "Invalid ID format")
 	}
 
+	scope, err := dataScopeFromContext(c)
+	if err != nil {
+		return err
+	}
+	delinquency, err := h.queries.GetDelinquencyForUpdate(ctx, id)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return echo.NewHTTPError(http.StatusNotFound, "Delinquency not found")
+		}
+		h.logger.ErrorContext(ctx, "Failed to get delinquency for status history", "error", err, "delinquency_id", id)
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to retrieve status history")
+	}
+	if !scope.allows(delinquency.BusinessLine) {
+		return echo.NewHTTPError(http.StatusNotFound, "Delinquency not found")
+	}
+
 	statusHistory, err := h.queries.GetStatusHistoryForDelinquencies(ctx, id)
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
@@ -0,0 +1,112 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/jjckrbbt/cdms/backend/internal/cdms_data/processor"
+	"github.com/jjckrbbt/cdms/backend/internal/cdms_data/reportdef"
+	"github.com/jjckrbbt/cdms/backend/internal/db"
+	"github.com/labstack/echo/v4"
+)
+
+// maxDefinitionSize bounds the body of a published report definition.
+const maxDefinitionSize = 1 << 20
+
+type ReportDefinitionHandler struct {
+	processor *processor.Processor
+	queries   db.Querier
+	logger    *slog.Logger
+}
+
+func NewReportDefinitionHandler(proc *processor.Processor, q db.Querier, logger *slog.Logger) *ReportDefinitionHandler {
+	return &ReportDefinitionHandler{
+		processor: proc,
+		queries:   q,
+		logger:    logger.With("component", "report_definition_handler"),
+	}
+}
+
+// ReportDefinitionVersion is one published version of a report type's definition.
+type ReportDefinitionVersion struct {
+	db.ReportDefinition
+	Definition json.RawMessage `json:"definition"`
+}
+
+// ReportDefinitionResponse is a report type's current definition and its published history.
+type ReportDefinitionResponse struct {
+	Current  reportdef.Definition      `json:"current"`
+	Versions []ReportDefinitionVersion `json:"versions"`
+}
+
+// HandleListReportDefinitions returns the current definition of every report type.
+func (h *ReportDefinitionHandler) HandleListReportDefinitions(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	defs, err := h.processor.Definitions(ctx)
+	if err != nil {
+		h.logger.ErrorContext(ctx, "Failed to list report definitions", "error", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to list report definitions")
+	}
+	return c.JSON(http.StatusOK, defs)
+}
+
+// HandleGetReportDefinition returns a report type's current definition and every
+// version published to the database.
+func (h *ReportDefinitionHandler) HandleGetReportDefinition(c echo.Context) error {
+	ctx := c.Request().Context()
+	reportType := strings.ToUpper(c.Param("reportType"))
+
+	current, err := h.processor.Definition(ctx, reportType)
+	if err != nil {
+		if errors.Is(err, processor.ErrUnknownReportType) {
+			return echo.NewHTTPError(http.StatusNotFound, "Report type not found")
+		}
+		h.logger.ErrorContext(ctx, "Failed to load report definition", "report_type", reportType, "error", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to load report definition")
+	}
+
+	rows, err := h.queries.ListReportDefinitionVersions(ctx, reportType)
+	if err != nil {
+		h.logger.ErrorContext(ctx, "Failed to list report definition versions", "report_type", reportType, "error", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to list report definition versions")
+	}
+
+	response := ReportDefinitionResponse{Current: current, Versions: []ReportDefinitionVersion{}}
+	for _, row := range rows {
+		response.Versions = append(response.Versions, ReportDefinitionVersion{ReportDefinition: row, Definition: json.RawMessage(row.Definition)})
+	}
+	return c.JSON(http.StatusOK, response)
+}
+
+// HandlePublishReportDefinition stores the request body as the next version of its
+// report type's definition. Uploads processed from then on use it.
+func (h *ReportDefinitionHandler) HandlePublishReportDefinition(c echo.Context) error {
+	ctx := c.Request().Context()
+	user := c.Get("user").(db.CdmsUser)
+
+	body, err := io.ReadAll(io.LimitReader(c.Request().Body, maxDefinitionSize+1))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Failed to read request body")
+	}
+	if len(body) > maxDefinitionSize {
+		return echo.NewHTTPError(http.StatusRequestEntityTooLarge, "Report definition is too large")
+	}
+
+	def, err := h.processor.PublishDefinition(ctx, body, user.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, reportdef.ErrInvalidDefinition):
+			return echo.NewHTTPError(http.StatusUnprocessableEntity, err.Error())
+		case errors.Is(err, processor.ErrDefinitionConflict):
+			return echo.NewHTTPError(http.StatusConflict, err.Error())
+		}
+		h.logger.ErrorContext(ctx, "Failed to publish report definition", "error", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to publish report definition")
+	}
+	return c.JSON(http.StatusCreated, def)
+}
@@ -0,0 +1,160 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jjckrbbt/cdms/backend/internal/cdms_data/importer"
+	"github.com/jjckrbbt/cdms/backend/internal/db"
+	"github.com/labstack/echo/v4"
+)
+
+// Aggregate statuses of an upload bundle.
+const (
+	BundleStatusProcessing       = "PROCESSING"
+	BundleStatusAwaitingApproval = "AWAITING_APPROVAL"
+	BundleStatusComplete         = "COMPLETE"
+	BundleStatusCompleteIssues   = "COMPLETE_WITH_ISSUES"
+	BundleStatusPartiallyFailed  = "PARTIALLY_FAILED"
+	BundleStatusFailed           = "FAILED"
+)
+
+// UploadBundleResponse is a bundle with its members in processing order and their
+// aggregate status.
+type UploadBundleResponse struct {
+	db.UploadBundle
+	Status  string                    `json:"status"`
+	Members []db.ListBundleUploadsRow `json:"members"`
+}
+
+// bundleStatus sums up the statuses of a bundle's members. A bundle is processing
+// while any member is, awaiting approval while any member is staged, and otherwise
+// complete, failed or partially failed according to how its members ended.
+func bundleStatus(members []db.ListBundleUploadsRow) string {
+	var awaiting, failed, issues, succeeded int
+	for _, m := range members {
+		switch {
+		case inFlightUploadStatuses[m.Status]:
+			return BundleStatusProcessing
+		case m.Status == "STAGED":
+			awaiting++
+		case m.Status == "COMPLETE_WITH_ISSUES":
+			issues++
+			succeeded++
+		case m.Status == "COMPLETE" || m.Status == "DRY_RUN_COMPLETE":
+			succeeded++
+		default:
+			// FAILED_*, REJECTED, ROLLED_BACK and CANCELLED members did not load.
+			failed++
+		}
+	}
+
+	switch {
+	case awaiting > 0:
+		return BundleStatusAwaitingApproval
+	case failed > 0 && failed == len(members):
+		return BundleStatusFailed
+	case failed > 0:
+		return BundleStatusPartiallyFailed
+	case issues > 0:
+		return BundleStatusCompleteIssues
+	}
+	return BundleStatusComplete
+}
+
+// HandleUploadBundle accepts a .zip of reports, or a single .csv.gz, and queues each
+// report as its own upload. Report types are detected from file names or headers, and
+// the members are processed one after another, VENDOR_CODE first.
+func (h *UploadHandler) HandleUploadBundle(c echo.Context) error {
+	user := c.Get("user").(db.CdmsUser)
+
+	dryRun, err := parseBoolQueryParam(c, "dry_run")
+	if err != nil {
+		return err
+	}
+	// force accepts a file whose content was already uploaded for its report type.
+	force, err := parseBoolQueryParam(c, "force")
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request().Context(), 2*time.Minute)
+	defer cancel()
+
+	fileHeader, err := c.FormFile("report_file")
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Bad Request: No file uploaded or wrong field name ('report_file')")
+	}
+	encoding, delimiter, err := parseDialectOverrides(c.FormValue("encoding"), c.FormValue("delimiter"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	bundle, uploads, err := h.importer.StoreBundle(ctx, fileHeader, user, h.processor.DetectReport, importer.StoreOptions{
+		SheetName: strings.TrimSpace(c.FormValue("sheet")),
+		DryRun:    dryRun,
+		Encoding:  encoding,
+		Delimiter: delimiter,
+		Force:     force,
+	})
+	if err != nil {
+		if errors.Is(err, importer.ErrInvalidBundle) {
+			return echo.NewHTTPError(http.StatusUnprocessableEntity, err.Error())
+		}
+		var duplicate *importer.DuplicateUploadError
+		if errors.As(err, &duplicate) {
+			return respondWithDuplicates(c, duplicate)
+		}
+		h.logger.ErrorContext(ctx, "Failed to store upload bundle", "filename", fileHeader.Filename, "error", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("Failed to accept bundle: %s", err.Error()))
+	}
+
+	h.queue.Notify()
+
+	h.logger.InfoContext(ctx, "Upload bundle queued", "bundle_id", uuid.UUID(bundle.ID.Bytes), "filename", bundle.Filename, "members", len(uploads), "uploaded_by", user.ID)
+	return h.respondWithBundle(c, http.StatusAccepted, bundle)
+}
+
+// HandleGetUploadBundle returns a bundle with the status of each member and of the
+// bundle as a whole.
+func (h *UploadHandler) HandleGetUploadBundle(c echo.Context) error {
+	ctx := c.Request().Context()
+	bundleID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid bundle ID format")
+	}
+
+	bundle, err := h.queries.GetUploadBundle(ctx, pgtype.UUID{Bytes: bundleID, Valid: true})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return echo.NewHTTPError(http.StatusNotFound, "Bundle not found")
+		}
+		h.logger.ErrorContext(ctx, "Failed to get upload bundle", "bundle_id", bundleID, "error", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to retrieve bundle")
+	}
+	return h.respondWithBundle(c, http.StatusOK, bundle)
+}
+
+func (h *UploadHandler) respondWithBundle(c echo.Context, code int, bundle db.UploadBundle) error {
+	ctx := c.Request().Context()
+	members, err := h.queries.ListBundleUploads(ctx, bundle.ID)
+	if err != nil {
+		h.logger.ErrorContext(ctx, "Failed to list bundle uploads", "bundle_id", uuid.UUID(bundle.ID.Bytes), "error", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to retrieve bundle uploads")
+	}
+	if members == nil {
+		members = []db.ListBundleUploadsRow{}
+	}
+	return c.JSON(code, UploadBundleResponse{
+		UploadBundle: bundle,
+		Status:       bundleStatus(members),
+		Members:      members,
+	})
+}
@@ -0,0 +1,36 @@
+package api
+
+import (
+	"testing"
+
+	"github.com/jjckrbbt/cdms/backend/internal/db"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBundleStatus(t *testing.T) {
+	members := func(statuses ...string) []db.ListBundleUploadsRow {
+		rows := make([]db.ListBundleUploadsRow, len(statuses))
+		for i, s := range statuses {
+			rows[i].Status = s
+		}
+		return rows
+	}
+
+	testCases := []struct {
+		name     string
+		members  []db.ListBundleUploadsRow
+		expected string
+	}{
+		{"A member still processing", members("COMPLETE", "PROCESSING", "STAGED"), BundleStatusProcessing},
+		{"A member awaiting approval", members("COMPLETE", "STAGED", "FAILED_GENERIC"), BundleStatusAwaitingApproval},
+		{"All merged", members("COMPLETE", "COMPLETE"), BundleStatusComplete},
+		{"Merged with removed rows", members("COMPLETE", "COMPLETE_WITH_ISSUES"), BundleStatusCompleteIssues},
+		{"Some failed", members("COMPLETE", "FAILED_HEADERS_MISMATCH"), BundleStatusPartiallyFailed},
+		{"All failed or rejected", members("FAILED_GENERIC", "REJECTED"), BundleStatusFailed},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, bundleStatus(tc.members))
+		})
+	}
+}
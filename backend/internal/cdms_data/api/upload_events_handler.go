@@ -0,0 +1,135 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jjckrbbt/cdms/backend/internal/cdms_data/progress"
+	"github.com/labstack/echo/v4"
+)
+
+// uploadEventsKeepAlive is how often an idle event stream sends a comment to keep
+// proxies from closing it, and rechecks the upload's status in case a notification
+// was missed while the listener was reconnecting.
+const uploadEventsKeepAlive = 15 * time.Second
+
+// HandleUploadEvents streams an upload's progress as Server-Sent Events. The stream
+// opens with the latest progress and the current status, then relays "progress" and
+// "status" events as they happen, and ends once the upload is no longer being
+// processed.
+//
+// The stream is authenticated like any other API call, by the bearer token in the
+// Authorization header. Browsers' EventSource cannot set that header, so clients read
+// the stream with fetch instead (see frontend/src/lib/uploadEvents.ts); a token in the
+// query string is not accepted, since it would be written to access logs.
+func (h *UploadHandler) HandleUploadEvents(c echo.Context) error {
+	ctx := c.Request().Context()
+	uploadID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid upload ID format")
+	}
+	pgUploadID := pgtype.UUID{Bytes: uploadID, Valid: true}
+
+	// Subscribe before reading the current state so nothing happens unseen in between.
+	events, unsubscribe := h.progress.Subscribe(uploadID)
+	defer unsubscribe()
+
+	upload, err := h.queries.GetUpload(ctx, pgUploadID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return echo.NewHTTPError(http.StatusNotFound, "Upload not found")
+		}
+		h.logger.ErrorContext(ctx, "Failed to get upload for event stream", "upload_id", uploadID, "error", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to retrieve upload")
+	}
+
+	var latest *progress.Event
+	if raw, err := h.queries.GetUploadProgress(ctx, pgUploadID); err == nil {
+		var event progress.Event
+		if err := json.Unmarshal(raw, &event); err == nil {
+			latest = &event
+		}
+	} else if !errors.Is(err, pgx.ErrNoRows) {
+		h.logger.WarnContext(ctx, "Failed to get latest upload progress", "upload_id", uploadID, "error", err)
+	}
+
+	res := c.Response()
+	res.Header().Set(echo.HeaderContentType, "text/event-stream")
+	res.Header().Set(echo.HeaderCacheControl, "no-cache")
+	res.Header().Set(echo.HeaderConnection, "keep-alive")
+	res.Header().Set("X-Accel-Buffering", "no")
+	res.WriteHeader(http.StatusOK)
+
+	if latest != nil {
+		if err := writeUploadEvent(res, *latest); err != nil {
+			return nil
+		}
+	}
+	status := uploadStatusEvent(uploadID, upload.Status, upload.ErrorDetails.String)
+	if err := writeUploadEvent(res, status); err != nil || !inFlightUploadStatuses[status.Status] {
+		return nil
+	}
+
+	ticker := time.NewTicker(uploadEventsKeepAlive)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+
+		case event := <-events:
+			if err := writeUploadEvent(res, event); err != nil {
+				return nil
+			}
+			if event.Type == progress.TypeStatus && !inFlightUploadStatuses[event.Status] {
+				return nil
+			}
+
+		case <-ticker.C:
+			upload, err := h.queries.GetUpload(ctx, pgUploadID)
+			if err != nil {
+				if ctx.Err() == nil {
+					h.logger.WarnContext(ctx, "Failed to recheck upload status for event stream", "upload_id", uploadID, "error", err)
+				}
+				continue
+			}
+			if !inFlightUploadStatuses[upload.Status] {
+				writeUploadEvent(res, uploadStatusEvent(uploadID, upload.Status, upload.ErrorDetails.String))
+				return nil
+			}
+			if _, err := fmt.Fprint(res, ": keep-alive\n\n"); err != nil {
+				return nil
+			}
+			res.Flush()
+		}
+	}
+}
+
+func uploadStatusEvent(uploadID uuid.UUID, status, errorDetails string) progress.Event {
+	return progress.Event{
+		Type:     progress.TypeStatus,
+		UploadID: uploadID,
+		Status:   status,
+		Error:    errorDetails,
+		At:       time.Now(),
+	}
+}
+
+// writeUploadEvent writes one Server-Sent Event named after the event's type.
+func writeUploadEvent(res *echo.Response, event progress.Event) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(res, "event: %s\ndata: %s\n\n", event.Type, data); err != nil {
+		return err
+	}
+	res.Flush()
+	return nil
+}
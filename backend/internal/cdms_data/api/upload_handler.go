@@ -2,8 +2,10 @@ package api
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"log/slog"
 	"net/http"
 	"strconv"
@@ -16,31 +18,50 @@ import (
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgtype"
 	"github.com/jjckrbbt/cdms/backend/internal/cdms_data/importer"
+	"github.com/jjckrbbt/cdms/backend/internal/cdms_data/jobqueue"
 	"github.com/jjckrbbt/cdms/backend/internal/cdms_data/processor"
+	"github.com/jjckrbbt/cdms/backend/internal/cdms_data/progress"
+	"github.com/jjckrbbt/cdms/backend/internal/cdms_data/reportdef"
 	"github.com/jjckrbbt/cdms/backend/internal/db"
+	"github.com/jjckrbbt/cdms/backend/internal/dialect"
 )
 
+// UploadProcessor is the part of the report processor the upload handlers use. It is
+// satisfied by *processor.Processor.
+type UploadProcessor interface {
+	Definition(ctx context.Context, reportType string) (reportdef.Definition, error)
+	DetectReport(ctx context.Context, filename string, content io.Reader) (reportdef.Definition, error)
+	RollbackUpload(ctx context.Context, uploadID uuid.UUID, userID int64) (processor.RollbackResult, error)
+	ResubmitRemovedRow(ctx context.Context, rowID uuid.UUID, corrections map[string]string, note string, userID int64) (processor.ResubmitResult, error)
+}
+
 type UploadHandler struct {
 	importer  *importer.Importer
-	processor *processor.Processor
+	processor UploadProcessor
+	queue     *jobqueue.Queue
+	progress  *progress.Hub
 	queries   db.Querier
 	logger    *slog.Logger
 }
 
-func NewUploadHandler(imp *importer.Importer, proc *processor.Processor, q db.Querier, appLogger *slog.Logger) *UploadHandler {
+func NewUploadHandler(imp *importer.Importer, proc UploadProcessor, queue *jobqueue.Queue, hub *progress.Hub, q db.Querier, appLogger *slog.Logger) *UploadHandler {
 	return &UploadHandler{
 		importer:  imp,
 		processor: proc,
+		queue:     queue,
+		progress:  hub,
 		queries:   q,
 		logger:    appLogger.With("component", "cdms_api_handler"),
 	}
 }
 
-var AllowedReportTypes = map[string]bool{
-	"BC1300":            true,
-	"BC1048":            true,
-	"OUTSTANDING_BILLS": true,
-	"VENDOR_CODE":       true,
+// inFlightUploadStatuses are the statuses of an upload that is still being processed.
+var inFlightUploadStatuses = map[string]bool{
+	"UPLOADED":         true,
+	"QUEUED":           true,
+	"PROCESSING":       true,
+	"WAITING_FOR_LOCK": true,
+	"RETRYING":         true,
 }
 
 func (h *UploadHandler) HandleGetUploads(c echo.Context) error {
@@ -69,6 +90,71 @@ func (h *UploadHandler) HandleGetUploads(c echo.Context) error {
 	return c.JSON(http.StatusOK, uploads)
 }
 
+// UploadDetailResponse is a single upload with its preview, sanity check, header check
+// and dialect decoded as JSON.
+type UploadDetailResponse struct {
+	db.GetUploadRow
+	Preview     json.RawMessage `json:"preview"`
+	SanityCheck json.RawMessage `json:"sanity_check"`
+	HeaderCheck json.RawMessage `json:"header_check"`
+	Dialect     json.RawMessage `json:"dialect"`
+}
+
+func (h *UploadHandler) HandleGetUpload(c echo.Context) error {
+	ctx := c.Request().Context()
+	uploadID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid upload ID format")
+	}
+
+	upload, err := h.queries.GetUpload(ctx, pgtype.UUID{Bytes: uploadID, Valid: true})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return echo.NewHTTPError(http.StatusNotFound, "Upload not found")
+		}
+		h.logger.ErrorContext(ctx, "Failed to get upload", "upload_id", uploadID, "error", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to retrieve upload")
+	}
+
+	response := UploadDetailResponse{GetUploadRow: upload}
+	if len(upload.Preview) > 0 {
+		response.Preview = json.RawMessage(upload.Preview)
+	}
+	if len(upload.SanityCheck) > 0 {
+		response.SanityCheck = json.RawMessage(upload.SanityCheck)
+	}
+	if len(upload.HeaderCheck) > 0 {
+		response.HeaderCheck = json.RawMessage(upload.HeaderCheck)
+	}
+	if len(upload.Dialect) > 0 {
+		response.Dialect = json.RawMessage(upload.Dialect)
+	}
+	return c.JSON(http.StatusOK, response)
+}
+
+// RemovedRowResponse is a removed row with its row data and validation issues decoded
+// as JSON.
+type RemovedRowResponse struct {
+	db.RemovedRowsLog
+	OriginalRowData  json.RawMessage `json:"original_row_data"`
+	CorrectedRowData json.RawMessage `json:"corrected_row_data"`
+	Issues           json.RawMessage `json:"issues"`
+}
+
+func newRemovedRowResponse(row db.RemovedRowsLog) RemovedRowResponse {
+	response := RemovedRowResponse{RemovedRowsLog: row, Issues: json.RawMessage("[]")}
+	if len(row.OriginalRowData) > 0 {
+		response.OriginalRowData = json.RawMessage(row.OriginalRowData)
+	}
+	if len(row.CorrectedRowData) > 0 {
+		response.CorrectedRowData = json.RawMessage(row.CorrectedRowData)
+	}
+	if len(row.Issues) > 0 {
+		response.Issues = json.RawMessage(row.Issues)
+	}
+	return response
+}
+
 func (h *UploadHandler) HandleGetRemovedRows(c echo.Context) error {
 	ctx := c.Request().Context()
 	uploadIDStr := c.Param("id")
@@ -82,16 +168,53 @@ func (h *UploadHandler) HandleGetRemovedRows(c echo.Context) error {
 	rows, err := h.queries.GetRemovedRowsByUploadID(ctx, pgUUID)
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
-			return c.JSON(http.StatusOK, []db.RemovedRowsLog{})
+			return c.JSON(http.StatusOK, []RemovedRowResponse{})
 		}
-		h.logger.ErrorContext(ctx, "Failed to get removed rows for upload", uploadIDStr, "error", err)
+		h.logger.ErrorContext(ctx, "Failed to get removed rows for upload", "upload_id", uploadIDStr, "error", err)
 		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to retrieve removed rows")
 	}
 
-	return c.JSON(http.StatusOK, rows)
+	response := make([]RemovedRowResponse, 0, len(rows))
+	for _, row := range rows {
+		response = append(response, newRemovedRowResponse(row))
+	}
+	return c.JSON(http.StatusOK, response)
 }
 
+// HandleSummarizeRemovedRowIssues counts the validation issues of open removed rows by
+// report type, rule and column, optionally for one report type.
+func (h *UploadHandler) HandleSummarizeRemovedRowIssues(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	reportType := strings.ToUpper(c.QueryParam("report_type"))
+	if reportType != "" {
+		if _, err := h.processor.Definition(ctx, reportType); err != nil {
+			if errors.Is(err, processor.ErrUnknownReportType) {
+				return echo.NewHTTPError(http.StatusBadRequest, "Unsupported report type")
+			}
+			h.logger.ErrorContext(ctx, "Failed to load report definition", "report_type", reportType, "error", err)
+			return echo.NewHTTPError(http.StatusInternalServerError, "Failed to load report definition")
+		}
+	}
+
+	summary, err := h.queries.SummarizeRemovedRowIssues(ctx, reportType)
+	if err != nil {
+		h.logger.ErrorContext(ctx, "Failed to summarize removed row issues", "report_type", reportType, "error", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to summarize removed row issues")
+	}
+	if summary == nil {
+		summary = []db.SummarizeRemovedRowIssuesRow{}
+	}
+	return c.JSON(http.StatusOK, summary)
+}
+
+// HandleUpload stores a report file as an upload made by the authenticated user, who
+// may then not approve it, and queues it for staging.
 func (h *UploadHandler) HandleUpload(c echo.Context) error {
+	user, ok := c.Get("user").(db.CdmsUser)
+	if !ok {
+		return echo.NewHTTPError(http.StatusInternalServerError, "User not found in context")
+	}
 	requestID, _ := c.Get("requestID").(string)
 	if requestID == "" {
 		requestID = uuid.New().String()
@@ -100,15 +223,30 @@ func (h *UploadHandler) HandleUpload(c echo.Context) error {
 		"request_id", requestID,
 		"http_method", c.Request().Method,
 		"path", c.Request().URL.Path,
+		"user_id", user.ID,
 	)
 
 	reportType := strings.ToUpper(c.Param("reportType"))
-	if !AllowedReportTypes[reportType] {
-		reqLogger.WarnContext(c.Request().Context(), "Attempted upload with unsupported report type", "report_type", reportType)
-		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("Unsupported report type: '%s'", reportType))
+	if _, err := h.processor.Definition(c.Request().Context(), reportType); err != nil {
+		if errors.Is(err, processor.ErrUnknownReportType) {
+			reqLogger.WarnContext(c.Request().Context(), "Attempted upload with unsupported report type", "report_type", reportType)
+			return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("Unsupported report type: '%s'", reportType))
+		}
+		reqLogger.ErrorContext(c.Request().Context(), "Failed to load report definition", "report_type", reportType, "error", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to load report definition")
 	}
 
-	reqLogger.InfoContext(c.Request().Context(), "Received file upload request", "report_type", reportType)
+	dryRun, err := parseBoolQueryParam(c, "dry_run")
+	if err != nil {
+		return err
+	}
+	// force accepts a file whose content was already uploaded for its report type.
+	force, err := parseBoolQueryParam(c, "force")
+	if err != nil {
+		return err
+	}
+
+	reqLogger.InfoContext(c.Request().Context(), "Received file upload request", "report_type", reportType, "dry_run", dryRun, "force", force)
 
 	ctx, cancel := context.WithTimeout(c.Request().Context(), 30*time.Second)
 	defer cancel()
@@ -119,50 +257,501 @@ func (h *UploadHandler) HandleUpload(c echo.Context) error {
 		return echo.NewHTTPError(http.StatusBadRequest, "Bad Request: No file uploaded or wrong field name ('report_file')")
 	}
 
-	reqLogger.InfoContext(ctx, "File received from client", "filename", fileHeader.Filename, "size_bytes", fileHeader.Size)
+	// Optional worksheet name for .xlsx uploads; the first sheet is used when omitted.
+	sheetName := strings.TrimSpace(c.FormValue("sheet"))
 
-	uploadRecord, err := h.importer.StoreFile(ctx, fileHeader, reportType)
+	// Optional encoding and delimiter for delimited uploads; detected when omitted.
+	encoding, delimiter, err := parseDialectOverrides(c.FormValue("encoding"), c.FormValue("delimiter"))
 	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	reqLogger.InfoContext(ctx, "File received from client", "filename", fileHeader.Filename, "size_bytes", fileHeader.Size, "sheet", sheetName, "encoding", encoding, "delimiter", delimiter)
+
+	uploadRecord, err := h.importer.StoreFile(ctx, fileHeader, reportType, user, importer.StoreOptions{
+		SheetName: sheetName,
+		DryRun:    dryRun,
+		Encoding:  encoding,
+		Delimiter: delimiter,
+		Force:     force,
+	})
+	if err != nil {
+		var duplicate *importer.DuplicateUploadError
+		if errors.As(err, &duplicate) {
+			reqLogger.InfoContext(ctx, "Refused duplicate upload", "filename", fileHeader.Filename, "duplicate_of", duplicate.Duplicates[0].DuplicateOf)
+			return respondWithDuplicates(c, duplicate)
+		}
 		reqLogger.ErrorContext(ctx, "Failed to store uploaded file via Importer service", "error", err)
 		return echo.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("Failed to accept upload: %s", err.Error()))
 	}
 
+	h.queue.Notify()
+
+	message := fmt.Sprintf("File '%s' accepted for processing; it will be staged for approval.", uploadRecord.Filename)
+	if dryRun {
+		message = fmt.Sprintf("File '%s' accepted for a dry run; no changes will be committed.", uploadRecord.Filename)
+	}
+
 	response := map[string]string{
-		"message":     fmt.Sprintf("File '%s' accepted for processing.", uploadRecord.Filename),
+		"message":     message,
 		"upload_id":   uploadRecord.ID.String(),
 		"report_type": reportType,
+		"status":      uploadRecord.Status,
 	}
 	if err := c.JSON(http.StatusAccepted, response); err != nil {
 		reqLogger.ErrorContext(ctx, "Failed to write 202 JSON response", "error", err)
 		return err
 	}
-	reqLogger.InfoContext(ctx, "File accepted and 202 response sent to client", "upload_id", uploadRecord.ID.String())
-
-	go func() {
-		procCtx, procCancel := context.WithTimeout(context.Background(), 10*time.Minute)
-		defer procCancel()
-
-		result := h.processor.ProcessFileFromCloudStorage(procCtx, uploadRecord.ID.String(), uploadRecord.StorageKey, uploadRecord.ReportType)
-
-		var errorMsg string
-		if result.Error != nil {
-			errorMsg = result.Error.Error()
-			reqLogger.ErrorContext(procCtx, "Asynchronous report processing failed",
-				"upload_id", uploadRecord.ID.String(),
-				"final_status", result.Status,
-				"error", result.Error)
-		} else {
-			reqLogger.InfoContext(procCtx, "Asynchronous report processing completed",
-				"upload_id", uploadRecord.ID.String(),
-				"final_status", result.Status,
-				"rows_upserted", result.RowsUpserted,
-				"rows_removed", result.RowsRemoved)
+	reqLogger.InfoContext(ctx, "File queued and 202 response sent to client", "upload_id", uploadRecord.ID.String())
+
+	return nil
+}
+
+// ReviewUploadRequest carries an approver's decision note. A reason is required to reject.
+type ReviewUploadRequest struct {
+	Reason string `json:"reason"`
+}
+
+// HandleApproveUpload approves a STAGED upload and queues its merge. The approver must
+// hold reports:approve and must not be the user who uploaded the file.
+func (h *UploadHandler) HandleApproveUpload(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	user, ok := c.Get("user").(db.CdmsUser)
+	if !ok {
+		return echo.NewHTTPError(http.StatusInternalServerError, "User not found in context")
+	}
+
+	uploadID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid upload ID format")
+	}
+
+	var req ReviewUploadRequest
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request body")
+	}
+
+	upload, err := h.getStagedUpload(ctx, uploadID)
+	if err != nil {
+		return err
+	}
+	if upload.ProcessedByUserID == user.ID {
+		return echo.NewHTTPError(http.StatusForbidden, "An upload must be approved by someone other than the user who uploaded it")
+	}
+
+	reason := strings.TrimSpace(req.Reason)
+	job, err := h.queries.ApproveUpload(ctx, db.ApproveUploadParams{
+		ReviewerID:   user.ID,
+		ReviewReason: pgtype.Text{String: reason, Valid: reason != ""},
+		ID:           pgtype.UUID{Bytes: uploadID, Valid: true},
+		MaxAttempts:  h.queue.MaxAttempts(),
+	})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return echo.NewHTTPError(http.StatusConflict, "Upload is no longer awaiting approval")
+		}
+		h.logger.ErrorContext(ctx, "Failed to approve upload", "upload_id", uploadID, "error", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to approve upload")
+	}
+	h.queue.Notify()
+
+	h.logger.InfoContext(ctx, "Upload approved", "upload_id", uploadID, "approved_by", user.ID, "job_id", job.ID)
+	return c.JSON(http.StatusAccepted, map[string]string{
+		"message":   fmt.Sprintf("Upload '%s' approved and queued for merge.", upload.Filename),
+		"upload_id": uploadID.String(),
+		"status":    job.Status,
+	})
+}
+
+// HandleRejectUpload rejects a STAGED upload with a reason and discards its staged rows.
+func (h *UploadHandler) HandleRejectUpload(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	user, ok := c.Get("user").(db.CdmsUser)
+	if !ok {
+		return echo.NewHTTPError(http.StatusInternalServerError, "User not found in context")
+	}
+
+	uploadID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid upload ID format")
+	}
+
+	var req ReviewUploadRequest
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request body")
+	}
+	reason := strings.TrimSpace(req.Reason)
+	if reason == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, "A reason is required to reject an upload")
+	}
+
+	if _, err := h.getStagedUpload(ctx, uploadID); err != nil {
+		return err
+	}
+
+	pgUploadID := pgtype.UUID{Bytes: uploadID, Valid: true}
+	rejected, err := h.queries.RejectUpload(ctx, db.RejectUploadParams{
+		ReviewerID:   user.ID,
+		ReviewReason: reason,
+		ID:           pgUploadID,
+	})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return echo.NewHTTPError(http.StatusConflict, "Upload is no longer awaiting approval")
 		}
+		h.logger.ErrorContext(ctx, "Failed to reject upload", "upload_id", uploadID, "error", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to reject upload")
+	}
 
-		if err := h.importer.UpdateUploadStatus(procCtx, uploadRecord.ID, result.Status, errorMsg, result.RowsUpserted, result.RowsRemoved); err != nil {
-			reqLogger.ErrorContext(procCtx, "FATAL: Could not update final upload status in DB", "update_error", err)
+	if err := h.queries.DeleteUploadStagedRows(ctx, pgUploadID); err != nil {
+		// The upload can never be merged now, so leftover rows are only wasted space.
+		h.logger.ErrorContext(ctx, "Failed to discard staged rows of rejected upload", "upload_id", uploadID, "error", err)
+	}
+
+	h.logger.InfoContext(ctx, "Upload rejected", "upload_id", uploadID, "rejected_by", user.ID)
+	return c.JSON(http.StatusOK, map[string]string{
+		"message":   fmt.Sprintf("Upload '%s' rejected.", rejected.Filename),
+		"upload_id": uploadID.String(),
+		"status":    rejected.Status,
+	})
+}
+
+// HandleOverrideSanityCheck forces an upload that failed the mass-deactivation sanity
+// check through by queueing it again with the check overridden. An upload stopped while
+// staging is staged again and still needs approval; one stopped while merging is merged.
+func (h *UploadHandler) HandleOverrideSanityCheck(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	user, ok := c.Get("user").(db.CdmsUser)
+	if !ok {
+		return echo.NewHTTPError(http.StatusInternalServerError, "User not found in context")
+	}
+
+	uploadID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid upload ID format")
+	}
+
+	upload, err := h.queries.OverrideUploadSanityCheck(ctx, db.OverrideUploadSanityCheckParams{
+		ID:          pgtype.UUID{Bytes: uploadID, Valid: true},
+		MaxAttempts: h.queue.MaxAttempts(),
+		UserID:      user.ID,
+	})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return echo.NewHTTPError(http.StatusConflict, "Only uploads in FAILED_SANITY_CHECK can be overridden")
 		}
-	}()
+		h.logger.ErrorContext(ctx, "Failed to override sanity check", "upload_id", uploadID, "error", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to override sanity check")
+	}
 
-	return nil
+	h.queue.Notify()
+
+	h.logger.WarnContext(ctx, "Sanity check overridden", "upload_id", uploadID, "overridden_by", user.ID)
+	return c.JSON(http.StatusAccepted, map[string]string{
+		"message":   fmt.Sprintf("Sanity check overridden; upload '%s' queued again.", upload.Filename),
+		"upload_id": uploadID.String(),
+		"status":    upload.Status,
+	})
+}
+
+// parseBoolQueryParam reads an optional true/false query parameter, false when absent.
+func parseBoolQueryParam(c echo.Context, name string) (bool, error) {
+	raw := c.QueryParam(name)
+	if raw == "" {
+		return false, nil
+	}
+	parsed, err := strconv.ParseBool(raw)
+	if err != nil {
+		return false, echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("%s must be true or false", name))
+	}
+	return parsed, nil
+}
+
+// respondWithDuplicates refuses files whose content was already uploaded, pointing at
+// the earlier uploads.
+func respondWithDuplicates(c echo.Context, duplicate *importer.DuplicateUploadError) error {
+	return c.JSON(http.StatusConflict, map[string]any{
+		"message":    duplicate.Error() + "; upload again with force=true to process it anyway",
+		"duplicates": duplicate.Duplicates,
+	})
+}
+
+// parseDialectOverrides validates the encoding and delimiter an uploader chose. Empty
+// values leave them to be detected.
+func parseDialectOverrides(encoding, delimiter string) (string, string, error) {
+	enc, err := dialect.ParseEncoding(encoding)
+	if err != nil {
+		return "", "", err
+	}
+	delim, err := dialect.ParseDelimiter(delimiter)
+	if err != nil {
+		return "", "", err
+	}
+	return enc, delim, nil
+}
+
+// HandleReprocessUpload re-runs an upload's stored file as a new upload linked to the
+// original, for example after the validation rules have been fixed. The new upload goes
+// through staging and approval like any other. The encoding and delimiter query
+// parameters force how the file is read, e.g. when detection got it wrong.
+func (h *UploadHandler) HandleReprocessUpload(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	user, ok := c.Get("user").(db.CdmsUser)
+	if !ok {
+		return echo.NewHTTPError(http.StatusInternalServerError, "User not found in context")
+	}
+
+	uploadID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid upload ID format")
+	}
+
+	source, err := h.queries.GetUpload(ctx, pgtype.UUID{Bytes: uploadID, Valid: true})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return echo.NewHTTPError(http.StatusNotFound, "Upload not found")
+		}
+		h.logger.ErrorContext(ctx, "Failed to get upload", "upload_id", uploadID, "error", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to retrieve upload")
+	}
+	if inFlightUploadStatuses[source.Status] {
+		return echo.NewHTTPError(http.StatusConflict, fmt.Sprintf("Upload is %s; wait for it to finish before reprocessing", source.Status))
+	}
+
+	encoding, delimiter, err := parseDialectOverrides(c.QueryParam("encoding"), c.QueryParam("delimiter"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	job, err := h.queries.CreateReprocessedUpload(ctx, db.CreateReprocessedUploadParams{
+		ID:                pgtype.UUID{Bytes: uuid.New(), Valid: true},
+		ProcessedByUserID: user.ID,
+		EncodingOverride:  pgtype.Text{String: encoding, Valid: encoding != ""},
+		DelimiterOverride: pgtype.Text{String: delimiter, Valid: delimiter != ""},
+		SourceUploadID:    pgtype.UUID{Bytes: uploadID, Valid: true},
+		MaxAttempts:       h.queue.MaxAttempts(),
+	})
+	if err != nil {
+		h.logger.ErrorContext(ctx, "Failed to record reprocessed upload", "upload_id", uploadID, "error", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to reprocess upload")
+	}
+	h.queue.Notify()
+	newUploadID := uuid.UUID(job.UploadID.Bytes)
+
+	h.logger.InfoContext(ctx, "Upload queued for reprocessing", "upload_id", newUploadID, "reprocessed_from", uploadID, "requested_by", user.ID, "job_id", job.ID)
+	return c.JSON(http.StatusAccepted, map[string]string{
+		"message":          fmt.Sprintf("File '%s' queued for reprocessing.", source.Filename),
+		"upload_id":        newUploadID.String(),
+		"reprocessed_from": uploadID.String(),
+		"report_type":      source.ReportType,
+		"status":           job.Status,
+	})
+}
+
+// HandleCancelUpload cancels an upload that has not been committed. An upload waiting
+// for a worker is cancelled at once; for one being processed, the worker is asked to
+// stop and roll back, and the upload becomes CANCELLED once it has.
+func (h *UploadHandler) HandleCancelUpload(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	user, ok := c.Get("user").(db.CdmsUser)
+	if !ok {
+		return echo.NewHTTPError(http.StatusInternalServerError, "User not found in context")
+	}
+
+	uploadID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid upload ID format")
+	}
+
+	upload, err := h.queries.GetUpload(ctx, pgtype.UUID{Bytes: uploadID, Valid: true})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return echo.NewHTTPError(http.StatusNotFound, "Upload not found")
+		}
+		h.logger.ErrorContext(ctx, "Failed to get upload", "upload_id", uploadID, "error", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to retrieve upload")
+	}
+	if !inFlightUploadStatuses[upload.Status] {
+		return echo.NewHTTPError(http.StatusConflict, cancelRefusal(upload.Status))
+	}
+
+	cancelled, err := h.queue.Cancel(ctx, uploadID, user.ID)
+	if err != nil {
+		if errors.Is(err, jobqueue.ErrNotCancellable) {
+			return echo.NewHTTPError(http.StatusConflict, "Upload finished processing before it could be cancelled")
+		}
+		h.logger.ErrorContext(ctx, "Failed to cancel upload", "upload_id", uploadID, "error", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to cancel upload")
+	}
+
+	if cancelled {
+		h.logger.InfoContext(ctx, "Upload cancelled", "upload_id", uploadID, "cancelled_by", user.ID)
+		return c.JSON(http.StatusOK, map[string]string{
+			"message":   fmt.Sprintf("Upload '%s' cancelled.", upload.Filename),
+			"upload_id": uploadID.String(),
+			"status":    jobqueue.StatusCancelled,
+		})
+	}
+
+	h.logger.InfoContext(ctx, "Upload cancellation requested", "upload_id", uploadID, "requested_by", user.ID)
+	return c.JSON(http.StatusAccepted, map[string]string{
+		"message":   fmt.Sprintf("Cancellation of '%s' requested. It will be CANCELLED once processing stops, unless it commits first.", upload.Filename),
+		"upload_id": uploadID.String(),
+		"status":    upload.Status,
+	})
+}
+
+// cancelRefusal explains why an upload that is no longer being processed cannot be
+// cancelled, and what to do instead.
+func cancelRefusal(status string) string {
+	switch status {
+	case "COMPLETE", "COMPLETE_WITH_ISSUES":
+		return fmt.Sprintf("Upload is %s: its rows have already been committed. Roll it back instead.", status)
+	case "STAGED":
+		return "Upload is STAGED: its rows have already been committed for review. Reject it instead."
+	case "DRY_RUN_COMPLETE":
+		return "Upload is DRY_RUN_COMPLETE: the dry run has finished and changed nothing."
+	case jobqueue.StatusCancelled:
+		return "Upload has already been cancelled."
+	}
+	return fmt.Sprintf("Upload is %s: it has already finished processing.", status)
+}
+
+// HandleRollbackUpload undoes the merge of the latest merged upload of a report type.
+func (h *UploadHandler) HandleRollbackUpload(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	user, ok := c.Get("user").(db.CdmsUser)
+	if !ok {
+		return echo.NewHTTPError(http.StatusInternalServerError, "User not found in context")
+	}
+
+	uploadID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid upload ID format")
+	}
+
+	result, err := h.processor.RollbackUpload(ctx, uploadID, user.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, pgx.ErrNoRows):
+			return echo.NewHTTPError(http.StatusNotFound, "Upload not found")
+		case errors.Is(err, processor.ErrRollbackRefused):
+			return echo.NewHTTPError(http.StatusConflict, err.Error())
+		}
+		h.logger.ErrorContext(ctx, "Failed to roll back upload", "upload_id", uploadID, "error", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to roll back upload")
+	}
+
+	return c.JSON(http.StatusOK, result)
+}
+
+// ResolveRemovedRowRequest carries the fixes for a removed row, keyed by the report's
+// column headers, and an optional note. Dismissing a row requires a note.
+type ResolveRemovedRowRequest struct {
+	Corrections map[string]string `json:"corrections"`
+	Note        string            `json:"note"`
+}
+
+// HandleResubmitRemovedRow applies corrections to an open removed row, identified by
+// its own ID, and merges it through the normal validation and upsert path.
+func (h *UploadHandler) HandleResubmitRemovedRow(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	user, ok := c.Get("user").(db.CdmsUser)
+	if !ok {
+		return echo.NewHTTPError(http.StatusInternalServerError, "User not found in context")
+	}
+
+	rowID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid removed row ID format")
+	}
+
+	var req ResolveRemovedRowRequest
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request body")
+	}
+	if len(req.Corrections) == 0 {
+		return echo.NewHTTPError(http.StatusBadRequest, "At least one correction is required to resubmit a row")
+	}
+
+	result, err := h.processor.ResubmitRemovedRow(ctx, rowID, req.Corrections, strings.TrimSpace(req.Note), user.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, pgx.ErrNoRows):
+			return echo.NewHTTPError(http.StatusNotFound, "Removed row not found")
+		case errors.Is(err, processor.ErrResubmitInvalid):
+			return echo.NewHTTPError(http.StatusUnprocessableEntity, err.Error())
+		case errors.Is(err, processor.ErrResubmitRefused):
+			return echo.NewHTTPError(http.StatusConflict, err.Error())
+		}
+		h.logger.ErrorContext(ctx, "Failed to resubmit removed row", "removed_row_id", rowID, "error", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to resubmit removed row")
+	}
+
+	return c.JSON(http.StatusOK, result)
+}
+
+// HandleDismissRemovedRow closes an open removed row without merging it.
+func (h *UploadHandler) HandleDismissRemovedRow(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	user, ok := c.Get("user").(db.CdmsUser)
+	if !ok {
+		return echo.NewHTTPError(http.StatusInternalServerError, "User not found in context")
+	}
+
+	rowID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid removed row ID format")
+	}
+
+	var req ResolveRemovedRowRequest
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request body")
+	}
+	note := strings.TrimSpace(req.Note)
+	if note == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, "A note is required to dismiss a removed row")
+	}
+
+	dismissed, err := h.queries.ResolveRemovedRow(ctx, db.ResolveRemovedRowParams{
+		ResolutionStatus: "DISMISSED",
+		ResolutionNote:   pgtype.Text{String: note, Valid: true},
+		ResolvedByUserID: user.ID,
+		ID:               pgtype.UUID{Bytes: rowID, Valid: true},
+	})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return echo.NewHTTPError(http.StatusConflict, "Removed row not found or already resolved")
+		}
+		h.logger.ErrorContext(ctx, "Failed to dismiss removed row", "removed_row_id", rowID, "error", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to dismiss removed row")
+	}
+
+	h.logger.InfoContext(ctx, "Removed row dismissed", "removed_row_id", rowID, "dismissed_by", user.ID)
+	return c.JSON(http.StatusOK, newRemovedRowResponse(dismissed))
+}
+
+// getStagedUpload loads an upload that is awaiting review, returning an HTTP error when
+// it does not exist or is not in the STAGED state.
+func (h *UploadHandler) getStagedUpload(ctx context.Context, uploadID uuid.UUID) (db.GetUploadRow, error) {
+	upload, err := h.queries.GetUpload(ctx, pgtype.UUID{Bytes: uploadID, Valid: true})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return db.GetUploadRow{}, echo.NewHTTPError(http.StatusNotFound, "Upload not found")
+		}
+		h.logger.ErrorContext(ctx, "Failed to get upload", "upload_id", uploadID, "error", err)
+		return db.GetUploadRow{}, echo.NewHTTPError(http.StatusInternalServerError, "Failed to retrieve upload")
+	}
+	if upload.Status != "STAGED" {
+		return db.GetUploadRow{}, echo.NewHTTPError(http.StatusConflict, fmt.Sprintf("Upload is %s, only STAGED uploads can be reviewed", upload.Status))
+	}
+	return upload, nil
 }
@@ -0,0 +1,343 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jjckrbbt/cdms/backend/internal/cdms_data/importer"
+	"github.com/jjckrbbt/cdms/backend/internal/cdms_data/jobqueue"
+	"github.com/jjckrbbt/cdms/backend/internal/cdms_data/processor"
+	"github.com/jjckrbbt/cdms/backend/internal/config"
+	"github.com/jjckrbbt/cdms/backend/internal/db"
+	"github.com/jjckrbbt/cdms/backend/internal/logger"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRespondWithDuplicates(t *testing.T) {
+	earlier := uuid.New()
+	err := fmt.Errorf("storing upload: %w", &importer.DuplicateUploadError{Duplicates: []importer.Duplicate{{
+		Filename:            "BC1048 August.csv",
+		ReportType:          "BC1048",
+		ContentSHA256:       "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08",
+		DuplicateOf:         earlier,
+		DuplicateOfFilename: "bc1048.csv",
+		DuplicateOfStatus:   "COMPLETE",
+	}}})
+	require.True(t, errors.Is(err, importer.ErrDuplicateUpload))
+
+	var duplicate *importer.DuplicateUploadError
+	require.True(t, errors.As(err, &duplicate))
+
+	rec := httptest.NewRecorder()
+	c := echo.New().NewContext(httptest.NewRequest(http.MethodPost, "/api/uploads/BC1048", nil), rec)
+	require.NoError(t, respondWithDuplicates(c, duplicate))
+	assert.Equal(t, http.StatusConflict, rec.Code)
+
+	var body struct {
+		Message    string `json:"message"`
+		Duplicates []struct {
+			DuplicateOf uuid.UUID `json:"duplicate_of"`
+		} `json:"duplicates"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+	assert.Contains(t, body.Message, earlier.String())
+	assert.Contains(t, body.Message, "force=true")
+	require.Len(t, body.Duplicates, 1)
+	assert.Equal(t, earlier, body.Duplicates[0].DuplicateOf)
+}
+
+func TestParseBoolQueryParam(t *testing.T) {
+	e := echo.New()
+	newContext := func(query string) echo.Context {
+		return e.NewContext(httptest.NewRequest(http.MethodPost, "/api/uploads/BC1048?"+query, nil), httptest.NewRecorder())
+	}
+
+	force, err := parseBoolQueryParam(newContext(""), "force")
+	require.NoError(t, err)
+	assert.False(t, force)
+
+	force, err = parseBoolQueryParam(newContext("force=true"), "force")
+	require.NoError(t, err)
+	assert.True(t, force)
+
+	_, err = parseBoolQueryParam(newContext("force=maybe"), "force")
+	var httpErr *echo.HTTPError
+	require.True(t, errors.As(err, &httpErr))
+	assert.Equal(t, http.StatusBadRequest, httpErr.Code)
+}
+
+func TestCancelRefusal(t *testing.T) {
+	testCases := []struct {
+		status string
+		expect string
+	}{
+		{status: "COMPLETE", expect: "Roll it back instead"},
+		{status: "COMPLETE_WITH_ISSUES", expect: "Roll it back instead"},
+		{status: "STAGED", expect: "Reject it instead"},
+		{status: "DRY_RUN_COMPLETE", expect: "changed nothing"},
+		{status: "CANCELLED", expect: "already been cancelled"},
+		{status: "FAILED_HEADERS_MISMATCH", expect: "already finished processing"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.status, func(t *testing.T) {
+			assert.Contains(t, cancelRefusal(tc.status), tc.expect)
+		})
+	}
+}
+
+func (m *MockQuerier) GetUpload(ctx context.Context, id pgtype.UUID) (db.GetUploadRow, error) {
+	args := m.Called(ctx, id)
+	return args.Get(0).(db.GetUploadRow), args.Error(1)
+}
+
+func (m *MockQuerier) ApproveUpload(ctx context.Context, params db.ApproveUploadParams) (db.UploadJob, error) {
+	args := m.Called(ctx, params)
+	return args.Get(0).(db.UploadJob), args.Error(1)
+}
+
+func TestHandleApproveUpload(t *testing.T) {
+	e := echo.New()
+	logger.InitLogger("development")
+	appLogger := logger.L()
+
+	uploadID := uuid.New()
+	pgUploadID := pgtype.UUID{Bytes: uploadID, Valid: true}
+	staged := db.GetUploadRow{ID: pgUploadID, Filename: "bc1048.csv", Status: "STAGED", ProcessedByUserID: 7}
+
+	newContext := func(user db.CdmsUser) echo.Context {
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{}`))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		c := e.NewContext(req, httptest.NewRecorder())
+		c.Set("user", user)
+		c.SetParamNames("id")
+		c.SetParamValues(uploadID.String())
+		return c
+	}
+
+	newHandler := func(mockQ *MockQuerier) *UploadHandler {
+		queue := jobqueue.NewQueue(mockQ, nil, nil, appLogger, &config.Config{UploadMaxAttempts: 3})
+		return NewUploadHandler(nil, nil, queue, nil, mockQ, appLogger)
+	}
+
+	t.Run("Uploader cannot approve their own upload", func(t *testing.T) {
+		mockQ := new(MockQuerier)
+		mockQ.On("GetUpload", mock.Anything, pgUploadID).Return(staged, nil).Once()
+
+		handler := newHandler(mockQ)
+		httpErr := handler.HandleApproveUpload(newContext(db.CdmsUser{ID: 7})).(*echo.HTTPError)
+
+		assert.Equal(t, http.StatusForbidden, httpErr.Code)
+		mockQ.AssertNotCalled(t, "ApproveUpload", mock.Anything, mock.Anything)
+		mockQ.AssertExpectations(t)
+	})
+
+	t.Run("Another user's approval queues the merge", func(t *testing.T) {
+		mockQ := new(MockQuerier)
+		mockQ.On("GetUpload", mock.Anything, pgUploadID).Return(staged, nil).Once()
+		mockQ.On("ApproveUpload", mock.Anything, db.ApproveUploadParams{ReviewerID: 8, ID: pgUploadID, MaxAttempts: 3}).
+			Return(db.UploadJob{ID: 11, UploadID: pgUploadID, Status: jobqueue.StatusQueued}, nil).
+			Once()
+
+		c := newContext(db.CdmsUser{ID: 8})
+		handler := newHandler(mockQ)
+		require.NoError(t, handler.HandleApproveUpload(c))
+
+		rec := c.Response().Writer.(*httptest.ResponseRecorder)
+		assert.Equal(t, http.StatusAccepted, rec.Code)
+		var body map[string]string
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+		assert.Equal(t, jobqueue.StatusQueued, body["status"])
+		mockQ.AssertExpectations(t)
+	})
+
+	t.Run("Upload approved meanwhile is a conflict", func(t *testing.T) {
+		mockQ := new(MockQuerier)
+		mockQ.On("GetUpload", mock.Anything, pgUploadID).Return(staged, nil).Once()
+		mockQ.On("ApproveUpload", mock.Anything, db.ApproveUploadParams{ReviewerID: 8, ID: pgUploadID, MaxAttempts: 3}).
+			Return(db.UploadJob{}, pgx.ErrNoRows).
+			Once()
+
+		handler := newHandler(mockQ)
+		httpErr := handler.HandleApproveUpload(newContext(db.CdmsUser{ID: 8})).(*echo.HTTPError)
+
+		assert.Equal(t, http.StatusConflict, httpErr.Code)
+		mockQ.AssertExpectations(t)
+	})
+}
+
+func (m *MockQuerier) OverrideUploadSanityCheck(ctx context.Context, params db.OverrideUploadSanityCheckParams) (db.Upload, error) {
+	args := m.Called(ctx, params)
+	return args.Get(0).(db.Upload), args.Error(1)
+}
+
+func TestHandleOverrideSanityCheck(t *testing.T) {
+	e := echo.New()
+	logger.InitLogger("development")
+	appLogger := logger.L()
+
+	uploadID := uuid.New()
+	params := db.OverrideUploadSanityCheckParams{ID: pgtype.UUID{Bytes: uploadID, Valid: true}, MaxAttempts: 3, UserID: 7}
+
+	tests := []struct {
+		name       string
+		upload     db.Upload
+		err        error
+		wantCode   int
+		wantStatus string
+	}{
+		{name: "Overridden upload is queued again", upload: db.Upload{Filename: "bc1048.csv", Status: jobqueue.StatusQueued}, wantCode: http.StatusAccepted, wantStatus: jobqueue.StatusQueued},
+		{name: "Upload that did not fail the check is a conflict", err: pgx.ErrNoRows, wantCode: http.StatusConflict},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			mockQ := new(MockQuerier)
+			mockQ.On("OverrideUploadSanityCheck", mock.Anything, params).Return(tc.upload, tc.err).Once()
+
+			rec := httptest.NewRecorder()
+			c := e.NewContext(httptest.NewRequest(http.MethodPost, "/", nil), rec)
+			c.Set("user", db.CdmsUser{ID: 7})
+			c.SetParamNames("id")
+			c.SetParamValues(uploadID.String())
+
+			queue := jobqueue.NewQueue(mockQ, nil, nil, appLogger, &config.Config{UploadMaxAttempts: 3})
+			err := NewUploadHandler(nil, nil, queue, nil, mockQ, appLogger).HandleOverrideSanityCheck(c)
+
+			if tc.err != nil {
+				assert.Equal(t, tc.wantCode, err.(*echo.HTTPError).Code)
+			} else {
+				require.NoError(t, err)
+				assert.Equal(t, tc.wantCode, rec.Code)
+				var body map[string]string
+				require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+				assert.Equal(t, tc.wantStatus, body["status"])
+			}
+			mockQ.AssertExpectations(t)
+		})
+	}
+}
+
+// fakeProcessor stands in for the report processor. Only rollback is used by the tests.
+type fakeProcessor struct {
+	UploadProcessor
+	rollbackErr error
+}
+
+func (f *fakeProcessor) RollbackUpload(ctx context.Context, uploadID uuid.UUID, userID int64) (processor.RollbackResult, error) {
+	return processor.RollbackResult{}, f.rollbackErr
+}
+
+func TestHandleRollbackUpload(t *testing.T) {
+	e := echo.New()
+	logger.InitLogger("development")
+	appLogger := logger.L()
+
+	tests := []struct {
+		name     string
+		err      error
+		wantCode int
+	}{
+		{name: "Refused rollback is a conflict", err: fmt.Errorf("%w: a later BC1048 upload has been merged since", processor.ErrRollbackRefused), wantCode: http.StatusConflict},
+		{name: "Unknown upload is Not Found", err: pgx.ErrNoRows, wantCode: http.StatusNotFound},
+		{name: "Other failures are server errors", err: errors.New("connection reset"), wantCode: http.StatusInternalServerError},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			c := e.NewContext(httptest.NewRequest(http.MethodPost, "/", nil), httptest.NewRecorder())
+			c.Set("user", db.CdmsUser{ID: 7})
+			c.SetParamNames("id")
+			c.SetParamValues(uuid.New().String())
+
+			handler := NewUploadHandler(nil, &fakeProcessor{rollbackErr: tc.err}, nil, nil, nil, appLogger)
+			httpErr := handler.HandleRollbackUpload(c).(*echo.HTTPError)
+
+			assert.Equal(t, tc.wantCode, httpErr.Code)
+		})
+	}
+}
+
+func (m *MockQuerier) CreateReprocessedUpload(ctx context.Context, params db.CreateReprocessedUploadParams) (db.UploadJob, error) {
+	args := m.Called(ctx, params)
+	return args.Get(0).(db.UploadJob), args.Error(1)
+}
+
+func TestHandleReprocessUpload(t *testing.T) {
+	e := echo.New()
+	logger.InitLogger("development")
+	appLogger := logger.L()
+
+	uploadID := uuid.New()
+	pgUploadID := pgtype.UUID{Bytes: uploadID, Valid: true}
+	newUploadID := pgtype.UUID{Bytes: uuid.New(), Valid: true}
+
+	mockQ := new(MockQuerier)
+	mockQ.On("GetUpload", mock.Anything, pgUploadID).
+		Return(db.GetUploadRow{ID: pgUploadID, Filename: "bc1048.csv", ReportType: "BC1048", Status: "FAILED_INVALID_FORMAT"}, nil).
+		Once()
+	mockQ.On("CreateReprocessedUpload", mock.Anything, mock.MatchedBy(func(params db.CreateReprocessedUploadParams) bool {
+		return params.SourceUploadID == pgUploadID && params.MaxAttempts == 3 && params.ProcessedByUserID == 7
+	})).Return(db.UploadJob{ID: 11, UploadID: newUploadID, Status: jobqueue.StatusQueued}, nil).Once()
+
+	rec := httptest.NewRecorder()
+	c := e.NewContext(httptest.NewRequest(http.MethodPost, "/", nil), rec)
+	c.Set("user", db.CdmsUser{ID: 7})
+	c.SetParamNames("id")
+	c.SetParamValues(uploadID.String())
+
+	queue := jobqueue.NewQueue(mockQ, nil, nil, appLogger, &config.Config{UploadMaxAttempts: 3})
+	require.NoError(t, NewUploadHandler(nil, nil, queue, nil, mockQ, appLogger).HandleReprocessUpload(c))
+
+	assert.Equal(t, http.StatusAccepted, rec.Code)
+	var body map[string]string
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+	assert.Equal(t, uuid.UUID(newUploadID.Bytes).String(), body["upload_id"])
+	assert.Equal(t, jobqueue.StatusQueued, body["status"])
+	mockQ.AssertExpectations(t)
+}
+
+func TestHandleReprocessUploadRefusals(t *testing.T) {
+	e := echo.New()
+	logger.InitLogger("development")
+	appLogger := logger.L()
+
+	tests := []struct {
+		name     string
+		upload   db.GetUploadRow
+		err      error
+		wantCode int
+	}{
+		{name: "Upload still processing", upload: db.GetUploadRow{Status: "PROCESSING"}, wantCode: http.StatusConflict},
+		{name: "Upload waiting for a merge lock", upload: db.GetUploadRow{Status: "WAITING_FOR_LOCK"}, wantCode: http.StatusConflict},
+		{name: "Unknown upload", err: pgx.ErrNoRows, wantCode: http.StatusNotFound},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			uploadID := uuid.New()
+			mockQ := new(MockQuerier)
+			mockQ.On("GetUpload", mock.Anything, pgtype.UUID{Bytes: uploadID, Valid: true}).Return(tc.upload, tc.err).Once()
+
+			c := e.NewContext(httptest.NewRequest(http.MethodPost, "/", nil), httptest.NewRecorder())
+			c.Set("user", db.CdmsUser{ID: 7})
+			c.SetParamNames("id")
+			c.SetParamValues(uploadID.String())
+
+			handler := NewUploadHandler(nil, nil, nil, nil, mockQ, appLogger)
+			httpErr := handler.HandleReprocessUpload(c).(*echo.HTTPError)
+
+			assert.Equal(t, tc.wantCode, httpErr.Code)
+			mockQ.AssertExpectations(t)
+		})
+	}
+}
@@ -264,11 +264,7 @@ func (h *UserHandler) HandleUpdateUserBusinessLines(c echo.Context) error {
 // --- Helper Functions ---
 
 func hasPermission(permissions interface{}, required string) bool {
-	perms, ok := permissions.([]string)
-	if !ok {
-		return false
-	}
-	for _, p := range perms {
+	for _, p := range toStringSlice(permissions) {
 		if p == required {
 			return true
 		}
@@ -0,0 +1,156 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jjckrbbt/cdms/backend/internal/db"
+	"github.com/labstack/echo/v4"
+)
+
+type VendorHandler struct {
+	queries db.Querier
+	logger  *slog.Logger
+}
+
+func NewVendorHandler(q db.Querier, logger *slog.Logger) *VendorHandler {
+	return &VendorHandler{
+		queries: q,
+		logger:  logger.With("component", "vendor_handler"),
+	}
+}
+
+type PaginatedVendorsResponse struct {
+	TotalCount int64               `json:"total_count"`
+	Data       []db.ListVendorsRow `json:"data"`
+}
+
+// VendorChange is one recorded change to a vendor's directory entry. Changes maps each
+// field that changed to its old and new value.
+type VendorChange struct {
+	db.ListVendorHistoryRow
+	Changes json.RawMessage `json:"changes"`
+}
+
+// VendorResponse is a vendor's directory entry with the open items the caller may see
+// and the entry's change history.
+type VendorResponse struct {
+	Vendor            db.AgencyBureau                      `json:"vendor"`
+	OpenChargebacks   []db.ActiveChargebacksWithVendorInfo `json:"open_chargebacks"`
+	OpenDelinquencies []db.ActiveNonipacWithVendorInfo     `json:"open_delinquencies"`
+	History           []VendorChange                       `json:"history"`
+}
+
+// HandleListVendors pages through the vendor directory. The search parameter narrows it
+// to vendor codes starting with, or names containing, the given text, and needs_review
+// to the placeholder vendors created for unknown vendor codes.
+func (h *VendorHandler) HandleListVendors(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	scope, err := dataScopeFromContext(c)
+	if err != nil {
+		return err
+	}
+
+	limit, err := strconv.Atoi(c.QueryParam("limit"))
+	if err != nil || limit <= 0 {
+		limit = 50
+	}
+	page, err := strconv.Atoi(c.QueryParam("page"))
+	if err != nil || page <= 0 {
+		page = 1
+	}
+	offset := (page - 1) * limit
+	needsReview, err := parseBoolQueryParam(c, "needs_review")
+	if err != nil {
+		return err
+	}
+
+	vendors, err := h.queries.ListVendors(ctx, db.ListVendorsParams{
+		AllBusinessLines: scope.AllBusinessLines,
+		BusinessLines:    scope.BusinessLines,
+		Search:           strings.TrimSpace(c.QueryParam("search")),
+		NeedsReviewOnly:  needsReview,
+		PageLimit:        int32(limit),
+		PageOffset:       int32(offset),
+	})
+	if err != nil {
+		h.logger.ErrorContext(ctx, "Failed to list vendors", "error", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to retrieve vendors")
+	}
+
+	response := PaginatedVendorsResponse{Data: vendors}
+	if response.Data == nil {
+		response.Data = []db.ListVendorsRow{}
+	}
+	if len(vendors) > 0 {
+		response.TotalCount = vendors[0].TotalCount
+	}
+	return c.JSON(http.StatusOK, response)
+}
+
+// HandleGetVendor returns a vendor's directory entry along with its open chargebacks
+// and delinquencies in the caller's business lines and the entry's change history.
+func (h *VendorHandler) HandleGetVendor(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	scope, err := dataScopeFromContext(c)
+	if err != nil {
+		return err
+	}
+
+	code := c.Param("code")
+	vendor, err := h.queries.GetVendor(ctx, code)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return echo.NewHTTPError(http.StatusNotFound, "Vendor not found")
+		}
+		h.logger.ErrorContext(ctx, "Failed to get vendor", "error", err, "vendor_code", code)
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to retrieve vendor")
+	}
+
+	response := VendorResponse{
+		Vendor:            vendor,
+		OpenChargebacks:   []db.ActiveChargebacksWithVendorInfo{},
+		OpenDelinquencies: []db.ActiveNonipacWithVendorInfo{},
+		History:           []VendorChange{},
+	}
+
+	chargebacks, err := h.queries.ListVendorOpenChargebacks(ctx, db.ListVendorOpenChargebacksParams{
+		VendorCode:       code,
+		AllBusinessLines: scope.AllBusinessLines,
+		BusinessLines:    scope.BusinessLines,
+	})
+	if err != nil {
+		h.logger.ErrorContext(ctx, "Failed to list vendor chargebacks", "error", err, "vendor_code", code)
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to retrieve vendor")
+	}
+	response.OpenChargebacks = append(response.OpenChargebacks, chargebacks...)
+
+	delinquencies, err := h.queries.ListVendorOpenDelinquencies(ctx, db.ListVendorOpenDelinquenciesParams{
+		VendorCode:       code,
+		AllBusinessLines: scope.AllBusinessLines,
+		BusinessLines:    scope.BusinessLines,
+	})
+	if err != nil {
+		h.logger.ErrorContext(ctx, "Failed to list vendor delinquencies", "error", err, "vendor_code", code)
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to retrieve vendor")
+	}
+	response.OpenDelinquencies = append(response.OpenDelinquencies, delinquencies...)
+
+	history, err := h.queries.ListVendorHistory(ctx, code)
+	if err != nil {
+		h.logger.ErrorContext(ctx, "Failed to list vendor history", "error", err, "vendor_code", code)
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to retrieve vendor")
+	}
+	for _, row := range history {
+		response.History = append(response.History, VendorChange{ListVendorHistoryRow: row, Changes: json.RawMessage(row.Changes)})
+	}
+
+	return c.JSON(http.StatusOK, response)
+}
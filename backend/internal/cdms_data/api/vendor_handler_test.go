@@ -0,0 +1,94 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jjckrbbt/cdms/backend/internal/db"
+	"github.com/jjckrbbt/cdms/backend/internal/logger"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func (m *MockQuerier) GetVendor(ctx context.Context, vendorCode string) (db.AgencyBureau, error) {
+	args := m.Called(ctx, vendorCode)
+	return args.Get(0).(db.AgencyBureau), args.Error(1)
+}
+
+func (m *MockQuerier) ListVendorOpenChargebacks(ctx context.Context, params db.ListVendorOpenChargebacksParams) ([]db.ActiveChargebacksWithVendorInfo, error) {
+	args := m.Called(ctx, params)
+	return args.Get(0).([]db.ActiveChargebacksWithVendorInfo), args.Error(1)
+}
+
+func (m *MockQuerier) ListVendorOpenDelinquencies(ctx context.Context, params db.ListVendorOpenDelinquenciesParams) ([]db.ActiveNonipacWithVendorInfo, error) {
+	args := m.Called(ctx, params)
+	return args.Get(0).([]db.ActiveNonipacWithVendorInfo), args.Error(1)
+}
+
+func (m *MockQuerier) ListVendorHistory(ctx context.Context, vendorCode string) ([]db.ListVendorHistoryRow, error) {
+	args := m.Called(ctx, vendorCode)
+	return args.Get(0).([]db.ListVendorHistoryRow), args.Error(1)
+}
+
+func TestHandleGetVendor(t *testing.T) {
+	e := echo.New()
+	logger.InitLogger("development")
+	appLogger := logger.L()
+
+	newContext := func(code string) (echo.Context, *httptest.ResponseRecorder) {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.Set("user_context", hotelsUserContext)
+		c.SetParamNames("code")
+		c.SetParamValues(code)
+		return c, rec
+	}
+
+	t.Run("Details, scoped open items and history are returned together", func(t *testing.T) {
+		mockQ := new(MockQuerier)
+		scoped := []db.ChargebackBusinessLine{"Hotels"}
+
+		mockQ.On("GetVendor", mock.Anything, "V1234567").
+			Return(db.AgencyBureau{VendorCode: "V1234567", Name: pgtype.Text{String: "Acme Supply Co", Valid: true}}, nil).
+			Once()
+		mockQ.On("ListVendorOpenChargebacks", mock.Anything, db.ListVendorOpenChargebacksParams{VendorCode: "V1234567", BusinessLines: scoped}).
+			Return([]db.ActiveChargebacksWithVendorInfo{{ID: 7, Vendor: "V1234567"}}, nil).
+			Once()
+		mockQ.On("ListVendorOpenDelinquencies", mock.Anything, db.ListVendorOpenDelinquenciesParams{VendorCode: "V1234567", BusinessLines: scoped}).
+			Return([]db.ActiveNonipacWithVendorInfo(nil), nil).
+			Once()
+		mockQ.On("ListVendorHistory", mock.Anything, "V1234567").
+			Return([]db.ListVendorHistoryRow{{AuditID: 3, Operation: "U", Changes: []byte(`{"city":{"old":"Springfield","new":"Chicago"}}`)}}, nil).
+			Once()
+
+		c, rec := newContext("V1234567")
+		err := NewVendorHandler(mockQ, appLogger).HandleGetVendor(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Contains(t, rec.Body.String(), `"name":"Acme Supply Co"`)
+		assert.Contains(t, rec.Body.String(), `"open_chargebacks":[{"id":7`)
+		assert.Contains(t, rec.Body.String(), `"open_delinquencies":[]`)
+		assert.Contains(t, rec.Body.String(), `"changes":{"city":{"old":"Springfield","new":"Chicago"}}`)
+		mockQ.AssertExpectations(t)
+	})
+
+	t.Run("Unknown vendor code is Not Found", func(t *testing.T) {
+		mockQ := new(MockQuerier)
+		mockQ.On("GetVendor", mock.Anything, "NOPE").
+			Return(db.AgencyBureau{}, pgx.ErrNoRows).
+			Once()
+
+		c, _ := newContext("NOPE")
+		httpErr := NewVendorHandler(mockQ, appLogger).HandleGetVendor(c).(*echo.HTTPError)
+
+		assert.Equal(t, http.StatusNotFound, httpErr.Code)
+		mockQ.AssertExpectations(t)
+	})
+}
@@ -0,0 +1,262 @@
+package importer
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jjckrbbt/cdms/backend/internal/cdms_data/model"
+	"github.com/jjckrbbt/cdms/backend/internal/cdms_data/reportdef"
+	"github.com/jjckrbbt/cdms/backend/internal/db"
+)
+
+// ErrInvalidBundle marks a bundle that cannot be imported as uploaded: it is not a zip
+// or gzip file, holds no reports or too many, is too large once decompressed, or holds
+// a file whose report type is unknown.
+var ErrInvalidBundle = errors.New("invalid upload bundle")
+
+// A zip bundle may hold at most maxBundleMembers reports and maxBundleBytes once
+// decompressed. Every member is read twice, once to detect and hash it and once to
+// store it, so a small archive that decompresses to far more is refused up front.
+const (
+	maxBundleMembers = 100
+	maxBundleBytes   = 1 << 30
+)
+
+var (
+	zipMagic  = []byte("PK\x03\x04")
+	gzipMagic = []byte{0x1f, 0x8b}
+)
+
+// ReportDetector works out which report a file is from its name or contents.
+type ReportDetector func(ctx context.Context, filename string, content io.Reader) (reportdef.Definition, error)
+
+// bundleMember is one report file found in a bundle.
+type bundleMember struct {
+	filename string
+	open     func() (io.ReadCloser, error)
+	def      reportdef.Definition
+	sha256   string
+}
+
+// StoreBundle stores each report in a .zip or .gz bundle as its own upload made by user,
+// queued for processing and linked to a new upload_bundles row. Every member's report type is detected before anything is
+// stored, so a bundle with an unrecognisable file is rejected as a whole. Members are
+// numbered in the order they must be processed: entities others reference first, then
+// by report type and file name. Likewise, unless opts.Force is set, a bundle holding a
+// report already uploaded is refused as a whole with a *DuplicateUploadError.
+func (i *Importer) StoreBundle(ctx context.Context, fileHeader *multipart.FileHeader, user db.CdmsUser, detect ReportDetector, opts StoreOptions) (db.UploadBundle, []*model.Upload, error) {
+	file, err := fileHeader.Open()
+	if err != nil {
+		return db.UploadBundle{}, nil, fmt.Errorf("importer: failed to open uploaded bundle: %w", err)
+	}
+	defer file.Close()
+
+	members, err := bundleMembers(file, fileHeader.Filename, fileHeader.Size)
+	if err != nil {
+		return db.UploadBundle{}, nil, err
+	}
+
+	var problems []string
+	for idx := range members {
+		m := &members[idx]
+		content, err := m.open()
+		if err != nil {
+			return db.UploadBundle{}, nil, fmt.Errorf("%w: %s: %v", ErrInvalidBundle, m.filename, err)
+		}
+		hash := sha256.New()
+		tee := io.TeeReader(content, hash)
+		m.def, err = detect(ctx, m.filename, tee)
+		if err != nil {
+			problems = append(problems, err.Error())
+		}
+		if _, err := io.Copy(io.Discard, tee); err != nil {
+			content.Close()
+			return db.UploadBundle{}, nil, fmt.Errorf("%w: %s: %v", ErrInvalidBundle, m.filename, err)
+		}
+		content.Close()
+		m.sha256 = hex.EncodeToString(hash.Sum(nil))
+	}
+	if len(problems) > 0 {
+		return db.UploadBundle{}, nil, fmt.Errorf("%w: %s", ErrInvalidBundle, strings.Join(problems, "; "))
+	}
+
+	sort.SliceStable(members, func(a, b int) bool {
+		ma, mb := members[a], members[b]
+		if oa, ob := ma.def.Entity.LoadOrder(), mb.def.Entity.LoadOrder(); oa != ob {
+			return oa < ob
+		}
+		if ma.def.ReportType != mb.def.ReportType {
+			return ma.def.ReportType < mb.def.ReportType
+		}
+		return ma.filename < mb.filename
+	})
+
+	if !opts.DryRun && !opts.Force {
+		if err := checkBundleDuplicates(members); err != nil {
+			return db.UploadBundle{}, nil, err
+		}
+	}
+
+	// Every member is in storage before any is recorded, and the bundle is recorded with
+	// its members and their jobs in one transaction, so that a failure part way through
+	// queues none of them.
+	bundleID := uuid.New()
+	stored := make([]db.CreateUploadParams, 0, len(members))
+	for position, m := range members {
+		params, err := i.putBundleMember(ctx, m, db.CreateUploadParams{
+			ReportType:        m.def.ReportType,
+			ProcessedByUserID: user.ID,
+			SheetName:         pgtype.Text{String: opts.SheetName, Valid: opts.SheetName != ""},
+			DryRun:            opts.DryRun,
+			EncodingOverride:  pgtype.Text{String: opts.Encoding, Valid: opts.Encoding != ""},
+			DelimiterOverride: pgtype.Text{String: opts.Delimiter, Valid: opts.Delimiter != ""},
+			BundleID:          pgtype.UUID{Bytes: bundleID, Valid: true},
+			BundlePosition:    pgtype.Int4{Int32: int32(position + 1), Valid: true},
+		})
+		if err != nil {
+			for _, put := range stored {
+				i.discard(ctx, put.StorageKey)
+			}
+			return db.UploadBundle{}, nil, err
+		}
+		stored = append(stored, params)
+	}
+
+	tx, err := i.pool.Begin(ctx)
+	if err != nil {
+		return db.UploadBundle{}, nil, fmt.Errorf("importer: failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	queries := db.New(tx)
+
+	if !opts.DryRun && !opts.Force {
+		duplicates, err := findDuplicates(ctx, queries, stored)
+		if err == nil && len(duplicates) > 0 {
+			err = &DuplicateUploadError{Duplicates: duplicates}
+		}
+		if err != nil {
+			for _, put := range stored {
+				i.discard(ctx, put.StorageKey)
+			}
+			return db.UploadBundle{}, nil, err
+		}
+	}
+
+	bundle, err := queries.CreateUploadBundle(ctx, db.CreateUploadBundleParams{
+		ID:               pgtype.UUID{Bytes: bundleID, Valid: true},
+		Filename:         fileHeader.Filename,
+		UploadedByUserID: user.ID,
+	})
+	if err != nil {
+		return db.UploadBundle{}, nil, fmt.Errorf("importer: failed to record upload bundle in DB: %w", err)
+	}
+	uploads := make([]*model.Upload, 0, len(stored))
+	for _, params := range stored {
+		upload, err := i.recordUpload(ctx, queries, params)
+		if err != nil {
+			return db.UploadBundle{}, nil, err
+		}
+		uploads = append(uploads, upload)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return db.UploadBundle{}, nil, fmt.Errorf("importer: failed to commit upload bundle: %w", err)
+	}
+
+	i.logger.InfoContext(ctx, "Importer: Bundle stored", "bundle_id", bundleID, "filename", fileHeader.Filename, "members", len(uploads))
+	return bundle, uploads, nil
+}
+
+// putBundleMember writes a bundle member to blob storage; see putUpload.
+func (i *Importer) putBundleMember(ctx context.Context, m bundleMember, params db.CreateUploadParams) (db.CreateUploadParams, error) {
+	content, err := m.open()
+	if err != nil {
+		return params, fmt.Errorf("importer: failed to reopen %s: %w", m.filename, err)
+	}
+	defer content.Close()
+	return i.putUpload(ctx, content, m.filename, "", params)
+}
+
+// checkBundleDuplicates refuses a bundle holding the same report twice.
+func checkBundleDuplicates(members []bundleMember) error {
+	seen := make(map[string]string)
+	for _, m := range members {
+		key := m.def.ReportType + "/" + m.sha256
+		if first, ok := seen[key]; ok {
+			return fmt.Errorf("%w: %s and %s are the same %s report", ErrInvalidBundle, first, m.filename, m.def.ReportType)
+		}
+		seen[key] = m.filename
+	}
+	return nil
+}
+
+// bundleMembers lists the report files in a bundle. A zip yields each file it holds,
+// skipping directories and the hidden files archivers add. A gzip file is a single
+// report; it is stored compressed and decompressed when processed.
+func bundleMembers(file multipart.File, filename string, size int64) ([]bundleMember, error) {
+	head := make([]byte, 4)
+	n, _ := file.ReadAt(head, 0)
+	head = head[:n]
+
+	switch {
+	case bytes.HasPrefix(head, zipMagic):
+		zr, err := zip.NewReader(file, size)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidBundle, err)
+		}
+		var members []bundleMember
+		var total uint64
+		for _, f := range zr.File {
+			name := path.Base(f.Name)
+			if f.FileInfo().IsDir() || strings.HasPrefix(f.Name, "__MACOSX/") || strings.HasPrefix(name, ".") {
+				continue
+			}
+			if len(members) == maxBundleMembers {
+				return nil, fmt.Errorf("%w: %s holds more than %d files", ErrInvalidBundle, filename, maxBundleMembers)
+			}
+			total += f.UncompressedSize64
+			if f.UncompressedSize64 > maxBundleBytes || total > maxBundleBytes {
+				return nil, fmt.Errorf("%w: %s decompresses to more than %d MiB", ErrInvalidBundle, filename, maxBundleBytes>>20)
+			}
+			members = append(members, bundleMember{filename: name, open: openZipMember(f)})
+		}
+		if len(members) == 0 {
+			return nil, fmt.Errorf("%w: %s holds no files", ErrInvalidBundle, filename)
+		}
+		return members, nil
+
+	case bytes.HasPrefix(head, gzipMagic):
+		open := func() (io.ReadCloser, error) {
+			return io.NopCloser(io.NewSectionReader(file, 0, size)), nil
+		}
+		return []bundleMember{{filename: filename, open: open}}, nil
+	}
+	return nil, fmt.Errorf("%w: %s is not a .zip or .gz file", ErrInvalidBundle, filename)
+}
+
+// openZipMember opens a zip member for reading no further than the size its header
+// declares, which is what the bundle's size limit was checked against.
+func openZipMember(f *zip.File) func() (io.ReadCloser, error) {
+	return func() (io.ReadCloser, error) {
+		rc, err := f.Open()
+		if err != nil {
+			return nil, err
+		}
+		return struct {
+			io.Reader
+			io.Closer
+		}{io.LimitReader(rc, int64(f.UncompressedSize64)), rc}, nil
+	}
+}
@@ -0,0 +1,169 @@
+package importer
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"strings"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jjckrbbt/cdms/backend/internal/cdms_data/reportdef"
+	"github.com/jjckrbbt/cdms/backend/internal/db"
+)
+
+// memFile is an uploaded file held in memory.
+type memFile struct {
+	*bytes.Reader
+}
+
+func (memFile) Close() error { return nil }
+
+func buildZip(t *testing.T, add func(zw *zip.Writer)) memFile {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	add(zw)
+	if err := zw.Close(); err != nil {
+		t.Fatalf("failed to build zip: %v", err)
+	}
+	return memFile{bytes.NewReader(buf.Bytes())}
+}
+
+func TestBundleMembers(t *testing.T) {
+	t.Run("reports are listed", func(t *testing.T) {
+		file := buildZip(t, func(zw *zip.Writer) {
+			w, _ := zw.Create("reports/bc1048.csv")
+			w.Write([]byte("a,b\n1,2\n"))
+			zw.Create("__MACOSX/._bc1048.csv")
+		})
+		members, err := bundleMembers(file, "bundle.zip", file.Size())
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(members) != 1 || members[0].filename != "bc1048.csv" {
+			t.Fatalf("unexpected members %+v", members)
+		}
+		content, err := members[0].open()
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		defer content.Close()
+		if data, _ := io.ReadAll(content); string(data) != "a,b\n1,2\n" {
+			t.Errorf("unexpected content %q", data)
+		}
+	})
+
+	t.Run("too many files", func(t *testing.T) {
+		file := buildZip(t, func(zw *zip.Writer) {
+			for n := 0; n <= maxBundleMembers; n++ {
+				zw.Create(fmt.Sprintf("report-%d.csv", n))
+			}
+		})
+		_, err := bundleMembers(file, "bundle.zip", file.Size())
+		if !errors.Is(err, ErrInvalidBundle) {
+			t.Errorf("expected ErrInvalidBundle, got %v", err)
+		}
+	})
+
+	t.Run("too large once decompressed", func(t *testing.T) {
+		file := buildZip(t, func(zw *zip.Writer) {
+			for _, name := range []string{"bc1048.csv", "bc1300.csv"} {
+				w, err := zw.CreateRaw(&zip.FileHeader{Name: name, Method: zip.Deflate, CompressedSize64: 1, UncompressedSize64: maxBundleBytes/2 + 1})
+				if err != nil {
+					t.Fatalf("failed to add %s: %v", name, err)
+				}
+				w.Write([]byte{0})
+			}
+		})
+		_, err := bundleMembers(file, "bundle.zip", file.Size())
+		if !errors.Is(err, ErrInvalidBundle) {
+			t.Errorf("expected ErrInvalidBundle, got %v", err)
+		}
+	})
+}
+
+// fileHeader uploads content as a multipart form file.
+func fileHeader(t *testing.T, filename string, content []byte) *multipart.FileHeader {
+	t.Helper()
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	w, _ := mw.CreateFormFile("report_file", filename)
+	w.Write(content)
+	mw.Close()
+
+	form, err := multipart.NewReader(&body, mw.Boundary()).ReadForm(1 << 20)
+	if err != nil {
+		t.Fatalf("failed to read form: %v", err)
+	}
+	return form.File["report_file"][0]
+}
+
+func TestStoreBundleQueuesMembersTogether(t *testing.T) {
+	bundle := buildZip(t, func(zw *zip.Writer) {
+		for _, name := range []string{"a.csv", "b.csv"} {
+			w, _ := zw.Create(name)
+			w.Write([]byte("a,b\n1,2\n"))
+		}
+	})
+	content, _ := io.ReadAll(bundle)
+	header := fileHeader(t, "reports.zip", content)
+	bc1048, _ := reportdef.Builtin("BC1048")
+	detect := func(ctx context.Context, filename string, content io.Reader) (reportdef.Definition, error) {
+		return bc1048, nil
+	}
+	opts := StoreOptions{DryRun: true}
+
+	t.Run("bundle and every member are committed together", func(t *testing.T) {
+		tx := &fakeTx{}
+		_, uploads, err := newTestImporter(tx).StoreBundle(context.Background(), header, db.CdmsUser{ID: 7}, detect, opts)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(uploads) != 2 {
+			t.Errorf("got %d uploads, want 2", len(uploads))
+		}
+		want := "CreateUploadBundle,CreateUpload,EnqueueUploadJob,CreateUpload,EnqueueUploadJob"
+		if got := strings.Join(tx.queries, ","); got != want {
+			t.Errorf("queries = %s, want %s", got, want)
+		}
+		if !tx.committed {
+			t.Error("transaction was not committed")
+		}
+	})
+
+	t.Run("no member is queued when one cannot be", func(t *testing.T) {
+		queueErr := errors.New("connection reset")
+		tx := &fakeTx{fail: map[string]error{"EnqueueUploadJob": queueErr}}
+		_, _, err := newTestImporter(tx).StoreBundle(context.Background(), header, db.CdmsUser{ID: 7}, detect, opts)
+		if !errors.Is(err, queueErr) {
+			t.Fatalf("expected the queueing error, got %v", err)
+		}
+		if tx.committed || !tx.rolledBack {
+			t.Errorf("committed = %v, rolled back = %v; want the bundle rolled back", tx.committed, tx.rolledBack)
+		}
+	})
+	t.Run("members are checked for duplicates under their locks", func(t *testing.T) {
+		bundle := buildZip(t, func(zw *zip.Writer) {
+			for _, name := range []string{"a.csv", "b.csv"} {
+				w, _ := zw.Create(name)
+				w.Write([]byte("a,b\n" + name + ",2\n"))
+			}
+		})
+		content, _ := io.ReadAll(bundle)
+		tx := &fakeTx{fail: map[string]error{"FindUploadByContentSHA256": pgx.ErrNoRows}}
+		_, _, err := newTestImporter(tx).StoreBundle(context.Background(), fileHeader(t, "reports.zip", content), db.CdmsUser{ID: 7}, detect, StoreOptions{})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		want := "AcquireAdvisoryXactLock,AcquireAdvisoryXactLock,FindUploadByContentSHA256,FindUploadByContentSHA256," +
+			"CreateUploadBundle,CreateUpload,EnqueueUploadJob,CreateUpload,EnqueueUploadJob"
+		if got := strings.Join(tx.queries, ","); got != want {
+			t.Errorf("queries = %s, want %s", got, want)
+		}
+	})
+}
@@ -0,0 +1,109 @@
+package importer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jjckrbbt/cdms/backend/internal/db"
+)
+
+// ErrDuplicateUpload marks a file whose content was already uploaded for the same
+// report type. Errors matching it are a *DuplicateUploadError.
+var ErrDuplicateUpload = errors.New("duplicate upload")
+
+// Duplicate is a file whose content matches an earlier upload of its report type.
+type Duplicate struct {
+	Filename            string    `json:"filename"`
+	ReportType          string    `json:"report_type"`
+	ContentSHA256       string    `json:"content_sha256"`
+	DuplicateOf         uuid.UUID `json:"duplicate_of"`
+	DuplicateOfFilename string    `json:"duplicate_of_filename"`
+	DuplicateOfStatus   string    `json:"duplicate_of_status"`
+	DuplicateOfUploaded time.Time `json:"duplicate_of_uploaded_at"`
+}
+
+// DuplicateUploadError refuses one or more files as duplicates of earlier uploads.
+type DuplicateUploadError struct {
+	Duplicates []Duplicate
+}
+
+func (e *DuplicateUploadError) Error() string {
+	descriptions := make([]string, len(e.Duplicates))
+	for idx, d := range e.Duplicates {
+		descriptions[idx] = fmt.Sprintf("%s matches %s upload %s of %s (%s)", d.Filename, d.ReportType, d.DuplicateOf, d.DuplicateOfFilename, d.DuplicateOfStatus)
+	}
+	return fmt.Sprintf("%s: %s", ErrDuplicateUpload, strings.Join(descriptions, "; "))
+}
+
+func (e *DuplicateUploadError) Unwrap() error { return ErrDuplicateUpload }
+
+// findDuplicates looks for an earlier upload of the same content as each of uploads,
+// within the transaction behind queries. It first locks each report type and content
+// hash until that transaction ends, so a concurrent upload of the same report waits
+// until the caller's uploads are recorded or rolled back and cannot slip in between the
+// check and the insert. The locks are taken in key order so that two bundles sharing
+// reports cannot deadlock.
+func findDuplicates(ctx context.Context, queries *db.Queries, uploads []db.CreateUploadParams) ([]Duplicate, error) {
+	keys := make([]int64, 0, len(uploads))
+	for _, upload := range uploads {
+		keys = append(keys, uploadContentLockKey(upload.ReportType, upload.ContentSha256.String))
+	}
+	slices.Sort(keys)
+	for _, key := range slices.Compact(keys) {
+		if err := queries.AcquireAdvisoryXactLock(ctx, key); err != nil {
+			return nil, fmt.Errorf("importer: failed to lock uploaded content: %w", err)
+		}
+	}
+
+	var duplicates []Duplicate
+	for _, upload := range uploads {
+		duplicate, err := findDuplicate(ctx, queries, upload.Filename, upload.ReportType, upload.ContentSha256.String)
+		if err != nil {
+			return nil, err
+		}
+		if duplicate != nil {
+			duplicates = append(duplicates, *duplicate)
+		}
+	}
+	return duplicates, nil
+}
+
+// uploadContentLockKey is the advisory lock key for uploads of a report type with the
+// given content hash.
+func uploadContentLockKey(reportType, contentSHA256 string) int64 {
+	h := fnv.New64a()
+	h.Write([]byte("cdms:upload:" + reportType + "/" + contentSHA256))
+	return int64(h.Sum64())
+}
+
+// findDuplicate looks for an earlier upload of the same content for a report type. It
+// returns nil when there is none.
+func findDuplicate(ctx context.Context, queries *db.Queries, filename, reportType, contentSHA256 string) (*Duplicate, error) {
+	existing, err := queries.FindUploadByContentSHA256(ctx, db.FindUploadByContentSHA256Params{
+		ReportType:    reportType,
+		ContentSha256: pgtype.Text{String: contentSHA256, Valid: true},
+	})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("importer: failed to look up earlier uploads of %s: %w", filename, err)
+	}
+	return &Duplicate{
+		Filename:            filename,
+		ReportType:          reportType,
+		ContentSHA256:       contentSHA256,
+		DuplicateOf:         existing.ID.Bytes,
+		DuplicateOfFilename: existing.Filename,
+		DuplicateOfStatus:   existing.Status,
+		DuplicateOfUploaded: existing.UploadedAt.Time,
+	}, nil
+}
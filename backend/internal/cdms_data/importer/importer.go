@@ -2,111 +2,185 @@ package importer
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"io"
 	"log/slog"
 	"mime/multipart"
-	"os"
 
-	"cloud.google.com/go/storage"
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jjckrbbt/cdms/backend/internal/blobstore"
 	"github.com/jjckrbbt/cdms/backend/internal/cdms_data/model"
 	"github.com/jjckrbbt/cdms/backend/internal/config"
 	"github.com/jjckrbbt/cdms/backend/internal/database"
 	"github.com/jjckrbbt/cdms/backend/internal/db"
-	"google.golang.org/api/option"
 )
 
-type Importer struct {
-	dbClient  *database.DBClient
-	logger    *slog.Logger
-	cfg       *config.Config
-	gcsClient *storage.Client
-	gcsBucket string
+// dbPool is the database uploads are recorded in. It is satisfied by *pgxpool.Pool.
+type dbPool interface {
+	db.DBTX
+	Begin(ctx context.Context) (pgx.Tx, error)
 }
 
-func NewImporter(dbClient *database.DBClient, logger *slog.Logger, cfg *config.Config) (*Importer, error) {
-	ctx := context.Background()
-
-	var clientOptions []option.ClientOption
-	if keyFilePath := os.Getenv("GOOGLE_APPLICATION_CREDENTIALS"); keyFilePath != "" {
-		clientOptions = append(clientOptions, option.WithCredentialsFile(keyFilePath))
-		logger.Info("Importer: Using GOOGLE_APPLICATION_CREDENTIALS for GCS client initialization")
-	} else {
-		logger.Info("Importer: GOOGLE_APPLICATION_CREDENTIALS not set, relying on default GCP authentication.")
-	}
+type Importer struct {
+	pool   dbPool
+	logger *slog.Logger
+	cfg    *config.Config
+	store  blobstore.Store
+}
 
-	gcsClient, err := storage.NewClient(ctx, clientOptions...)
-	if err != nil {
-		logger.Error("Importer: Failed to create GCS client", "error", err)
-		return nil, fmt.Errorf("failed to create GCS client for importer: %w", err)
+func NewImporter(dbClient *database.DBClient, logger *slog.Logger, cfg *config.Config, store blobstore.Store) (*Importer, error) {
+	if store == nil {
+		return nil, fmt.Errorf("importer: blob store is required")
 	}
 
 	return &Importer{
-		dbClient:  dbClient,
-		logger:    logger.With("component", "file_importer"),
-		cfg:       cfg,
-		gcsClient: gcsClient,
-		gcsBucket: cfg.GCSBucketName,
+		pool:   dbClient.Pool,
+		logger: logger.With("component", "file_importer"),
+		cfg:    cfg,
+		store:  store,
 	}, nil
 }
 
-func (i *Importer) StoreFile(ctx context.Context, fileHeader *multipart.FileHeader, reportType string) (*model.Upload, error) {
-	uploadID := uuid.New()
-	gcsObjectKey := fmt.Sprintf("raw-reports/%s/%s-%s", reportType, uploadID.String(), fileHeader.Filename)
+// StoreOptions are the per-upload choices made by the uploader.
+type StoreOptions struct {
+	// SheetName selects the worksheet to process when the file is an .xlsx workbook.
+	SheetName string
+	// DryRun previews the effect of processing the file without committing it.
+	DryRun bool
+	// Encoding and Delimiter force how a delimited file is read; empty means detect.
+	Encoding  string
+	Delimiter string
+	// Force accepts a file even when the same content was already uploaded for its
+	// report type.
+	Force bool
+}
 
+// StoreFile writes the uploaded file to blob storage and records the upload as made by
+// user, queued for processing.
+func (i *Importer) StoreFile(ctx context.Context, fileHeader *multipart.FileHeader, reportType string, user db.CdmsUser, opts StoreOptions) (*model.Upload, error) {
 	file, err := fileHeader.Open()
 	if err != nil {
-		i.logger.ErrorContext(ctx, "Importer: Failed to open uploaded file", "error", err, "upload_id", uploadID)
+		i.logger.ErrorContext(ctx, "Importer: Failed to open uploaded file", "error", err)
 		return nil, fmt.Errorf("importer: failed to open uploaded file: %w", err)
 	}
 	defer file.Close()
 
-	wc := i.gcsClient.Bucket(i.gcsBucket).Object(gcsObjectKey).NewWriter(ctx)
-	wc.ContentType = fileHeader.Header.Get("Content-Type")
+	return i.storeUpload(ctx, file, fileHeader.Filename, fileHeader.Header.Get("Content-Type"), db.CreateUploadParams{
+		ReportType:        reportType,
+		ProcessedByUserID: user.ID,
+		SheetName:         pgtype.Text{String: opts.SheetName, Valid: opts.SheetName != ""},
+		DryRun:            opts.DryRun,
+		EncodingOverride:  pgtype.Text{String: opts.Encoding, Valid: opts.Encoding != ""},
+		DelimiterOverride: pgtype.Text{String: opts.Delimiter, Valid: opts.Delimiter != ""},
+	}, opts.Force)
+}
+
+// storeUpload writes one report file to blob storage and records it as a new upload,
+// queued for processing. params carries everything but the ID, storage key, filename,
+// status and content hash. Unless force is set or the upload is a dry run, a file
+// matching an earlier upload of its report type is deleted again and refused with a
+// *DuplicateUploadError.
+func (i *Importer) storeUpload(ctx context.Context, content io.Reader, filename string, contentType string, params db.CreateUploadParams, force bool) (*model.Upload, error) {
+	params, err := i.putUpload(ctx, content, filename, contentType, params)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := i.pool.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("importer: failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+	queries := db.New(tx)
+
+	if !params.DryRun {
+		duplicates, err := findDuplicates(ctx, queries, []db.CreateUploadParams{params})
+		if err != nil {
+			i.discard(ctx, params.StorageKey)
+			return nil, err
+		}
+		if len(duplicates) > 0 {
+			if !force {
+				i.discard(ctx, params.StorageKey)
+				return nil, &DuplicateUploadError{Duplicates: duplicates}
+			}
+			i.logger.WarnContext(ctx, "Importer: Storing file despite matching an earlier upload", "upload_id", uuid.UUID(params.ID.Bytes), "duplicate_of", duplicates[0].DuplicateOf, "content_sha256", params.ContentSha256.String)
+		}
+	}
 
-	if _, err := io.Copy(wc, file); err != nil {
-		wc.Close()
-		i.logger.ErrorContext(ctx, "Importer: Failed to write file content to GCS", "error", err, "object_key", gcsObjectKey)
-		return nil, fmt.Errorf("importer: failed to write file content to GCS: %w", err)
+	upload, err := i.recordUpload(ctx, queries, params)
+	if err != nil {
+		i.logger.ErrorContext(ctx, "Importer: Failed to record upload in database", "error", err, "upload_id", uuid.UUID(params.ID.Bytes))
+		return nil, err
 	}
-	if err := wc.Close(); err != nil {
-		i.logger.ErrorContext(ctx, "Importer: Failed to close GCS writer", "error", err, "object_key", gcsObjectKey)
-		return nil, fmt.Errorf("importer: failed to close GCS writer: %w", err)
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("importer: failed to commit upload: %w", err)
 	}
 
-	queries := db.New(i.dbClient.Pool)
+	i.logger.InfoContext(ctx, "Importer: File stored and upload queued", "upload_id", upload.ID, "object_key", params.StorageKey, "content_sha256", params.ContentSha256.String, "uploaded_by", params.ProcessedByUserID)
+	return upload, nil
+}
+
+// putUpload writes one report file to blob storage and fills in the ID, storage key,
+// filename, status and content hash of params to record it with. The content is hashed
+// on its way into storage.
+func (i *Importer) putUpload(ctx context.Context, content io.Reader, filename string, contentType string, params db.CreateUploadParams) (db.CreateUploadParams, error) {
+	uploadID := uuid.New()
+	objectKey := fmt.Sprintf("raw-reports/%s/%s-%s", params.ReportType, uploadID.String(), filename)
 
-	params := db.CreateUploadParams{
-		ID:                pgtype.UUID{Bytes: uploadID, Valid: true},
-		StorageKey:        gcsObjectKey,
-		Filename:          fileHeader.Filename,
-		ReportType:        reportType,
-		Status:            "UPLOADED",
-		ProcessedByUserID: 1, // Assuming a default user ID for now; this should be replaced with actual user ID logic.,
+	hash := sha256.New()
+	if err := i.store.Put(ctx, objectKey, io.TeeReader(content, hash), contentType); err != nil {
+		i.logger.ErrorContext(ctx, "Importer: Failed to write file content to blob storage", "error", err, "object_key", objectKey)
+		return params, fmt.Errorf("importer: failed to write file content to blob storage: %w", err)
 	}
 
+	params.ID = pgtype.UUID{Bytes: uploadID, Valid: true}
+	params.StorageKey = objectKey
+	params.Filename = filename
+	params.Status = "UPLOADED"
+	params.ContentSha256 = pgtype.Text{String: hex.EncodeToString(hash.Sum(nil)), Valid: true}
+	return params, nil
+}
+
+// recordUpload records a stored file as an upload and queues it for processing. Both
+// are written through the same transaction, so that an upload is never left without
+// the job that processes it.
+func (i *Importer) recordUpload(ctx context.Context, queries *db.Queries, params db.CreateUploadParams) (*model.Upload, error) {
 	createdUpload, err := queries.CreateUpload(ctx, params)
 	if err != nil {
-		i.logger.ErrorContext(ctx, "Importer: Failed to record upload in database", "error", err, "upload_id", uploadID)
 		return nil, fmt.Errorf("importer: failed to record upload in DB: %w", err)
 	}
-
-	i.logger.InfoContext(ctx, "Importer: File stored in GCS and upload record created", "upload_id", uploadID, "gcs_key", gcsObjectKey)
+	job, err := queries.EnqueueUploadJob(ctx, db.EnqueueUploadJobParams{
+		UploadID:    createdUpload.ID,
+		MaxAttempts: int32(i.cfg.UploadMaxAttempts),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("importer: failed to queue upload for processing: %w", err)
+	}
 
 	return &model.Upload{
 		ID:         createdUpload.ID.Bytes,
 		StorageKey: createdUpload.StorageKey,
 		Filename:   createdUpload.Filename,
 		ReportType: createdUpload.ReportType,
-		Status:     createdUpload.Status,
+		Status:     job.Status,
 		UploadedAt: createdUpload.UploadedAt.Time,
 	}, nil
 }
 
+// discard deletes a stored file that will not be recorded as an upload.
+func (i *Importer) discard(ctx context.Context, objectKey string) {
+	if err := i.store.Delete(ctx, objectKey); err != nil {
+		i.logger.WarnContext(ctx, "Importer: Failed to delete refused file from blob storage", "error", err, "object_key", objectKey)
+	}
+}
+
 func (i *Importer) UpdateUploadStatus(ctx context.Context, uploadID uuid.UUID, status string, errorDetails string, rowsUpserted int64, rowsRemoved int) error {
-	queries := db.New(i.dbClient.Pool)
+	queries := db.New(i.pool)
 
 	// sqlc will generate these new fields on the params struct.
 	params := db.UpdateUploadStatusParams{
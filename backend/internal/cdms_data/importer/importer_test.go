@@ -0,0 +1,139 @@
+package importer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jjckrbbt/cdms/backend/internal/blobstore"
+	"github.com/jjckrbbt/cdms/backend/internal/config"
+	"github.com/jjckrbbt/cdms/backend/internal/db"
+)
+
+// fakeTx is a transaction whose queries succeed without returning anything, except
+// those named in fail. It records how it ended.
+type fakeTx struct {
+	pgx.Tx
+	fail       map[string]error
+	queries    []string
+	committed  bool
+	rolledBack bool
+}
+
+func (f *fakeTx) run(sql string) error {
+	name := strings.Fields(sql)[2]
+	f.queries = append(f.queries, name)
+	return f.fail[name]
+}
+
+func (f *fakeTx) Exec(ctx context.Context, sql string, args ...interface{}) (pgconn.CommandTag, error) {
+	return pgconn.NewCommandTag("SELECT 1"), f.run(sql)
+}
+
+func (f *fakeTx) Query(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error) {
+	return nil, fmt.Errorf("unexpected query %s", strings.Fields(sql)[2])
+}
+
+func (f *fakeTx) QueryRow(ctx context.Context, sql string, args ...interface{}) pgx.Row {
+	return fakeRow{f.run(sql)}
+}
+
+func (f *fakeTx) Commit(ctx context.Context) error {
+	if !f.rolledBack {
+		f.committed = true
+	}
+	return nil
+}
+
+func (f *fakeTx) Rollback(ctx context.Context) error {
+	if !f.committed {
+		f.rolledBack = true
+	}
+	return nil
+}
+
+type fakeRow struct{ err error }
+
+func (r fakeRow) Scan(dest ...any) error { return r.err }
+
+// fakePool hands out a single transaction.
+type fakePool struct {
+	db.DBTX
+	tx *fakeTx
+}
+
+func (f *fakePool) Begin(ctx context.Context) (pgx.Tx, error) { return f.tx, nil }
+
+func newTestImporter(tx *fakeTx) *Importer {
+	return &Importer{
+		pool:   &fakePool{tx: tx},
+		logger: slog.New(slog.NewTextHandler(io.Discard, nil)),
+		cfg:    &config.Config{UploadMaxAttempts: 3},
+		store:  blobstore.NewMemoryStore(),
+	}
+}
+
+func TestStoreUploadQueuesUpload(t *testing.T) {
+	params := db.CreateUploadParams{ReportType: "BC1048", ProcessedByUserID: 7, DryRun: true}
+
+	t.Run("upload and job are committed together", func(t *testing.T) {
+		tx := &fakeTx{}
+		if _, err := newTestImporter(tx).storeUpload(context.Background(), strings.NewReader("a,b\n"), "bc1048.csv", "text/csv", params, false); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if want := "CreateUpload,EnqueueUploadJob"; strings.Join(tx.queries, ",") != want {
+			t.Errorf("queries = %v, want %s", tx.queries, want)
+		}
+		if !tx.committed {
+			t.Error("transaction was not committed")
+		}
+	})
+
+	t.Run("upload is not recorded when it cannot be queued", func(t *testing.T) {
+		queueErr := errors.New("connection reset")
+		tx := &fakeTx{fail: map[string]error{"EnqueueUploadJob": queueErr}}
+		_, err := newTestImporter(tx).storeUpload(context.Background(), strings.NewReader("a,b\n"), "bc1048.csv", "text/csv", params, false)
+		if !errors.Is(err, queueErr) {
+			t.Fatalf("expected the queueing error, got %v", err)
+		}
+		if tx.committed || !tx.rolledBack {
+			t.Errorf("committed = %v, rolled back = %v; want the upload rolled back", tx.committed, tx.rolledBack)
+		}
+	})
+	t.Run("duplicate check and insert happen under one lock", func(t *testing.T) {
+		tx := &fakeTx{fail: map[string]error{"FindUploadByContentSHA256": pgx.ErrNoRows}}
+		params := params
+		params.DryRun = false
+		if _, err := newTestImporter(tx).storeUpload(context.Background(), strings.NewReader("a,b\n"), "bc1048.csv", "text/csv", params, false); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if want := "AcquireAdvisoryXactLock,FindUploadByContentSHA256,CreateUpload,EnqueueUploadJob"; strings.Join(tx.queries, ",") != want {
+			t.Errorf("queries = %v, want %s", tx.queries, want)
+		}
+		if !tx.committed {
+			t.Error("transaction was not committed")
+		}
+	})
+
+	t.Run("duplicate is refused", func(t *testing.T) {
+		tx := &fakeTx{}
+		params := params
+		params.DryRun = false
+		_, err := newTestImporter(tx).storeUpload(context.Background(), strings.NewReader("a,b\n"), "bc1048.csv", "text/csv", params, false)
+		if !errors.Is(err, ErrDuplicateUpload) {
+			t.Fatalf("expected ErrDuplicateUpload, got %v", err)
+		}
+		if want := "AcquireAdvisoryXactLock,FindUploadByContentSHA256"; strings.Join(tx.queries, ",") != want {
+			t.Errorf("queries = %v, want %s", tx.queries, want)
+		}
+		if tx.committed {
+			t.Error("duplicate upload was committed")
+		}
+	})
+}
@@ -0,0 +1,430 @@
+package jobqueue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jjckrbbt/cdms/backend/internal/cdms_data/processor"
+	"github.com/jjckrbbt/cdms/backend/internal/cdms_data/progress"
+	"github.com/jjckrbbt/cdms/backend/internal/config"
+	"github.com/jjckrbbt/cdms/backend/internal/db"
+)
+
+// Job states stored in upload_jobs.status. While a job is in flight the matching
+// upload carries the same QUEUED/PROCESSING/RETRYING status, or WAITING_FOR_LOCK while
+// its merge waits for another of the same reporting source; once it finishes the
+// upload takes the processor's result status instead.
+const (
+	StatusQueued     = "QUEUED"
+	StatusProcessing = "PROCESSING"
+	StatusRetrying   = "RETRYING"
+	StatusSucceeded  = "SUCCEEDED"
+	StatusFailed     = "FAILED"
+	StatusCancelled  = "CANCELLED"
+)
+
+// ErrNotCancellable marks an upload that is neither waiting to be processed nor being
+// processed, so there is nothing left to cancel.
+var ErrNotCancellable = errors.New("upload cannot be cancelled")
+
+const (
+	pollInterval   = 5 * time.Second
+	processTimeout = 10 * time.Minute
+	maxRetryDelay  = 30 * time.Minute
+)
+
+// FileProcessor is the part of processor.Processor the queue drives. A new upload is
+// staged for approval from its stored file; an approved upload has its staged rows merged.
+type FileProcessor interface {
+	ProcessFileFromCloudStorage(ctx context.Context, uploadID string, storageKey string, reportType string, opts processor.FileOptions) *processor.ProcessingResult
+	MergeApprovedUpload(ctx context.Context, uploadID string, reportType string, opts processor.FileOptions) *processor.ProcessingResult
+}
+
+// EventSource delivers the events published for an upload by any server instance; the
+// queue watches them for requests to cancel the job it is running. It is satisfied by
+// *progress.Hub.
+type EventSource interface {
+	Subscribe(uploadID uuid.UUID) (<-chan progress.Event, func())
+}
+
+// Queue is a Postgres-backed work queue for uploaded report files. Jobs survive
+// restarts: a job whose worker stops heartbeating is reclaimed once its lease expires.
+type Queue struct {
+	queries      db.Querier
+	processor    FileProcessor
+	events       EventSource
+	logger       *slog.Logger
+	workers      int
+	maxAttempts  int
+	retryBackoff time.Duration
+	lease        time.Duration
+	workerPrefix string
+	wake         chan struct{}
+	wg           sync.WaitGroup
+}
+
+// NewQueue creates a queue. events may be nil, in which case a cancellation request is
+// only noticed at the job's next heartbeat.
+func NewQueue(queries db.Querier, proc FileProcessor, events EventSource, logger *slog.Logger, cfg *config.Config) *Queue {
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "cdms"
+	}
+
+	return &Queue{
+		queries:      queries,
+		processor:    proc,
+		events:       events,
+		logger:       logger.With("component", "upload_job_queue"),
+		workers:      cfg.UploadWorkers,
+		maxAttempts:  cfg.UploadMaxAttempts,
+		retryBackoff: cfg.UploadRetryBackoff,
+		lease:        cfg.UploadJobLease,
+		workerPrefix: fmt.Sprintf("%s-%d", hostname, os.Getpid()),
+		wake:         make(chan struct{}, 1),
+	}
+}
+
+// MaxAttempts is how many times a job is attempted before its upload fails, for
+// queries that queue an upload in the same statement that readies it.
+func (q *Queue) MaxAttempts() int32 {
+	return int32(q.maxAttempts)
+}
+
+// Notify nudges an idle worker to look for jobs, e.g. once a transaction that queued an
+// upload has committed. Workers otherwise find it at their next poll.
+func (q *Queue) Notify() {
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Cancel stops an upload before it is committed. An upload still waiting for a worker
+// is cancelled at once and Cancel reports true. For an upload being processed, the
+// worker holding its job is asked to stop, on whichever instance it runs, and records
+// the cancellation itself once processing has rolled back; if its work commits first,
+// the upload keeps its result.
+func (q *Queue) Cancel(ctx context.Context, uploadID uuid.UUID, userID int64) (bool, error) {
+	pgUploadID := pgtype.UUID{Bytes: uploadID, Valid: true}
+
+	_, err := q.queries.CancelWaitingUpload(ctx, db.CancelWaitingUploadParams{
+		CancelledBy: userID,
+		ID:          pgUploadID,
+	})
+	if err == nil {
+		return true, nil
+	}
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return false, fmt.Errorf("failed to cancel upload %s: %w", uploadID, err)
+	}
+
+	_, err = q.queries.RequestUploadJobCancel(ctx, db.RequestUploadJobCancelParams{
+		RequestedBy: userID,
+		UploadID:    pgUploadID,
+	})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return false, ErrNotCancellable
+		}
+		return false, fmt.Errorf("failed to request cancellation of upload %s: %w", uploadID, err)
+	}
+	return false, nil
+}
+
+// Start launches the worker goroutines. Workers stop claiming new jobs once ctx is
+// cancelled; a job already in flight runs to completion on its own context.
+func (q *Queue) Start(ctx context.Context) {
+	for n := 1; n <= q.workers; n++ {
+		workerID := fmt.Sprintf("%s-%d", q.workerPrefix, n)
+		q.wg.Add(1)
+		go func() {
+			defer q.wg.Done()
+			q.runWorker(ctx, workerID)
+		}()
+	}
+	q.logger.Info("Upload job workers started", "workers", q.workers, "max_attempts", q.maxAttempts, "lease", q.lease)
+}
+
+// Wait blocks until every worker has exited.
+func (q *Queue) Wait() {
+	q.wg.Wait()
+}
+
+func (q *Queue) runWorker(ctx context.Context, workerID string) {
+	logger := q.logger.With("worker_id", workerID)
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	for {
+		// Drain the queue before going back to sleep.
+		for ctx.Err() == nil {
+			claimed, err := q.claimAndRun(ctx, workerID)
+			if err != nil {
+				logger.ErrorContext(ctx, "Failed to claim upload job", "error", err)
+				break
+			}
+			if !claimed {
+				break
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			logger.Info("Upload job worker stopping")
+			return
+		case <-ticker.C:
+		case <-q.wake:
+		}
+	}
+}
+
+// claimAndRun claims one job and processes it. It reports false when no job was runnable.
+func (q *Queue) claimAndRun(ctx context.Context, workerID string) (bool, error) {
+	job, err := q.queries.ClaimUploadJob(ctx, db.ClaimUploadJobParams{
+		WorkerID:     workerID,
+		LeaseSeconds: q.leaseSeconds(),
+	})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	q.runJob(workerID, job)
+	return true, nil
+}
+
+func (q *Queue) runJob(workerID string, job db.ClaimUploadJobRow) {
+	uploadID := uuid.UUID(job.UploadID.Bytes).String()
+	logger := q.logger.With("worker_id", workerID, "job_id", job.ID, "upload_id", uploadID, "attempt", job.Attempts)
+
+	procCtx, procCancel := context.WithTimeout(context.Background(), processTimeout)
+	defer procCancel()
+
+	// The job's previous worker was asked to cancel it and died before recording that.
+	if job.CancelRequested {
+		logger.InfoContext(procCtx, "Upload job was cancelled before it was reclaimed")
+		q.finishCancelled(procCtx, logger, job)
+		return
+	}
+
+	// A job claimed past its last attempt was orphaned mid-run by a worker that died.
+	if int(job.Attempts) > int(job.MaxAttempts) {
+		logger.ErrorContext(procCtx, "Upload job abandoned after exhausting its attempts")
+		q.finish(procCtx, logger, job, &processor.ProcessingResult{
+			Status: "FAILED_GENERIC",
+			Error:  fmt.Errorf("processing did not finish after %d attempts", job.MaxAttempts),
+		}, StatusFailed)
+		return
+	}
+
+	logger.InfoContext(procCtx, "Processing upload job", "report_type", job.ReportType, "approved", job.Approved)
+
+	cancelRequested := make(chan struct{})
+	var cancelOnce sync.Once
+	requestCancel := func() {
+		cancelOnce.Do(func() {
+			logger.InfoContext(procCtx, "Cancellation requested; stopping upload job")
+			close(cancelRequested)
+			procCancel()
+		})
+	}
+	if q.events != nil {
+		events, unsubscribe := q.events.Subscribe(uuid.UUID(job.UploadID.Bytes))
+		defer unsubscribe()
+		go watchForCancel(procCtx, events, requestCancel)
+	}
+
+	leaseLost := make(chan struct{})
+	heartbeatDone := make(chan struct{})
+	go func() {
+		defer close(heartbeatDone)
+		q.heartbeat(procCtx, procCancel, requestCancel, leaseLost, workerID, job.ID, logger)
+	}()
+
+	opts := processor.FileOptions{
+		SheetName:           job.SheetName.String,
+		DryRun:              job.DryRun,
+		OverrideSanityCheck: job.SanityOverride,
+		Encoding:            job.EncodingOverride.String,
+		Delimiter:           job.DelimiterOverride.String,
+		UploadedBy:          job.ProcessedByUserID,
+	}
+	var result *processor.ProcessingResult
+	if job.Approved {
+		result = q.processor.MergeApprovedUpload(procCtx, uploadID, job.ReportType, opts)
+	} else {
+		result = q.processor.ProcessFileFromCloudStorage(procCtx, uploadID, job.StorageKey, job.ReportType, opts)
+	}
+	procCancel()
+	<-heartbeatDone
+
+	select {
+	case <-leaseLost:
+		// Another worker owns the job now; it records the outcome.
+		logger.WarnContext(context.Background(), "Lease lost while processing; abandoning result", "status", result.Status)
+		return
+	default:
+	}
+
+	ctx := context.Background()
+	select {
+	case <-cancelRequested:
+		if result.Error != nil {
+			// Processing stopped before committing, so its transaction rolled back.
+			logger.InfoContext(ctx, "Upload job cancelled", "stopped_with", result.Error)
+			q.finishCancelled(ctx, logger, job)
+			return
+		}
+		logger.WarnContext(ctx, "Cancellation requested after the upload was committed; keeping its result", "status", result.Status)
+	default:
+	}
+
+	switch {
+	case result.Error == nil:
+		logger.InfoContext(ctx, "Upload job completed",
+			"final_status", result.Status,
+			"rows_upserted", result.RowsUpserted,
+			"rows_removed", result.RowsRemoved)
+		q.finish(ctx, logger, job, result, StatusSucceeded)
+	case isRetryable(result.Status) && job.Attempts < job.MaxAttempts:
+		delay := retryDelay(q.retryBackoff, int(job.Attempts))
+		logger.WarnContext(ctx, "Upload job failed; will retry", "status", result.Status, "error", result.Error, "retry_in", delay)
+		err := q.queries.RetryUploadJob(ctx, db.RetryUploadJobParams{
+			RunAfter:  pgtype.Timestamptz{Time: time.Now().Add(delay), Valid: true},
+			LastError: pgtype.Text{String: result.Error.Error(), Valid: true},
+			ID:        job.ID,
+		})
+		if err != nil {
+			logger.ErrorContext(ctx, "Failed to schedule upload job retry", "error", err)
+		}
+	default:
+		logger.ErrorContext(ctx, "Upload job failed", "final_status", result.Status, "error", result.Error)
+		q.finish(ctx, logger, job, result, StatusFailed)
+	}
+}
+
+// finish records the processor's result on the upload and closes out the job.
+func (q *Queue) finish(ctx context.Context, logger *slog.Logger, job db.ClaimUploadJobRow, result *processor.ProcessingResult, jobStatus string) {
+	var errorMsg string
+	if result.Error != nil {
+		errorMsg = result.Error.Error()
+	}
+
+	err := q.queries.UpdateUploadStatus(ctx, db.UpdateUploadStatusParams{
+		ID:           job.UploadID,
+		Status:       result.Status,
+		ErrorDetails: pgtype.Text{String: errorMsg, Valid: errorMsg != ""},
+		RowsUpserted: pgtype.Int4{Int32: int32(result.RowsUpserted), Valid: true},
+		RowsRemoved:  pgtype.Int4{Int32: int32(result.RowsRemoved), Valid: true},
+	})
+	if err != nil {
+		logger.ErrorContext(ctx, "FATAL: Could not update final upload status in DB", "update_error", err)
+		return
+	}
+
+	err = q.queries.FinishUploadJob(ctx, db.FinishUploadJobParams{
+		Status:    jobStatus,
+		LastError: pgtype.Text{String: errorMsg, Valid: errorMsg != ""},
+		ID:        job.ID,
+	})
+	if err != nil {
+		logger.ErrorContext(ctx, "Failed to mark upload job finished", "error", err)
+	}
+}
+
+// finishCancelled closes out a job that stopped because a user cancelled its upload.
+func (q *Queue) finishCancelled(ctx context.Context, logger *slog.Logger, job db.ClaimUploadJobRow) {
+	if err := q.queries.FinishCancelledUploadJob(ctx, job.ID); err != nil {
+		logger.ErrorContext(ctx, "FATAL: Could not record upload cancellation in DB", "error", err)
+	}
+}
+
+// watchForCancel calls requestCancel when a cancel event arrives, until ctx is done.
+func watchForCancel(ctx context.Context, events <-chan progress.Event, requestCancel func()) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event := <-events:
+			if event.Type == progress.TypeCancel {
+				requestCancel()
+				return
+			}
+		}
+	}
+}
+
+// heartbeat renews the job's lease until ctx is done. If the lease can no longer be
+// renewed (it expired and another worker reclaimed the job) processing is cancelled.
+// It also catches cancellation requests whose event this instance missed.
+func (q *Queue) heartbeat(ctx context.Context, cancel context.CancelFunc, requestCancel func(), leaseLost chan<- struct{}, workerID string, jobID int64, logger *slog.Logger) {
+	ticker := time.NewTicker(time.Duration(q.leaseSeconds()) * time.Second / 3)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		cancelRequested, err := q.queries.HeartbeatUploadJob(ctx, db.HeartbeatUploadJobParams{
+			LeaseSeconds: q.leaseSeconds(),
+			ID:           jobID,
+			WorkerID:     workerID,
+		})
+		if errors.Is(err, pgx.ErrNoRows) {
+			close(leaseLost)
+			cancel()
+			return
+		}
+		if err != nil {
+			if ctx.Err() == nil {
+				logger.WarnContext(ctx, "Upload job heartbeat failed", "error", err)
+			}
+			continue
+		}
+		if cancelRequested {
+			requestCancel()
+		}
+	}
+}
+
+func (q *Queue) leaseSeconds() int32 {
+	secs := int32(q.lease / time.Second)
+	if secs < 1 {
+		secs = 1
+	}
+	return secs
+}
+
+// isRetryable reports whether a processing status may be transient. Format, header,
+// sanity check and unknown vendor failures will fail the same way on every attempt, so
+// they are final.
+func isRetryable(status string) bool {
+	return status == "FAILED_GENERIC"
+}
+
+// retryDelay is the backoff before retrying after the given (1-based) attempt:
+// base, 2*base, 4*base, ... capped at maxRetryDelay.
+func retryDelay(base time.Duration, attempt int) time.Duration {
+	delay := base
+	for i := 1; i < attempt; i++ {
+		delay *= 2
+		if delay >= maxRetryDelay {
+			return maxRetryDelay
+		}
+	}
+	return delay
+}
@@ -0,0 +1,267 @@
+package jobqueue
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jjckrbbt/cdms/backend/internal/cdms_data/processor"
+	"github.com/jjckrbbt/cdms/backend/internal/cdms_data/progress"
+	"github.com/jjckrbbt/cdms/backend/internal/config"
+	"github.com/jjckrbbt/cdms/backend/internal/db"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeQuerier struct {
+	db.Querier
+	retried   []db.RetryUploadJobParams
+	uploads   []db.UpdateUploadStatusParams
+	finished  []db.FinishUploadJobParams
+	cancelled []int64
+}
+
+func (f *fakeQuerier) RetryUploadJob(ctx context.Context, arg db.RetryUploadJobParams) error {
+	f.retried = append(f.retried, arg)
+	return nil
+}
+
+func (f *fakeQuerier) UpdateUploadStatus(ctx context.Context, arg db.UpdateUploadStatusParams) error {
+	f.uploads = append(f.uploads, arg)
+	return nil
+}
+
+func (f *fakeQuerier) FinishUploadJob(ctx context.Context, arg db.FinishUploadJobParams) error {
+	f.finished = append(f.finished, arg)
+	return nil
+}
+
+func (f *fakeQuerier) FinishCancelledUploadJob(ctx context.Context, id int64) error {
+	f.cancelled = append(f.cancelled, id)
+	return nil
+}
+
+type fakeProcessor struct {
+	result *processor.ProcessingResult
+	// run, when set, stands in for processing the file.
+	run    func(ctx context.Context) *processor.ProcessingResult
+	calls  int
+	merges int
+}
+
+func (f *fakeProcessor) ProcessFileFromCloudStorage(ctx context.Context, uploadID string, storageKey string, reportType string, opts processor.FileOptions) *processor.ProcessingResult {
+	f.calls++
+	if f.run != nil {
+		return f.run(ctx)
+	}
+	return f.result
+}
+
+func (f *fakeProcessor) MergeApprovedUpload(ctx context.Context, uploadID string, reportType string, opts processor.FileOptions) *processor.ProcessingResult {
+	f.merges++
+	return f.result
+}
+
+// fakeEvents hands every subscriber the same channel.
+type fakeEvents struct {
+	ch chan progress.Event
+}
+
+func (f *fakeEvents) Subscribe(uploadID uuid.UUID) (<-chan progress.Event, func()) {
+	return f.ch, func() {}
+}
+
+func newTestQueue(q db.Querier, proc FileProcessor) *Queue {
+	cfg := &config.Config{
+		UploadWorkers:      1,
+		UploadMaxAttempts:  3,
+		UploadRetryBackoff: 30 * time.Second,
+		UploadJobLease:     time.Minute,
+	}
+	return NewQueue(q, proc, nil, slog.New(slog.NewTextHandler(io.Discard, nil)), cfg)
+}
+
+func testJob(attempts int32) db.ClaimUploadJobRow {
+	return db.ClaimUploadJobRow{
+		ID:          7,
+		UploadID:    pgtype.UUID{Bytes: uuid.New(), Valid: true},
+		Attempts:    attempts,
+		MaxAttempts: 3,
+		StorageKey:  "raw-reports/BC1048/x.csv",
+		ReportType:  "BC1048",
+	}
+}
+
+func TestRunJob(t *testing.T) {
+	testCases := []struct {
+		name            string
+		attempts        int32
+		approved        bool
+		result          *processor.ProcessingResult
+		expectRetry     bool
+		expectJobStatus string
+		expectUpload    string
+		expectProcessed bool
+		expectMerged    bool
+	}{
+		{
+			name:            "Success finishes the job",
+			attempts:        1,
+			result:          &processor.ProcessingResult{Status: "COMPLETE", RowsUpserted: 10},
+			expectJobStatus: StatusSucceeded,
+			expectUpload:    "COMPLETE",
+			expectProcessed: true,
+		},
+		{
+			name:            "New upload is staged for approval",
+			attempts:        1,
+			result:          &processor.ProcessingResult{Status: "STAGED", RowsRemoved: 2},
+			expectJobStatus: StatusSucceeded,
+			expectUpload:    "STAGED",
+			expectProcessed: true,
+		},
+		{
+			name:            "Approved upload is merged from its staged rows",
+			attempts:        1,
+			approved:        true,
+			result:          &processor.ProcessingResult{Status: "COMPLETE", RowsUpserted: 10},
+			expectJobStatus: StatusSucceeded,
+			expectUpload:    "COMPLETE",
+			expectMerged:    true,
+		},
+		{
+			name:            "Transient failure is retried",
+			attempts:        1,
+			result:          &processor.ProcessingResult{Status: "FAILED_GENERIC", Error: errors.New("connection reset")},
+			expectRetry:     true,
+			expectProcessed: true,
+		},
+		{
+			name:            "Transient failure on the last attempt is final",
+			attempts:        3,
+			result:          &processor.ProcessingResult{Status: "FAILED_GENERIC", Error: errors.New("connection reset")},
+			expectJobStatus: StatusFailed,
+			expectUpload:    "FAILED_GENERIC",
+			expectProcessed: true,
+		},
+		{
+			name:            "Header mismatch is not retried",
+			attempts:        1,
+			result:          &processor.ProcessingResult{Status: "FAILED_HEADERS_MISMATCH", Error: errors.New("header validation failed")},
+			expectJobStatus: StatusFailed,
+			expectUpload:    "FAILED_HEADERS_MISMATCH",
+			expectProcessed: true,
+		},
+		{
+			name:            "Unknown vendor codes fail the merge without retrying",
+			attempts:        1,
+			approved:        true,
+			result:          &processor.ProcessingResult{Status: "FAILED_UNKNOWN_VENDORS", Error: errors.New("unknown vendor codes: V1, V2")},
+			expectJobStatus: StatusFailed,
+			expectUpload:    "FAILED_UNKNOWN_VENDORS",
+			expectMerged:    true,
+		},
+		{
+			name:            "Orphaned job past its attempts fails without processing",
+			attempts:        4,
+			expectJobStatus: StatusFailed,
+			expectUpload:    "FAILED_GENERIC",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			q := &fakeQuerier{}
+			proc := &fakeProcessor{result: tc.result}
+			queue := newTestQueue(q, proc)
+
+			job := testJob(tc.attempts)
+			job.Approved = tc.approved
+			queue.runJob("worker-1", job)
+
+			assert.Equal(t, tc.expectProcessed, proc.calls == 1)
+			assert.Equal(t, tc.expectMerged, proc.merges == 1)
+			if tc.expectRetry {
+				assert.Len(t, q.retried, 1)
+				assert.Empty(t, q.finished)
+				assert.Empty(t, q.uploads)
+				return
+			}
+			assert.Empty(t, q.retried)
+			if assert.Len(t, q.finished, 1) && assert.Len(t, q.uploads, 1) {
+				assert.Equal(t, tc.expectJobStatus, q.finished[0].Status)
+				assert.Equal(t, tc.expectUpload, q.uploads[0].Status)
+				if tc.result != nil && tc.result.Error != nil {
+					assert.Equal(t, tc.result.Error.Error(), q.uploads[0].ErrorDetails.String)
+				}
+			}
+		})
+	}
+}
+
+func TestRunJobCancelled(t *testing.T) {
+	t.Run("Cancel event stops processing and cancels the upload", func(t *testing.T) {
+		q := &fakeQuerier{}
+		events := &fakeEvents{ch: make(chan progress.Event, 1)}
+		proc := &fakeProcessor{run: func(ctx context.Context) *processor.ProcessingResult {
+			events.ch <- progress.Event{Type: progress.TypeCancel}
+			<-ctx.Done()
+			return &processor.ProcessingResult{Status: "FAILED_GENERIC", Error: ctx.Err()}
+		}}
+		queue := newTestQueue(q, proc)
+		queue.events = events
+
+		job := testJob(1)
+		queue.runJob("worker-1", job)
+
+		assert.Equal(t, []int64{job.ID}, q.cancelled)
+		assert.Empty(t, q.retried)
+		assert.Empty(t, q.finished)
+		assert.Empty(t, q.uploads)
+	})
+
+	t.Run("Cancel after commit keeps the result", func(t *testing.T) {
+		q := &fakeQuerier{}
+		events := &fakeEvents{ch: make(chan progress.Event, 1)}
+		proc := &fakeProcessor{run: func(ctx context.Context) *processor.ProcessingResult {
+			events.ch <- progress.Event{Type: progress.TypeCancel}
+			<-ctx.Done()
+			return &processor.ProcessingResult{Status: "COMPLETE", RowsUpserted: 10}
+		}}
+		queue := newTestQueue(q, proc)
+		queue.events = events
+
+		queue.runJob("worker-1", testJob(1))
+
+		assert.Empty(t, q.cancelled)
+		if assert.Len(t, q.finished, 1) && assert.Len(t, q.uploads, 1) {
+			assert.Equal(t, StatusSucceeded, q.finished[0].Status)
+			assert.Equal(t, "COMPLETE", q.uploads[0].Status)
+		}
+	})
+
+	t.Run("Reclaimed job whose cancellation was requested is not processed", func(t *testing.T) {
+		q := &fakeQuerier{}
+		proc := &fakeProcessor{result: &processor.ProcessingResult{Status: "COMPLETE"}}
+		queue := newTestQueue(q, proc)
+
+		job := testJob(2)
+		job.CancelRequested = true
+		queue.runJob("worker-1", job)
+
+		assert.Zero(t, proc.calls)
+		assert.Equal(t, []int64{job.ID}, q.cancelled)
+		assert.Empty(t, q.finished)
+	})
+}
+
+func TestRetryDelay(t *testing.T) {
+	assert.Equal(t, 30*time.Second, retryDelay(30*time.Second, 1))
+	assert.Equal(t, time.Minute, retryDelay(30*time.Second, 2))
+	assert.Equal(t, 2*time.Minute, retryDelay(30*time.Second, 3))
+	assert.Equal(t, maxRetryDelay, retryDelay(30*time.Second, 20))
+}
@@ -104,12 +104,22 @@ type AgencyBureau struct {
 }
 
 type RemovedRow struct {
-	ID               uuid.UUID `json:"id"`
-	UploadID         uuid.UUID `json:"upload_id"`
-	Timestamp        time.Time `json:"timestamp"`
-	ReportType       string    `json:"report_type"`
-	OriginalRowData  string    `json:"original_row_data"`
-	ReasonForRemoval string    `json:"reason_for_removal"`
+	ID               uuid.UUID         `json:"id"`
+	UploadID         uuid.UUID         `json:"upload_id"`
+	Timestamp        time.Time         `json:"timestamp"`
+	ReportType       string            `json:"report_type"`
+	OriginalRowData  string            `json:"original_row_data"`
+	ReasonForRemoval string            `json:"reason_for_removal"`
+	Issues           []ValidationIssue `json:"issues"`
+}
+
+// ValidationIssue is one reason a row was removed, in a form that can be grouped by
+// rule and column.
+type ValidationIssue struct {
+	Column   string `json:"column"`
+	RawValue string `json:"raw_value"`
+	Rule     string `json:"rule"`
+	Message  string `json:"message"`
 }
 
 type Upload struct {
@@ -0,0 +1,268 @@
+package processor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jjckrbbt/cdms/backend/internal/cdms_data/reportdef"
+	"github.com/jjckrbbt/cdms/backend/internal/db"
+)
+
+// ErrUnknownReportType marks a report type with neither a built-in nor a published definition.
+var ErrUnknownReportType = errors.New("unknown report type")
+
+// ErrDefinitionConflict marks a definition that was published concurrently or whose
+// reporting source is already used by another report type.
+var ErrDefinitionConflict = errors.New("report definition conflicts with an existing one")
+
+// uniqueViolation is the SQLSTATE raised when an insert collides with an existing key.
+const uniqueViolation = "23505"
+
+// entityLabels name each entity in rejection reasons.
+var entityLabels = map[reportdef.Entity]string{
+	reportdef.EntityChargeback:   "Chargeback",
+	reportdef.EntityNonIpac:      "NonIpac",
+	reportdef.EntityAgencyBureau: "AgencyBureau",
+}
+
+// Definition returns the current definition of a report type.
+func (p *Processor) Definition(ctx context.Context, reportType string) (reportdef.Definition, error) {
+	return loadDefinition(ctx, db.New(p.db.Pool), reportType)
+}
+
+// loadDefinition returns the latest published version of a report type's definition,
+// falling back to the one shipped with the application.
+func loadDefinition(ctx context.Context, q *db.Queries, reportType string) (reportdef.Definition, error) {
+	row, err := q.GetCurrentReportDefinition(ctx, reportType)
+	if errors.Is(err, pgx.ErrNoRows) {
+		if def, ok := reportdef.Builtin(reportType); ok {
+			return def, nil
+		}
+		return reportdef.Definition{}, fmt.Errorf("%w: %s", ErrUnknownReportType, reportType)
+	}
+	if err != nil {
+		return reportdef.Definition{}, fmt.Errorf("failed to load %s definition: %w", reportType, err)
+	}
+	return definitionFromRow(row)
+}
+
+func definitionFromRow(row db.ReportDefinition) (reportdef.Definition, error) {
+	def, err := reportdef.Parse(row.Definition)
+	if err != nil {
+		return reportdef.Definition{}, fmt.Errorf("stored %s definition version %d: %w", row.ReportType, row.Version, err)
+	}
+	def.Version = row.Version
+	return def, nil
+}
+
+// Definitions returns the current definition of every report type, built-in ones
+// first in report type order, then any published only to the database.
+func (p *Processor) Definitions(ctx context.Context) ([]reportdef.Definition, error) {
+	rows, err := db.New(p.db.Pool).ListCurrentReportDefinitions(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list report definitions: %w", err)
+	}
+	published := make(map[string]db.ReportDefinition, len(rows))
+	for _, row := range rows {
+		published[row.ReportType] = row
+	}
+
+	defs := []reportdef.Definition{}
+	for _, reportType := range reportdef.BuiltinReportTypes() {
+		if row, ok := published[reportType]; ok {
+			def, err := definitionFromRow(row)
+			if err != nil {
+				return nil, err
+			}
+			defs = append(defs, def)
+			delete(published, reportType)
+			continue
+		}
+		def, _ := reportdef.Builtin(reportType)
+		defs = append(defs, def)
+	}
+	for _, row := range rows {
+		if _, ok := published[row.ReportType]; !ok {
+			continue
+		}
+		def, err := definitionFromRow(row)
+		if err != nil {
+			return nil, err
+		}
+		defs = append(defs, def)
+	}
+	return defs, nil
+}
+
+// PublishDefinition validates a definition and stores it as the next version of its
+// report type, superseding the current one for uploads processed from now on.
+func (p *Processor) PublishDefinition(ctx context.Context, data []byte, userID int64) (reportdef.Definition, error) {
+	def, err := reportdef.Parse(data)
+	if err != nil {
+		return reportdef.Definition{}, err
+	}
+
+	current, err := p.Definitions(ctx)
+	if err != nil {
+		return reportdef.Definition{}, err
+	}
+	def.Version = 1
+	for _, existing := range current {
+		if existing.ReportType == def.ReportType {
+			if err := reportdef.CheckSuccessor(existing, def); err != nil {
+				return reportdef.Definition{}, err
+			}
+			def.Version = existing.Version + 1
+			continue
+		}
+		if def.ReportingSource != "" && existing.ReportingSource == def.ReportingSource {
+			return reportdef.Definition{}, fmt.Errorf("%w: reporting source %s belongs to %s", ErrDefinitionConflict, def.ReportingSource, existing.ReportType)
+		}
+	}
+
+	_, err = db.New(p.db.Pool).CreateReportDefinition(ctx, db.CreateReportDefinitionParams{
+		ReportType:      def.ReportType,
+		Version:         def.Version,
+		Definition:      data,
+		CreatedByUserID: userID,
+	})
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == uniqueViolation {
+			return reportdef.Definition{}, fmt.Errorf("%w: version %d of %s was published concurrently", ErrDefinitionConflict, def.Version, def.ReportType)
+		}
+		return reportdef.Definition{}, fmt.Errorf("failed to publish %s definition: %w", def.ReportType, err)
+	}
+
+	p.logger.InfoContext(ctx, "Report definition published", "report_type", def.ReportType, "version", def.Version, "published_by", userID)
+	return def, nil
+}
+
+// convertRecord parses one report row into the staging columns of the definition's
+// entity. Every failing column is reported, joined into one error.
+func convertRecord(def reportdef.Definition, record []string, headerMap map[string]int) ([]any, error) {
+	columns := def.Entity.Columns()
+	values := make([]any, len(columns))
+	for i, column := range columns {
+		switch column {
+		case reportdef.FieldReportingSource:
+			values[i] = def.ReportingSource
+		case reportdef.FieldIsActive:
+			values[i] = true
+		}
+	}
+
+	var parseErrors []error
+	for _, col := range def.Columns {
+		if len(col.Fields) == 0 {
+			continue
+		}
+		val, err := parseColumn(col, record, headerMap)
+		if err != nil {
+			parseErrors = append(parseErrors, err)
+			continue
+		}
+		for _, field := range col.Fields {
+			values[columnIndex(columns, field)] = val
+		}
+	}
+
+	if len(parseErrors) > 0 {
+		return nil, errors.Join(parseErrors...)
+	}
+	return values, nil
+}
+
+// parseColumn reads and checks one column's value. Empty optional values are nil,
+// apart from decimals and booleans, which default to zero and false.
+func parseColumn(col reportdef.Column, record []string, headerMap map[string]int) (any, error) {
+	raw, _ := getString(record, headerMap, col.Header)
+
+	switch col.Parser {
+	case reportdef.ParserInt16:
+		if raw == "" && !col.Required {
+			return nil, nil
+		}
+		val, err := parseInt16(record, headerMap, col.Header)
+		if err != nil {
+			return nil, err
+		}
+		return val, nil
+	case reportdef.ParserDate:
+		if raw == "" && !col.Required {
+			return nil, nil
+		}
+		val, err := parseDate(record, headerMap, col.Header)
+		if err != nil {
+			return nil, err
+		}
+		return val, nil
+	case reportdef.ParserDecimal:
+		if raw == "" && col.Required {
+			return nil, missingField(col.Header)
+		}
+		val, err := parseDecimal(record, headerMap, col.Header)
+		if err != nil {
+			return nil, err
+		}
+		return val, nil
+	case reportdef.ParserBool:
+		if raw == "" && col.Required {
+			return nil, missingField(col.Header)
+		}
+		val, err := parseBool(record, headerMap, col.Header)
+		if err != nil {
+			return nil, err
+		}
+		return val, nil
+	}
+
+	if raw == "" {
+		if col.Required {
+			return nil, missingField(col.Header)
+		}
+		return nil, nil
+	}
+	if !col.IsAllowed(raw) {
+		return nil, newFieldError(col.Header, raw, RuleEnumInvalid, "invalid '%s' value: %s", col.Header, raw)
+	}
+	if !col.Match(raw) {
+		rule, hint := col.PatternRule, col.PatternHint
+		if rule == "" {
+			rule = RuleFormatInvalid
+		}
+		if hint == "" {
+			hint = col.Pattern
+		}
+		return nil, newFieldError(col.Header, raw, rule, "value for '%s' does not match required format (%s): '%s'", col.Header, hint, raw)
+	}
+	if col.MaxLength > 0 && len(raw) > col.MaxLength {
+		return nil, newFieldError(col.Header, raw, RuleMaxLength, "value for '%s' exceeds %d characters: '%s'", col.Header, col.MaxLength, raw)
+	}
+	return raw, nil
+}
+
+// businessKey identifies a converted row within its report, e.g. "BD123-1" for a
+// chargeback's BD Doc Num and AL Num.
+func businessKey(entity reportdef.Entity, values []any) string {
+	columns := entity.Columns()
+	keyFields := entity.KeyFields()
+	parts := make([]string, len(keyFields))
+	for i, field := range keyFields {
+		parts[i] = fmt.Sprint(values[columnIndex(columns, field)])
+	}
+	return strings.Join(parts, "-")
+}
+
+func columnIndex(columns []string, field string) int {
+	for i, c := range columns {
+		if c == field {
+			return i
+		}
+	}
+	return -1
+}
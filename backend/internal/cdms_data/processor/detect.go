@@ -0,0 +1,94 @@
+package processor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+
+	"github.com/jjckrbbt/cdms/backend/internal/cdms_data/reportdef"
+)
+
+// ErrReportNotDetected marks a file that matches no report definition, or more than one.
+var ErrReportNotDetected = errors.New("report type could not be detected")
+
+// DetectReport works out which report a file is. A file whose name matches exactly one
+// definition's filename pattern is that report; otherwise the definition its header
+// row fits best is used.
+func (p *Processor) DetectReport(ctx context.Context, filename string, content io.Reader) (reportdef.Definition, error) {
+	defs, err := p.Definitions(ctx)
+	if err != nil {
+		return reportdef.Definition{}, err
+	}
+
+	readHeaders := func() ([]string, error) {
+		records, closeRecords, err := p.openRecordReader(ctx, "", content, filename, FileOptions{})
+		if err != nil {
+			return nil, err
+		}
+		defer closeRecords()
+		headers, err := records.Read()
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s: error reading header row: %v", ErrReportNotDetected, filename, err)
+		}
+		return headers, nil
+	}
+	return detectReport(defs, filename, readHeaders)
+}
+
+// detectReport picks the definition a file belongs to. readHeaders is only called when
+// the file name is not enough.
+func detectReport(defs []reportdef.Definition, filename string, readHeaders func() ([]string, error)) (reportdef.Definition, error) {
+	name := path.Base(strings.TrimSuffix(filename, ".gz"))
+
+	var byName []reportdef.Definition
+	for _, def := range defs {
+		if def.MatchesFilename(name) {
+			byName = append(byName, def)
+		}
+	}
+	if len(byName) == 1 {
+		return byName[0], nil
+	}
+	candidates := defs
+	if len(byName) > 1 {
+		candidates = byName
+	}
+
+	headers, err := readHeaders()
+	if err != nil {
+		return reportdef.Definition{}, err
+	}
+
+	// The best fit is the definition whose columns are all present with the fewest
+	// headers left over or missing from its ignored columns.
+	var best []reportdef.Definition
+	bestScore := -1
+	for _, def := range candidates {
+		match := def.MatchHeaders(headers)
+		if !match.OK() {
+			continue
+		}
+		score := len(match.Unexpected) + len(match.MissingIgnored)
+		switch {
+		case bestScore == -1 || score < bestScore:
+			best, bestScore = []reportdef.Definition{def}, score
+		case score == bestScore:
+			best = append(best, def)
+		}
+	}
+
+	switch len(best) {
+	case 0:
+		return reportdef.Definition{}, fmt.Errorf("%w: %s matches no report's headers", ErrReportNotDetected, filename)
+	case 1:
+		return best[0], nil
+	}
+	types := make([]string, len(best))
+	for i, def := range best {
+		types[i] = def.ReportType
+	}
+	return reportdef.Definition{}, fmt.Errorf("%w: %s could be any of %s", ErrReportNotDetected, filename, strings.Join(types, ", "))
+}
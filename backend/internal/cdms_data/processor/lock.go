@@ -0,0 +1,124 @@
+package processor
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jjckrbbt/cdms/backend/internal/cdms_data/reportdef"
+	"github.com/jjckrbbt/cdms/backend/internal/db"
+)
+
+// vendorCodeLock names the lock every merge takes. VENDOR_CODE reports load the
+// agency_bureau table that chargeback and non-ipac rows reference, so their merges
+// take it exclusively and the others share it.
+const vendorCodeLock = "VENDOR_CODE"
+
+// mergeLock is one Postgres advisory lock a merge holds until its transaction ends.
+// Advisory locks live in the database, so they serialize merges across every server
+// instance.
+type mergeLock struct {
+	name   string
+	shared bool
+}
+
+// key is the advisory lock key for the lock's name.
+func (l mergeLock) key() int64 {
+	h := fnv.New64a()
+	h.Write([]byte("cdms:merge:" + l.name))
+	return int64(h.Sum64())
+}
+
+// mergeLocks are the locks a merge of the report takes, in the order they are taken.
+// Merging a report deactivates its reporting source's records before upserting its
+// own, so two merges of the same source must not overlap or whichever commits last
+// decides which records end up deactivated.
+func mergeLocks(def reportdef.Definition) []mergeLock {
+	if def.Entity == reportdef.EntityAgencyBureau {
+		return []mergeLock{{name: vendorCodeLock}}
+	}
+	source := def.ReportingSource
+	if source == "" {
+		source = def.ReportType
+	}
+	return []mergeLock{
+		{name: vendorCodeLock, shared: true},
+		{name: source},
+	}
+}
+
+// lockMerge takes the merge locks of the report within the transaction behind q,
+// waiting for any held by another merge. While it waits the upload shows
+// WAITING_FOR_LOCK.
+func (p *Processor) lockMerge(ctx context.Context, q *db.Queries, uploadID uuid.UUID, def reportdef.Definition) error {
+	waiting := false
+	for _, lock := range mergeLocks(def) {
+		acquired, err := tryLock(ctx, q, lock)
+		if err != nil {
+			return err
+		}
+		if acquired {
+			continue
+		}
+
+		if !waiting {
+			waiting = true
+			p.logger.InfoContext(ctx, "Waiting for another merge to finish", "upload_id", uploadID, "report_type", def.ReportType, "lock", lock.name)
+			p.setWaitingForLock(ctx, uploadID, true)
+		}
+		if lock.shared {
+			err = q.AcquireAdvisoryXactLockShared(ctx, lock.key())
+		} else {
+			err = q.AcquireAdvisoryXactLock(ctx, lock.key())
+		}
+		if err != nil {
+			return fmt.Errorf("failed to wait for %s merge lock: %w", lock.name, err)
+		}
+	}
+
+	if waiting {
+		p.logger.InfoContext(ctx, "Merge lock acquired", "upload_id", uploadID, "report_type", def.ReportType)
+		p.setWaitingForLock(ctx, uploadID, false)
+	}
+	return nil
+}
+
+// tryLockMerge takes the merge locks of the report without waiting. It reports false,
+// naming the lock, if another merge holds one of them.
+func tryLockMerge(ctx context.Context, q *db.Queries, def reportdef.Definition) (bool, string, error) {
+	for _, lock := range mergeLocks(def) {
+		acquired, err := tryLock(ctx, q, lock)
+		if err != nil || !acquired {
+			return false, lock.name, err
+		}
+	}
+	return true, "", nil
+}
+
+func tryLock(ctx context.Context, q *db.Queries, lock mergeLock) (bool, error) {
+	var acquired bool
+	var err error
+	if lock.shared {
+		acquired, err = q.TryAdvisoryXactLockShared(ctx, lock.key())
+	} else {
+		acquired, err = q.TryAdvisoryXactLock(ctx, lock.key())
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to take %s merge lock: %w", lock.name, err)
+	}
+	return acquired, nil
+}
+
+// setWaitingForLock updates the upload's status outside the merge transaction, so it
+// shows while the merge waits. Failing to update it never fails the merge.
+func (p *Processor) setWaitingForLock(ctx context.Context, uploadID uuid.UUID, waiting bool) {
+	err := db.New(p.db.Pool).SetUploadWaitingForLock(ctx, db.SetUploadWaitingForLockParams{
+		Waiting: waiting,
+		ID:      pgtype.UUID{Bytes: uploadID, Valid: true},
+	})
+	if err != nil {
+		p.logger.WarnContext(ctx, "Failed to update upload status while waiting for merge lock", "upload_id", uploadID, "waiting", waiting, "error", err)
+	}
+}
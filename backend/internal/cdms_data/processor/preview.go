@@ -0,0 +1,165 @@
+package processor
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/jjckrbbt/cdms/backend/internal/cdms_data/model"
+	"github.com/jjckrbbt/cdms/backend/internal/cdms_data/reportdef"
+	"github.com/jjckrbbt/cdms/backend/internal/db"
+)
+
+// previewSampleLimit caps how many updated and removed rows a preview lists individually.
+const previewSampleLimit = 100
+
+// Columns compared when previewing updates: the columns each upsert overwrites, plus
+// is_active so that reactivated records show up as changes.
+var (
+	chargebackCompareColumns   = []string{"reporting_source", "fund", "business_line", "region", "location_system", "program", "source_num", "agreement_num", "title", "alc", "customer_tas", "task_subtask", "class_id", "customer_name", "org_code", "document_date", "accomp_date", "assigned_rebill_drn", "chargeback_amount", "statement", "vendor", "articles_services", "action", "is_active"}
+	nonipacCompareColumns      = []string{"reporting_source", "business_line", "billed_total_amount", "principle_amount", "interest_amount", "penalty_amount", "administration_charges_amount", "debit_outstanding_amount", "credit_total_amount", "credit_outstanding_amount", "title", "document_date", "address_code", "vendor", "debt_appeal_forbearance", "statement", "vendor_code", "collection_due_date", "open_date", "is_active"}
+	agencyBureauCompareColumns = []string{"agency", "bureau_code", "agency_location_code", "vendor_address_code", "name", "address_line_1", "address_line_2", "address_line_3", "city", "state", "zip", "status", "vendor_type", "reporting_attribute", "security_org", "transmit_to_vcss_flag"}
+)
+
+// UploadPreview describes what processing an upload would change. It is computed by a
+// dry run and stored on the uploads row.
+type UploadPreview struct {
+	ReportType        string              `json:"report_type"`
+	RowsStaged        int64               `json:"rows_staged"`
+	NewRows           int64               `json:"new_rows"`
+	UpdatedRows       int64               `json:"updated_rows"`
+	UnchangedRows     int64               `json:"unchanged_rows"`
+	DeactivatedRows   int64               `json:"deactivated_rows"`
+	RemovedRows       int                 `json:"removed_rows"`
+	FieldChangeCounts map[string]int64    `json:"field_change_counts"`
+	UpdatedSample     []PreviewUpdate     `json:"updated_sample"`
+	RemovedSample     []PreviewRemovedRow `json:"removed_sample"`
+}
+
+// PreviewUpdate lists the fields that would change on one existing record, keyed by
+// column name with "old" and "new" values.
+type PreviewUpdate struct {
+	BusinessKey string          `json:"business_key"`
+	Changes     json.RawMessage `json:"changes"`
+}
+
+// PreviewRemovedRow is a row that would be written to removed_rows_log.
+type PreviewRemovedRow struct {
+	OriginalRowData  json.RawMessage         `json:"original_row_data"`
+	ReasonForRemoval string                  `json:"reason_for_removal"`
+	Issues           []model.ValidationIssue `json:"issues"`
+}
+
+func newUploadPreview(reportType string) *UploadPreview {
+	return &UploadPreview{
+		ReportType:        reportType,
+		FieldChangeCounts: map[string]int64{},
+		UpdatedSample:     []PreviewUpdate{},
+		RemovedSample:     []PreviewRemovedRow{},
+	}
+}
+
+// addRemoved records rows that would be removed, keeping the first previewSampleLimit.
+func (pv *UploadPreview) addRemoved(rows []model.RemovedRow) {
+	pv.RemovedRows += len(rows)
+	for _, row := range rows {
+		if len(pv.RemovedSample) >= previewSampleLimit {
+			return
+		}
+		pv.RemovedSample = append(pv.RemovedSample, PreviewRemovedRow{
+			OriginalRowData:  json.RawMessage(row.OriginalRowData),
+			ReasonForRemoval: row.ReasonForRemoval,
+			Issues:           row.Issues,
+		})
+	}
+}
+
+// computeMergeDiff fills in the new/updated/deactivated counts by comparing the staging
+// table with the live table. It must run after staging and before the merge.
+func (pv *UploadPreview) computeMergeDiff(ctx context.Context, q *db.Queries, def reportdef.Definition, rowsStaged int64) error {
+	pv.RowsStaged = rowsStaged
+	if rowsStaged == 0 {
+		// Nothing is deactivated or upserted when a report has no valid rows.
+		return nil
+	}
+
+	var totalUpdated int64
+
+	switch def.Entity {
+	case reportdef.EntityChargeback:
+		summary, err := q.PreviewChargebackMerge(ctx, db.ChargebackReportingSource(def.ReportingSource))
+		if err != nil {
+			return fmt.Errorf("failed to preview chargeback merge: %w", err)
+		}
+		pv.NewRows, pv.DeactivatedRows = summary.NewRows, summary.DeactivatedRows
+
+		changes, err := q.PreviewChargebackFieldChanges(ctx, chargebackCompareColumns)
+		if err != nil {
+			return fmt.Errorf("failed to preview chargeback field changes: %w", err)
+		}
+		for _, c := range changes {
+			pv.FieldChangeCounts[c.Field] = c.ChangedRows
+		}
+
+		rows, err := q.PreviewChargebackUpdates(ctx, db.PreviewChargebackUpdatesParams{CompareColumns: chargebackCompareColumns, SampleLimit: previewSampleLimit})
+		if err != nil {
+			return fmt.Errorf("failed to preview chargeback updates: %w", err)
+		}
+		for _, r := range rows {
+			pv.UpdatedSample = append(pv.UpdatedSample, PreviewUpdate{BusinessKey: r.BusinessKey, Changes: r.Changes})
+			totalUpdated = r.TotalUpdated
+		}
+
+	case reportdef.EntityNonIpac:
+		summary, err := q.PreviewNonIpacMerge(ctx, db.NonipacReportingSource(def.ReportingSource))
+		if err != nil {
+			return fmt.Errorf("failed to preview non-ipac merge: %w", err)
+		}
+		pv.NewRows, pv.DeactivatedRows = summary.NewRows, summary.DeactivatedRows
+
+		changes, err := q.PreviewNonIpacFieldChanges(ctx, nonipacCompareColumns)
+		if err != nil {
+			return fmt.Errorf("failed to preview non-ipac field changes: %w", err)
+		}
+		for _, c := range changes {
+			pv.FieldChangeCounts[c.Field] = c.ChangedRows
+		}
+
+		rows, err := q.PreviewNonIpacUpdates(ctx, db.PreviewNonIpacUpdatesParams{CompareColumns: nonipacCompareColumns, SampleLimit: previewSampleLimit})
+		if err != nil {
+			return fmt.Errorf("failed to preview non-ipac updates: %w", err)
+		}
+		for _, r := range rows {
+			pv.UpdatedSample = append(pv.UpdatedSample, PreviewUpdate{BusinessKey: r.BusinessKey, Changes: r.Changes})
+			totalUpdated = r.TotalUpdated
+		}
+
+	case reportdef.EntityAgencyBureau:
+		newRows, err := q.PreviewAgencyBureauMerge(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to preview agency bureau merge: %w", err)
+		}
+		pv.NewRows = newRows
+
+		changes, err := q.PreviewAgencyBureauFieldChanges(ctx, agencyBureauCompareColumns)
+		if err != nil {
+			return fmt.Errorf("failed to preview agency bureau field changes: %w", err)
+		}
+		for _, c := range changes {
+			pv.FieldChangeCounts[c.Field] = c.ChangedRows
+		}
+
+		rows, err := q.PreviewAgencyBureauUpdates(ctx, db.PreviewAgencyBureauUpdatesParams{CompareColumns: agencyBureauCompareColumns, SampleLimit: previewSampleLimit})
+		if err != nil {
+			return fmt.Errorf("failed to preview agency bureau updates: %w", err)
+		}
+		for _, r := range rows {
+			pv.UpdatedSample = append(pv.UpdatedSample, PreviewUpdate{BusinessKey: r.BusinessKey, Changes: r.Changes})
+			totalUpdated = r.TotalUpdated
+		}
+	}
+
+	pv.UpdatedRows = totalUpdated
+	pv.UnchangedRows = rowsStaged - pv.NewRows - pv.UpdatedRows
+	return nil
+}
@@ -1,30 +1,45 @@
 package processor
 
 import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
 	"context"
 	"encoding/csv"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"log/slog"
 	"os"
-	"regexp"
 	"strconv"
 	"strings"
 	"time"
 
-	"cloud.google.com/go/storage"
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jjckrbbt/cdms/backend/internal/blobstore"
 	"github.com/jjckrbbt/cdms/backend/internal/cdms_data/model"
+	"github.com/jjckrbbt/cdms/backend/internal/cdms_data/progress"
+	"github.com/jjckrbbt/cdms/backend/internal/cdms_data/reportdef"
 	"github.com/jjckrbbt/cdms/backend/internal/config"
 	"github.com/jjckrbbt/cdms/backend/internal/database"
 	"github.com/jjckrbbt/cdms/backend/internal/db"
+	"github.com/jjckrbbt/cdms/backend/internal/dialect"
+	"github.com/jjckrbbt/cdms/backend/internal/xlsx"
 	"github.com/shopspring/decimal"
-	"google.golang.org/api/option"
 )
 
-var alcFormatRegex = regexp.MustCompile(`^[0-9]{4,8}$`)
+// processingBatchSize bounds how many records are held in memory at once while a
+// report streams from storage into its staging table.
+const processingBatchSize = 5000
+
+// gzipMagic starts every gzip-compressed file.
+var gzipMagic = []byte{0x1f, 0x8b}
+
+// errInvalidFormat marks failures caused by the file's contents rather than the database.
+var errInvalidFormat = errors.New("invalid file format")
 
 type ProcessingResult struct {
 	Status       string
@@ -33,39 +48,18 @@ type ProcessingResult struct {
 	RowsUpserted int64
 }
 
-var ExpectedHeaders1048 = []string{"Fund", "Business Line", "Region", "Location/System", "Program", "Statement", "BD Doc Num", "AL Num", "Source Num", "Agreement Num", "Agreement Line Number", "Title", "ALC", "Customer TAS", "Task/Subtask", "Class ID", "Vendor", "Vendor Name", "Org Code", "Agency", "Bureau Code", "Chargeback Amount", "Doc Date", "Days Old", "Accomp Date", "Assigned Rebill DRN", "Articles or Services"}
-var ExpectedHeaders1300 = []string{"Fund", "Business Line", "Region", "Location/System", "Program", "Statement", "BD Doc Num", "AL Num", "Source Num", "Agreement Line Number", "Title", "ALC", "Customer TAS", "Task/Subtask", "Class ID", "Vendor", "Vendor Name", "Org Code", "Agency", "Bureau Code", "Chargeback Amount", "Doc Date", "Days Old", "Accomp Date", "Assigned Rebill DRN", "Articles or Services"}
-var ExpectedHeadersOutstandingBills = []string{"G_Inv_IPAC_Indicator", "Business_Application_Type", "Business_Application_Code", "Document_Type", "BD Doc Num", "Billing_Reference_Number", "Statement", "Requester_Servicer_Type", "GTC_Num", "G_Invoicing_Order_Number", "Order_Line_Num", "Order_Schedule_Num", "G_Invoicing_Line_Type", "Chargeback Amount", "Principal_Amount", "Interest_Amount", "Penalty_Amount", "System_Generated_Bill_Reduction_Amount", "Total_Write_Off_Amount", "Administration_Charges_Amount", "Outstanding_Amount", "Credit_Total_Amount", "Credit_Outstanding_Amount", "Title", "Doc Date", "Collection_Due_Date", "Debt_Age_Category", "User_ID", "Vendor", "Address_Code", "Vendor Name", "Business Line", "Debt_Appeal_Forebearance", "Rebill_Flag", "Selected_For_G_Inv_IPAC", "Chargeback_End_Date", "Chargeback_Age"}
-var ExpectedHeadersVendorCode = []string{"Vendor Agency Code", "Bureau Code", "Agency Location Code", "Vendor Code", "Vendor Address Code", "Name", "Address Line 1", "Address Line 2", "Address Line 3", "City", "State", "Zip", "Status", "Vendor Type", "Reporting Attribute", "Security Org", "Transmit to VCSS Flag"}
-
-var validChargebackFunds = map[string]bool{
-	"F-100": true, "F-201": true, "F-305": true, "F-410": true, "F-501": true,
-}
-var validBusinessLines = map[string]bool{
-	"Procurement": true, "Operations": true, "Research & Dev": true, "IT Services": true,
-	"Logistics": true, "Admin": true, "Cars": true, "Rent": true, "Credit": true,
-	"Hotels": true, "Grocery": true,
-}
-
 type Processor struct {
 	db           *database.DBClient
 	logger       *slog.Logger
 	cfg          *config.Config
-	gcsClient    *storage.Client
-	gcsBucket    string
+	store        blobstore.Store
 	systemUserID int64
 }
 
-func NewProcessor(dbClient *database.DBClient, logger *slog.Logger, cfg *config.Config) (*Processor, error) {
+func NewProcessor(dbClient *database.DBClient, logger *slog.Logger, cfg *config.Config, store blobstore.Store) (*Processor, error) {
 	ctx := context.Background()
-	var clientOptions []option.ClientOption
-	if keyFilePath := os.Getenv("GOOGLE_APPLICATION_CREDENTIALS"); keyFilePath != "" {
-		clientOptions = append(clientOptions, option.WithCredentialsFile(keyFilePath))
-	}
-	gcsClient, err := storage.NewClient(ctx, clientOptions...)
-	if err != nil {
-		logger.Error("Failed to create GCS client for processor", "error", err)
-		return nil, fmt.Errorf("failed to create GCS client for processor: %w", err)
+	if store == nil {
+		return nil, fmt.Errorf("processor: blob store is required")
 	}
 
 	queries := db.New(dbClient.Pool)
@@ -79,307 +73,771 @@ func NewProcessor(dbClient *database.DBClient, logger *slog.Logger, cfg *config.
 		db:           dbClient,
 		logger:       logger,
 		cfg:          cfg,
-		gcsClient:    gcsClient,
-		gcsBucket:    cfg.GCSBucketName,
+		store:        store,
 		systemUserID: systemUser.ID,
 	}, nil
 }
 
-func (p *Processor) ProcessFileFromCloudStorage(ctx context.Context, uploadID string, storageKey string, reportType string) *ProcessingResult {
+// FileOptions are per-upload processing settings.
+type FileOptions struct {
+	// SheetName selects the worksheet of an .xlsx upload; empty means the first sheet.
+	SheetName string
+	// DryRun runs the whole merge, records an UploadPreview of its effects and rolls back.
+	DryRun bool
+	// OverrideSanityCheck lets the upload through even if it fails the mass-deactivation guard.
+	OverrideSanityCheck bool
+	// Encoding and Delimiter force how a delimited upload is read instead of detecting
+	// it. They are normalised by dialect.ParseEncoding and dialect.ParseDelimiter.
+	Encoding  string
+	Delimiter string
+	// UploadedBy is the user who uploaded the file. The upload's changes are audited as
+	// the system user acting on their behalf.
+	UploadedBy int64
+}
+
+// recordReader yields one row of fields per call and io.EOF at the end. It is
+// satisfied by *csv.Reader and *xlsx.RowReader.
+type recordReader interface {
+	Read() ([]string, error)
+}
+
+func (p *Processor) ProcessFileFromCloudStorage(ctx context.Context, uploadID string, storageKey string, reportType string, opts FileOptions) *ProcessingResult {
 	procLogger := p.logger.With("upload_id", uploadID, "storage_key", storageKey, "report_type", reportType)
 	procLogger.InfoContext(ctx, "Starting asynchronous report processing from cloud storage")
 
-	reader, err := p.gcsClient.Bucket(p.gcsBucket).Object(storageKey).NewReader(ctx)
+	report := p.newProgressReporter(uploadID)
+
+	reader, err := p.store.Get(ctx, storageKey)
 	if err != nil {
-		procLogger.ErrorContext(ctx, "Failed to download file from GCS", "error", err)
-		return &ProcessingResult{Status: "FAILED_GENERIC", Error: fmt.Errorf("failed to download from GCS: %w", err)}
+		procLogger.ErrorContext(ctx, "Failed to read file from blob storage", "error", err)
+		return &ProcessingResult{Status: "FAILED_GENERIC", Error: fmt.Errorf("failed to read from blob storage: %w", err)}
 	}
 	defer reader.Close()
 
-	csvReader := csv.NewReader(reader)
-	csvReader.TrimLeadingSpace = true
+	// The file's size is only needed to report how far through it processing is.
+	var size int64
+	if info, err := p.store.Stat(ctx, storageKey); err == nil {
+		size = info.Size
+	} else {
+		procLogger.WarnContext(ctx, "Failed to get file size for progress reporting", "error", err)
+	}
+	counted := report.countBytes(reader, size)
+	report.phase(ctx, progress.PhaseDownload)
 
-	headers, err := csvReader.Read()
+	records, closeRecords, err := p.openRecordReader(ctx, uploadID, counted, storageKey, opts)
+	if err != nil {
+		procLogger.ErrorContext(ctx, "Failed to open report file", "error", err)
+		if errors.Is(err, errInvalidFormat) {
+			return &ProcessingResult{Status: "FAILED_INVALID_FORMAT", Error: err}
+		}
+		return &ProcessingResult{Status: "FAILED_GENERIC", Error: err}
+	}
+	defer closeRecords()
+
+	headers, err := records.Read()
 	if err != nil {
 		procLogger.ErrorContext(ctx, "Error reading header row", "error", err)
 		return &ProcessingResult{Status: "FAILED_INVALID_FORMAT", Error: fmt.Errorf("error reading header row: %w", err)}
 	}
-	var expectedHeaders []string
-	switch reportType {
-	case "BC1300":
-		expectedHeaders = ExpectedHeaders1300
-	case "BC1048":
-		expectedHeaders = ExpectedHeaders1048
-	case "OUTSTANDING_BILLS":
-		expectedHeaders = ExpectedHeadersOutstandingBills
-	case "VENDOR_CODE":
-		expectedHeaders = ExpectedHeadersVendorCode
-	default:
-		return &ProcessingResult{Status: "FAILED_GENERIC", Error: fmt.Errorf("unknown report type: %s", reportType)}
+	def, err := p.Definition(ctx, reportType)
+	if err != nil {
+		procLogger.ErrorContext(ctx, "Failed to load report definition", "error", err)
+		return &ProcessingResult{Status: "FAILED_GENERIC", Error: err}
+	}
+	report.phase(ctx, progress.PhaseValidate)
+	match := def.MatchHeaders(headers)
+	if storeErr := p.storeHeaderCheck(ctx, uploadID, match); storeErr != nil {
+		procLogger.ErrorContext(ctx, "Failed to store header check", "error", storeErr)
 	}
-	if !areHeadersValid(headers, expectedHeaders) {
-		err := fmt.Errorf("header validation failed")
-		procLogger.ErrorContext(ctx, err.Error(), "expected", expectedHeaders, "actual", headers)
+	if !match.OK() {
+		err := fmt.Errorf("header validation failed: missing %s", strings.Join(match.Missing, ", "))
+		procLogger.ErrorContext(ctx, err.Error(), "missing", match.Missing, "unexpected", match.Unexpected, "actual", headers)
 		return &ProcessingResult{Status: "FAILED_HEADERS_MISMATCH", Error: err}
 	}
-	procLogger.InfoContext(ctx, "Headers validated successfully")
+	if match.HasWarnings() {
+		procLogger.WarnContext(ctx, "Headers differ from report definition", "missing_ignored", match.MissingIgnored, "unexpected", match.Unexpected, "aliased", match.Aliased)
+	}
+	procLogger.InfoContext(ctx, "Headers validated successfully", "definition_version", def.Version)
 
-	headerMap := make(map[string]int)
-	for i, h := range headers {
-		headerMap[strings.TrimSpace(h)] = i
+	staged, err := p.executeStagingTransaction(ctx, uploadID, def, records, match.Positions, opts, report)
+	if staged != nil {
+		if storeErr := p.storeSanityCheck(ctx, uploadID, staged.sanity); storeErr != nil {
+			procLogger.ErrorContext(ctx, "Failed to store sanity check", "error", storeErr)
+		}
+	}
+	if err != nil {
+		switch {
+		case errors.Is(err, errInvalidFormat):
+			procLogger.ErrorContext(ctx, "Failed to read report records", "error", err)
+			return &ProcessingResult{Status: "FAILED_INVALID_FORMAT", Error: err}
+		case errors.Is(err, errSanityCheck):
+			procLogger.WarnContext(ctx, "Upload stopped by sanity check", "error", err)
+			return &ProcessingResult{Status: "FAILED_SANITY_CHECK", Error: err}
+		}
+		procLogger.ErrorContext(ctx, "Failed to execute database staging transaction", "error", err)
+		return &ProcessingResult{Status: "FAILED_GENERIC", Error: err}
+	}
+
+	preview := staged.preview
+	if opts.DryRun {
+		if err := p.storePreview(ctx, uploadID, preview); err != nil {
+			procLogger.ErrorContext(ctx, "Failed to store dry-run preview", "error", err)
+			return &ProcessingResult{Status: "FAILED_GENERIC", Error: err}
+		}
+		procLogger.InfoContext(ctx, "Dry run complete", "new_rows", preview.NewRows, "updated_rows", preview.UpdatedRows, "deactivated_rows", preview.DeactivatedRows, "removed_rows", preview.RemovedRows, "sanity_check_passed", staged.sanity.Passed)
+		return &ProcessingResult{Status: "DRY_RUN_COMPLETE", RowsRemoved: staged.rowsRemoved}
 	}
 
-	allRecords, err := csvReader.ReadAll()
+	procLogger.InfoContext(ctx, "Upload staged for approval", "rows_staged", preview.RowsStaged, "removed_rows", staged.rowsRemoved, "sanity_check_overridden", staged.sanity.Overridden)
+	return &ProcessingResult{Status: "STAGED", RowsRemoved: staged.rowsRemoved}
+}
+
+// MergeApprovedUpload merges the rows staged for an approved upload into the live
+// table and discards them.
+func (p *Processor) MergeApprovedUpload(ctx context.Context, uploadID string, reportType string, opts FileOptions) *ProcessingResult {
+	procLogger := p.logger.With("upload_id", uploadID, "report_type", reportType)
+	procLogger.InfoContext(ctx, "Merging approved upload")
+
+	merged, err := p.executeMergeTransaction(ctx, uploadID, reportType, opts, p.newProgressReporter(uploadID))
+	if merged.sanity != nil {
+		if storeErr := p.storeSanityCheck(ctx, uploadID, *merged.sanity); storeErr != nil {
+			procLogger.ErrorContext(ctx, "Failed to store sanity check", "error", storeErr)
+		}
+	}
 	if err != nil {
-		procLogger.ErrorContext(ctx, "Failed to read all CSV records", "error", err)
-		return &ProcessingResult{Status: "FAILED_INVALID_FORMAT", Error: fmt.Errorf("failed to read all CSV records: %w", err)}
+		status := mergeFailureStatus(err)
+		switch status {
+		case "FAILED_SANITY_CHECK":
+			procLogger.WarnContext(ctx, "Merge stopped by sanity check", "error", err)
+		case "FAILED_UNKNOWN_VENDORS":
+			procLogger.WarnContext(ctx, "Merge stopped by unknown vendor codes", "error", err)
+		default:
+			procLogger.ErrorContext(ctx, "Failed to execute database merge transaction", "error", err)
+		}
+		return &ProcessingResult{Status: status, Error: err}
 	}
 
-	existingChargebackSources := make(map[string]db.ChargebackReportingSource)
+	status := "COMPLETE"
+	if merged.rowsRemoved > 0 {
+		status = "COMPLETE_WITH_ISSUES"
+	}
 
-	if reportType == "BC1048" || reportType == "BC1300" {
-		bdDocNums := make([]string, 0, len(allRecords))
-		bdDocNumIndex, ok := headerMap["BD Doc Num"]
-		if !ok {
-			return &ProcessingResult{Status: "FAILED_INVALID_FORMAT", Error: errors.New("missing 'BD Doc Num' header")}
+	return &ProcessingResult{
+		Status:       status,
+		RowsRemoved:  merged.rowsRemoved,
+		RowsUpserted: merged.rowsUpserted,
+	}
+}
+
+// mergeFailureStatus is the upload status for a failed merge. Only FAILED_GENERIC is
+// retried: a sanity check or unknown vendor codes stop the merge the same way on every
+// attempt, until the upload is overridden or the vendor directory is loaded.
+func mergeFailureStatus(err error) string {
+	switch {
+	case errors.Is(err, errSanityCheck):
+		return "FAILED_SANITY_CHECK"
+	case errors.Is(err, errUnknownVendors):
+		return "FAILED_UNKNOWN_VENDORS"
+	}
+	return "FAILED_GENERIC"
+}
+
+// stagingTable is the temporary table a report's converted rows are copied into
+// before being merged into the live table.
+type stagingTable struct {
+	name      string
+	createSQL string
+	columns   []string
+}
+
+// persistSQL copies the staging table into upload_staged_rows for the upload given as $1.
+func (t stagingTable) persistSQL() string {
+	return fmt.Sprintf(`INSERT INTO upload_staged_rows (upload_id, row_num, row_data)
+SELECT $1, row_number() OVER (), to_jsonb(s) FROM %s s`, pgx.Identifier{t.name}.Sanitize())
+}
+
+// restoreSQL loads the rows persisted by persistSQL for the upload given as $1 back
+// into the staging table.
+func (t stagingTable) restoreSQL() string {
+	table := pgx.Identifier{t.name}.Sanitize()
+	return fmt.Sprintf(`INSERT INTO %s
+SELECT r.* FROM upload_staged_rows s, jsonb_populate_record(NULL::%s, s.row_data) r
+WHERE s.upload_id = $1
+ORDER BY s.row_num`, table, table)
+}
+
+// stagingTables are the staging tables of each entity. Their columns follow the
+// entity's column order in reportdef.
+var stagingTables = map[reportdef.Entity]stagingTable{
+	reportdef.EntityChargeback: {
+		name:      "temp_chargeback_staging",
+		createSQL: "CREATE TEMPORARY TABLE temp_chargeback_staging (LIKE chargeback INCLUDING DEFAULTS) ON COMMIT DROP;",
+		columns:   reportdef.EntityChargeback.Columns(),
+	},
+	reportdef.EntityNonIpac: {
+		name:      "temp_nonipac_staging",
+		createSQL: `CREATE TEMPORARY TABLE temp_nonipac_staging (LIKE "nonipac" INCLUDING DEFAULTS) ON COMMIT DROP;`,
+		columns:   reportdef.EntityNonIpac.Columns(),
+	},
+	reportdef.EntityAgencyBureau: {
+		name:      "temp_agency_bureau_staging",
+		createSQL: "CREATE TEMPORARY TABLE temp_agency_bureau_staging (LIKE agency_bureau INCLUDING DEFAULTS) ON COMMIT DROP;",
+		columns:   reportdef.EntityAgencyBureau.Columns(),
+	},
+}
+
+// openRecordReader picks a reader for the stored file: gzip-compressed files are
+// decompressed first, .xlsx workbooks are detected by their zip signature or file
+// extension, and anything else is read as delimited text in the dialect detected from
+// its first bytes, which is recorded on the upload unless uploadID is empty. Workbooks
+// are spooled to a temporary file because the zip directory sits at the end of the file.
+func (p *Processor) openRecordReader(ctx context.Context, uploadID string, src io.Reader, storageKey string, opts FileOptions) (recordReader, func(), error) {
+	buffered := bufio.NewReaderSize(src, dialect.SampleSize)
+	head, _ := buffered.Peek(4)
+
+	if bytes.HasPrefix(head, gzipMagic) {
+		gz, err := gzip.NewReader(buffered)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%w: %v", errInvalidFormat, err)
 		}
-		for _, record := range allRecords {
-			if len(record) > bdDocNumIndex {
-				bdDocNums = append(bdDocNums, record[bdDocNumIndex])
+		buffered = bufio.NewReaderSize(gz, dialect.SampleSize)
+		head, _ = buffered.Peek(4)
+		storageKey = strings.TrimSuffix(storageKey, ".gz")
+	}
+
+	if !xlsx.IsXLSX(head, storageKey) {
+		sample, _ := buffered.Peek(dialect.SampleSize)
+		d := dialect.Detect(sample, opts.Encoding, opts.Delimiter)
+		if uploadID != "" {
+			p.logger.InfoContext(ctx, "Reading report as delimited text", "upload_id", uploadID, "encoding", d.Encoding, "encoding_source", d.EncodingSource, "bom", d.BOM, "delimiter", d.Delimiter, "delimiter_source", d.DelimiterSource)
+			if err := p.storeDialect(ctx, uploadID, d); err != nil {
+				p.logger.ErrorContext(ctx, "Failed to store upload dialect", "upload_id", uploadID, "error", err)
 			}
 		}
 
-		if len(bdDocNums) > 0 {
-			queries := db.New(p.db.Pool)
-			existingRows, err := queries.GetChargebackSourcesByBDDocNums(ctx, bdDocNums)
-			if err != nil {
-				return &ProcessingResult{Status: "FAILED_GENERIC", Error: fmt.Errorf("failed to get existing chargebacks: %w", err)}
-			}
+		text, err := dialect.NewReader(buffered, d)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%w: %v", errInvalidFormat, err)
+		}
+		csvReader := csv.NewReader(text)
+		csvReader.Comma = d.Comma()
+		csvReader.TrimLeadingSpace = d.Comma() != '\t'
+		return csvReader, func() {}, nil
+	}
 
-			for _, row := range existingRows {
-				key := fmt.Sprintf("%s-%d", row.BdDocNum, row.AlNum)
-				existingChargebackSources[key] = row.ReportingSource
-			}
+	tmp, err := os.CreateTemp("", "cdms-upload-*.xlsx")
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create temp file for workbook: %w", err)
+	}
+	cleanup := func() {
+		tmp.Close()
+		os.Remove(tmp.Name())
+	}
+
+	size, err := io.Copy(tmp, buffered)
+	if err != nil {
+		cleanup()
+		return nil, nil, fmt.Errorf("failed to spool workbook: %w", err)
+	}
+
+	workbook, err := xlsx.Open(tmp, size)
+	if err != nil {
+		cleanup()
+		return nil, nil, fmt.Errorf("%w: %v", errInvalidFormat, err)
+	}
+	rows, err := workbook.Rows(opts.SheetName)
+	if err != nil {
+		cleanup()
+		return nil, nil, fmt.Errorf("%w: %v", errInvalidFormat, err)
+	}
+
+	p.logger.InfoContext(ctx, "Reading report from workbook", "storage_key", storageKey, "sheets", workbook.SheetNames(), "requested_sheet", opts.SheetName)
+	return rows, func() {
+		rows.Close()
+		cleanup()
+	}, nil
+}
+
+// beginProcessingTx opens a transaction whose changes are audited as the system user
+// on behalf of the uploader and tagged with the upload, so a merged upload can be traced
+// to who uploaded it and later rolled back.
+func (p *Processor) beginProcessingTx(ctx context.Context, uploadID uuid.UUID, uploadedBy int64) (pgx.Tx, error) {
+	tx, err := p.db.Pool.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin pgx transaction: %w", err)
+	}
+
+	setQuery := fmt.Sprintf("SET LOCAL app.user_id = %d", p.systemUserID)
+	if _, err := tx.Exec(ctx, setQuery); err != nil {
+		tx.Rollback(ctx)
+		return nil, fmt.Errorf("failed to set user for transaction: %w", err)
+	}
+	if uploadedBy != 0 {
+		setQuery = fmt.Sprintf("SET LOCAL app.on_behalf_of = %d", uploadedBy)
+		if _, err := tx.Exec(ctx, setQuery); err != nil {
+			tx.Rollback(ctx)
+			return nil, fmt.Errorf("failed to set uploader for transaction: %w", err)
 		}
 	}
+	setQuery = fmt.Sprintf("SET LOCAL app.upload_id = '%s'", uploadID)
+	if _, err := tx.Exec(ctx, setQuery); err != nil {
+		tx.Rollback(ctx)
+		return nil, fmt.Errorf("failed to set upload for transaction: %w", err)
+	}
+	return tx, nil
+}
+
+// stagingResult is what executeStagingTransaction found while staging an upload.
+type stagingResult struct {
+	preview     *UploadPreview
+	sanity      SanityCheck
+	rowsRemoved int
+}
+
+// mergeResult is the outcome of executeMergeTransaction. sanity is nil if the
+// transaction failed before the check ran.
+type mergeResult struct {
+	rowsUpserted int64
+	rowsRemoved  int
+	sanity       *SanityCheck
+}
+
+// executeStagingTransaction streams the remaining records through conversion into
+// the staging table in batches of processingBatchSize and previews the merge. Only
+// one batch of records is held in memory at a time.
+//
+// The staged rows are persisted to upload_staged_rows to await approval, along with
+// the rows removed during conversion and the preview. An upload that fails the sanity
+// check is rolled back instead, returning the check alongside errSanityCheck. For a
+// dry run the merge is run as well, so that any database errors surface, and
+// everything is rolled back. A dry run commits nothing, so it takes no merge locks.
+// Progress is reported after every batch.
+func (p *Processor) executeStagingTransaction(ctx context.Context, uploadID string, def reportdef.Definition, records recordReader, headerMap map[string]int, opts FileOptions, report *progressReporter) (*stagingResult, error) {
+	reportType := def.ReportType
+	staging, ok := stagingTables[def.Entity]
+	if !ok {
+		return nil, fmt.Errorf("unknown entity %q for report type %s", def.Entity, reportType)
+	}
+	uid, err := uuid.Parse(uploadID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid upload id %q: %w", uploadID, err)
+	}
 
-	processedChargebacks := []model.Chargeback{}
-	processedNonIpacs := []model.NonIpac{}
-	processedAgencyBureaus := []model.AgencyBureau{}
-	removedRows := []model.RemovedRow{}
-	processedKeys := make(map[string]bool)
+	tx, err := p.beginProcessingTx(ctx, uid, opts.UploadedBy)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
 
-	for _, record := range allRecords {
-		switch reportType {
-		case "BC1300", "BC1048":
-			chargeback, convErr := convertRecordToChargeback(record, headerMap, reportType)
-			if convErr != nil {
-				removedRows = append(removedRows, createRemovedRowEntry(uploadID, record, fmt.Sprintf("Data conversion/validation error: %v", convErr), reportType))
-				continue
-			}
+	q := db.New(tx)
 
-			businessKey := fmt.Sprintf("%s-%d", chargeback.BDDocNum, chargeback.ALNum)
+	if err := q.SetUploadReportDefinitionVersion(ctx, db.SetUploadReportDefinitionVersionParams{
+		ID:                      pgtype.UUID{Bytes: uid, Valid: true},
+		ReportDefinitionVersion: pgtype.Int4{Int32: def.Version, Valid: true},
+	}); err != nil {
+		return nil, fmt.Errorf("failed to record report definition version: %w", err)
+	}
 
-			if existingSource, found := existingChargebackSources[businessKey]; found {
-				if string(existingSource) != reportType {
-					reason := fmt.Sprintf("Conflict: Record exists but belongs to a different report source ('%s')", existingSource)
-					removedRows = append(removedRows, createRemovedRowEntry(uploadID, record, reason, reportType))
-					continue
-				}
-			}
+	if _, err := tx.Exec(ctx, staging.createSQL); err != nil {
+		return nil, fmt.Errorf("failed to create staging table %s: %w", staging.name, err)
+	}
 
-			if _, found := processedKeys[businessKey]; found {
-				removedRows = append(removedRows, createRemovedRowEntry(uploadID, record, "Duplicate within current report (Chargeback)", reportType))
-			} else {
-				processedChargebacks = append(processedChargebacks, chargeback)
-				processedKeys[businessKey] = true
+	preview := newUploadPreview(reportType)
+	report.phase(ctx, progress.PhaseStage)
+
+	// Business keys seen so far, for duplicate detection across batches.
+	processedKeys := make(map[string]bool)
+	var rowsStaged int64
+	var rowsRemoved int
+
+	for {
+		batchRecords, readErr := readBatch(records, processingBatchSize)
+		if len(batchRecords) > 0 {
+			batch, err := p.convertBatch(ctx, q, uploadID, def, batchRecords, headerMap, processedKeys)
+			if err != nil {
+				return nil, err
 			}
 
-		case "OUTSTANDING_BILLS":
-			nonipac, convErr := convertRecordToNonIpac(record, headerMap, reportType)
-			if convErr != nil {
-				removedRows = append(removedRows, createRemovedRowEntry(uploadID, record, fmt.Sprintf("Data conversion/validation error: %v", convErr), reportType))
-			} else {
-				businessKey := nonipac.DocumentNumber
-				if _, found := processedKeys[businessKey]; found {
-					removedRows = append(removedRows, createRemovedRowEntry(uploadID, record, "Duplicate within current report (NonIpac)", reportType))
-				} else {
-					processedNonIpacs = append(processedNonIpacs, nonipac)
-					processedKeys[businessKey] = true
+			if batch.rowCount > 0 {
+				if _, err := tx.CopyFrom(ctx, pgx.Identifier{staging.name}, staging.columns, batch.rows); err != nil {
+					return nil, fmt.Errorf("failed to stage rows into %s: %w", staging.name, err)
 				}
+				rowsStaged += int64(batch.rowCount)
 			}
-		case "VENDOR_CODE":
-			agencyBureau, convErr := convertRecordToAgencyBureau(record, headerMap)
-			if convErr != nil {
-				removedRows = append(removedRows, createRemovedRowEntry(uploadID, record, fmt.Sprintf("Data conversion/validation error: %v", convErr), reportType))
-			} else {
-				businessKey := agencyBureau.VendorCode
-				if _, found := processedKeys[businessKey]; found {
-					removedRows = append(removedRows, createRemovedRowEntry(uploadID, record, "Duplicate within current report (AgencyBureau)", reportType))
-				} else {
-					processedAgencyBureaus = append(processedAgencyBureaus, agencyBureau)
-					processedKeys[businessKey] = true
+			report.rows(ctx, len(batchRecords), batch.rowCount, len(batch.removedRows))
+
+			if len(batch.removedRows) > 0 {
+				report.phase(ctx, progress.PhaseLogRemovedRows)
+				columnNames := []string{"id", "upload_id", "timestamp", "report_type", "original_row_data", "reason_for_removal", "issues"}
+				if _, err := tx.CopyFrom(ctx, pgx.Identifier{"removed_rows_log"}, columnNames, newRemovedRowCopySource(uploadID, batch.removedRows)); err != nil {
+					return nil, fmt.Errorf("failed to log removed rows: %w", err)
 				}
+				rowsRemoved += len(batch.removedRows)
+				preview.addRemoved(batch.removedRows)
 			}
 		}
+
+		if errors.Is(readErr, io.EOF) {
+			break
+		}
+		if readErr != nil {
+			return nil, fmt.Errorf("%w: failed to read records: %v", errInvalidFormat, readErr)
+		}
+	}
+
+	p.logger.InfoContext(ctx, "Report rows staged", "upload_id", uploadID, "rows_staged", rowsStaged, "rows_removed", rowsRemoved)
+
+	if err := preview.computeMergeDiff(ctx, q, def, rowsStaged); err != nil {
+		return nil, err
 	}
 
-	rowsUpserted, err := p.executeMergeTransaction(ctx, uploadID, reportType, removedRows, processedChargebacks, processedNonIpacs, processedAgencyBureaus)
+	sanity, err := p.checkSanity(ctx, q, def, rowsStaged, opts.OverrideSanityCheck)
 	if err != nil {
-		procLogger.ErrorContext(ctx, "Failed to execute database merge transaction", "error", err)
-		return &ProcessingResult{Status: "FAILED_GENERIC", Error: err}
+		return nil, err
 	}
+	result := &stagingResult{preview: preview, sanity: sanity, rowsRemoved: rowsRemoved}
 
-	status := "COMPLETE"
-	if len(removedRows) > 0 {
-		status = "COMPLETE_WITH_ISSUES"
+	if opts.DryRun {
+		report.phase(ctx, progress.PhaseUpsert)
+		if _, err := p.mergeStaged(ctx, q, uploadID, def, rowsStaged); err != nil {
+			return nil, err
+		}
+		p.logger.InfoContext(ctx, "Dry run: rolling back merge transaction", "upload_id", uploadID)
+		return result, tx.Rollback(ctx)
 	}
 
-	return &ProcessingResult{
-		Status:       status,
-		RowsRemoved:  len(removedRows),
-		RowsUpserted: rowsUpserted,
+	if err := sanity.err(); err != nil {
+		return result, err
+	}
+
+	if _, err := tx.Exec(ctx, staging.persistSQL(), pgtype.UUID{Bytes: uid, Valid: true}); err != nil {
+		return nil, fmt.Errorf("failed to persist staged rows: %w", err)
+	}
+	previewJSON, err := json.Marshal(preview)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode preview: %w", err)
+	}
+	if err := q.MarkUploadStaged(ctx, db.MarkUploadStagedParams{
+		Preview:    previewJSON,
+		RowsStaged: pgtype.Int4{Int32: int32(rowsStaged), Valid: true},
+		ID:         pgtype.UUID{Bytes: uid, Valid: true},
+	}); err != nil {
+		return nil, fmt.Errorf("failed to mark upload staged: %w", err)
 	}
+
+	return result, tx.Commit(ctx)
 }
 
-func (p *Processor) executeMergeTransaction(ctx context.Context, uploadID string, reportType string, removedRows []model.RemovedRow, chargebacks []model.Chargeback, nonipacs []model.NonIpac, agencyBureaus []model.AgencyBureau) (int64, error) {
-	tx, err := p.db.Pool.Begin(ctx)
+// executeMergeTransaction loads an approved upload's persisted rows back into the
+// staging table, merges them into the live table and discards them. The sanity check
+// is repeated because the live table may have changed since the upload was staged.
+// Merges of the same reporting source run one at a time; see mergeLocks.
+func (p *Processor) executeMergeTransaction(ctx context.Context, uploadID string, reportType string, opts FileOptions, report *progressReporter) (mergeResult, error) {
+	uid, err := uuid.Parse(uploadID)
 	if err != nil {
-		return 0, fmt.Errorf("failed to begin pgx transaction: %w", err)
+		return mergeResult{}, fmt.Errorf("invalid upload id %q: %w", uploadID, err)
 	}
-	defer tx.Rollback(ctx)
+	pgUploadID := pgtype.UUID{Bytes: uid, Valid: true}
 
-	setQuery := fmt.Sprintf("SET LOCAL app.user_id = %d", p.systemUserID)
-	_, err = tx.Exec(ctx, setQuery)
+	tx, err := p.beginProcessingTx(ctx, uid, opts.UploadedBy)
 	if err != nil {
-		return 0, fmt.Errorf("failed to set user for transaction: %w", err)
+		return mergeResult{}, err
 	}
+	defer tx.Rollback(ctx)
 
 	q := db.New(tx)
-	var rowsAffected int64
 
-	switch reportType {
-	case "BC1300", "BC1048":
-		if len(chargebacks) > 0 {
-			_, err := tx.Exec(ctx, "CREATE TEMPORARY TABLE temp_chargeback_staging (LIKE chargeback INCLUDING DEFAULTS) ON COMMIT DROP;")
-			if err != nil {
-				return 0, fmt.Errorf("failed to create temp chargeback table: %w", err)
-			}
-			if err := q.DeactivateChargebacksBySource(ctx, db.ChargebackReportingSource(reportType)); err != nil {
-				return 0, err
-			}
-			columnNames := []string{"reporting_source", "fund", "business_line", "region", "location_system", "program", "al_num", "source_num", "agreement_num", "title", "alc", "customer_tas", "task_subtask", "class_id", "customer_name", "org_code", "document_date", "accomp_date", "assigned_rebill_drn", "chargeback_amount", "statement", "bd_doc_num", "vendor", "articles_services", "reason_code", "action", "is_active"}
-			_, err = tx.CopyFrom(ctx, pgx.Identifier{"temp_chargeback_staging"}, columnNames, newChargebackCopySource(chargebacks))
-			if err != nil {
-				return 0, fmt.Errorf("failed to stage chargebacks: %w", err)
-			}
-			rowsAffected, err = q.UpsertChargebacks(ctx)
-			if err != nil {
-				return 0, fmt.Errorf("failed to upsert chargebacks: %w", err)
-			}
-		}
-	case "OUTSTANDING_BILLS":
-		if len(nonipacs) > 0 {
-			_, err := tx.Exec(ctx, `CREATE TEMPORARY TABLE temp_nonipac_staging (LIKE "nonipac" INCLUDING DEFAULTS) ON COMMIT DROP;`)
-			if err != nil {
-				return 0, fmt.Errorf("failed to create temp non-ipac table: %w", err)
-			}
-			if err := q.DeactivateNonIpacsBySource(ctx, db.NonipacReportingSource(reportType)); err != nil {
-				return 0, err
-			}
-			columnNames := []string{"reporting_source", "business_line", "billed_total_amount", "principle_amount", "interest_amount", "penalty_amount", "administration_charges_amount", "debit_outstanding_amount", "credit_total_amount", "credit_outstanding_amount", "title", "document_date", "address_code", "vendor", "debt_appeal_forbearance", "statement", "document_number", "vendor_code", "collection_due_date", "open_date", "is_active"}
-			_, err = tx.CopyFrom(ctx, pgx.Identifier{"temp_nonipac_staging"}, columnNames, newNonIpacCopySource(nonipacs))
-			if err != nil {
-				return 0, fmt.Errorf("failed to stage non-ipacs: %w", err)
-			}
-			rowsAffected, err = q.UpsertNonIpacs(ctx)
-			if err != nil {
-				return 0, fmt.Errorf("failed to upsert non-ipacs: %w", err)
-			}
+	// The entity and reporting source of a report type never change between versions,
+	// so the current definition merges rows staged under an earlier one.
+	def, err := loadDefinition(ctx, q, reportType)
+	if err != nil {
+		return mergeResult{}, err
+	}
+	staging, ok := stagingTables[def.Entity]
+	if !ok {
+		return mergeResult{}, fmt.Errorf("unknown entity %q for report type %s", def.Entity, reportType)
+	}
+
+	// Take the locks before the sanity check, which reads the live table the merge changes.
+	if err := p.lockMerge(ctx, q, uid, def); err != nil {
+		return mergeResult{}, err
+	}
+
+	if _, err := tx.Exec(ctx, staging.createSQL); err != nil {
+		return mergeResult{}, fmt.Errorf("failed to create staging table %s: %w", staging.name, err)
+	}
+
+	tag, err := tx.Exec(ctx, staging.restoreSQL(), pgUploadID)
+	if err != nil {
+		return mergeResult{}, fmt.Errorf("failed to load staged rows into %s: %w", staging.name, err)
+	}
+	rowsStaged := tag.RowsAffected()
+
+	sanity, err := p.checkSanity(ctx, q, def, rowsStaged, opts.OverrideSanityCheck)
+	if err != nil {
+		return mergeResult{}, err
+	}
+	if err := sanity.err(); err != nil {
+		return mergeResult{sanity: &sanity}, err
+	}
+
+	report.phase(ctx, progress.PhaseUpsert)
+	rowsAffected, err := p.mergeStaged(ctx, q, uploadID, def, rowsStaged)
+	if err != nil {
+		return mergeResult{}, err
+	}
+
+	if err := q.DeleteUploadStagedRows(ctx, pgUploadID); err != nil {
+		return mergeResult{}, fmt.Errorf("failed to discard staged rows: %w", err)
+	}
+
+	rowsRemoved, err := q.CountRemovedRowsByUploadID(ctx, pgUploadID)
+	if err != nil {
+		return mergeResult{}, fmt.Errorf("failed to count removed rows: %w", err)
+	}
+
+	p.logger.InfoContext(ctx, "Approved upload merged", "upload_id", uploadID, "rows_staged", rowsStaged, "rows_affected", rowsAffected)
+	return mergeResult{rowsUpserted: rowsAffected, rowsRemoved: int(rowsRemoved), sanity: &sanity}, tx.Commit(ctx)
+}
+
+// mergeStaged reconciles records missing from the report, deactivates the report's
+// existing records and upserts the staged rows. Records that were reconciled off
+// report and are back in this one are reopened. Nothing changes when no rows were
+// staged, so an empty report cannot deactivate every record from its source.
+func (p *Processor) mergeStaged(ctx context.Context, q *db.Queries, uploadID string, def reportdef.Definition, rowsStaged int64) (int64, error) {
+	if rowsStaged == 0 {
+		return 0, nil
+	}
+	if err := p.ensureStagedVendors(ctx, q, uploadID, def); err != nil {
+		return 0, err
+	}
+
+	switch def.Entity {
+	case reportdef.EntityChargeback:
+		source := db.ChargebackReportingSource(def.ReportingSource)
+		reconciled, reopened, err := reconcileStaged(ctx, q, uploadID, def.ReportType,
+			func() (int64, error) { return q.ReconcileOffReportChargebacks(ctx, source) },
+			func() error { return q.DeactivateChargebacksBySource(ctx, source) },
+			func() (int64, error) { return q.ReopenReconciledChargebacks(ctx) },
+		)
+		if err != nil {
+			return 0, err
 		}
-	case "VENDOR_CODE":
-		if len(agencyBureaus) > 0 {
-			_, err := tx.Exec(ctx, "CREATE TEMPORARY TABLE temp_agency_bureau_staging (LIKE agency_bureau INCLUDING DEFAULTS) ON COMMIT DROP;")
-			if err != nil {
-				return 0, fmt.Errorf("failed to create temp agency bureau table: %w", err)
-			}
-			columnNames := []string{"agency", "bureau_code", "vendor_code"}
-			_, err = tx.CopyFrom(ctx, pgx.Identifier{"temp_agency_bureau_staging"}, columnNames, newAgencyBureauCopySource(agencyBureaus))
-			if err != nil {
-				return 0, fmt.Errorf("failed to stage agency bureaus: %w", err)
-			}
-			rowsAffected, err = q.UpsertAgencyBureaus(ctx)
-			if err != nil {
-				return 0, fmt.Errorf("failed to upsert agency bureaus: %w", err)
-			}
+		p.logger.InfoContext(ctx, "Chargebacks reconciled against report", "upload_id", uploadID, "reconciled_off_report", reconciled, "reopened", reopened)
+	case reportdef.EntityNonIpac:
+		source := db.NonipacReportingSource(def.ReportingSource)
+		reconciled, reopened, err := reconcileStaged(ctx, q, uploadID, def.ReportType,
+			func() (int64, error) { return q.ReconcileOffReportNonIpacs(ctx, source) },
+			func() error { return q.DeactivateNonIpacsBySource(ctx, source) },
+			func() (int64, error) { return q.ReopenReconciledNonIpacs(ctx) },
+		)
+		if err != nil {
+			return 0, err
 		}
+		p.logger.InfoContext(ctx, "Non-ipacs reconciled against report", "upload_id", uploadID, "reconciled_off_report", reconciled, "reopened", reopened)
+	case reportdef.EntityAgencyBureau:
+	default:
+		return 0, fmt.Errorf("unknown entity %q for report type %s", def.Entity, def.ReportType)
 	}
+	return upsertStaged(ctx, q, def.Entity)
+}
 
-	if len(removedRows) > 0 {
-		p.logger.InfoContext(ctx, "Logging removed rows to database", "count", len(removedRows))
-		columnNames := []string{"id", "upload_id", "timestamp", "report_type", "original_row_data", "reason_for_removal"}
-		_, err := tx.CopyFrom(ctx, pgx.Identifier{"removed_rows_log"}, columnNames, newRemovedRowCopySource(uploadID, removedRows))
+// upsertStaged upserts the staging table of the entity into its live table.
+func upsertStaged(ctx context.Context, q *db.Queries, entity reportdef.Entity) (int64, error) {
+	switch entity {
+	case reportdef.EntityChargeback:
+		rowsAffected, err := q.UpsertChargebacks(ctx)
+		if err != nil {
+			return 0, fmt.Errorf("failed to upsert chargebacks: %w", err)
+		}
+		return rowsAffected, nil
+	case reportdef.EntityNonIpac:
+		rowsAffected, err := q.UpsertNonIpacs(ctx)
+		if err != nil {
+			return 0, fmt.Errorf("failed to upsert non-ipacs: %w", err)
+		}
+		return rowsAffected, nil
+	case reportdef.EntityAgencyBureau:
+		rowsAffected, err := q.UpsertAgencyBureaus(ctx)
 		if err != nil {
-			p.logger.ErrorContext(ctx, "Critical: failed to log removed rows after successful data merge", "error", err)
+			return 0, fmt.Errorf("failed to upsert agency bureaus: %w", err)
 		}
+		return rowsAffected, nil
 	}
-
-	return rowsAffected, tx.Commit(ctx)
+	return 0, fmt.Errorf("unknown entity %q", entity)
 }
 
-type chargebackCopySource struct {
-	rows []model.Chargeback
-	idx  int
-}
+// reconcileStaged runs the status changes that precede an upsert. Each change is made
+// under a status history note naming the upload, and the note is cleared afterwards so
+// the upsert's own inserts keep the trigger's default note.
+func reconcileStaged(ctx context.Context, q *db.Queries, uploadID string, reportType string, reconcile func() (int64, error), deactivate func() error, reopen func() (int64, error)) (int64, int64, error) {
+	if err := q.SetStatusHistoryNote(ctx, offReportNote(reportType, uploadID)); err != nil {
+		return 0, 0, fmt.Errorf("failed to set status history note: %w", err)
+	}
+	reconciled, err := reconcile()
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to reconcile records missing from report: %w", err)
+	}
 
-func newChargebackCopySource(rows []model.Chargeback) *chargebackCopySource {
-	return &chargebackCopySource{rows: rows, idx: -1}
-}
-func (s *chargebackCopySource) Next() bool {
-	s.idx++
-	return s.idx < len(s.rows)
-}
-func (s *chargebackCopySource) Values() ([]any, error) {
-	row := s.rows[s.idx]
-	return []any{row.ReportingSource, row.Fund, row.BusinessLine, row.Region, row.LocationSystem, row.Program, row.ALNum, row.SourceNum, row.AgreementNum, row.Title, row.ALC, row.CustomerTAS, row.TaskSubtask, row.ClassID, row.CustomerName, row.OrgCode, row.DocumentDate, row.AccompDate, row.AssignedRebillDRN, row.ChargebackAmount, row.Statement, row.BDDocNum, row.Vendor, row.ArticlesServices, row.ReasonCode, row.Action, row.IsActive}, nil
-}
-func (s *chargebackCopySource) Err() error { return nil }
+	if err := deactivate(); err != nil {
+		return 0, 0, err
+	}
+
+	if err := q.SetStatusHistoryNote(ctx, reopenedNote(reportType, uploadID)); err != nil {
+		return 0, 0, fmt.Errorf("failed to set status history note: %w", err)
+	}
+	reopened, err := reopen()
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to reopen records back on report: %w", err)
+	}
 
-type nonipacCopySource struct {
-	rows []model.NonIpac
-	idx  int
+	if err := q.SetStatusHistoryNote(ctx, ""); err != nil {
+		return 0, 0, fmt.Errorf("failed to clear status history note: %w", err)
+	}
+	return reconciled, reopened, nil
 }
 
-func newNonIpacCopySource(rows []model.NonIpac) *nonipacCopySource {
-	return &nonipacCopySource{rows: rows, idx: -1}
+// offReportNote is the status history note for a record that dropped off a report.
+func offReportNote(reportType, uploadID string) string {
+	return fmt.Sprintf("%s: no longer on %s report (upload %s)", db.CdmsStatusReconciledOffReport, reportType, uploadID)
 }
-func (s *nonipacCopySource) Next() bool {
-	s.idx++
-	return s.idx < len(s.rows)
+
+// reopenedNote is the status history note for a record that reappeared on a report
+// after being reconciled off it.
+func reopenedNote(reportType, uploadID string) string {
+	return fmt.Sprintf("Reopened: reappeared on %s report (upload %s)", reportType, uploadID)
 }
-func (s *nonipacCopySource) Values() ([]any, error) {
-	row := s.rows[s.idx]
-	return []any{row.ReportingSource, row.BusinessLine, row.BilledTotalAmount, row.PrincipleAmount, row.InterestAmount, row.PenaltyAmount, row.AdministrationChargesAmount, row.DebitOutstandingAmount, row.CreditTotalAmount, row.CreditOutstandingAmount, row.Title, row.DocumentDate, row.AddressCode, row.Vendor, row.DebtAppealForbearance, row.Statement, row.DocumentNumber, row.VendorCode, row.CollectionDueDate, row.OpenDate, row.IsActive}, nil
+
+// storePreview saves a dry run's preview on the uploads row.
+func (p *Processor) storePreview(ctx context.Context, uploadID string, preview *UploadPreview) error {
+	uid, err := uuid.Parse(uploadID)
+	if err != nil {
+		return fmt.Errorf("invalid upload id %q: %w", uploadID, err)
+	}
+	previewJSON, err := json.Marshal(preview)
+	if err != nil {
+		return fmt.Errorf("failed to encode preview: %w", err)
+	}
+	return db.New(p.db.Pool).SetUploadPreview(ctx, db.SetUploadPreviewParams{
+		ID:      pgtype.UUID{Bytes: uid, Valid: true},
+		Preview: previewJSON,
+	})
 }
-func (s *nonipacCopySource) Err() error { return nil }
 
-type agencyBureauCopySource struct {
-	rows []model.AgencyBureau
-	idx  int
+// readBatch reads up to n records. It returns io.EOF, possibly alongside a final
+// partial batch, once the reader is exhausted.
+func readBatch(reader recordReader, n int) ([][]string, error) {
+	records := make([][]string, 0, n)
+	for len(records) < n {
+		record, err := reader.Read()
+		if err != nil {
+			return records, err
+		}
+		records = append(records, record)
+	}
+	return records, nil
 }
 
-func newAgencyBureauCopySource(rows []model.AgencyBureau) *agencyBureauCopySource {
-	return &agencyBureauCopySource{rows: rows, idx: -1}
+// convertedBatch holds one batch of converted rows, ready to copy into staging.
+type convertedBatch struct {
+	rows        pgx.CopyFromSource
+	rowCount    int
+	removedRows []model.RemovedRow
 }
-func (s *agencyBureauCopySource) Next() bool {
-	s.idx++
-	return s.idx < len(s.rows)
+
+// convertBatch converts and validates one batch of records. processedKeys carries the
+// business keys already accepted from earlier batches so duplicates are still caught.
+func (p *Processor) convertBatch(ctx context.Context, q *db.Queries, uploadID string, def reportdef.Definition, records [][]string, headerMap map[string]int, processedKeys map[string]bool) (convertedBatch, error) {
+	reportType := def.ReportType
+	removedRows := []model.RemovedRow{}
+	keyHeader := def.HeaderFor(def.Entity.KeyFields()[0])
+
+	var existingChargebackSources map[string]db.ChargebackReportingSource
+	if def.Entity == reportdef.EntityChargeback {
+		var err error
+		existingChargebackSources, err = lookupChargebackSources(ctx, q, records, headerMap[keyHeader])
+		if err != nil {
+			return convertedBatch{}, err
+		}
+	}
+
+	var knownVendors map[string]bool
+	vendorField := def.Entity.VendorField()
+	vendorHeader := def.HeaderFor(vendorField)
+	if p.rejectsUnknownVendors(def) {
+		var err error
+		knownVendors, err = p.lookupKnownVendors(ctx, q, uploadID, records, headerMap, vendorHeader)
+		if err != nil {
+			return convertedBatch{}, err
+		}
+	}
+
+	rows := make([][]any, 0, len(records))
+	for _, record := range records {
+		values, convErr := convertRecord(def, record, headerMap)
+		if convErr != nil {
+			removedRows = append(removedRows, createRemovedRowEntry(uploadID, record, fmt.Sprintf("Data conversion/validation error: %v", convErr), reportType, issuesFrom(convErr)))
+			continue
+		}
+
+		businessKey := businessKey(def.Entity, values)
+
+		if existingSource, found := existingChargebackSources[businessKey]; found {
+			if string(existingSource) != def.ReportingSource {
+				reason := fmt.Sprintf("Conflict: Record exists but belongs to a different report source ('%s')", existingSource)
+				removedRows = append(removedRows, createRemovedRowEntry(uploadID, record, reason, reportType, recordIssue(record, headerMap, keyHeader, RuleCrossSourceConflict, reason)))
+				continue
+			}
+		}
+
+		if knownVendors != nil {
+			vendor, _ := values[columnIndex(def.Entity.Columns(), vendorField)].(string)
+			if !knownVendors[vendor] {
+				reason := fmt.Sprintf("Unknown vendor: '%s' is not in the vendor directory", vendor)
+				removedRows = append(removedRows, createRemovedRowEntry(uploadID, record, reason, reportType, recordIssue(record, headerMap, vendorHeader, RuleUnknownVendor, reason)))
+				continue
+			}
+		}
+
+		if _, found := processedKeys[businessKey]; found {
+			reason := fmt.Sprintf("Duplicate within current report (%s)", entityLabels[def.Entity])
+			removedRows = append(removedRows, createRemovedRowEntry(uploadID, record, reason, reportType, recordIssue(record, headerMap, keyHeader, RuleDuplicateInFile, reason)))
+			continue
+		}
+		rows = append(rows, values)
+		processedKeys[businessKey] = true
+	}
+	return convertedBatch{rows: pgx.CopyFromRows(rows), rowCount: len(rows), removedRows: removedRows}, nil
 }
-func (s *agencyBureauCopySource) Values() ([]any, error) {
-	row := s.rows[s.idx]
-	return []any{row.Agency, row.BureauCode, row.VendorCode}, nil
+
+func lookupChargebackSources(ctx context.Context, q *db.Queries, records [][]string, bdDocNumIndex int) (map[string]db.ChargebackReportingSource, error) {
+	existingChargebackSources := make(map[string]db.ChargebackReportingSource)
+
+	seen := make(map[string]bool, len(records))
+	bdDocNums := make([]string, 0, len(records))
+	for _, record := range records {
+		if len(record) > bdDocNumIndex {
+			bdDocNum := strings.TrimSpace(record[bdDocNumIndex])
+			if bdDocNum != "" && !seen[bdDocNum] {
+				seen[bdDocNum] = true
+				bdDocNums = append(bdDocNums, bdDocNum)
+			}
+		}
+	}
+	if len(bdDocNums) == 0 {
+		return existingChargebackSources, nil
+	}
+
+	existingRows, err := q.GetChargebackSourcesByBDDocNums(ctx, bdDocNums)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get existing chargebacks: %w", err)
+	}
+	for _, row := range existingRows {
+		key := fmt.Sprintf("%s-%d", row.BdDocNum, row.AlNum)
+		existingChargebackSources[key] = row.ReportingSource
+	}
+	return existingChargebackSources, nil
 }
-func (s *agencyBureauCopySource) Err() error { return nil }
 
 type removedRowCopySource struct {
 	uploadID uuid.UUID
@@ -397,20 +855,41 @@ func (s *removedRowCopySource) Next() bool {
 }
 func (s *removedRowCopySource) Values() ([]any, error) {
 	row := s.rows[s.idx]
-	return []any{row.ID, s.uploadID, row.Timestamp, row.ReportType, row.OriginalRowData, row.ReasonForRemoval}, nil
+	return []any{row.ID, s.uploadID, row.Timestamp, row.ReportType, row.OriginalRowData, row.ReasonForRemoval, row.Issues}, nil
 }
 func (s *removedRowCopySource) Err() error { return nil }
 
-func areHeadersValid(actual, expected []string) bool {
-	if len(actual) != len(expected) {
-		return false
+// storeDialect saves the encoding and delimiter a delimited upload is read with.
+func (p *Processor) storeDialect(ctx context.Context, uploadID string, d dialect.Dialect) error {
+	uid, err := uuid.Parse(uploadID)
+	if err != nil {
+		return fmt.Errorf("invalid upload id %q: %w", uploadID, err)
 	}
-	for i := range actual {
-		if strings.TrimSpace(actual[i]) != strings.TrimSpace(expected[i]) {
-			return false
-		}
+	dialectJSON, err := json.Marshal(d)
+	if err != nil {
+		return fmt.Errorf("failed to encode dialect: %w", err)
 	}
-	return true
+	return db.New(p.db.Pool).SetUploadDialect(ctx, db.SetUploadDialectParams{
+		ID:      pgtype.UUID{Bytes: uid, Valid: true},
+		Dialect: dialectJSON,
+	})
+}
+
+// storeHeaderCheck saves how the upload's header row was matched. The column positions
+// are needed later to read rows back out of removed_rows_log.
+func (p *Processor) storeHeaderCheck(ctx context.Context, uploadID string, match reportdef.HeaderMatch) error {
+	uid, err := uuid.Parse(uploadID)
+	if err != nil {
+		return fmt.Errorf("invalid upload id %q: %w", uploadID, err)
+	}
+	matchJSON, err := json.Marshal(match)
+	if err != nil {
+		return fmt.Errorf("failed to encode header check: %w", err)
+	}
+	return db.New(p.db.Pool).SetUploadHeaderCheck(ctx, db.SetUploadHeaderCheckParams{
+		ID:          pgtype.UUID{Bytes: uid, Valid: true},
+		HeaderCheck: matchJSON,
+	})
 }
 
 func getString(record []string, headerMap map[string]int, headerName string) (string, bool) {
@@ -420,22 +899,14 @@ func getString(record []string, headerMap map[string]int, headerName string) (st
 	return "", false
 }
 
-func getStringPtr(record []string, headerMap map[string]int, headerName string) *string {
-	s, ok := getString(record, headerMap, headerName)
-	if ok && s != "" {
-		return &s
-	}
-	return nil
-}
-
 func parseInt16(record []string, headerMap map[string]int, headerName string) (int16, error) {
 	s, ok := getString(record, headerMap, headerName)
 	if !ok || s == "" {
-		return 0, fmt.Errorf("missing or empty '%s'", headerName)
+		return 0, newFieldError(headerName, s, RuleRequired, "missing or empty '%s'", headerName)
 	}
 	val, err := strconv.ParseInt(strings.ReplaceAll(s, ",", ""), 10, 16)
 	if err != nil {
-		return 0, fmt.Errorf("invalid '%s' format: %w", headerName, err)
+		return 0, newFieldError(headerName, s, RuleNumberInvalid, "invalid '%s' format: %v", headerName, err)
 	}
 	return int16(val), nil
 }
@@ -445,11 +916,12 @@ func parseDecimal(record []string, headerMap map[string]int, headerName string)
 	if !ok || s == "" {
 		return decimal.Zero, nil
 	}
+	raw := s
 	s = strings.ReplaceAll(s, ",", "")
 	s = strings.ReplaceAll(s, "$", "")
 	val, err := decimal.NewFromString(s)
 	if err != nil {
-		return decimal.Decimal{}, fmt.Errorf("invalid '%s' decimal format: %w", headerName, err)
+		return decimal.Decimal{}, newFieldError(headerName, raw, RuleNumberInvalid, "invalid '%s' decimal format: %v", headerName, err)
 	}
 	return val, nil
 }
@@ -457,7 +929,7 @@ func parseDecimal(record []string, headerMap map[string]int, headerName string)
 func parseDate(record []string, headerMap map[string]int, headerName string) (time.Time, error) {
 	s, ok := getString(record, headerMap, headerName)
 	if !ok || s == "" {
-		return time.Time{}, fmt.Errorf("missing or empty '%s'", headerName)
+		return time.Time{}, newFieldError(headerName, s, RuleRequired, "missing or empty '%s'", headerName)
 	}
 	layouts := []string{"1/2/2006", "2006-01-02", "01-Jan-06", time.RFC3339}
 	for _, layout := range layouts {
@@ -466,7 +938,7 @@ func parseDate(record []string, headerMap map[string]int, headerName string) (ti
 			return t, nil
 		}
 	}
-	return time.Time{}, fmt.Errorf("un-parsable '%s' date format: %s", headerName, s)
+	return time.Time{}, newFieldError(headerName, s, RuleDateUnparsable, "un-parsable '%s' date format: %s", headerName, s)
 }
 
 func parseBool(record []string, headerMap map[string]int, headerName string) (bool, error) {
@@ -474,6 +946,7 @@ func parseBool(record []string, headerMap map[string]int, headerName string) (bo
 	if !ok || s == "" {
 		return false, nil
 	}
+	raw := s
 	s = strings.ToUpper(strings.TrimSpace(s))
 	if s == "TRUE" || s == "1" || s == "Y" {
 		return true, nil
@@ -481,10 +954,10 @@ func parseBool(record []string, headerMap map[string]int, headerName string) (bo
 	if s == "FALSE" || s == "0" || s == "N" {
 		return false, nil
 	}
-	return false, fmt.Errorf("invalid '%s' boolean format: %s", headerName, s)
+	return false, newFieldError(headerName, raw, RuleBooleanInvalid, "invalid '%s' boolean format: %s", headerName, s)
 }
 
-func createRemovedRowEntry(uploadID string, originalData []string, reason string, reportType string) model.RemovedRow {
+func createRemovedRowEntry(uploadID string, originalData []string, reason string, reportType string, issues []model.ValidationIssue) model.RemovedRow {
 	uid, _ := uuid.Parse(uploadID)
 
 	jsonData, err := json.Marshal(originalData)
@@ -502,255 +975,6 @@ func createRemovedRowEntry(uploadID string, originalData []string, reason string
 		ReportType:       reportType,
 		OriginalRowData:  rowData,
 		ReasonForRemoval: reason,
+		Issues:           issues,
 	}
 }
-func convertRecordToChargeback(record []string, headerMap map[string]int, reportType string) (model.Chargeback, error) {
-	var parseErrors []error
-	chargeback := model.Chargeback{
-		ReportingSource: model.ChargebackReportingSource(reportType),
-		IsActive:        true,
-	}
-
-	if val, ok := getString(record, headerMap, "Fund"); !ok || val == "" {
-		parseErrors = append(parseErrors, errors.New("missing 'Fund'"))
-	} else if !validChargebackFunds[val] {
-		parseErrors = append(parseErrors, fmt.Errorf("invalid 'Fund' value: %s", val))
-	} else {
-		chargeback.Fund = model.ChargebackFund(val)
-	}
-	if val, ok := getString(record, headerMap, "Business Line"); !ok || val == "" {
-		parseErrors = append(parseErrors, errors.New("missing 'Business Line'"))
-	} else if !validBusinessLines[val] {
-		parseErrors = append(parseErrors, fmt.Errorf("invalid 'Business Line' value: %s", val))
-	} else {
-		chargeback.BusinessLine = model.ChargebackBusinessLine(val)
-	}
-	if val, err := parseInt16(record, headerMap, "Region"); err != nil {
-		parseErrors = append(parseErrors, err)
-	} else {
-		chargeback.Region = val
-	}
-	chargeback.LocationSystem = getStringPtr(record, headerMap, "Location/System")
-	if val, ok := getString(record, headerMap, "Program"); !ok || val == "" {
-		parseErrors = append(parseErrors, errors.New("missing 'Program'"))
-	} else {
-		chargeback.Program = val
-	}
-	if val, err := parseInt16(record, headerMap, "AL Num"); err != nil {
-		parseErrors = append(parseErrors, err)
-	} else {
-		chargeback.ALNum = val
-	}
-	if val, ok := getString(record, headerMap, "Source Num"); !ok || val == "" {
-		parseErrors = append(parseErrors, errors.New("missing 'Source Num'"))
-	} else {
-		chargeback.SourceNum = val
-	}
-	chargeback.AgreementNum = getStringPtr(record, headerMap, "Agreement Num")
-	chargeback.Title = getStringPtr(record, headerMap, "Title")
-
-	if val, ok := getString(record, headerMap, "ALC"); !ok || val == "" {
-		parseErrors = append(parseErrors, errors.New("missing 'ALC'"))
-	} else if !alcFormatRegex.MatchString(val) {
-		parseErrors = append(parseErrors, fmt.Errorf("value for 'ALC' does not match required format (4-8 digits): '%s'", val))
-	} else {
-		chargeback.ALC = val
-	}
-	if val, ok := getString(record, headerMap, "Org Code"); !ok || val == "" {
-		parseErrors = append(parseErrors, errors.New("missing 'Org Code'"))
-	} else if len(val) > 8 {
-		parseErrors = append(parseErrors, fmt.Errorf("value for 'Org Code' exceeds 8 characters: '%s'", val))
-	} else {
-		chargeback.OrgCode = val
-	}
-	if valPtr := getStringPtr(record, headerMap, "Assigned Rebill DRN"); valPtr != nil && len(*valPtr) > 8 {
-		parseErrors = append(parseErrors, fmt.Errorf("value for 'Assigned Rebill DRN' exceeds 8 characters: '%s'", *valPtr))
-	} else {
-		chargeback.AssignedRebillDRN = valPtr
-	}
-	if val, ok := getString(record, headerMap, "Statement"); !ok || val == "" {
-		parseErrors = append(parseErrors, errors.New("missing 'Statement'"))
-	} else if len(val) > 8 {
-		parseErrors = append(parseErrors, fmt.Errorf("value for 'Statement' exceeds 8 characters: '%s'", val))
-	} else {
-		chargeback.Statement = val
-	}
-	if val, ok := getString(record, headerMap, "Vendor"); !ok || val == "" {
-		parseErrors = append(parseErrors, errors.New("missing 'Vendor'"))
-	} else if len(val) > 8 {
-		parseErrors = append(parseErrors, fmt.Errorf("value for 'Vendor' exceeds 8 characters: '%s'", val))
-	} else {
-		chargeback.Vendor = val
-	}
-
-	if val, ok := getString(record, headerMap, "Customer TAS"); !ok || val == "" {
-		parseErrors = append(parseErrors, errors.New("missing 'Customer TAS'"))
-	} else {
-		chargeback.CustomerTAS = val
-	}
-	if val, ok := getString(record, headerMap, "Task/Subtask"); !ok || val == "" {
-		parseErrors = append(parseErrors, errors.New("missing 'Task/Subtask'"))
-	} else {
-		chargeback.TaskSubtask = val
-	}
-	chargeback.ClassID = getStringPtr(record, headerMap, "Class ID")
-	if val, ok := getString(record, headerMap, "Vendor Name"); !ok || val == "" {
-		parseErrors = append(parseErrors, errors.New("missing 'Customer Name' (from Vendor Name column)"))
-	} else {
-		chargeback.CustomerName = val
-	}
-	if val, err := parseDate(record, headerMap, "Doc Date"); err != nil {
-		parseErrors = append(parseErrors, err)
-	} else {
-		chargeback.DocumentDate = val
-	}
-	if val, err := parseDate(record, headerMap, "Accomp Date"); err != nil {
-		parseErrors = append(parseErrors, err)
-	} else {
-		chargeback.AccompDate = val
-	}
-	if val, err := parseDecimal(record, headerMap, "Chargeback Amount"); err != nil {
-		parseErrors = append(parseErrors, err)
-	} else {
-		chargeback.ChargebackAmount = val
-	}
-	if val, ok := getString(record, headerMap, "BD Doc Num"); !ok || val == "" {
-		parseErrors = append(parseErrors, errors.New("missing 'BD Doc Num'"))
-	} else {
-		chargeback.BDDocNum = val
-	}
-	chargeback.ArticlesServices = getStringPtr(record, headerMap, "Articles or Services")
-
-	if len(parseErrors) > 0 {
-		return model.Chargeback{}, errors.Join(parseErrors...)
-	}
-	return chargeback, nil
-}
-func convertRecordToNonIpac(record []string, headerMap map[string]int, reportType string) (model.NonIpac, error) {
-	nonipac := model.NonIpac{
-		ReportingSource: model.NonIpacReportingSource(reportType),
-		IsActive:        true,
-	}
-	var parseErrors []error
-
-	if val, ok := getString(record, headerMap, "Business Line"); !ok || val == "" {
-		parseErrors = append(parseErrors, errors.New("missing 'Business Line'"))
-	} else if !validBusinessLines[val] {
-		parseErrors = append(parseErrors, fmt.Errorf("invalid 'Business Line' value: %s", val))
-	} else {
-		nonipac.BusinessLine = model.ChargebackBusinessLine(val)
-	}
-	if val, err := parseDecimal(record, headerMap, "Chargeback Amount"); err != nil {
-		parseErrors = append(parseErrors, err)
-	} else {
-		nonipac.BilledTotalAmount = val
-	}
-	if val, err := parseDecimal(record, headerMap, "Principal_Amount"); err != nil {
-		parseErrors = append(parseErrors, err)
-	} else {
-		nonipac.PrincipleAmount = val
-	}
-	if val, err := parseDecimal(record, headerMap, "Interest_Amount"); err != nil {
-		parseErrors = append(parseErrors, err)
-	} else {
-		nonipac.InterestAmount = val
-	}
-	if val, err := parseDecimal(record, headerMap, "Penalty_Amount"); err != nil {
-		parseErrors = append(parseErrors, err)
-	} else {
-		nonipac.PenaltyAmount = val
-	}
-	if val, err := parseDecimal(record, headerMap, "Administration_Charges_Amount"); err != nil {
-		parseErrors = append(parseErrors, err)
-	} else {
-		nonipac.AdministrationChargesAmount = val
-	}
-	if val, err := parseDecimal(record, headerMap, "Outstanding_Amount"); err != nil {
-		parseErrors = append(parseErrors, err)
-	} else {
-		nonipac.DebitOutstandingAmount = val
-	}
-	if val, err := parseDecimal(record, headerMap, "Credit_Total_Amount"); err != nil {
-		parseErrors = append(parseErrors, err)
-	} else {
-		nonipac.CreditTotalAmount = val
-	}
-	if val, err := parseDecimal(record, headerMap, "Credit_Outstanding_Amount"); err != nil {
-		parseErrors = append(parseErrors, err)
-	} else {
-		nonipac.CreditOutstandingAmount = val
-	}
-	nonipac.Title = getStringPtr(record, headerMap, "Title")
-	if val, err := parseDate(record, headerMap, "Doc Date"); err != nil {
-		parseErrors = append(parseErrors, err)
-	} else {
-		nonipac.DocumentDate = val
-		nonipac.OpenDate = val
-	}
-	if val, ok := getString(record, headerMap, "Address_Code"); !ok || val == "" {
-		parseErrors = append(parseErrors, errors.New("missing 'Address_Code'"))
-	} else {
-		nonipac.AddressCode = val
-	}
-	if val, ok := getString(record, headerMap, "Vendor Name"); !ok || val == "" {
-		parseErrors = append(parseErrors, errors.New("missing 'Vendor Name'"))
-	} else {
-		nonipac.Vendor = val
-	}
-	if val, err := parseBool(record, headerMap, "Debt_Appeal_Forebearance"); err != nil {
-		parseErrors = append(parseErrors, err)
-	} else {
-		nonipac.DebtAppealForbearance = val
-	}
-	if val, ok := getString(record, headerMap, "Statement"); !ok || val == "" {
-		parseErrors = append(parseErrors, errors.New("missing 'Statement'"))
-	} else {
-		nonipac.Statement = val
-	}
-	if val, ok := getString(record, headerMap, "BD Doc Num"); !ok || val == "" {
-		parseErrors = append(parseErrors, errors.New("missing 'Document Number' (from BD Doc Num)"))
-	} else {
-		nonipac.DocumentNumber = val
-	}
-	if val, ok := getString(record, headerMap, "Vendor"); !ok || val == "" {
-		parseErrors = append(parseErrors, errors.New("missing 'Vendor'"))
-	} else {
-		nonipac.VendorCode = val
-	}
-	if val, err := parseDate(record, headerMap, "Collection_Due_Date"); err != nil {
-		parseErrors = append(parseErrors, err)
-	} else {
-		nonipac.CollectionDueDate = val
-	}
-
-	if len(parseErrors) > 0 {
-		return model.NonIpac{}, errors.Join(parseErrors...)
-	}
-	return nonipac, nil
-}
-
-func convertRecordToAgencyBureau(record []string, headerMap map[string]int) (model.AgencyBureau, error) {
-	agencyBureau := model.AgencyBureau{}
-	var parseErrors []error
-
-	if val, ok := getString(record, headerMap, "Vendor Agency Code"); !ok || val == "" {
-		parseErrors = append(parseErrors, errors.New("missing 'Vendor Agency Code'"))
-	} else {
-		agencyBureau.Agency = val
-	}
-	if val, ok := getString(record, headerMap, "Bureau Code"); !ok || val == "" {
-		parseErrors = append(parseErrors, errors.New("missing 'Bureau Code'"))
-	} else {
-		agencyBureau.BureauCode = val
-	}
-	if val, ok := getString(record, headerMap, "Vendor Code"); !ok || val == "" {
-		parseErrors = append(parseErrors, errors.New("missing 'Vendor Code'"))
-	} else {
-		agencyBureau.VendorCode = val
-	}
-
-	if len(parseErrors) > 0 {
-		return model.AgencyBureau{}, errors.Join(parseErrors...)
-	}
-	return agencyBureau, nil
-}
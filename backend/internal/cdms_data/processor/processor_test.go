@@ -1,12 +1,27 @@
 package processor
 
 import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
 	"reflect"
 	"strings"
 	"testing"
 	"time"
 
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgtype"
 	"github.com/jjckrbbt/cdms/backend/internal/cdms_data/model"
+	"github.com/jjckrbbt/cdms/backend/internal/cdms_data/reportdef"
+	"github.com/jjckrbbt/cdms/backend/internal/config"
+	"github.com/jjckrbbt/cdms/backend/internal/db"
 	"github.com/shopspring/decimal"
 )
 
@@ -113,8 +128,13 @@ func TestConvertRecordToChargeback(t *testing.T) {
 	// --- Test Execution ---
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
+			def, ok := reportdef.Builtin(tc.reportType)
+			if !ok {
+				t.Fatalf("no built-in definition for %s", tc.reportType)
+			}
+
 			// Call the function we are testing
-			result, err := convertRecordToChargeback(tc.inputRecord, headerMap, tc.reportType)
+			result, err := convertRecord(def, tc.inputRecord, headerMap)
 
 			if tc.expectError {
 				// We expected an error
@@ -127,51 +147,619 @@ func TestConvertRecordToChargeback(t *testing.T) {
 			} else {
 				// We did not expect an error
 				if err != nil {
-					t.Errorf("did not expect an error but got: %v", err)
+					t.Fatalf("did not expect an error but got: %v", err)
 				}
 
-				// If no error, we can check if the returned struct is correct
-				// We'll create the expected struct for the "Happy Path" case
-				title := "Test Title"
-				loc := "LOC"
-				agrNum := "AGR1"
-				classID := "C123"
-				drn := "DRN123"
-				articles := "Test Services"
-
-				expected := model.Chargeback{
-					ReportingSource:   "BC1048",
-					IsActive:          true,
-					CurrentStatus:     "Open",
-					Fund:              "F-100",
-					BusinessLine:      "IT Services",
-					Region:            10,
-					LocationSystem:    &loc,
-					Program:           "PROG",
-					ALNum:             123,
-					SourceNum:         "S123",
-					AgreementNum:      &agrNum,
-					Title:             &title,
-					ALC:               "12345678",
-					CustomerTAS:       "TAS123",
-					TaskSubtask:       "TASK1",
-					ClassID:           &classID,
-					CustomerName:      "Test Customer",
-					OrgCode:           "ORG123",
-					DocumentDate:      docDate,
-					AccompDate:        accompDate,
-					AssignedRebillDRN: &drn,
-					ChargebackAmount:  validAmount,
-					Statement:         "STMT1",
-					BDDocNum:          "BD123",
-					Vendor:            "VEND123",
-					ArticlesServices:  &articles,
+				// If no error, check the staged values of the "Happy Path" case
+				expected := map[string]any{
+					"reporting_source":    "BC1048",
+					"is_active":           true,
+					"fund":                "F-100",
+					"business_line":       "IT Services",
+					"region":              int16(10),
+					"location_system":     "LOC",
+					"program":             "PROG",
+					"al_num":              int16(123),
+					"source_num":          "S123",
+					"agreement_num":       "AGR1",
+					"title":               "Test Title",
+					"alc":                 "12345678",
+					"customer_tas":        "TAS123",
+					"task_subtask":        "TASK1",
+					"class_id":            "C123",
+					"customer_name":       "Test Customer",
+					"org_code":            "ORG123",
+					"document_date":       docDate,
+					"accomp_date":         accompDate,
+					"assigned_rebill_drn": "DRN123",
+					"chargeback_amount":   validAmount,
+					"statement":           "STMT1",
+					"bd_doc_num":          "BD123",
+					"vendor":              "VEND123",
+					"articles_services":   "Test Services",
+					"reason_code":         nil,
+					"action":              nil,
 				}
 
-				if !reflect.DeepEqual(result, expected) {
-					t.Errorf("struct mismatch: got %+v, want %+v", result, expected)
+				columns := reportdef.EntityChargeback.Columns()
+				if len(result) != len(columns) {
+					t.Fatalf("expected %d values, got %d", len(columns), len(result))
+				}
+				for i, column := range columns {
+					if !reflect.DeepEqual(result[i], expected[column]) {
+						t.Errorf("%s: got %#v, want %#v", column, result[i], expected[column])
+					}
 				}
 			}
 		})
 	}
 }
+
+func TestConvertRecordToVendor(t *testing.T) {
+	def, _ := reportdef.Builtin("VENDOR_CODE")
+	headerMap := make(map[string]int, len(def.Columns))
+	for i, header := range def.Headers() {
+		headerMap[header] = i
+	}
+	record := []string{"047", "00", "47000016", "V1234567", "AC01", "Acme Supply Co", "100 Main St", "Suite 4", "", "Springfield", "IL", "62701", "ACTIVE", "COMMERCIAL", "RA1", "ORG9", "Y"}
+
+	values, err := convertRecord(def, record, headerMap)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	got := make(map[string]any, len(values))
+	for i, column := range reportdef.EntityAgencyBureau.Columns() {
+		got[column] = values[i]
+	}
+	expected := map[string]any{
+		"agency": "047", "bureau_code": "00", "vendor_code": "V1234567",
+		"agency_location_code": "47000016", "vendor_address_code": "AC01", "name": "Acme Supply Co",
+		"address_line_1": "100 Main St", "address_line_2": "Suite 4", "address_line_3": nil,
+		"city": "Springfield", "state": "IL", "zip": "62701", "status": "ACTIVE",
+		"vendor_type": "COMMERCIAL", "reporting_attribute": "RA1", "security_org": "ORG9",
+		"transmit_to_vcss_flag": "Y",
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("got %v, want %v", got, expected)
+	}
+}
+
+func TestReadBatch(t *testing.T) {
+	csvReader := csv.NewReader(strings.NewReader("a,1\nb,2\nc,3\nd,4\ne,5\n"))
+
+	var batchSizes []int
+	for {
+		records, err := readBatch(csvReader, 2)
+		batchSizes = append(batchSizes, len(records))
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	if !reflect.DeepEqual(batchSizes, []int{2, 2, 1}) {
+		t.Errorf("expected batches of [2 2 1], got %v", batchSizes)
+	}
+}
+
+func TestReadBatchMalformedRecord(t *testing.T) {
+	csvReader := csv.NewReader(strings.NewReader("a,1\nb,2,extra\n"))
+
+	records, err := readBatch(csvReader, 10)
+	if err == nil || errors.Is(err, io.EOF) {
+		t.Fatalf("expected a parse error, got %v", err)
+	}
+	if len(records) != 1 {
+		t.Errorf("expected the record before the error to be returned, got %d", len(records))
+	}
+}
+
+func TestUploadPreviewAddRemoved(t *testing.T) {
+	preview := newUploadPreview("BC1300")
+
+	rows := make([]model.RemovedRow, previewSampleLimit+5)
+	for i := range rows {
+		rows[i] = model.RemovedRow{OriginalRowData: `{"row":1}`, ReasonForRemoval: "Invalid ALC"}
+	}
+	preview.addRemoved(rows[:3])
+	preview.addRemoved(rows[3:])
+
+	if preview.RemovedRows != len(rows) {
+		t.Errorf("expected %d removed rows, got %d", len(rows), preview.RemovedRows)
+	}
+	if len(preview.RemovedSample) != previewSampleLimit {
+		t.Errorf("expected the sample to be capped at %d, got %d", previewSampleLimit, len(preview.RemovedSample))
+	}
+}
+
+func TestEvaluateSanity(t *testing.T) {
+	threshold := config.SanityThreshold{MaxDeactivatedPct: 30, MaxRowDropPct: 50}
+	previous := int64(1000)
+
+	testCases := []struct {
+		name           string
+		threshold      config.SanityThreshold
+		active         int64
+		deactivated    int64
+		staged         int64
+		previousRows   *int64
+		expectPassed   bool
+		expectFailures int
+	}{
+		{name: "Normal report passes", threshold: threshold, active: 1000, deactivated: 50, staged: 980, previousRows: &previous, expectPassed: true},
+		{name: "Too many deactivations fail", threshold: threshold, active: 1000, deactivated: 400, staged: 700, previousRows: &previous, expectFailures: 1},
+		{name: "Truncated report fails both checks", threshold: threshold, active: 1000, deactivated: 900, staged: 100, previousRows: &previous, expectFailures: 2},
+		{name: "First upload has no row-count baseline", threshold: threshold, active: 0, deactivated: 0, staged: 10, expectPassed: true},
+		{name: "Zero limits disable the checks", active: 1000, deactivated: 1000, staged: 0, previousRows: &previous, expectPassed: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			check := evaluateSanity("BC1300", tc.threshold, tc.active, tc.deactivated, tc.staged, tc.previousRows)
+			if check.Passed != tc.expectPassed {
+				t.Errorf("expected passed=%v, got %v (failures: %v)", tc.expectPassed, check.Passed, check.Failures)
+			}
+			if len(check.Failures) != tc.expectFailures {
+				t.Errorf("expected %d failures, got %v", tc.expectFailures, check.Failures)
+			}
+			if !tc.expectPassed && !errors.Is(check.err(), errSanityCheck) {
+				t.Errorf("expected errSanityCheck, got %v", check.err())
+			}
+		})
+	}
+
+	overridden := evaluateSanity("BC1300", threshold, 1000, 900, 100, &previous)
+	overridden.Overridden = true
+	if overridden.err() != nil {
+		t.Errorf("expected an overridden check to allow the merge, got %v", overridden.err())
+	}
+}
+
+func TestReconciliationNotes(t *testing.T) {
+	uploadID := "3f2504e0-4f89-11d3-9a0c-0305e82c3301"
+
+	offReport := offReportNote("BC1300", uploadID)
+	if !strings.HasPrefix(offReport, "Reconciled - Off Report") || !strings.Contains(offReport, uploadID) {
+		t.Errorf("off-report note should name the status and upload, got %q", offReport)
+	}
+
+	reopened := reopenedNote("OUTSTANDING_BILLS", uploadID)
+	if !strings.HasPrefix(reopened, "Reopened") || !strings.Contains(reopened, uploadID) {
+		t.Errorf("reopened note should say the record reopened and name the upload, got %q", reopened)
+	}
+}
+
+func TestCorrectRecord(t *testing.T) {
+	def, _ := reportdef.Builtin("VENDOR_CODE")
+	original := []byte(`["ABC","12","BAD-ALC"]`)
+
+	t.Run("Corrections are applied by header and short rows are padded", func(t *testing.T) {
+		record, headerMap, err := correctRecord(def, nil, original, map[string]string{"Agency Location Code": "12345678"})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(record) != len(def.Columns) {
+			t.Fatalf("expected %d fields, got %d", len(def.Columns), len(record))
+		}
+		if got := record[headerMap["Agency Location Code"]]; got != "12345678" {
+			t.Errorf("expected corrected ALC, got %q", got)
+		}
+		if got := record[headerMap["Vendor Agency Code"]]; got != "ABC" {
+			t.Errorf("expected untouched field to keep its value, got %q", got)
+		}
+	})
+
+	t.Run("Fields are read at the positions the upload's file had them", func(t *testing.T) {
+		positions := map[string]int{"Agency Location Code": 0, "Vendor Code": 1, "Bureau Code": 2, "Vendor Agency Code": 3}
+		record, headerMap, err := correctRecord(def, positions, []byte(`["BAD-ALC","V1","12","ABC"]`), map[string]string{"Agency Location Code": "12345678"})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got := record[0]; got != "12345678" {
+			t.Errorf("expected corrected ALC in the file's first field, got %q", got)
+		}
+		if got := record[headerMap["Vendor Agency Code"]]; got != "ABC" {
+			t.Errorf("expected untouched field to keep its value, got %q", got)
+		}
+	})
+
+	t.Run("Unknown columns are rejected", func(t *testing.T) {
+		_, _, err := correctRecord(def, nil, original, map[string]string{"Not A Column": "x"})
+		if !errors.Is(err, ErrResubmitInvalid) {
+			t.Errorf("expected ErrResubmitInvalid, got %v", err)
+		}
+	})
+}
+
+func TestIssuesFrom(t *testing.T) {
+	headerMap := map[string]int{"Doc Date": 0, "ALC": 1}
+	record := []string{"not-a-date", "12AB"}
+
+	_, dateErr := parseDate(record, headerMap, "Doc Date")
+	err := errors.Join(
+		missingField("Fund"),
+		dateErr,
+		newFieldError("ALC", "12AB", RuleALCFormat, "value for 'ALC' does not match required format (4-8 digits): '%s'", "12AB"),
+		errors.New("something else"),
+	)
+
+	issues := issuesFrom(err)
+	expected := []model.ValidationIssue{
+		{Column: "Fund", Rule: RuleRequired, Message: "missing 'Fund'"},
+		{Column: "Doc Date", RawValue: "not-a-date", Rule: RuleDateUnparsable, Message: "un-parsable 'Doc Date' date format: not-a-date"},
+		{Column: "ALC", RawValue: "12AB", Rule: RuleALCFormat, Message: "value for 'ALC' does not match required format (4-8 digits): '12AB'"},
+		{Rule: RuleUnclassified, Message: "something else"},
+	}
+	if !reflect.DeepEqual(issues, expected) {
+		t.Errorf("unexpected issues:\n got: %+v\nwant: %+v", issues, expected)
+	}
+
+	if got := issuesFrom(nil); got == nil || len(got) != 0 {
+		t.Errorf("expected an empty, non-nil list for a nil error, got %#v", got)
+	}
+}
+
+func TestDetectReport(t *testing.T) {
+	var defs []reportdef.Definition
+	for _, reportType := range reportdef.BuiltinReportTypes() {
+		def, _ := reportdef.Builtin(reportType)
+		defs = append(defs, def)
+	}
+	headersOf := func(reportType string) func() ([]string, error) {
+		def, _ := reportdef.Builtin(reportType)
+		return func() ([]string, error) { return def.Headers(), nil }
+	}
+	noHeaders := func() ([]string, error) {
+		t.Fatal("headers should not be read when the file name is enough")
+		return nil, nil
+	}
+
+	testCases := []struct {
+		name        string
+		filename    string
+		readHeaders func() ([]string, error)
+		expected    string
+	}{
+		{name: "By file name", filename: "exports/Vendor_Codes_2025-07.csv", readHeaders: noHeaders, expected: "VENDOR_CODE"},
+		{name: "By compressed file name", filename: "bc-1300 july.csv.gz", readHeaders: noHeaders, expected: "BC1300"},
+		{name: "By headers", filename: "july.csv", readHeaders: headersOf("OUTSTANDING_BILLS"), expected: "OUTSTANDING_BILLS"},
+		{name: "By best fitting headers", filename: "july.csv", readHeaders: headersOf("BC1048"), expected: "BC1048"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			def, err := detectReport(defs, tc.filename, tc.readHeaders)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if def.ReportType != tc.expected {
+				t.Errorf("expected %s, got %s", tc.expected, def.ReportType)
+			}
+		})
+	}
+
+	t.Run("Unknown headers are not detected", func(t *testing.T) {
+		_, err := detectReport(defs, "july.csv", func() ([]string, error) { return []string{"A", "B"}, nil })
+		if !errors.Is(err, ErrReportNotDetected) {
+			t.Errorf("expected ErrReportNotDetected, got %v", err)
+		}
+	})
+}
+
+func TestOpenRecordReaderGzip(t *testing.T) {
+	var compressed bytes.Buffer
+	gz := gzip.NewWriter(&compressed)
+	gz.Write([]byte("Name;City\nAna;Lyon\n"))
+	gz.Close()
+
+	p := &Processor{logger: slog.New(slog.NewTextHandler(io.Discard, nil))}
+	records, closeRecords, err := p.openRecordReader(context.Background(), "", &compressed, "raw-reports/x.csv.gz", FileOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer closeRecords()
+
+	var got [][]string
+	for {
+		record, err := records.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		got = append(got, record)
+	}
+	if !reflect.DeepEqual(got, [][]string{{"Name", "City"}, {"Ana", "Lyon"}}) {
+		t.Errorf("unexpected records %q", got)
+	}
+}
+
+func TestMergeLocks(t *testing.T) {
+	vendorCode, _ := reportdef.Builtin("VENDOR_CODE")
+	bc1048, _ := reportdef.Builtin("BC1048")
+	bc1300, _ := reportdef.Builtin("BC1300")
+
+	locks := mergeLocks(vendorCode)
+	if !reflect.DeepEqual(locks, []mergeLock{{name: "VENDOR_CODE"}}) {
+		t.Errorf("VENDOR_CODE locks = %+v, want the vendor code lock exclusively", locks)
+	}
+
+	locks = mergeLocks(bc1048)
+	if !reflect.DeepEqual(locks, []mergeLock{{name: "VENDOR_CODE", shared: true}, {name: "BC1048"}}) {
+		t.Errorf("BC1048 locks = %+v, want the vendor code lock shared, then BC1048", locks)
+	}
+
+	if mergeLocks(bc1048)[1].key() == mergeLocks(bc1300)[1].key() {
+		t.Error("BC1048 and BC1300 merges share a lock key")
+	}
+	if mergeLocks(bc1048)[1].key() != (mergeLock{name: "BC1048"}).key() {
+		t.Error("lock key is not stable")
+	}
+}
+
+func TestRejectsUnknownVendors(t *testing.T) {
+	vendorCode, _ := reportdef.Builtin("VENDOR_CODE")
+	bc1048, _ := reportdef.Builtin("BC1048")
+
+	reject := &Processor{cfg: &config.Config{UnknownVendors: config.UnknownVendorsReject}}
+	placeholder := &Processor{cfg: &config.Config{UnknownVendors: config.UnknownVendorsPlaceholder}}
+
+	if !reject.rejectsUnknownVendors(bc1048) {
+		t.Error("BC1048 rows with unknown vendors are not set aside")
+	}
+	if placeholder.rejectsUnknownVendors(bc1048) {
+		t.Error("BC1048 rows with unknown vendors are set aside in placeholder mode")
+	}
+	if reject.rejectsUnknownVendors(vendorCode) {
+		t.Error("VENDOR_CODE rows are checked against the vendor directory")
+	}
+}
+
+func TestMergeFailureStatus(t *testing.T) {
+	testCases := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{name: "sanity check", err: fmt.Errorf("%w: too many rows deactivated", errSanityCheck), want: "FAILED_SANITY_CHECK"},
+		{name: "unknown vendor codes", err: fmt.Errorf("failed to merge: %w", unknownVendorsError([]string{"V1"})), want: "FAILED_UNKNOWN_VENDORS"},
+		{name: "anything else", err: errors.New("connection reset"), want: "FAILED_GENERIC"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := mergeFailureStatus(tc.err); got != tc.want {
+				t.Errorf("mergeFailureStatus() = %s, want %s", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestUnknownVendorsError(t *testing.T) {
+	err := unknownVendorsError([]string{"V1", "V2"})
+	if !errors.Is(err, errUnknownVendors) {
+		t.Errorf("expected errUnknownVendors, got %v", err)
+	}
+	if !strings.HasSuffix(err.Error(), ": V1, V2") {
+		t.Errorf("unexpected error %q", err)
+	}
+
+	err = unknownVendorsError([]string{"V1", "V2", "V3", "V4", "V5", "V6", "V7"})
+	if !strings.HasSuffix(err.Error(), ": V1, V2, V3, V4, V5 and 2 more") {
+		t.Errorf("unexpected error %q", err)
+	}
+}
+
+func TestRollbackRefusal(t *testing.T) {
+	uploadID := pgtype.UUID{Bytes: uuid.New(), Valid: true}
+	laterID := pgtype.UUID{Bytes: uuid.New(), Valid: true}
+
+	tests := []struct {
+		name    string
+		status  string
+		latest  pgtype.UUID
+		changes int64
+		want    string
+	}{
+		{name: "latest merged upload", status: "COMPLETE", latest: uploadID},
+		{name: "merged with issues", status: "COMPLETE_WITH_ISSUES", latest: uploadID},
+		{name: "not merged", status: "STAGED", latest: laterID, want: "upload is STAGED"},
+		{name: "already rolled back", status: "ROLLED_BACK", latest: laterID, want: "upload is ROLLED_BACK"},
+		{name: "no merged upload of the report type", status: "FAILED_GENERIC", want: "upload is FAILED_GENERIC"},
+		{name: "not latest", status: "COMPLETE", latest: laterID, want: "a later BC1048 upload has been merged since"},
+		{name: "later changes exist", status: "COMPLETE", latest: uploadID, changes: 3, want: "3 changes have been made since"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			upload := db.GetUploadRow{ID: uploadID, ReportType: "BC1048", Status: tc.status}
+			err := rollbackRefusal(upload, tc.latest, tc.changes)
+			if tc.want == "" {
+				if err != nil {
+					t.Fatalf("unexpected refusal: %v", err)
+				}
+				return
+			}
+			if !errors.Is(err, ErrRollbackRefused) || !strings.Contains(err.Error(), tc.want) {
+				t.Errorf("expected ErrRollbackRefused mentioning %q, got %v", tc.want, err)
+			}
+		})
+	}
+}
+
+func TestRestoreError(t *testing.T) {
+	fkErr := fmt.Errorf("failed to delete records created by upload: %w", &pgconn.PgError{Code: foreignKeyViolation, ConstraintName: "fk_chargeback_vendor"})
+	err := restoreError(fkErr)
+	if !errors.Is(err, ErrRollbackRefused) || !strings.Contains(err.Error(), "fk_chargeback_vendor") {
+		t.Errorf("FK-referenced inserts: expected ErrRollbackRefused naming the constraint, got %v", err)
+	}
+
+	otherErr := fmt.Errorf("failed to restore records: %w", &pgconn.PgError{Code: "40001"})
+	if err := restoreError(otherErr); err != otherErr {
+		t.Errorf("expected other errors to pass through, got %v", err)
+	}
+}
+
+// recordingDB is a db.DBTX that records the name of each query run through it, and the
+// note of each status history note set. Every statement affects one row, and lists of
+// staged vendor codes missing from the directory return unknownVendors.
+type recordingDB struct {
+	calls          []string
+	unknownVendors []string
+}
+
+func (r *recordingDB) Exec(ctx context.Context, sql string, args ...interface{}) (pgconn.CommandTag, error) {
+	name := strings.Fields(sql)[2]
+	if name == "SetStatusHistoryNote" {
+		name = fmt.Sprintf("%s(%v)", name, args[0])
+	}
+	r.calls = append(r.calls, name)
+	return pgconn.NewCommandTag("UPDATE 1"), nil
+}
+
+func (r *recordingDB) Query(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error) {
+	name := strings.Fields(sql)[2]
+	if !strings.HasPrefix(name, "ListUnknownStaged") {
+		return nil, fmt.Errorf("unexpected query %s", name)
+	}
+	r.calls = append(r.calls, name)
+	return &stringRows{values: r.unknownVendors, index: -1}, nil
+}
+
+func (r *recordingDB) QueryRow(ctx context.Context, sql string, args ...interface{}) pgx.Row {
+	return errRow{fmt.Errorf("unexpected query %s", strings.Fields(sql)[2])}
+}
+
+type errRow struct{ err error }
+
+func (r errRow) Scan(dest ...any) error { return r.err }
+
+// stringRows is a single-column result of text values.
+type stringRows struct {
+	pgx.Rows
+	values []string
+	index  int
+}
+
+func (r *stringRows) Next() bool {
+	r.index++
+	return r.index < len(r.values)
+}
+
+func (r *stringRows) Scan(dest ...any) error {
+	*dest[0].(*string) = r.values[r.index]
+	return nil
+}
+
+func (r *stringRows) Err() error { return nil }
+
+func (r *stringRows) Close() {}
+
+func TestMergeResubmittedReopensReconciledRecords(t *testing.T) {
+	uploadID := "0d5c3f2e-8f43-4a8e-9a52-3c1f2b7f1a10"
+	bc1048, _ := reportdef.Builtin("BC1048")
+	outstanding, _ := reportdef.Builtin("OUTSTANDING_BILLS")
+	p := &Processor{
+		cfg:    &config.Config{UnknownVendors: config.UnknownVendorsReject},
+		logger: slog.New(slog.NewTextHandler(io.Discard, nil)),
+	}
+
+	tests := []struct {
+		def     reportdef.Definition
+		vendors string
+		reopen  string
+		upsert  string
+	}{
+		{def: bc1048, vendors: "ListUnknownStagedChargebackVendors", reopen: "ReopenReconciledChargebacks", upsert: "UpsertChargebacks"},
+		{def: outstanding, vendors: "ListUnknownStagedNonIpacVendors", reopen: "ReopenReconciledNonIpacs", upsert: "UpsertNonIpacs"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.def.ReportType, func(t *testing.T) {
+			rec := &recordingDB{}
+			rows, err := p.mergeResubmitted(context.Background(), db.New(rec), uploadID, tc.def)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if rows != 1 {
+				t.Errorf("rows upserted = %d, want 1", rows)
+			}
+
+			want := []string{
+				tc.vendors,
+				fmt.Sprintf("SetStatusHistoryNote(%s)", reopenedNote(tc.def.ReportType, uploadID)),
+				tc.reopen,
+				fmt.Sprintf("SetStatusHistoryNote(Corrected row resubmitted from %s upload %s)", tc.def.ReportType, uploadID),
+				tc.upsert,
+			}
+			if !reflect.DeepEqual(rec.calls, want) {
+				t.Errorf("statements = %q, want %q", rec.calls, want)
+			}
+		})
+	}
+}
+
+func TestMergeResubmittedChecksVendors(t *testing.T) {
+	uploadID := "0d5c3f2e-8f43-4a8e-9a52-3c1f2b7f1a10"
+	bc1048, _ := reportdef.Builtin("BC1048")
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	t.Run("placeholder mode adds the vendor before the upsert", func(t *testing.T) {
+		p := &Processor{cfg: &config.Config{UnknownVendors: config.UnknownVendorsPlaceholder}, logger: logger}
+		rec := &recordingDB{unknownVendors: []string{"V1"}}
+		if _, err := p.mergeResubmitted(context.Background(), db.New(rec), uploadID, bc1048); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(rec.calls) < 2 || rec.calls[1] != "CreatePlaceholderVendors" {
+			t.Fatalf("statements = %q, want the placeholder vendors created first", rec.calls)
+		}
+		if last := rec.calls[len(rec.calls)-1]; last != "UpsertChargebacks" {
+			t.Errorf("last statement = %s, want UpsertChargebacks", last)
+		}
+	})
+
+	t.Run("reject mode refuses the row", func(t *testing.T) {
+		p := &Processor{cfg: &config.Config{UnknownVendors: config.UnknownVendorsReject}, logger: logger}
+		rec := &recordingDB{unknownVendors: []string{"V1"}}
+		_, err := p.mergeResubmitted(context.Background(), db.New(rec), uploadID, bc1048)
+		if !errors.Is(err, ErrResubmitInvalid) || !strings.Contains(err.Error(), "V1") {
+			t.Fatalf("expected ErrResubmitInvalid naming V1, got %v", err)
+		}
+		if want := []string{"ListUnknownStagedChargebackVendors"}; !reflect.DeepEqual(rec.calls, want) {
+			t.Errorf("statements = %q, want %q", rec.calls, want)
+		}
+	})
+}
+
+func TestResubmitRefusal(t *testing.T) {
+	uploadID := pgtype.UUID{Bytes: uuid.New(), Valid: true}
+	laterID := pgtype.UUID{Bytes: uuid.New(), Valid: true}
+
+	tests := []struct {
+		name   string
+		status string
+		latest pgtype.UUID
+		want   string
+	}{
+		{name: "latest merged upload", status: "COMPLETE_WITH_ISSUES", latest: uploadID},
+		{name: "not merged", status: "STAGED", latest: laterID, want: "upload is STAGED"},
+		{name: "rolled back", status: "ROLLED_BACK", latest: laterID, want: "upload is ROLLED_BACK"},
+		{name: "replaced by a later upload", status: "COMPLETE_WITH_ISSUES", latest: laterID, want: "a later BC1048 upload has been merged since"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			upload := db.GetUploadRow{ID: uploadID, ReportType: "BC1048", Status: tc.status}
+			err := resubmitRefusal(upload, tc.latest)
+			if tc.want == "" {
+				if err != nil {
+					t.Fatalf("unexpected refusal: %v", err)
+				}
+				return
+			}
+			if !errors.Is(err, ErrResubmitRefused) || !strings.Contains(err.Error(), tc.want) {
+				t.Errorf("expected ErrResubmitRefused mentioning %q, got %v", tc.want, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,97 @@
+package processor
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jjckrbbt/cdms/backend/internal/cdms_data/progress"
+	"github.com/jjckrbbt/cdms/backend/internal/db"
+)
+
+// countingReader counts the bytes read through it.
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// progressReporter publishes the progress of processing one upload. Events go out
+// on the pool rather than the processing transaction, whose notifications would only
+// be delivered once it commits. A nil reporter publishes nothing, and failing to
+// publish never fails processing.
+type progressReporter struct {
+	queries *db.Queries
+	logger  *slog.Logger
+	event   progress.Event
+	bytes   *countingReader
+}
+
+func (p *Processor) newProgressReporter(uploadID string) *progressReporter {
+	id, err := uuid.Parse(uploadID)
+	if err != nil {
+		return nil
+	}
+	return &progressReporter{
+		queries: db.New(p.db.Pool),
+		logger:  p.logger,
+		event:   progress.Event{Type: progress.TypeProgress, UploadID: id},
+	}
+}
+
+// countBytes reports how much of a file of the given size has been read through r.
+func (r *progressReporter) countBytes(src io.Reader, total int64) io.Reader {
+	if r == nil {
+		return src
+	}
+	r.bytes = &countingReader{r: src}
+	r.event.BytesTotal = total
+	return r.bytes
+}
+
+// phase publishes the start of a phase.
+func (r *progressReporter) phase(ctx context.Context, phase string) {
+	if r == nil {
+		return
+	}
+	r.event.Phase = phase
+	r.publish(ctx)
+}
+
+// rows adds a batch's counts and publishes them.
+func (r *progressReporter) rows(ctx context.Context, read, converted, rejected int) {
+	if r == nil {
+		return
+	}
+	r.event.RowsRead += int64(read)
+	r.event.RowsConverted += int64(converted)
+	r.event.RowsRejected += int64(rejected)
+	r.publish(ctx)
+}
+
+func (r *progressReporter) publish(ctx context.Context) {
+	r.event.At = time.Now()
+	if r.bytes != nil {
+		r.event.BytesRead = r.bytes.n
+	}
+	payload, err := json.Marshal(r.event)
+	if err != nil {
+		r.logger.WarnContext(ctx, "Failed to encode upload progress", "upload_id", r.event.UploadID, "error", err)
+		return
+	}
+	if err := r.queries.PublishUploadProgress(ctx, db.PublishUploadProgressParams{
+		UploadID: pgtype.UUID{Bytes: r.event.UploadID, Valid: true},
+		Event:    payload,
+	}); err != nil {
+		r.logger.WarnContext(ctx, "Failed to publish upload progress", "upload_id", r.event.UploadID, "phase", r.event.Phase, "error", err)
+	}
+}
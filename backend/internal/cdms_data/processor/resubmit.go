@@ -0,0 +1,251 @@
+package processor
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jjckrbbt/cdms/backend/internal/cdms_data/reportdef"
+	"github.com/jjckrbbt/cdms/backend/internal/db"
+)
+
+// ErrResubmitRefused marks a removed row that cannot be resubmitted in its current state.
+var ErrResubmitRefused = errors.New("removed row cannot be resubmitted")
+
+// ErrResubmitInvalid marks corrections that are malformed or still fail validation.
+var ErrResubmitInvalid = errors.New("corrected row is invalid")
+
+// ResubmitResult is a removed row closed by a successful resubmission.
+type ResubmitResult struct {
+	RemovedRow   db.RemovedRowsLog `json:"removed_row"`
+	RowsUpserted int64             `json:"rows_upserted"`
+}
+
+// ResubmitRemovedRow applies corrections, keyed by report column header, to an open
+// removed row and runs it through the same conversion, validation and upsert as the
+// upload it was removed from, which must be the latest merged upload of its report
+// type. The row is marked CORRECTED only if it merges. The change
+// is audited as userID and tagged with the original upload, so rolling that upload
+// back also undoes the resubmission.
+func (p *Processor) ResubmitRemovedRow(ctx context.Context, rowID uuid.UUID, corrections map[string]string, note string, userID int64) (ResubmitResult, error) {
+	tx, err := p.db.Pool.Begin(ctx)
+	if err != nil {
+		return ResubmitResult{}, fmt.Errorf("failed to begin pgx transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	setQuery := fmt.Sprintf("SET LOCAL app.user_id = %d", userID)
+	if _, err := tx.Exec(ctx, setQuery); err != nil {
+		return ResubmitResult{}, fmt.Errorf("failed to set user for transaction: %w", err)
+	}
+
+	q := db.New(tx)
+
+	removed, err := q.GetRemovedRowForUpdate(ctx, pgtype.UUID{Bytes: rowID, Valid: true})
+	if err != nil {
+		return ResubmitResult{}, err
+	}
+	if removed.ResolutionStatus != "OPEN" {
+		return ResubmitResult{}, fmt.Errorf("%w: row is already %s", ErrResubmitRefused, removed.ResolutionStatus)
+	}
+
+	upload, err := q.GetUpload(ctx, removed.UploadID)
+	if err != nil {
+		return ResubmitResult{}, fmt.Errorf("failed to get upload: %w", err)
+	}
+	def, err := loadDefinition(ctx, q, removed.ReportType)
+	if err != nil {
+		return ResubmitResult{}, err
+	}
+	// The row is merged into the same records as its report, so like a rollback the
+	// resubmission is refused while a merge of the report holds the lock, and keeps
+	// later merges out until it commits.
+	locked, lockName, err := tryLockMerge(ctx, q, def)
+	if err != nil {
+		return ResubmitResult{}, err
+	}
+	if !locked {
+		return ResubmitResult{}, fmt.Errorf("%w: a %s merge is in progress, try again once it finishes", ErrResubmitRefused, lockName)
+	}
+
+	latest, err := q.GetLatestMergedUploadID(ctx, upload.ReportType)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return ResubmitResult{}, fmt.Errorf("failed to find latest %s upload: %w", upload.ReportType, err)
+	}
+	if err := resubmitRefusal(upload, latest); err != nil {
+		return ResubmitResult{}, err
+	}
+	uploadID := uuid.UUID(removed.UploadID.Bytes).String()
+
+	setQuery = fmt.Sprintf("SET LOCAL app.upload_id = '%s'", uploadID)
+	if _, err := tx.Exec(ctx, setQuery); err != nil {
+		return ResubmitResult{}, fmt.Errorf("failed to set upload for transaction: %w", err)
+	}
+
+	positions, err := headerPositions(upload.HeaderCheck)
+	if err != nil {
+		return ResubmitResult{}, err
+	}
+	record, headerMap, err := correctRecord(def, positions, removed.OriginalRowData, corrections)
+	if err != nil {
+		return ResubmitResult{}, err
+	}
+
+	batch, err := p.convertBatch(ctx, q, uploadID, def, [][]string{record}, headerMap, map[string]bool{})
+	if err != nil {
+		return ResubmitResult{}, err
+	}
+	if batch.rowCount == 0 {
+		return ResubmitResult{}, fmt.Errorf("%w: %s", ErrResubmitInvalid, batch.removedRows[0].ReasonForRemoval)
+	}
+
+	staging := stagingTables[def.Entity]
+	if _, err := tx.Exec(ctx, staging.createSQL); err != nil {
+		return ResubmitResult{}, fmt.Errorf("failed to create staging table %s: %w", staging.name, err)
+	}
+	if _, err := tx.CopyFrom(ctx, pgx.Identifier{staging.name}, staging.columns, batch.rows); err != nil {
+		return ResubmitResult{}, fmt.Errorf("failed to copy corrected row into %s: %w", staging.name, err)
+	}
+
+	rowsUpserted, err := p.mergeResubmitted(ctx, q, uploadID, def)
+	if err != nil {
+		return ResubmitResult{}, err
+	}
+
+	correctedJSON, err := json.Marshal(record)
+	if err != nil {
+		return ResubmitResult{}, fmt.Errorf("failed to encode corrected row: %w", err)
+	}
+	resolved, err := q.ResolveRemovedRow(ctx, db.ResolveRemovedRowParams{
+		ResolutionStatus: "CORRECTED",
+		CorrectedRowData: correctedJSON,
+		ResolutionNote:   pgtype.Text{String: note, Valid: note != ""},
+		ResolvedByUserID: userID,
+		ID:               removed.ID,
+	})
+	if err != nil {
+		return ResubmitResult{}, fmt.Errorf("failed to mark removed row corrected: %w", err)
+	}
+
+	p.logger.InfoContext(ctx, "Removed row resubmitted", "removed_row_id", rowID, "upload_id", uploadID, "rows_upserted", rowsUpserted, "resolved_by", userID)
+	return ResubmitResult{RemovedRow: resolved, RowsUpserted: rowsUpserted}, tx.Commit(ctx)
+}
+
+// resubmitRefusal explains why rows removed from an upload cannot be resubmitted: the
+// upload has not been merged, or latest is a later merged upload of its report type,
+// whose report no longer holds the row's record as the upload's did. It returns nil
+// when neither applies.
+func resubmitRefusal(upload db.GetUploadRow, latest pgtype.UUID) error {
+	if upload.Status != "COMPLETE" && upload.Status != "COMPLETE_WITH_ISSUES" {
+		return fmt.Errorf("%w: upload is %s, rows can be resubmitted once it has been merged", ErrResubmitRefused, upload.Status)
+	}
+	if latest != upload.ID {
+		return fmt.Errorf("%w: a later %s upload has been merged since and replaces its rows", ErrResubmitRefused, upload.ReportType)
+	}
+	return nil
+}
+
+// mergeResubmitted upserts a corrected row from the staging table of its entity. Its
+// vendor code is checked against the vendor directory as in a merge, and a record its
+// upload reconciled off report while the row was set aside is reopened first, as it
+// would have been had the row merged with the upload.
+func (p *Processor) mergeResubmitted(ctx context.Context, q *db.Queries, uploadID string, def reportdef.Definition) (int64, error) {
+	if err := p.ensureStagedVendors(ctx, q, uploadID, def); err != nil {
+		if errors.Is(err, errUnknownVendors) {
+			return 0, fmt.Errorf("%w: %v", ErrResubmitInvalid, err)
+		}
+		return 0, err
+	}
+
+	reopened, err := reopenStaged(ctx, q, uploadID, def)
+	if err != nil {
+		return 0, err
+	}
+	if reopened > 0 {
+		p.logger.InfoContext(ctx, "Resubmitted row reopened a reconciled record", "upload_id", uploadID, "report_type", def.ReportType)
+	}
+
+	if err := q.SetStatusHistoryNote(ctx, fmt.Sprintf("Corrected row resubmitted from %s upload %s", def.ReportType, uploadID)); err != nil {
+		return 0, fmt.Errorf("failed to set status history note: %w", err)
+	}
+	return upsertStaged(ctx, q, def.Entity)
+}
+
+// reopenStaged reopens records reconciled off report that are in the staging table of
+// the entity, under the status history note of a record back on its report.
+func reopenStaged(ctx context.Context, q *db.Queries, uploadID string, def reportdef.Definition) (int64, error) {
+	var reopen func(context.Context) (int64, error)
+	switch def.Entity {
+	case reportdef.EntityChargeback:
+		reopen = q.ReopenReconciledChargebacks
+	case reportdef.EntityNonIpac:
+		reopen = q.ReopenReconciledNonIpacs
+	default:
+		return 0, nil
+	}
+
+	if err := q.SetStatusHistoryNote(ctx, reopenedNote(def.ReportType, uploadID)); err != nil {
+		return 0, fmt.Errorf("failed to set status history note: %w", err)
+	}
+	reopened, err := reopen(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to reopen records back on report: %w", err)
+	}
+	return reopened, nil
+}
+
+// headerPositions reads the column positions recorded by the upload's header check.
+// Uploads processed before headers were matched by name have none.
+func headerPositions(headerCheck []byte) (map[string]int, error) {
+	if len(headerCheck) == 0 {
+		return nil, nil
+	}
+	var match reportdef.HeaderMatch
+	if err := json.Unmarshal(headerCheck, &match); err != nil {
+		return nil, fmt.Errorf("failed to decode upload header check: %w", err)
+	}
+	return match.Positions, nil
+}
+
+// correctRecord rebuilds a removed row from its logged fields and applies corrections
+// keyed by the definition's column headers. positions are where each header sat in the
+// upload's file; without them the fields are taken to be in the definition's order. It
+// returns the corrected record with the header map the converters expect.
+func correctRecord(def reportdef.Definition, positions map[string]int, originalRowData []byte, corrections map[string]string) ([]string, map[string]int, error) {
+	headerMap := positions
+	if headerMap == nil {
+		headerMap = make(map[string]int, len(def.Columns))
+		for i, h := range def.Headers() {
+			headerMap[h] = i
+		}
+	}
+
+	var record []string
+	if err := json.Unmarshal(originalRowData, &record); err != nil {
+		return nil, nil, fmt.Errorf("%w: original row data is not a list of fields: %v", ErrResubmitInvalid, err)
+	}
+	for _, idx := range headerMap {
+		for len(record) <= idx {
+			record = append(record, "")
+		}
+	}
+
+	columns := make([]string, 0, len(corrections))
+	for column := range corrections {
+		columns = append(columns, column)
+	}
+	sort.Strings(columns)
+	for _, column := range columns {
+		idx, ok := headerMap[column]
+		if !ok {
+			return nil, nil, fmt.Errorf("%w: %s has no column %q", ErrResubmitInvalid, def.ReportType, column)
+		}
+		record[idx] = corrections[column]
+	}
+	return record, headerMap, nil
+}
@@ -0,0 +1,157 @@
+package processor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jjckrbbt/cdms/backend/internal/cdms_data/reportdef"
+	"github.com/jjckrbbt/cdms/backend/internal/db"
+)
+
+// ErrRollbackRefused marks a rollback that is not allowed for the upload as it stands,
+// as opposed to one that failed part way.
+var ErrRollbackRefused = errors.New("upload cannot be rolled back")
+
+// foreignKeyViolation is the SQLSTATE raised when a deleted row is still referenced.
+const foreignKeyViolation = "23503"
+
+// RollbackResult counts the records a rollback touched.
+type RollbackResult struct {
+	RowsRestored int64     `json:"rows_restored"`
+	RowsDeleted  int64     `json:"rows_deleted"`
+	Upload       db.Upload `json:"upload"`
+}
+
+// RollbackUpload restores the live table of a merged upload to its state before the
+// merge, using the audit rows tagged with the upload: updated records get their old
+// values back and inserted records are deleted. Only the latest merged upload of its
+// report type can be rolled back, and only while nothing else has changed the records
+// it touched. The rollback is audited as userID.
+func (p *Processor) RollbackUpload(ctx context.Context, uploadID uuid.UUID, userID int64) (RollbackResult, error) {
+	pgUploadID := pgtype.UUID{Bytes: uploadID, Valid: true}
+
+	tx, err := p.db.Pool.Begin(ctx)
+	if err != nil {
+		return RollbackResult{}, fmt.Errorf("failed to begin pgx transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	setQuery := fmt.Sprintf("SET LOCAL app.user_id = %d", userID)
+	if _, err := tx.Exec(ctx, setQuery); err != nil {
+		return RollbackResult{}, fmt.Errorf("failed to set user for transaction: %w", err)
+	}
+
+	q := db.New(tx)
+
+	upload, err := q.GetUpload(ctx, pgUploadID)
+	if err != nil {
+		return RollbackResult{}, err
+	}
+
+	def, err := loadDefinition(ctx, q, upload.ReportType)
+	if err != nil {
+		return RollbackResult{}, err
+	}
+	// A rollback changes the same records as a merge of the report, so it must not
+	// overlap one. It is made while the user waits, so it is refused rather than queued.
+	locked, lockName, err := tryLockMerge(ctx, q, def)
+	if err != nil {
+		return RollbackResult{}, err
+	}
+	if !locked {
+		return RollbackResult{}, fmt.Errorf("%w: a %s merge is in progress, try again once it finishes", ErrRollbackRefused, lockName)
+	}
+
+	// With no merged upload of the report type there is no latest one, and the upload
+	// is refused as not merged.
+	latest, err := q.GetLatestMergedUploadID(ctx, upload.ReportType)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return RollbackResult{}, fmt.Errorf("failed to find latest %s upload: %w", upload.ReportType, err)
+	}
+	changes, err := q.CountChangesSinceUpload(ctx, pgUploadID)
+	if err != nil {
+		return RollbackResult{}, fmt.Errorf("failed to check for later changes: %w", err)
+	}
+	if err := rollbackRefusal(upload, latest, changes); err != nil {
+		return RollbackResult{}, err
+	}
+
+	if err := q.SetStatusHistoryNote(ctx, fmt.Sprintf("Rolled back %s upload %s", upload.ReportType, uploadID)); err != nil {
+		return RollbackResult{}, fmt.Errorf("failed to set status history note: %w", err)
+	}
+
+	result, err := restoreFromAudit(ctx, q, def.Entity, pgUploadID)
+	if err != nil {
+		return RollbackResult{}, restoreError(err)
+	}
+
+	result.Upload, err = q.MarkUploadRolledBack(ctx, db.MarkUploadRolledBackParams{
+		UserID: userID,
+		ID:     pgUploadID,
+	})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return RollbackResult{}, fmt.Errorf("%w: upload status changed during rollback", ErrRollbackRefused)
+		}
+		return RollbackResult{}, fmt.Errorf("failed to mark upload rolled back: %w", err)
+	}
+
+	p.logger.InfoContext(ctx, "Upload rolled back", "upload_id", uploadID, "report_type", upload.ReportType, "rows_restored", result.RowsRestored, "rows_deleted", result.RowsDeleted, "rolled_back_by", userID)
+	return result, tx.Commit(ctx)
+}
+
+// rollbackRefusal explains why an upload cannot be rolled back: it was never merged or
+// has been rolled back, latest is a later merged upload of its report type, or changes
+// have been made since to records it merged. It returns nil when none applies.
+func rollbackRefusal(upload db.GetUploadRow, latest pgtype.UUID, changesSince int64) error {
+	if upload.Status != "COMPLETE" && upload.Status != "COMPLETE_WITH_ISSUES" {
+		return fmt.Errorf("%w: upload is %s, only merged uploads can be rolled back", ErrRollbackRefused, upload.Status)
+	}
+	if latest != upload.ID {
+		return fmt.Errorf("%w: a later %s upload has been merged since", ErrRollbackRefused, upload.ReportType)
+	}
+	if changesSince > 0 {
+		return fmt.Errorf("%w: %d changes have been made since to records it merged", ErrRollbackRefused, changesSince)
+	}
+	return nil
+}
+
+// restoreError refuses the rollback when a record the upload created cannot be deleted
+// because something now references it, and passes any other error through.
+func restoreError(err error) error {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == foreignKeyViolation {
+		return fmt.Errorf("%w: records it created are now referenced elsewhere (%s)", ErrRollbackRefused, pgErr.ConstraintName)
+	}
+	return err
+}
+
+// restoreFromAudit undoes the upload's changes to the live table of its entity.
+func restoreFromAudit(ctx context.Context, q *db.Queries, entity reportdef.Entity, uploadID pgtype.UUID) (RollbackResult, error) {
+	var restore, remove func(context.Context, pgtype.UUID) (int64, error)
+	switch entity {
+	case reportdef.EntityChargeback:
+		restore, remove = q.RestoreChargebacksFromAudit, q.DeleteChargebacksInsertedByUpload
+	case reportdef.EntityNonIpac:
+		restore, remove = q.RestoreNonIpacsFromAudit, q.DeleteNonIpacsInsertedByUpload
+	case reportdef.EntityAgencyBureau:
+		restore, remove = q.RestoreAgencyBureausFromAudit, q.DeleteAgencyBureausInsertedByUpload
+	default:
+		return RollbackResult{}, fmt.Errorf("unknown entity %q", entity)
+	}
+
+	restored, err := restore(ctx, uploadID)
+	if err != nil {
+		return RollbackResult{}, fmt.Errorf("failed to restore records: %w", err)
+	}
+	deleted, err := remove(ctx, uploadID)
+	if err != nil {
+		return RollbackResult{}, fmt.Errorf("failed to delete records created by upload: %w", err)
+	}
+	return RollbackResult{RowsRestored: restored, RowsDeleted: deleted}, nil
+}
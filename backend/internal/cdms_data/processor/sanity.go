@@ -0,0 +1,139 @@
+package processor
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jjckrbbt/cdms/backend/internal/cdms_data/reportdef"
+	"github.com/jjckrbbt/cdms/backend/internal/config"
+	"github.com/jjckrbbt/cdms/backend/internal/db"
+)
+
+// errSanityCheck marks an upload stopped by the mass-deactivation guard.
+var errSanityCheck = errors.New("sanity check failed")
+
+// SanityCheck holds the numbers behind the mass-deactivation guard for one run of an
+// upload. It is stored on the uploads row whether or not the check passed.
+type SanityCheck struct {
+	ReportType        string   `json:"report_type"`
+	ActiveRecords     int64    `json:"active_records"`
+	DeactivatedRows   int64    `json:"deactivated_rows"`
+	DeactivatedPct    float64  `json:"deactivated_pct"`
+	MaxDeactivatedPct float64  `json:"max_deactivated_pct"`
+	RowsStaged        int64    `json:"rows_staged"`
+	PreviousRows      *int64   `json:"previous_rows"` // rows staged by the last successful upload, if any
+	RowDropPct        float64  `json:"row_drop_pct"`
+	MaxRowDropPct     float64  `json:"max_row_drop_pct"`
+	Failures          []string `json:"failures"`
+	Passed            bool     `json:"passed"`
+	Overridden        bool     `json:"overridden"`
+}
+
+// evaluateSanity applies threshold to the counts of a staged upload. A zero limit in
+// threshold disables that check.
+func evaluateSanity(reportType string, threshold config.SanityThreshold, activeRecords, deactivatedRows, rowsStaged int64, previousRows *int64) SanityCheck {
+	check := SanityCheck{
+		ReportType:        reportType,
+		ActiveRecords:     activeRecords,
+		DeactivatedRows:   deactivatedRows,
+		MaxDeactivatedPct: threshold.MaxDeactivatedPct,
+		RowsStaged:        rowsStaged,
+		PreviousRows:      previousRows,
+		MaxRowDropPct:     threshold.MaxRowDropPct,
+		Failures:          []string{},
+	}
+
+	if activeRecords > 0 {
+		check.DeactivatedPct = 100 * float64(deactivatedRows) / float64(activeRecords)
+		if threshold.MaxDeactivatedPct > 0 && check.DeactivatedPct > threshold.MaxDeactivatedPct {
+			check.Failures = append(check.Failures, fmt.Sprintf("%.1f%% of %d active records would be deactivated (limit %.1f%%)",
+				check.DeactivatedPct, activeRecords, threshold.MaxDeactivatedPct))
+		}
+	}
+
+	if previousRows != nil && *previousRows > 0 && rowsStaged < *previousRows {
+		check.RowDropPct = 100 * float64(*previousRows-rowsStaged) / float64(*previousRows)
+		if threshold.MaxRowDropPct > 0 && check.RowDropPct > threshold.MaxRowDropPct {
+			check.Failures = append(check.Failures, fmt.Sprintf("row count fell %.1f%% from %d to %d versus the last successful upload (limit %.1f%%)",
+				check.RowDropPct, *previousRows, rowsStaged, threshold.MaxRowDropPct))
+		}
+	}
+
+	check.Passed = len(check.Failures) == 0
+	return check
+}
+
+// err returns errSanityCheck describing the failures, or nil if the check passed or
+// was overridden.
+func (c SanityCheck) err() error {
+	if c.Passed || c.Overridden {
+		return nil
+	}
+	return fmt.Errorf("%w: %s", errSanityCheck, strings.Join(c.Failures, "; "))
+}
+
+// checkSanity evaluates the guard against the rows in the report's staging table. It
+// must run after staging and before the merge.
+func (p *Processor) checkSanity(ctx context.Context, q *db.Queries, def reportdef.Definition, rowsStaged int64, override bool) (SanityCheck, error) {
+	reportType := def.ReportType
+	var activeRecords, deactivatedRows int64
+	switch def.Entity {
+	case reportdef.EntityChargeback:
+		summary, err := q.PreviewChargebackMerge(ctx, db.ChargebackReportingSource(def.ReportingSource))
+		if err != nil {
+			return SanityCheck{}, fmt.Errorf("failed to count chargebacks for sanity check: %w", err)
+		}
+		activeRecords, deactivatedRows = summary.ActiveRows, summary.DeactivatedRows
+	case reportdef.EntityNonIpac:
+		summary, err := q.PreviewNonIpacMerge(ctx, db.NonipacReportingSource(def.ReportingSource))
+		if err != nil {
+			return SanityCheck{}, fmt.Errorf("failed to count non-ipacs for sanity check: %w", err)
+		}
+		activeRecords, deactivatedRows = summary.ActiveRows, summary.DeactivatedRows
+	}
+	if rowsStaged == 0 {
+		// An empty report skips the merge entirely, so nothing is deactivated.
+		deactivatedRows = 0
+	}
+
+	var previousRows *int64
+	lastCount, err := q.GetLastSuccessfulUploadRowCount(ctx, reportType)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return SanityCheck{}, fmt.Errorf("failed to read last successful upload for sanity check: %w", err)
+	}
+	if err == nil && lastCount.Valid {
+		n := int64(lastCount.Int32)
+		previousRows = &n
+	}
+
+	var threshold config.SanityThreshold
+	if p.cfg != nil {
+		threshold = p.cfg.SanityThresholdFor(reportType)
+	}
+
+	check := evaluateSanity(reportType, threshold, activeRecords, deactivatedRows, rowsStaged, previousRows)
+	check.Overridden = override && !check.Passed
+	return check, nil
+}
+
+// storeSanityCheck saves the result of the guard on the uploads row.
+func (p *Processor) storeSanityCheck(ctx context.Context, uploadID string, check SanityCheck) error {
+	uid, err := uuid.Parse(uploadID)
+	if err != nil {
+		return fmt.Errorf("invalid upload id %q: %w", uploadID, err)
+	}
+	checkJSON, err := json.Marshal(check)
+	if err != nil {
+		return fmt.Errorf("failed to encode sanity check: %w", err)
+	}
+	return db.New(p.db.Pool).SetUploadSanityCheck(ctx, db.SetUploadSanityCheckParams{
+		SanityCheck: checkJSON,
+		ID:          pgtype.UUID{Bytes: uid, Valid: true},
+	})
+}
@@ -0,0 +1,74 @@
+package processor
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/jjckrbbt/cdms/backend/internal/cdms_data/model"
+)
+
+// Rule codes of the validation issues recorded on removed rows.
+const (
+	RuleRequired            = "REQUIRED"
+	RuleALCFormat           = "ALC_FORMAT"
+	RuleFormatInvalid       = "FORMAT_INVALID"
+	RuleEnumInvalid         = "ENUM_INVALID"
+	RuleMaxLength           = "MAX_LENGTH"
+	RuleDateUnparsable      = "DATE_UNPARSABLE"
+	RuleNumberInvalid       = "NUMBER_INVALID"
+	RuleBooleanInvalid      = "BOOLEAN_INVALID"
+	RuleCrossSourceConflict = "CROSS_SOURCE_CONFLICT"
+	RuleDuplicateInFile     = "DUPLICATE_IN_FILE"
+	RuleUnknownVendor       = "UNKNOWN_VENDOR"
+	// RuleUnclassified covers errors that did not come from a column check.
+	RuleUnclassified = "UNCLASSIFIED"
+)
+
+// fieldError is a validation failure of one column. Its message is the text that ends
+// up in reason_for_removal; the issue is the machine-readable form.
+type fieldError struct {
+	issue model.ValidationIssue
+}
+
+func (e *fieldError) Error() string { return e.issue.Message }
+
+func newFieldError(column, rawValue, rule, format string, args ...any) *fieldError {
+	return &fieldError{issue: model.ValidationIssue{
+		Column:   column,
+		RawValue: rawValue,
+		Rule:     rule,
+		Message:  fmt.Sprintf(format, args...),
+	}}
+}
+
+// missingField is the error for a required column that is absent or empty.
+func missingField(column string) *fieldError {
+	return newFieldError(column, "", RuleRequired, "missing '%s'", column)
+}
+
+// issuesFrom flattens an error returned by convertRecord or parseColumn, usually an
+// errors.Join of fieldErrors, into validation issues.
+func issuesFrom(err error) []model.ValidationIssue {
+	issues := []model.ValidationIssue{}
+	if err == nil {
+		return issues
+	}
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		for _, e := range joined.Unwrap() {
+			issues = append(issues, issuesFrom(e)...)
+		}
+		return issues
+	}
+	var fe *fieldError
+	if errors.As(err, &fe) {
+		return append(issues, fe.issue)
+	}
+	return append(issues, model.ValidationIssue{Rule: RuleUnclassified, Message: err.Error()})
+}
+
+// recordIssue is the single issue of a row rejected as a whole, attributed to the
+// column holding its business key.
+func recordIssue(record []string, headerMap map[string]int, column, rule, message string) []model.ValidationIssue {
+	raw, _ := getString(record, headerMap, column)
+	return []model.ValidationIssue{{Column: column, RawValue: raw, Rule: rule, Message: message}}
+}
@@ -0,0 +1,132 @@
+package processor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jjckrbbt/cdms/backend/internal/cdms_data/reportdef"
+	"github.com/jjckrbbt/cdms/backend/internal/config"
+	"github.com/jjckrbbt/cdms/backend/internal/db"
+)
+
+// errUnknownVendors marks a merge stopped because staged rows reference vendor codes
+// that are not in the vendor directory.
+var errUnknownVendors = errors.New("unknown vendor codes")
+
+// unknownVendorsNamed bounds how many unknown vendor codes an error names.
+const unknownVendorsNamed = 5
+
+// rejectsUnknownVendors reports whether rows of the report whose vendor code is not in
+// the vendor directory are set aside while staging. Otherwise they are staged and the
+// merge adds their vendor codes to the directory as placeholders.
+func (p *Processor) rejectsUnknownVendors(def reportdef.Definition) bool {
+	return def.Entity.VendorField() != "" && p.cfg.UnknownVendors != config.UnknownVendorsPlaceholder
+}
+
+// lookupKnownVendors returns which of the vendor codes in the records are in the vendor
+// directory. Codes staged by a vendor directory report earlier in the upload's bundle
+// count as known, because that report is merged first.
+func (p *Processor) lookupKnownVendors(ctx context.Context, q *db.Queries, uploadID string, records [][]string, headerMap map[string]int, vendorHeader string) (map[string]bool, error) {
+	known := make(map[string]bool)
+
+	seen := make(map[string]bool, len(records))
+	codes := make([]string, 0, len(records))
+	for _, record := range records {
+		code, _ := getString(record, headerMap, vendorHeader)
+		if code != "" && !seen[code] {
+			seen[code] = true
+			codes = append(codes, code)
+		}
+	}
+	if len(codes) == 0 {
+		return known, nil
+	}
+
+	uid, err := uuid.Parse(uploadID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid upload id %q: %w", uploadID, err)
+	}
+	directoryTypes, err := p.directoryReportTypes(ctx)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := q.ListKnownVendorCodes(ctx, db.ListKnownVendorCodesParams{
+		VendorCodes:          codes,
+		UploadID:             pgtype.UUID{Bytes: uid, Valid: true},
+		DirectoryReportTypes: directoryTypes,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up vendor codes: %w", err)
+	}
+	for _, code := range rows {
+		known[code] = true
+	}
+	return known, nil
+}
+
+// directoryReportTypes returns the report types that load the vendor directory.
+func (p *Processor) directoryReportTypes(ctx context.Context) ([]string, error) {
+	defs, err := p.Definitions(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var types []string
+	for _, def := range defs {
+		if def.Entity == reportdef.EntityAgencyBureau {
+			types = append(types, def.ReportType)
+		}
+	}
+	return types, nil
+}
+
+// ensureStagedVendors checks that every staged row's vendor code is in the vendor
+// directory before the upsert, whose foreign keys would otherwise fail it as a whole.
+// Rows with unknown codes were set aside while staging, but the directory report of
+// their bundle may since have been rejected. In placeholder mode the missing codes are
+// added to the directory, flagged for review.
+func (p *Processor) ensureStagedVendors(ctx context.Context, q *db.Queries, uploadID string, def reportdef.Definition) error {
+	var listUnknown func(context.Context) ([]string, error)
+	switch def.Entity {
+	case reportdef.EntityChargeback:
+		listUnknown = q.ListUnknownStagedChargebackVendors
+	case reportdef.EntityNonIpac:
+		listUnknown = q.ListUnknownStagedNonIpacVendors
+	default:
+		return nil
+	}
+
+	unknown, err := listUnknown(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to check staged vendor codes: %w", err)
+	}
+	if len(unknown) == 0 {
+		return nil
+	}
+
+	if p.cfg.UnknownVendors != config.UnknownVendorsPlaceholder {
+		return unknownVendorsError(unknown)
+	}
+	created, err := q.CreatePlaceholderVendors(ctx, unknown)
+	if err != nil {
+		return fmt.Errorf("failed to create placeholder vendors: %w", err)
+	}
+	p.logger.WarnContext(ctx, "Placeholder vendors created for unknown vendor codes", "upload_id", uploadID, "report_type", def.ReportType, "created", created, "vendor_codes", unknown)
+	return nil
+}
+
+// unknownVendorsError names the first few unknown vendor codes.
+func unknownVendorsError(codes []string) error {
+	named := codes
+	if len(named) > unknownVendorsNamed {
+		named = named[:unknownVendorsNamed]
+	}
+	list := strings.Join(named, ", ")
+	if len(codes) > len(named) {
+		list += fmt.Sprintf(" and %d more", len(codes)-len(named))
+	}
+	return fmt.Errorf("%w: staged rows reference vendor codes that are not in the vendor directory: %s", errUnknownVendors, list)
+}
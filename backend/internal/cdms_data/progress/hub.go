@@ -0,0 +1,130 @@
+package progress
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const (
+	// subscriberBuffer is how many events a slow client may fall behind by before
+	// progress events are dropped for it.
+	subscriberBuffer = 16
+	maxReconnectWait = 30 * time.Second
+)
+
+// Hub listens on Channel and fans events out to the subscribers of each upload.
+type Hub struct {
+	pool        *pgxpool.Pool
+	logger      *slog.Logger
+	mu          sync.Mutex
+	subscribers map[uuid.UUID]map[chan Event]struct{}
+}
+
+func NewHub(pool *pgxpool.Pool, logger *slog.Logger) *Hub {
+	return &Hub{
+		pool:        pool,
+		logger:      logger.With("component", "upload_progress_hub"),
+		subscribers: make(map[uuid.UUID]map[chan Event]struct{}),
+	}
+}
+
+// Run listens for events until ctx is done, reconnecting with a growing delay when the
+// connection is lost. Events sent while it is reconnecting are missed.
+func (h *Hub) Run(ctx context.Context) {
+	wait := time.Second
+	for {
+		err := h.listen(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		h.logger.WarnContext(ctx, "Upload progress listener disconnected; reconnecting", "error", err, "retry_in", wait)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(wait):
+		}
+		wait = min(wait*2, maxReconnectWait)
+	}
+}
+
+// listen takes a connection out of the pool for LISTEN, since it stays subscribed to
+// the channel for as long as it is open.
+func (h *Hub) listen(ctx context.Context) error {
+	pooled, err := h.pool.Acquire(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to acquire connection: %w", err)
+	}
+	conn := pooled.Hijack()
+	defer conn.Close(context.Background())
+
+	if _, err := conn.Exec(ctx, "LISTEN "+Channel); err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", Channel, err)
+	}
+	h.logger.InfoContext(ctx, "Listening for upload progress", "channel", Channel)
+
+	for {
+		notification, err := conn.WaitForNotification(ctx)
+		if err != nil {
+			return err
+		}
+		h.dispatch(ctx, notification.Payload)
+	}
+}
+
+// Subscribe returns the events for an upload until the returned function is called.
+func (h *Hub) Subscribe(uploadID uuid.UUID) (<-chan Event, func()) {
+	ch := make(chan Event, subscriberBuffer)
+
+	h.mu.Lock()
+	if h.subscribers[uploadID] == nil {
+		h.subscribers[uploadID] = make(map[chan Event]struct{})
+	}
+	h.subscribers[uploadID][ch] = struct{}{}
+	h.mu.Unlock()
+
+	return ch, func() {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		delete(h.subscribers[uploadID], ch)
+		if len(h.subscribers[uploadID]) == 0 {
+			delete(h.subscribers, uploadID)
+		}
+	}
+}
+
+func (h *Hub) dispatch(ctx context.Context, payload string) {
+	var event Event
+	if err := json.Unmarshal([]byte(payload), &event); err != nil {
+		h.logger.WarnContext(ctx, "Ignoring malformed upload progress notification", "error", err)
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for ch := range h.subscribers[event.UploadID] {
+		deliver(ch, event)
+	}
+}
+
+// deliver sends an event without blocking the listener. A client that has fallen
+// behind loses its oldest event rather than holding up every other client.
+func deliver(ch chan Event, event Event) {
+	for {
+		select {
+		case ch <- event:
+			return
+		default:
+		}
+		select {
+		case <-ch:
+		default:
+		}
+	}
+}
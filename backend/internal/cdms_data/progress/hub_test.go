@@ -0,0 +1,58 @@
+package progress
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestHubDispatch(t *testing.T) {
+	h := NewHub(nil, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	ctx := context.Background()
+	uploadID, otherID := uuid.New(), uuid.New()
+
+	events, unsubscribe := h.Subscribe(uploadID)
+	payload, _ := json.Marshal(Event{Type: TypeProgress, UploadID: uploadID, Phase: PhaseStage, RowsRead: 5000})
+	other, _ := json.Marshal(Event{Type: TypeProgress, UploadID: otherID})
+
+	h.dispatch(ctx, string(other))
+	h.dispatch(ctx, "not json")
+	h.dispatch(ctx, string(payload))
+
+	select {
+	case event := <-events:
+		if event.Phase != PhaseStage || event.RowsRead != 5000 {
+			t.Errorf("got %+v", event)
+		}
+	default:
+		t.Fatal("expected the upload's event to be delivered")
+	}
+	select {
+	case event := <-events:
+		t.Errorf("expected no other events, got %+v", event)
+	default:
+	}
+
+	unsubscribe()
+	if len(h.subscribers) != 0 {
+		t.Errorf("expected no subscribers left, got %d", len(h.subscribers))
+	}
+	h.dispatch(ctx, string(payload))
+}
+
+func TestDeliverDropsOldestWhenFull(t *testing.T) {
+	ch := make(chan Event, 2)
+	for i := int64(1); i <= 3; i++ {
+		deliver(ch, Event{RowsRead: i})
+	}
+	if first := <-ch; first.RowsRead != 2 {
+		t.Errorf("expected the oldest event to be dropped, got %d first", first.RowsRead)
+	}
+	if second := <-ch; second.RowsRead != 3 {
+		t.Errorf("expected the newest event to be kept, got %d", second.RowsRead)
+	}
+}
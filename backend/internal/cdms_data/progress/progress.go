@@ -0,0 +1,52 @@
+// Package progress relays live progress of upload processing between server instances
+// over Postgres LISTEN/NOTIFY. The processor publishes progress events and a trigger on
+// uploads publishes status changes; a Hub in every instance listens and hands them to
+// the clients following an upload, and cancellation requests to the worker processing it.
+package progress
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Channel is the Postgres notification channel events are sent on.
+const Channel = "upload_progress"
+
+// Event types.
+const (
+	// TypeProgress events carry the processor's counters while it works on an upload.
+	TypeProgress = "progress"
+	// TypeStatus events are sent whenever an upload's status changes.
+	TypeStatus = "status"
+	// TypeCancel events are sent when a user asks for an upload being processed to be
+	// cancelled; the worker processing it stops.
+	TypeCancel = "cancel"
+)
+
+// Phases of processing an upload, in the order they happen. Rows rejected during
+// staging are logged batch by batch, so stage and log_removed_rows alternate.
+const (
+	PhaseDownload       = "download"
+	PhaseValidate       = "validate"
+	PhaseStage          = "stage"
+	PhaseLogRemovedRows = "log_removed_rows"
+	PhaseUpsert         = "upsert"
+)
+
+// Event is one progress or status update for an upload. BytesTotal is the stored size
+// of the file, so BytesRead/BytesTotal is how far through it the processor has read;
+// it is zero once staged rows are being merged.
+type Event struct {
+	Type          string    `json:"type"`
+	UploadID      uuid.UUID `json:"upload_id"`
+	Phase         string    `json:"phase,omitempty"`
+	RowsRead      int64     `json:"rows_read"`
+	RowsConverted int64     `json:"rows_converted"`
+	RowsRejected  int64     `json:"rows_rejected"`
+	BytesRead     int64     `json:"bytes_read"`
+	BytesTotal    int64     `json:"bytes_total"`
+	Status        string    `json:"status,omitempty"`
+	Error         string    `json:"error,omitempty"`
+	At            time.Time `json:"at"`
+}
@@ -0,0 +1,89 @@
+package reportdef
+
+import "strings"
+
+// HeaderMatch is how a file's header row lines up with a definition.
+type HeaderMatch struct {
+	// Positions maps each matched column's header to its index in the file.
+	Positions map[string]int `json:"positions"`
+	// Missing are the headers of mapped columns the file lacks. Any makes the file unusable.
+	Missing []string `json:"missing"`
+	// MissingIgnored are headers of columns the definition does not load that the file lacks.
+	MissingIgnored []string `json:"missing_ignored"`
+	// Unexpected are file headers that match no column, or repeat one already matched.
+	Unexpected []string `json:"unexpected"`
+	// Aliased are columns found under one of their aliases.
+	Aliased []AliasedHeader `json:"aliased"`
+}
+
+// AliasedHeader is a column found in the file under an alias.
+type AliasedHeader struct {
+	Header string `json:"header"`
+	Alias  string `json:"alias"`
+}
+
+// OK reports whether every column the definition loads was found.
+func (m HeaderMatch) OK() bool {
+	return len(m.Missing) == 0
+}
+
+// HasWarnings reports whether the file's headers differ from the definition in a way
+// that did not stop it from being read.
+func (m HeaderMatch) HasWarnings() bool {
+	return len(m.MissingIgnored) > 0 || len(m.Unexpected) > 0 || len(m.Aliased) > 0
+}
+
+// MatchHeaders finds the definition's columns in a file's header row by name or alias,
+// in any order and regardless of case. Columns with no fields may be absent.
+func (d Definition) MatchHeaders(actual []string) HeaderMatch {
+	byName := map[string]*Column{}
+	for i := range d.Columns {
+		c := &d.Columns[i]
+		byName[normalizeHeader(c.Header)] = c
+		for _, alias := range c.Aliases {
+			byName[normalizeHeader(alias)] = c
+		}
+	}
+
+	m := HeaderMatch{
+		Positions:      map[string]int{},
+		Missing:        []string{},
+		MissingIgnored: []string{},
+		Unexpected:     []string{},
+		Aliased:        []AliasedHeader{},
+	}
+	for i, h := range actual {
+		name := strings.TrimSpace(h)
+		c, ok := byName[normalizeHeader(name)]
+		if !ok {
+			m.Unexpected = append(m.Unexpected, name)
+			continue
+		}
+		if _, seen := m.Positions[c.Header]; seen {
+			m.Unexpected = append(m.Unexpected, name)
+			continue
+		}
+		m.Positions[c.Header] = i
+		if normalizeHeader(name) != normalizeHeader(c.Header) {
+			m.Aliased = append(m.Aliased, AliasedHeader{Header: c.Header, Alias: name})
+		}
+	}
+
+	for _, c := range d.Columns {
+		if _, ok := m.Positions[c.Header]; ok {
+			continue
+		}
+		if len(c.Fields) > 0 {
+			m.Missing = append(m.Missing, c.Header)
+		} else {
+			m.MissingIgnored = append(m.MissingIgnored, c.Header)
+		}
+	}
+	return m
+}
+
+// normalizeHeader folds a header for comparison: case, surrounding whitespace and runs
+// of inner whitespace are ignored.
+func normalizeHeader(h string) string {
+	return strings.ToLower(strings.Join(strings.Fields(h), " "))
+}
@@ -0,0 +1,389 @@
+// Package reportdef describes the reports CDMS ingests: the columns a file must have,
+// the entity field each column fills and how its values are parsed and checked.
+//
+// The definitions shipped with the application live in definitions/*.json. A newer
+// version of a definition can be published to the report_definitions table, so that a
+// renamed header or a new allowed value does not need a deploy. What a definition
+// cannot change is the entity it loads into or its reporting source, since the merge
+// into each entity's table is code.
+package reportdef
+
+import (
+	"bytes"
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/jjckrbbt/cdms/backend/internal/db"
+)
+
+// ErrInvalidDefinition is wrapped by every validation failure of a definition.
+var ErrInvalidDefinition = errors.New("invalid report definition")
+
+// Entity is the table a report's rows are merged into.
+type Entity string
+
+const (
+	EntityChargeback   Entity = "chargeback"
+	EntityNonIpac      Entity = "nonipac"
+	EntityAgencyBureau Entity = "agency_bureau"
+)
+
+// Parsers a column can use. Text is the default.
+const (
+	ParserText    = "text"
+	ParserInt16   = "int16"
+	ParserDecimal = "decimal"
+	ParserDate    = "date"
+	ParserBool    = "bool"
+)
+
+var knownParsers = map[string]bool{ParserText: true, ParserInt16: true, ParserDecimal: true, ParserDate: true, ParserBool: true}
+
+// Fixed entity columns filled by the processor rather than a report column.
+const (
+	FieldReportingSource = "reporting_source"
+	FieldIsActive        = "is_active"
+)
+
+// entitySpec is what the processor needs to know about an entity's staging table.
+type entitySpec struct {
+	// columns is the staging column order, including the fixed columns.
+	columns []string
+	// required fields must be filled by some report column.
+	required []string
+	// keyFields identify a record within a report and must come from required columns.
+	keyFields []string
+	// sources are the reporting sources a report may load the entity as. An entity
+	// without a reporting_source column has none.
+	sources []string
+	// loadOrder ranks entities so that the ones others reference by foreign key are
+	// loaded first.
+	loadOrder int
+	// vendorField references a vendor code in agency_bureau.
+	vendorField string
+}
+
+var entities = map[Entity]entitySpec{
+	EntityChargeback: {
+		columns:     []string{FieldReportingSource, "fund", "business_line", "region", "location_system", "program", "al_num", "source_num", "agreement_num", "title", "alc", "customer_tas", "task_subtask", "class_id", "customer_name", "org_code", "document_date", "accomp_date", "assigned_rebill_drn", "chargeback_amount", "statement", "bd_doc_num", "vendor", "articles_services", "reason_code", "action", FieldIsActive},
+		required:    []string{"fund", "business_line", "region", "program", "al_num", "source_num", "alc", "customer_tas", "task_subtask", "customer_name", "org_code", "document_date", "accomp_date", "chargeback_amount", "statement", "bd_doc_num", "vendor"},
+		keyFields:   []string{"bd_doc_num", "al_num"},
+		sources:     []string{string(db.ChargebackReportingSourceBC1048), string(db.ChargebackReportingSourceBC1300)},
+		loadOrder:   1,
+		vendorField: "vendor",
+	},
+	EntityNonIpac: {
+		columns:     []string{FieldReportingSource, "business_line", "billed_total_amount", "principle_amount", "interest_amount", "penalty_amount", "administration_charges_amount", "debit_outstanding_amount", "credit_total_amount", "credit_outstanding_amount", "title", "document_date", "address_code", "vendor", "debt_appeal_forbearance", "statement", "document_number", "vendor_code", "collection_due_date", "open_date", FieldIsActive},
+		required:    []string{"business_line", "billed_total_amount", "principle_amount", "interest_amount", "penalty_amount", "administration_charges_amount", "debit_outstanding_amount", "credit_total_amount", "credit_outstanding_amount", "document_date", "address_code", "vendor", "debt_appeal_forbearance", "statement", "document_number", "vendor_code", "collection_due_date", "open_date"},
+		keyFields:   []string{"document_number"},
+		sources:     []string{string(db.NonipacReportingSourceOUTSTANDINGBILLS)},
+		loadOrder:   1,
+		vendorField: "address_code",
+	},
+	EntityAgencyBureau: {
+		columns:   []string{"agency", "bureau_code", "vendor_code", "agency_location_code", "vendor_address_code", "name", "address_line_1", "address_line_2", "address_line_3", "city", "state", "zip", "status", "vendor_type", "reporting_attribute", "security_org", "transmit_to_vcss_flag"},
+		required:  []string{"agency", "bureau_code", "vendor_code"},
+		keyFields: []string{"vendor_code"},
+	},
+}
+
+// Columns returns the entity's staging columns in copy order.
+func (e Entity) Columns() []string {
+	return entities[e].columns
+}
+
+// KeyFields returns the fields that identify one of the entity's records in a report.
+func (e Entity) KeyFields() []string {
+	return entities[e].keyFields
+}
+
+// VendorField returns the field whose value must be a vendor code in agency_bureau, or
+// "" if the entity references none.
+func (e Entity) VendorField() string {
+	return entities[e].vendorField
+}
+
+// LoadOrder ranks the entity among those loaded together: agency_bureau, which
+// chargeback and nonipac rows reference, comes first.
+func (e Entity) LoadOrder() int {
+	return entities[e].loadOrder
+}
+
+// Definition is one version of a report type's layout.
+type Definition struct {
+	ReportType string `json:"report_type"`
+	// Version is assigned when the definition is published; definitions shipped with
+	// the application carry their own.
+	Version int32  `json:"version"`
+	Entity  Entity `json:"entity"`
+	// ReportingSource is written to the entity's reporting_source column and scopes
+	// the reconciliation of records that drop off the report.
+	ReportingSource string `json:"reporting_source,omitempty"`
+	// FilenamePattern is a regular expression, matched regardless of case, that
+	// recognises the report's files by name when several are uploaded as a bundle.
+	FilenamePattern string   `json:"filename_pattern,omitempty"`
+	Columns         []Column `json:"columns"`
+
+	filenamePattern *regexp.Regexp
+}
+
+// Column is one header of a report file.
+type Column struct {
+	Header string `json:"header"`
+	// Aliases are other names the header goes by in some exports. Headers and aliases
+	// are matched regardless of case and surrounding whitespace.
+	Aliases []string `json:"aliases,omitempty"`
+	// Fields are the entity columns the value is written to. A column with no fields
+	// must be present in the file but is otherwise ignored.
+	Fields []string `json:"fields,omitempty"`
+	Parser string   `json:"parser,omitempty"`
+	// Required rejects a row whose value is empty. Empty optional values are NULL,
+	// except for decimals and booleans, which default to zero and false.
+	Required bool `json:"required,omitempty"`
+	// Allowed lists the only values a text column may hold.
+	Allowed []string `json:"allowed,omitempty"`
+	// Pattern is a regular expression a text value must match. PatternHint describes
+	// it in rejection messages and PatternRule is the rule code recorded for a mismatch.
+	Pattern     string `json:"pattern,omitempty"`
+	PatternHint string `json:"pattern_hint,omitempty"`
+	PatternRule string `json:"pattern_rule,omitempty"`
+	MaxLength   int    `json:"max_length,omitempty"`
+
+	pattern *regexp.Regexp
+	allowed map[string]bool
+}
+
+// Match reports whether a text value matches the column's pattern, if it has one.
+func (c Column) Match(value string) bool {
+	return c.pattern == nil || c.pattern.MatchString(value)
+}
+
+// IsAllowed reports whether a text value is one of the column's allowed values, if it
+// has any.
+func (c Column) IsAllowed(value string) bool {
+	return c.allowed == nil || c.allowed[value]
+}
+
+// MatchesFilename reports whether a file name matches the definition's filename pattern.
+// A definition without one matches no file name.
+func (d Definition) MatchesFilename(name string) bool {
+	return d.filenamePattern != nil && d.filenamePattern.MatchString(name)
+}
+
+// Headers returns the header row the report's files must have.
+func (d Definition) Headers() []string {
+	headers := make([]string, len(d.Columns))
+	for i, c := range d.Columns {
+		headers[i] = c.Header
+	}
+	return headers
+}
+
+// HeaderFor returns the header of the column that fills field.
+func (d Definition) HeaderFor(field string) string {
+	for _, c := range d.Columns {
+		for _, f := range c.Fields {
+			if f == field {
+				return c.Header
+			}
+		}
+	}
+	return ""
+}
+
+var reportTypePattern = regexp.MustCompile(`^[A-Z][A-Z0-9_]*$`)
+
+// Parse decodes and validates a definition.
+func Parse(data []byte) (Definition, error) {
+	var d Definition
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&d); err != nil {
+		return Definition{}, fmt.Errorf("%w: %v", ErrInvalidDefinition, err)
+	}
+	if err := d.compile(); err != nil {
+		return Definition{}, err
+	}
+	return d, nil
+}
+
+// compile checks the definition against its entity and prepares its column checks.
+func (d *Definition) compile() error {
+	invalid := func(format string, args ...any) error {
+		return fmt.Errorf("%w: %s: %s", ErrInvalidDefinition, d.ReportType, fmt.Sprintf(format, args...))
+	}
+
+	if !reportTypePattern.MatchString(d.ReportType) {
+		return fmt.Errorf("%w: report type %q must be upper case letters, digits and underscores", ErrInvalidDefinition, d.ReportType)
+	}
+	spec, ok := entities[d.Entity]
+	if !ok {
+		return invalid("unknown entity %q", d.Entity)
+	}
+	if len(spec.sources) == 0 && d.ReportingSource != "" {
+		return invalid("entity %s has no reporting source", d.Entity)
+	}
+	if len(spec.sources) > 0 && !contains(spec.sources, d.ReportingSource) {
+		return invalid("reporting source %q is not one of %s", d.ReportingSource, strings.Join(spec.sources, ", "))
+	}
+	if len(d.Columns) == 0 {
+		return invalid("no columns")
+	}
+	if d.FilenamePattern != "" {
+		re, err := regexp.Compile("(?i)" + d.FilenamePattern)
+		if err != nil {
+			return invalid("invalid filename pattern: %v", err)
+		}
+		d.filenamePattern = re
+	}
+
+	fillable := map[string]bool{}
+	for _, f := range spec.columns {
+		if f != FieldReportingSource && f != FieldIsActive {
+			fillable[f] = true
+		}
+	}
+
+	names := map[string]string{}
+	filledBy := map[string]*Column{}
+	for i := range d.Columns {
+		c := &d.Columns[i]
+		c.Header = strings.TrimSpace(c.Header)
+		if c.Header == "" {
+			return invalid("column %d has no header", i+1)
+		}
+		for _, name := range append([]string{c.Header}, c.Aliases...) {
+			key := normalizeHeader(name)
+			if key == "" {
+				return invalid("column %q has an empty alias", c.Header)
+			}
+			if other, ok := names[key]; ok {
+				if other == c.Header {
+					return invalid("duplicate header %q", name)
+				}
+				return invalid("header %q of column %q is also a name of %q", name, c.Header, other)
+			}
+			names[key] = c.Header
+		}
+
+		if c.Parser == "" {
+			c.Parser = ParserText
+		}
+		if !knownParsers[c.Parser] {
+			return invalid("column %q has unknown parser %q", c.Header, c.Parser)
+		}
+		if c.Parser != ParserText && (len(c.Allowed) > 0 || c.Pattern != "" || c.MaxLength > 0) {
+			return invalid("column %q: allowed values, patterns and maximum lengths only apply to text", c.Header)
+		}
+		if c.MaxLength < 0 {
+			return invalid("column %q has a negative maximum length", c.Header)
+		}
+		if len(c.Allowed) > 0 {
+			c.allowed = make(map[string]bool, len(c.Allowed))
+			for _, v := range c.Allowed {
+				c.allowed[v] = true
+			}
+		}
+		if c.Pattern != "" {
+			re, err := regexp.Compile(c.Pattern)
+			if err != nil {
+				return invalid("column %q has an invalid pattern: %v", c.Header, err)
+			}
+			c.pattern = re
+		}
+
+		for _, f := range c.Fields {
+			if !fillable[f] {
+				return invalid("column %q fills unknown %s field %q", c.Header, d.Entity, f)
+			}
+			if other, ok := filledBy[f]; ok {
+				return invalid("field %q is filled by both %q and %q", f, other.Header, c.Header)
+			}
+			filledBy[f] = c
+		}
+	}
+
+	var missing []string
+	for _, f := range spec.required {
+		if filledBy[f] == nil {
+			missing = append(missing, f)
+		}
+	}
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return invalid("no column fills required field(s) %s", strings.Join(missing, ", "))
+	}
+	for _, f := range spec.keyFields {
+		if !filledBy[f].Required {
+			return invalid("key field %q must come from a required column", f)
+		}
+	}
+	return nil
+}
+
+// CheckSuccessor verifies that next may be published as a new version of current.
+func CheckSuccessor(current, next Definition) error {
+	if next.ReportType != current.ReportType {
+		return fmt.Errorf("%w: report type cannot change from %s to %s", ErrInvalidDefinition, current.ReportType, next.ReportType)
+	}
+	if next.Entity != current.Entity || next.ReportingSource != current.ReportingSource {
+		return fmt.Errorf("%w: %s: entity and reporting source cannot change", ErrInvalidDefinition, current.ReportType)
+	}
+	return nil
+}
+
+func contains(values []string, v string) bool {
+	for _, s := range values {
+		if s == v {
+			return true
+		}
+	}
+	return false
+}
+
+//go:embed definitions/*.json
+var builtinFiles embed.FS
+
+var builtin = mustLoadBuiltin()
+
+func mustLoadBuiltin() map[string]Definition {
+	files, err := builtinFiles.ReadDir("definitions")
+	if err != nil {
+		panic(fmt.Sprintf("reportdef: reading built-in definitions: %v", err))
+	}
+	defs := make(map[string]Definition, len(files))
+	for _, f := range files {
+		data, err := builtinFiles.ReadFile(path.Join("definitions", f.Name()))
+		if err != nil {
+			panic(fmt.Sprintf("reportdef: reading %s: %v", f.Name(), err))
+		}
+		d, err := Parse(data)
+		if err != nil {
+			panic(fmt.Sprintf("reportdef: %s: %v", f.Name(), err))
+		}
+		defs[d.ReportType] = d
+	}
+	return defs
+}
+
+// Builtin returns the definition shipped with the application for a report type.
+func Builtin(reportType string) (Definition, bool) {
+	d, ok := builtin[reportType]
+	return d, ok
+}
+
+// BuiltinReportTypes returns the report types shipped with the application, sorted.
+func BuiltinReportTypes() []string {
+	types := make([]string, 0, len(builtin))
+	for t := range builtin {
+		types = append(types, t)
+	}
+	sort.Strings(types)
+	return types
+}
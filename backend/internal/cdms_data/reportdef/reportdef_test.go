@@ -0,0 +1,159 @@
+package reportdef
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestBuiltinDefinitions(t *testing.T) {
+	// The header rows files had to have before report definitions existed.
+	expectedHeaders := map[string][]string{
+		"BC1048":            {"Fund", "Business Line", "Region", "Location/System", "Program", "Statement", "BD Doc Num", "AL Num", "Source Num", "Agreement Num", "Agreement Line Number", "Title", "ALC", "Customer TAS", "Task/Subtask", "Class ID", "Vendor", "Vendor Name", "Org Code", "Agency", "Bureau Code", "Chargeback Amount", "Doc Date", "Days Old", "Accomp Date", "Assigned Rebill DRN", "Articles or Services"},
+		"BC1300":            {"Fund", "Business Line", "Region", "Location/System", "Program", "Statement", "BD Doc Num", "AL Num", "Source Num", "Agreement Line Number", "Title", "ALC", "Customer TAS", "Task/Subtask", "Class ID", "Vendor", "Vendor Name", "Org Code", "Agency", "Bureau Code", "Chargeback Amount", "Doc Date", "Days Old", "Accomp Date", "Assigned Rebill DRN", "Articles or Services"},
+		"OUTSTANDING_BILLS": {"G_Inv_IPAC_Indicator", "Business_Application_Type", "Business_Application_Code", "Document_Type", "BD Doc Num", "Billing_Reference_Number", "Statement", "Requester_Servicer_Type", "GTC_Num", "G_Invoicing_Order_Number", "Order_Line_Num", "Order_Schedule_Num", "G_Invoicing_Line_Type", "Chargeback Amount", "Principal_Amount", "Interest_Amount", "Penalty_Amount", "System_Generated_Bill_Reduction_Amount", "Total_Write_Off_Amount", "Administration_Charges_Amount", "Outstanding_Amount", "Credit_Total_Amount", "Credit_Outstanding_Amount", "Title", "Doc Date", "Collection_Due_Date", "Debt_Age_Category", "User_ID", "Vendor", "Address_Code", "Vendor Name", "Business Line", "Debt_Appeal_Forebearance", "Rebill_Flag", "Selected_For_G_Inv_IPAC", "Chargeback_End_Date", "Chargeback_Age"},
+		"VENDOR_CODE":       {"Vendor Agency Code", "Bureau Code", "Agency Location Code", "Vendor Code", "Vendor Address Code", "Name", "Address Line 1", "Address Line 2", "Address Line 3", "City", "State", "Zip", "Status", "Vendor Type", "Reporting Attribute", "Security Org", "Transmit to VCSS Flag"},
+	}
+
+	types := BuiltinReportTypes()
+	if !reflect.DeepEqual(types, []string{"BC1048", "BC1300", "OUTSTANDING_BILLS", "VENDOR_CODE"}) {
+		t.Fatalf("unexpected built-in report types %v", types)
+	}
+	for reportType, headers := range expectedHeaders {
+		def, ok := Builtin(reportType)
+		if !ok {
+			t.Fatalf("no built-in definition for %s", reportType)
+		}
+		if got := def.Headers(); !reflect.DeepEqual(got, headers) {
+			t.Errorf("%s headers: got %v, want %v", reportType, got, headers)
+		}
+	}
+}
+
+func TestParseRejectsInvalidDefinitions(t *testing.T) {
+	base, _ := Builtin("VENDOR_CODE")
+
+	testCases := []struct {
+		name          string
+		mutate        func(d *Definition)
+		expectedError string
+	}{
+		{
+			name:          "Unknown field",
+			mutate:        func(d *Definition) { d.Columns[0].Fields = []string{"agency_name"} },
+			expectedError: `fills unknown agency_bureau field "agency_name"`,
+		},
+		{
+			name:          "Required field not mapped",
+			mutate:        func(d *Definition) { d.Columns[1].Fields = nil },
+			expectedError: "no column fills required field(s) bureau_code",
+		},
+		{
+			name: "Duplicate header",
+			mutate: func(d *Definition) {
+				d.Columns[1].Header = d.Columns[0].Header
+			},
+			expectedError: "duplicate header",
+		},
+		{
+			name:          "Reporting source on an entity without one",
+			mutate:        func(d *Definition) { d.ReportingSource = "BC1048" },
+			expectedError: "has no reporting source",
+		},
+		{
+			name:          "Pattern on a non-text column",
+			mutate:        func(d *Definition) { d.Columns[0].Parser, d.Columns[0].Pattern = ParserInt16, "^[0-9]+$" },
+			expectedError: "only apply to text",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			def := base
+			def.Columns = append([]Column(nil), base.Columns...)
+			tc.mutate(&def)
+			data, err := json.Marshal(def)
+			if err != nil {
+				t.Fatalf("failed to encode definition: %v", err)
+			}
+
+			_, err = Parse(data)
+			if !errors.Is(err, ErrInvalidDefinition) {
+				t.Fatalf("expected ErrInvalidDefinition, got %v", err)
+			}
+			if !strings.Contains(err.Error(), tc.expectedError) {
+				t.Errorf("expected error to contain %q, got %q", tc.expectedError, err)
+			}
+		})
+	}
+}
+
+func TestCheckSuccessor(t *testing.T) {
+	current, _ := Builtin("BC1300")
+
+	next := current
+	next.Columns = append([]Column(nil), current.Columns...)
+	next.Columns[0].Header = "Fund Code"
+	if err := CheckSuccessor(current, next); err != nil {
+		t.Errorf("renaming a header should be allowed, got %v", err)
+	}
+
+	moved := current
+	moved.ReportingSource = "BC1048"
+	if err := CheckSuccessor(current, moved); !errors.Is(err, ErrInvalidDefinition) {
+		t.Errorf("changing the reporting source should be refused, got %v", err)
+	}
+}
+
+func TestMatchHeaders(t *testing.T) {
+	def, _ := Builtin("OUTSTANDING_BILLS")
+
+	t.Run("Order, case and aliases are tolerated", func(t *testing.T) {
+		headers := def.Headers()
+		headers[0], headers[len(headers)-1] = headers[len(headers)-1], headers[0]
+		for i, h := range headers {
+			switch h {
+			case "Principal_Amount":
+				headers[i] = "Principle Amount"
+			case "Statement":
+				headers[i] = "  STATEMENT "
+			}
+		}
+		headers = append(headers, "Extra Column")
+
+		m := def.MatchHeaders(headers)
+		if !m.OK() {
+			t.Fatalf("expected a match, missing %v", m.Missing)
+		}
+		if got := m.Positions["Chargeback_Age"]; got != 0 {
+			t.Errorf("expected Chargeback_Age at 0, got %d", got)
+		}
+		if _, ok := m.Positions["Statement"]; !ok {
+			t.Errorf("expected Statement to match regardless of case and whitespace")
+		}
+		if !reflect.DeepEqual(m.Aliased, []AliasedHeader{{Header: "Principal_Amount", Alias: "Principle Amount"}}) {
+			t.Errorf("unexpected aliased headers %v", m.Aliased)
+		}
+		if !reflect.DeepEqual(m.Unexpected, []string{"Extra Column"}) {
+			t.Errorf("unexpected extra headers %v", m.Unexpected)
+		}
+	})
+
+	t.Run("Missing headers are reported by whether they are loaded", func(t *testing.T) {
+		m := def.MatchHeaders([]string{"BD Doc Num", "Statement", "Vendor", "Vendor"})
+		if m.OK() {
+			t.Fatalf("expected missing headers")
+		}
+		if contains(m.Missing, "Rebill_Flag") || !contains(m.MissingIgnored, "Rebill_Flag") {
+			t.Errorf("Rebill_Flag is not loaded and should only be a warning")
+		}
+		if !contains(m.Missing, "Doc Date") {
+			t.Errorf("expected Doc Date to be missing, got %v", m.Missing)
+		}
+		if !reflect.DeepEqual(m.Unexpected, []string{"Vendor"}) {
+			t.Errorf("expected the repeated header to be unexpected, got %v", m.Unexpected)
+		}
+	})
+}
@@ -3,17 +3,80 @@ package config
 import (
 	"fmt"
 	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
 )
 
+// Storage backends selectable with STORAGE_BACKEND.
+const (
+	StorageBackendGCS    = "gcs"
+	StorageBackendLocal  = "local"
+	StorageBackendMemory = "memory"
+)
+
+// Ways of handling report rows whose vendor code is not in the vendor directory,
+// selectable with UNKNOWN_VENDORS.
+const (
+	// UnknownVendorsReject sets the rows aside as removed rows.
+	UnknownVendorsReject = "reject"
+	// UnknownVendorsPlaceholder loads the rows and adds their vendor codes to the
+	// directory as placeholders flagged for review.
+	UnknownVendorsPlaceholder = "placeholder"
+)
+
+// SanityThreshold bounds how much a single upload may shrink a report's data before it
+// fails with FAILED_SANITY_CHECK. A zero percentage disables that check.
+type SanityThreshold struct {
+	// MaxDeactivatedPct is the largest share of the source's currently active records
+	// the merge may deactivate.
+	MaxDeactivatedPct float64
+	// MaxRowDropPct is the largest drop in row count versus the last successful upload
+	// of the same report type.
+	MaxRowDropPct float64
+}
+
+// defaultSanityThresholds apply when the SANITY_* variables for a report type are unset.
+// Vendor code reports never deactivate records, so only their row count is checked.
+var defaultSanityThresholds = map[string]SanityThreshold{
+	"BC1300":            {MaxDeactivatedPct: 30, MaxRowDropPct: 50},
+	"BC1048":            {MaxDeactivatedPct: 30, MaxRowDropPct: 50},
+	"OUTSTANDING_BILLS": {MaxDeactivatedPct: 30, MaxRowDropPct: 50},
+	"VENDOR_CODE":       {MaxRowDropPct: 50},
+}
+
+// defaultSanityThreshold applies to report types without an entry of their own, such as
+// those published as report definitions, unless SANITY_MAX_DEACTIVATED_PCT and
+// SANITY_MAX_ROW_DROP_PCT are set.
+var defaultSanityThreshold = SanityThreshold{MaxDeactivatedPct: 30, MaxRowDropPct: 50}
+
 type Config struct {
-	DatabaseURL   string
-	Auth0Domain   string
-	Auth0Audience string
-	AppEnv        string
-	GCSBucketName string
-	SentryDSN     string `env:"SENTRY_DSN"`
+	DatabaseURL     string
+	Auth0Domain     string
+	Auth0Audience   string
+	AppEnv          string
+	StorageBackend  string
+	GCSBucketName   string
+	LocalStorageDir string
+	SentryDSN       string `env:"SENTRY_DSN"`
+
+	// Upload job queue settings.
+	UploadWorkers      int
+	UploadMaxAttempts  int
+	UploadRetryBackoff time.Duration // delay before the first retry; doubles on each further attempt
+	UploadJobLease     time.Duration // how long a worker may go without a heartbeat before its job is reclaimed
+
+	// Mass-deactivation guard, keyed by report type. Report types without an entry use
+	// DefaultSanityThreshold; see SanityThresholdFor.
+	SanityThresholds       map[string]SanityThreshold
+	DefaultSanityThreshold SanityThreshold
+
+	// UnknownVendors is how rows referencing a vendor code missing from the directory
+	// are handled: UnknownVendorsReject or UnknownVendorsPlaceholder.
+	UnknownVendors string
 }
 
 func LoadConfig() (*Config, error) {
@@ -30,7 +93,7 @@ func LoadConfig() (*Config, error) {
 	}
 
 	auth0Audience := os.Getenv("AUTH0_AUDIENCE")
-	if auth0Domain == "" {
+	if auth0Audience == "" {
 		return nil, fmt.Errorf("AUTH0_AUDIENCE environment variable not set")
 	}
 
@@ -39,9 +102,27 @@ func LoadConfig() (*Config, error) {
 		appEnv = "development"
 	}
 
+	storageBackend := os.Getenv("STORAGE_BACKEND")
+	if storageBackend == "" {
+		storageBackend = StorageBackendGCS
+	}
+
 	gcsBucketName := os.Getenv("GCS_BUCKET_NAME")
-	if gcsBucketName == "" {
-		return nil, fmt.Errorf("GCS_BUCKET_NAME environment variable not set")
+	localStorageDir := os.Getenv("LOCAL_STORAGE_DIR")
+
+	switch storageBackend {
+	case StorageBackendGCS:
+		if gcsBucketName == "" {
+			return nil, fmt.Errorf("GCS_BUCKET_NAME environment variable not set")
+		}
+	case StorageBackendLocal:
+		if localStorageDir == "" {
+			localStorageDir = "./data/blobs"
+		}
+	case StorageBackendMemory:
+	default:
+		return nil, fmt.Errorf("STORAGE_BACKEND must be one of %q, %q or %q, got %q",
+			StorageBackendGCS, StorageBackendLocal, StorageBackendMemory, storageBackend)
 	}
 
 	SentryDSN := os.Getenv("SENTRY_DSN")
@@ -49,12 +130,152 @@ func LoadConfig() (*Config, error) {
 		return nil, fmt.Errorf("SENTRY_DSN environment variable not set")
 	}
 
+	uploadWorkers, err := envInt("UPLOAD_WORKERS", 2)
+	if err != nil {
+		return nil, err
+	}
+	uploadMaxAttempts, err := envInt("UPLOAD_MAX_ATTEMPTS", 3)
+	if err != nil {
+		return nil, err
+	}
+	uploadRetryBackoff, err := envDuration("UPLOAD_RETRY_BACKOFF", 30*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	uploadJobLease, err := envDuration("UPLOAD_JOB_LEASE", 2*time.Minute)
+	if err != nil {
+		return nil, err
+	}
+
+	defaultThreshold, err := envSanityThreshold("", defaultSanityThreshold)
+	if err != nil {
+		return nil, err
+	}
+	sanityThresholds := make(map[string]SanityThreshold, len(defaultSanityThresholds))
+	for _, reportType := range sanityReportTypes() {
+		def, ok := defaultSanityThresholds[reportType]
+		if !ok {
+			def = defaultThreshold
+		}
+		threshold, err := envSanityThreshold("_"+reportType, def)
+		if err != nil {
+			return nil, err
+		}
+		sanityThresholds[reportType] = threshold
+	}
+
+	unknownVendors := os.Getenv("UNKNOWN_VENDORS")
+	switch unknownVendors {
+	case "":
+		unknownVendors = UnknownVendorsReject
+	case UnknownVendorsReject, UnknownVendorsPlaceholder:
+	default:
+		return nil, fmt.Errorf("UNKNOWN_VENDORS must be %q or %q, got %q",
+			UnknownVendorsReject, UnknownVendorsPlaceholder, unknownVendors)
+	}
+
 	return &Config{
-		DatabaseURL:   dbURL,
-		Auth0Domain:   auth0Domain,
-		Auth0Audience: auth0Audience,
-		AppEnv:        appEnv,
-		GCSBucketName: gcsBucketName,
-		SentryDSN:     SentryDSN,
+		DatabaseURL:     dbURL,
+		Auth0Domain:     auth0Domain,
+		Auth0Audience:   auth0Audience,
+		AppEnv:          appEnv,
+		StorageBackend:  storageBackend,
+		GCSBucketName:   gcsBucketName,
+		LocalStorageDir: localStorageDir,
+		SentryDSN:       SentryDSN,
+
+		UploadWorkers:      uploadWorkers,
+		UploadMaxAttempts:  uploadMaxAttempts,
+		UploadRetryBackoff: uploadRetryBackoff,
+		UploadJobLease:     uploadJobLease,
+
+		SanityThresholds:       sanityThresholds,
+		DefaultSanityThreshold: defaultThreshold,
+
+		UnknownVendors: unknownVendors,
 	}, nil
 }
+
+// SanityThresholdFor returns the sanity thresholds of a report type, falling back to
+// DefaultSanityThreshold for one without its own.
+func (c *Config) SanityThresholdFor(reportType string) SanityThreshold {
+	if threshold, ok := c.SanityThresholds[reportType]; ok {
+		return threshold
+	}
+	return c.DefaultSanityThreshold
+}
+
+// sanityReportTypes lists the built-in report types plus any other report type named by
+// a SANITY_MAX_DEACTIVATED_PCT_<type> or SANITY_MAX_ROW_DROP_PCT_<type> variable.
+func sanityReportTypes() []string {
+	reportTypes := make([]string, 0, len(defaultSanityThresholds))
+	for reportType := range defaultSanityThresholds {
+		reportTypes = append(reportTypes, reportType)
+	}
+	for _, kv := range os.Environ() {
+		name, _, _ := strings.Cut(kv, "=")
+		for _, prefix := range []string{"SANITY_MAX_DEACTIVATED_PCT_", "SANITY_MAX_ROW_DROP_PCT_"} {
+			reportType, ok := strings.CutPrefix(name, prefix)
+			if !ok || reportType == "" || slices.Contains(reportTypes, reportType) {
+				continue
+			}
+			reportTypes = append(reportTypes, reportType)
+		}
+	}
+	return reportTypes
+}
+
+// envSanityThreshold reads the SANITY_MAX_DEACTIVATED_PCT and SANITY_MAX_ROW_DROP_PCT
+// variables ending in suffix, falling back to def for each one that is unset.
+func envSanityThreshold(suffix string, def SanityThreshold) (SanityThreshold, error) {
+	maxDeactivated, err := envPercent("SANITY_MAX_DEACTIVATED_PCT"+suffix, def.MaxDeactivatedPct)
+	if err != nil {
+		return SanityThreshold{}, err
+	}
+	maxRowDrop, err := envPercent("SANITY_MAX_ROW_DROP_PCT"+suffix, def.MaxRowDropPct)
+	if err != nil {
+		return SanityThreshold{}, err
+	}
+	return SanityThreshold{MaxDeactivatedPct: maxDeactivated, MaxRowDropPct: maxRowDrop}, nil
+}
+
+// envInt reads a positive integer from the environment, falling back to def when unset.
+func envInt(name string, def int) (int, error) {
+	raw := os.Getenv(name)
+	if raw == "" {
+		return def, nil
+	}
+	v, err := strconv.Atoi(raw)
+	if err != nil || v <= 0 {
+		return 0, fmt.Errorf("%s must be a positive integer, got %q", name, raw)
+	}
+	return v, nil
+}
+
+// envDuration reads a positive duration such as "30s" or "2m" from the environment,
+// falling back to def when unset.
+func envDuration(name string, def time.Duration) (time.Duration, error) {
+	raw := os.Getenv(name)
+	if raw == "" {
+		return def, nil
+	}
+	v, err := time.ParseDuration(raw)
+	if err != nil || v <= 0 {
+		return 0, fmt.Errorf("%s must be a positive duration, got %q", name, raw)
+	}
+	return v, nil
+}
+
+// envPercent reads a percentage between 0 and 100 from the environment, falling back to
+// def when unset.
+func envPercent(name string, def float64) (float64, error) {
+	raw := os.Getenv(name)
+	if raw == "" {
+		return def, nil
+	}
+	v, err := strconv.ParseFloat(raw, 64)
+	if err != nil || v < 0 || v > 100 {
+		return 0, fmt.Errorf("%s must be a percentage between 0 and 100, got %q", name, raw)
+	}
+	return v, nil
+}
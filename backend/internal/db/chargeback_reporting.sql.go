@@ -18,15 +18,23 @@ FROM
     historical_chargebacks_with_vendor_info
 WHERE
     pfs_completion_date BETWEEN $1 AND $2
+    AND ($3::boolean OR business_line = ANY($4::chargeback_business_line[]))
 `
 
 type GetAverageDaysForPFSCompletionForWindowParams struct {
-	PfsCompletionDate   interface{} `json:"pfs_completion_date"`
-	PfsCompletionDate_2 interface{} `json:"pfs_completion_date_2"`
+	WindowStart      interface{}              `json:"window_start"`
+	WindowEnd        interface{}              `json:"window_end"`
+	AllBusinessLines bool                     `json:"all_business_lines"`
+	BusinessLines    []ChargebackBusinessLine `json:"business_lines"`
 }
 
 func (q *Queries) GetAverageDaysForPFSCompletionForWindow(ctx context.Context, arg GetAverageDaysForPFSCompletionForWindowParams) (string, error) {
-	row := q.db.QueryRow(ctx, getAverageDaysForPFSCompletionForWindow, arg.PfsCompletionDate, arg.PfsCompletionDate_2)
+	row := q.db.QueryRow(ctx, getAverageDaysForPFSCompletionForWindow,
+		arg.WindowStart,
+		arg.WindowEnd,
+		arg.AllBusinessLines,
+		arg.BusinessLines,
+	)
 	var avg_days string
 	err := row.Scan(&avg_days)
 	return avg_days, err
@@ -39,15 +47,23 @@ FROM
     historical_chargebacks_with_vendor_info
 WHERE
     passed_to_pfs_date BETWEEN $1 AND $2
+    AND ($3::boolean OR business_line = ANY($4::chargeback_business_line[]))
 `
 
 type GetAverageDaysToPFSForWindowParams struct {
-	PassedToPfsDate   interface{} `json:"passed_to_pfs_date"`
-	PassedToPfsDate_2 interface{} `json:"passed_to_pfs_date_2"`
+	WindowStart      interface{}              `json:"window_start"`
+	WindowEnd        interface{}              `json:"window_end"`
+	AllBusinessLines bool                     `json:"all_business_lines"`
+	BusinessLines    []ChargebackBusinessLine `json:"business_lines"`
 }
 
 func (q *Queries) GetAverageDaysToPFSForWindow(ctx context.Context, arg GetAverageDaysToPFSForWindowParams) (string, error) {
-	row := q.db.QueryRow(ctx, getAverageDaysToPFSForWindow, arg.PassedToPfsDate, arg.PassedToPfsDate_2)
+	row := q.db.QueryRow(ctx, getAverageDaysToPFSForWindow,
+		arg.WindowStart,
+		arg.WindowEnd,
+		arg.AllBusinessLines,
+		arg.BusinessLines,
+	)
 	var avg_days string
 	err := row.Scan(&avg_days)
 	return avg_days, err
@@ -63,10 +79,16 @@ FROM
     historical_chargebacks_with_vendor_info
 WHERE
     current_status != 'Reconciled - Off Report' -- Exclude reconciled items from this summary
+    AND ($1::boolean OR business_line = ANY($2::chargeback_business_line[]))
 GROUP BY
     current_status
 `
 
+type GetChargebackStatusSummaryParams struct {
+	AllBusinessLines bool                     `json:"all_business_lines"`
+	BusinessLines    []ChargebackBusinessLine `json:"business_lines"`
+}
+
 type GetChargebackStatusSummaryRow struct {
 	CurrentStatus     CdmsStatus     `json:"current_status"`
 	StatusCount       int64          `json:"status_count"`
@@ -75,8 +97,9 @@ type GetChargebackStatusSummaryRow struct {
 }
 
 // Gets the count, total value, and percentage of total value for each chargeback status for active items.
-func (q *Queries) GetChargebackStatusSummary(ctx context.Context) ([]GetChargebackStatusSummaryRow, error) {
-	rows, err := q.db.Query(ctx, getChargebackStatusSummary)
+// Limited to the caller's business lines unless all_business_lines is set.
+func (q *Queries) GetChargebackStatusSummary(ctx context.Context, arg GetChargebackStatusSummaryParams) ([]GetChargebackStatusSummaryRow, error) {
+	rows, err := q.db.Query(ctx, getChargebackStatusSummary, arg.AllBusinessLines, arg.BusinessLines)
 	if err != nil {
 		return nil, err
 	}
@@ -108,11 +131,14 @@ FROM
     chargeback
 WHERE
     created_at BETWEEN $1 AND $2
+    AND ($3::boolean OR business_line = ANY($4::chargeback_business_line[]))
 `
 
 type GetNewChargebackStatsForWindowParams struct {
-	CreatedAt   pgtype.Timestamptz `json:"created_at"`
-	CreatedAt_2 pgtype.Timestamptz `json:"created_at_2"`
+	WindowStart      pgtype.Timestamptz       `json:"window_start"`
+	WindowEnd        pgtype.Timestamptz       `json:"window_end"`
+	AllBusinessLines bool                     `json:"all_business_lines"`
+	BusinessLines    []ChargebackBusinessLine `json:"business_lines"`
 }
 
 type GetNewChargebackStatsForWindowRow struct {
@@ -122,7 +148,12 @@ type GetNewChargebackStatsForWindowRow struct {
 
 // Gets the count and total value of new chargebacks created within a specific date window.
 func (q *Queries) GetNewChargebackStatsForWindow(ctx context.Context, arg GetNewChargebackStatsForWindowParams) (GetNewChargebackStatsForWindowRow, error) {
-	row := q.db.QueryRow(ctx, getNewChargebackStatsForWindow, arg.CreatedAt, arg.CreatedAt_2)
+	row := q.db.QueryRow(ctx, getNewChargebackStatsForWindow,
+		arg.WindowStart,
+		arg.WindowEnd,
+		arg.AllBusinessLines,
+		arg.BusinessLines,
+	)
 	var i GetNewChargebackStatsForWindowRow
 	err := row.Scan(&i.NewChargebacksCount, &i.NewChargebacksValue)
 	return i, err
@@ -134,11 +165,15 @@ SELECT
     COUNT(*) FILTER (WHERE pfs_completion_date BETWEEN $1 AND $2) AS completed_by_pfs_count
 FROM
     historical_chargebacks_with_vendor_info
+WHERE
+    ($3::boolean OR business_line = ANY($4::chargeback_business_line[]))
 `
 
 type GetPFSCountsForWindowParams struct {
-	PassedToPfsDate   interface{} `json:"passed_to_pfs_date"`
-	PassedToPfsDate_2 interface{} `json:"passed_to_pfs_date_2"`
+	WindowStart      interface{}              `json:"window_start"`
+	WindowEnd        interface{}              `json:"window_end"`
+	AllBusinessLines bool                     `json:"all_business_lines"`
+	BusinessLines    []ChargebackBusinessLine `json:"business_lines"`
 }
 
 type GetPFSCountsForWindowRow struct {
@@ -149,7 +184,12 @@ type GetPFSCountsForWindowRow struct {
 // Gets the count of chargebacks passed to PFS and completed by PFS within a specific date window.
 // This version uses conditional aggregation for better performance and to avoid ambiguity.
 func (q *Queries) GetPFSCountsForWindow(ctx context.Context, arg GetPFSCountsForWindowParams) (GetPFSCountsForWindowRow, error) {
-	row := q.db.QueryRow(ctx, getPFSCountsForWindow, arg.PassedToPfsDate, arg.PassedToPfsDate_2)
+	row := q.db.QueryRow(ctx, getPFSCountsForWindow,
+		arg.WindowStart,
+		arg.WindowEnd,
+		arg.AllBusinessLines,
+		arg.BusinessLines,
+	)
 	var i GetPFSCountsForWindowRow
 	err := row.Scan(&i.PassedToPfsCount, &i.CompletedByPfsCount)
 	return i, err
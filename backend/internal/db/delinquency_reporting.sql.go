@@ -28,12 +28,18 @@ FROM
     historical_nonipac_with_vendor_info
 WHERE
     is_active = TRUE AND current_status != 'Reconciled - Off Report' -- Filter for active, non-reconciled items
+    AND ($1::boolean OR business_line = ANY($2::chargeback_business_line[]))
 GROUP BY
     business_line
 ORDER BY
     business_line
 `
 
+type GetNonipacAgingScheduleByBusinessLineParams struct {
+	AllBusinessLines bool                     `json:"all_business_lines"`
+	BusinessLines    []ChargebackBusinessLine `json:"business_lines"`
+}
+
 type GetNonipacAgingScheduleByBusinessLineRow struct {
 	BusinessLine         ChargebackBusinessLine `json:"business_line"`
 	LessThan180DaysCount int64                  `json:"less_than_180_days_count"`
@@ -49,8 +55,9 @@ type GetNonipacAgingScheduleByBusinessLineRow struct {
 }
 
 // Provides an aging schedule for active nonipac items, broken down by business line and age categories.
-func (q *Queries) GetNonipacAgingScheduleByBusinessLine(ctx context.Context) ([]GetNonipacAgingScheduleByBusinessLineRow, error) {
-	rows, err := q.db.Query(ctx, getNonipacAgingScheduleByBusinessLine)
+// Limited to the caller's business lines unless all_business_lines is set.
+func (q *Queries) GetNonipacAgingScheduleByBusinessLine(ctx context.Context, arg GetNonipacAgingScheduleByBusinessLineParams) ([]GetNonipacAgingScheduleByBusinessLineRow, error) {
+	rows, err := q.db.Query(ctx, getNonipacAgingScheduleByBusinessLine, arg.AllBusinessLines, arg.BusinessLines)
 	if err != nil {
 		return nil, err
 	}
@@ -91,12 +98,18 @@ FROM
     historical_nonipac_with_vendor_info
 WHERE
     is_active = TRUE AND current_status != 'Reconciled - Off Report' -- Exclude inactive and reconciled items
+    AND ($1::boolean OR business_line = ANY($2::chargeback_business_line[]))
 GROUP BY
     current_status
 ORDER BY
     current_status
 `
 
+type GetNonipacStatusSummaryParams struct {
+	AllBusinessLines bool                     `json:"all_business_lines"`
+	BusinessLines    []ChargebackBusinessLine `json:"business_lines"`
+}
+
 type GetNonipacStatusSummaryRow struct {
 	CurrentStatus     CdmsStatus     `json:"current_status"`
 	StatusCount       int64          `json:"status_count"`
@@ -105,8 +118,9 @@ type GetNonipacStatusSummaryRow struct {
 }
 
 // Gets the count, total value, and percentage of total value for each nonipac status for active items.
-func (q *Queries) GetNonipacStatusSummary(ctx context.Context) ([]GetNonipacStatusSummaryRow, error) {
-	rows, err := q.db.Query(ctx, getNonipacStatusSummary)
+// Limited to the caller's business lines unless all_business_lines is set.
+func (q *Queries) GetNonipacStatusSummary(ctx context.Context, arg GetNonipacStatusSummaryParams) ([]GetNonipacStatusSummaryRow, error) {
+	rows, err := q.db.Query(ctx, getNonipacStatusSummary, arg.AllBusinessLines, arg.BusinessLines)
 	if err != nil {
 		return nil, err
 	}
@@ -61,16 +61,148 @@ func (q *Queries) GetChargebackSourcesByBDDocNums(ctx context.Context, dollar_1
 	return items, nil
 }
 
+const reconcileOffReportChargebacks = `-- name: ReconcileOffReportChargebacks :execrows
+UPDATE chargeback c
+SET current_status = 'Reconciled - Off Report'
+WHERE c.reporting_source = $1
+  AND c.is_active
+  AND c.current_status <> 'Reconciled - Off Report'
+  AND NOT EXISTS (
+    SELECT 1 FROM temp_chargeback_staging s WHERE s.bd_doc_num = c.bd_doc_num AND s.al_num = c.al_num
+  )
+`
+
+// Move active chargebacks from a report source that are missing from the staged report
+// to 'Reconciled - Off Report'. Runs before DeactivateChargebacksBySource
+func (q *Queries) ReconcileOffReportChargebacks(ctx context.Context, reportingSource ChargebackReportingSource) (int64, error) {
+	result, err := q.db.Exec(ctx, reconcileOffReportChargebacks, reportingSource)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const reconcileOffReportNonIpacs = `-- name: ReconcileOffReportNonIpacs :execrows
+UPDATE "nonipac" n
+SET
+    current_status = 'Reconciled - Off Report',
+    reconciled_date = CURRENT_DATE
+WHERE n.reporting_source = $1
+  AND n.is_active
+  AND n.current_status <> 'Reconciled - Off Report'
+  AND NOT EXISTS (
+    SELECT 1 FROM temp_nonipac_staging s WHERE s.document_number = n.document_number
+  )
+`
+
+// Move active delinquencies from a report source that are missing from the staged report
+// to 'Reconciled - Off Report' and date the reconciliation. Runs before DeactivateNonIpacsBySource
+func (q *Queries) ReconcileOffReportNonIpacs(ctx context.Context, reportingSource NonipacReportingSource) (int64, error) {
+	result, err := q.db.Exec(ctx, reconcileOffReportNonIpacs, reportingSource)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const reopenReconciledChargebacks = `-- name: ReopenReconciledChargebacks :execrows
+UPDATE chargeback c
+SET current_status = 'Open'
+FROM temp_chargeback_staging s
+WHERE s.bd_doc_num = c.bd_doc_num
+  AND s.al_num = c.al_num
+  AND c.current_status = 'Reconciled - Off Report'
+`
+
+// Reopen chargebacks reconciled off report that are back in the staged report
+func (q *Queries) ReopenReconciledChargebacks(ctx context.Context) (int64, error) {
+	result, err := q.db.Exec(ctx, reopenReconciledChargebacks)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const reopenReconciledNonIpacs = `-- name: ReopenReconciledNonIpacs :execrows
+UPDATE "nonipac" n
+SET
+    current_status = 'Open',
+    reconciled_date = NULL
+FROM temp_nonipac_staging s
+WHERE s.document_number = n.document_number
+  AND n.current_status = 'Reconciled - Off Report'
+`
+
+// Reopen delinquencies reconciled off report that are back in the staged report
+func (q *Queries) ReopenReconciledNonIpacs(ctx context.Context) (int64, error) {
+	result, err := q.db.Exec(ctx, reopenReconciledNonIpacs)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const setStatusHistoryNote = `-- name: SetStatusHistoryNote :exec
+SELECT set_config('app.status_note', $1::TEXT, true)
+`
+
+// Set the note the status history trigger records for status changes made by the
+// rest of the transaction; an empty note restores the trigger's default
+func (q *Queries) SetStatusHistoryNote(ctx context.Context, note string) error {
+	_, err := q.db.Exec(ctx, setStatusHistoryNote, note)
+	return err
+}
+
 const upsertAgencyBureaus = `-- name: UpsertAgencyBureaus :execrows
-INSERT INTO agency_bureau (agency, bureau_code, vendor_code, updated_at)
-SELECT agency, bureau_code, vendor_code, NOW()
+INSERT INTO agency_bureau (
+    agency, bureau_code, vendor_code, agency_location_code, vendor_address_code, name,
+    address_line_1, address_line_2, address_line_3, city, state, zip, status, vendor_type,
+    reporting_attribute, security_org, transmit_to_vcss_flag, updated_at
+)
+SELECT
+    agency, bureau_code, vendor_code, agency_location_code, vendor_address_code, name,
+    address_line_1, address_line_2, address_line_3, city, state, zip, status, vendor_type,
+    reporting_attribute, security_org, transmit_to_vcss_flag, NOW()
 FROM temp_agency_bureau_staging
 ON CONFLICT (vendor_code) DO UPDATE SET
     agency = EXCLUDED.agency,
     bureau_code = EXCLUDED.bureau_code,
+    agency_location_code = EXCLUDED.agency_location_code,
+    vendor_address_code = EXCLUDED.vendor_address_code,
+    name = EXCLUDED.name,
+    address_line_1 = EXCLUDED.address_line_1,
+    address_line_2 = EXCLUDED.address_line_2,
+    address_line_3 = EXCLUDED.address_line_3,
+    city = EXCLUDED.city,
+    state = EXCLUDED.state,
+    zip = EXCLUDED.zip,
+    status = EXCLUDED.status,
+    vendor_type = EXCLUDED.vendor_type,
+    reporting_attribute = EXCLUDED.reporting_attribute,
+    security_org = EXCLUDED.security_org,
+    transmit_to_vcss_flag = EXCLUDED.transmit_to_vcss_flag,
+    needs_review = FALSE,
     updated_at = NOW()
+WHERE (
+    agency_bureau.agency, agency_bureau.bureau_code, agency_bureau.agency_location_code,
+    agency_bureau.vendor_address_code, agency_bureau.name, agency_bureau.address_line_1,
+    agency_bureau.address_line_2, agency_bureau.address_line_3, agency_bureau.city,
+    agency_bureau.state, agency_bureau.zip, agency_bureau.status, agency_bureau.vendor_type,
+    agency_bureau.reporting_attribute, agency_bureau.security_org,
+    agency_bureau.transmit_to_vcss_flag, agency_bureau.needs_review
+) IS DISTINCT FROM (
+    EXCLUDED.agency, EXCLUDED.bureau_code, EXCLUDED.agency_location_code,
+    EXCLUDED.vendor_address_code, EXCLUDED.name, EXCLUDED.address_line_1,
+    EXCLUDED.address_line_2, EXCLUDED.address_line_3, EXCLUDED.city,
+    EXCLUDED.state, EXCLUDED.zip, EXCLUDED.status, EXCLUDED.vendor_type,
+    EXCLUDED.reporting_attribute, EXCLUDED.security_org,
+    EXCLUDED.transmit_to_vcss_flag, FALSE
+)
 `
 
+// Insert new vendors from the staging table and update the ones whose details changed.
+// Vendors the report repeats unchanged are left alone so that their audit history only
+// records real changes. A placeholder vendor the report fills in no longer needs review
 func (q *Queries) UpsertAgencyBureaus(ctx context.Context) (int64, error) {
 	result, err := q.db.Exec(ctx, upsertAgencyBureaus)
 	if err != nil {
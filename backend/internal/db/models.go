@@ -544,41 +544,74 @@ type ActiveNonipacWithVendorInfo struct {
 }
 
 type AgencyBureau struct {
-	Agency     string             `json:"agency"`
-	BureauCode string             `json:"bureau_code"`
+	Agency             string             `json:"agency"`
+	BureauCode         string             `json:"bureau_code"`
+	VendorCode         string             `json:"vendor_code"`
+	CreatedAt          pgtype.Timestamptz `json:"created_at"`
+	UpdatedAt          pgtype.Timestamptz `json:"updated_at"`
+	AgencyLocationCode pgtype.Text        `json:"agency_location_code"`
+	VendorAddressCode  pgtype.Text        `json:"vendor_address_code"`
+	Name               pgtype.Text        `json:"name"`
+	AddressLine1       pgtype.Text        `json:"address_line_1"`
+	AddressLine2       pgtype.Text        `json:"address_line_2"`
+	AddressLine3       pgtype.Text        `json:"address_line_3"`
+	City               pgtype.Text        `json:"city"`
+	State              pgtype.Text        `json:"state"`
+	Zip                pgtype.Text        `json:"zip"`
+	Status             pgtype.Text        `json:"status"`
+	VendorType         pgtype.Text        `json:"vendor_type"`
+	ReportingAttribute pgtype.Text        `json:"reporting_attribute"`
+	SecurityOrg        pgtype.Text        `json:"security_org"`
+	TransmitToVcssFlag pgtype.Text        `json:"transmit_to_vcss_flag"`
+	NeedsReview        bool               `json:"needs_review"`
+}
+
+type AuditAgencyBureauChange struct {
+	AuditID    int64              `json:"audit_id"`
 	VendorCode string             `json:"vendor_code"`
-	CreatedAt  pgtype.Timestamptz `json:"created_at"`
-	UpdatedAt  pgtype.Timestamptz `json:"updated_at"`
+	Operation  string             `json:"operation"`
+	ChangedBy  pgtype.Int8        `json:"changed_by"`
+	ChangedAt  pgtype.Timestamptz `json:"changed_at"`
+	OldData    []byte             `json:"old_data"`
+	NewData    []byte             `json:"new_data"`
+	UploadID   pgtype.UUID        `json:"upload_id"`
+	OnBehalfOf pgtype.Int8        `json:"on_behalf_of"`
 }
 
 type AuditCdmsUserChange struct {
-	AuditID   int64              `json:"audit_id"`
-	TargetID  int64              `json:"target_id"`
-	Operation string             `json:"operation"`
-	ChangedBy pgtype.Int8        `json:"changed_by"`
-	ChangedAt pgtype.Timestamptz `json:"changed_at"`
-	OldData   []byte             `json:"old_data"`
-	NewData   []byte             `json:"new_data"`
+	AuditID    int64              `json:"audit_id"`
+	TargetID   int64              `json:"target_id"`
+	Operation  string             `json:"operation"`
+	ChangedBy  pgtype.Int8        `json:"changed_by"`
+	ChangedAt  pgtype.Timestamptz `json:"changed_at"`
+	OldData    []byte             `json:"old_data"`
+	NewData    []byte             `json:"new_data"`
+	UploadID   pgtype.UUID        `json:"upload_id"`
+	OnBehalfOf pgtype.Int8        `json:"on_behalf_of"`
 }
 
 type AuditChargebackChange struct {
-	AuditID   int64              `json:"audit_id"`
-	TargetID  int64              `json:"target_id"`
-	Operation string             `json:"operation"`
-	ChangedBy pgtype.Int8        `json:"changed_by"`
-	ChangedAt pgtype.Timestamptz `json:"changed_at"`
-	OldData   []byte             `json:"old_data"`
-	NewData   []byte             `json:"new_data"`
+	AuditID    int64              `json:"audit_id"`
+	TargetID   int64              `json:"target_id"`
+	Operation  string             `json:"operation"`
+	ChangedBy  pgtype.Int8        `json:"changed_by"`
+	ChangedAt  pgtype.Timestamptz `json:"changed_at"`
+	OldData    []byte             `json:"old_data"`
+	NewData    []byte             `json:"new_data"`
+	UploadID   pgtype.UUID        `json:"upload_id"`
+	OnBehalfOf pgtype.Int8        `json:"on_behalf_of"`
 }
 
 type AuditNonipacChange struct {
-	AuditID   int64              `json:"audit_id"`
-	TargetID  int64              `json:"target_id"`
-	Operation string             `json:"operation"`
-	ChangedBy pgtype.Int8        `json:"changed_by"`
-	ChangedAt pgtype.Timestamptz `json:"changed_at"`
-	OldData   []byte             `json:"old_data"`
-	NewData   []byte             `json:"new_data"`
+	AuditID    int64              `json:"audit_id"`
+	TargetID   int64              `json:"target_id"`
+	Operation  string             `json:"operation"`
+	ChangedBy  pgtype.Int8        `json:"changed_by"`
+	ChangedAt  pgtype.Timestamptz `json:"changed_at"`
+	OldData    []byte             `json:"old_data"`
+	NewData    []byte             `json:"new_data"`
+	UploadID   pgtype.UUID        `json:"upload_id"`
+	OnBehalfOf pgtype.Int8        `json:"on_behalf_of"`
 }
 
 type CdmsUser struct {
@@ -838,6 +871,20 @@ type RemovedRowsLog struct {
 	ReportType       string             `json:"report_type"`
 	OriginalRowData  []byte             `json:"original_row_data"`
 	ReasonForRemoval string             `json:"reason_for_removal"`
+	ResolutionStatus string             `json:"resolution_status"`
+	CorrectedRowData []byte             `json:"corrected_row_data"`
+	ResolutionNote   pgtype.Text        `json:"resolution_note"`
+	ResolvedByUserID pgtype.Int8        `json:"resolved_by_user_id"`
+	ResolvedAt       pgtype.Timestamptz `json:"resolved_at"`
+	Issues           []byte             `json:"issues"`
+}
+
+type ReportDefinition struct {
+	ReportType      string             `json:"report_type"`
+	Version         int32              `json:"version"`
+	Definition      []byte             `json:"definition"`
+	CreatedByUserID int64              `json:"created_by_user_id"`
+	CreatedAt       pgtype.Timestamptz `json:"created_at"`
 }
 
 type Role struct {
@@ -852,11 +899,13 @@ type RolePermission struct {
 }
 
 type StatusHistory struct {
-	ID         int64              `json:"id"`
-	Status     CdmsStatus         `json:"status"`
-	StatusDate pgtype.Timestamptz `json:"status_date"`
-	Notes      pgtype.Text        `json:"notes"`
-	UserID     int64              `json:"user_id"`
+	ID               int64              `json:"id"`
+	Status           CdmsStatus         `json:"status"`
+	StatusDate       pgtype.Timestamptz `json:"status_date"`
+	Notes            pgtype.Text        `json:"notes"`
+	UserID           int64              `json:"user_id"`
+	OnBehalfOfUserID pgtype.Int8        `json:"on_behalf_of_user_id"`
+	UploadID         pgtype.UUID        `json:"upload_id"`
 }
 
 type TempAgencyBureauStaging struct {
@@ -940,17 +989,79 @@ type TempNonipacStaging struct {
 }
 
 type Upload struct {
-	ID                pgtype.UUID        `json:"id"`
-	StorageKey        string             `json:"storage_key"`
-	Filename          string             `json:"filename"`
-	ReportType        string             `json:"report_type"`
-	Status            string             `json:"status"`
-	UploadedAt        pgtype.Timestamptz `json:"uploaded_at"`
-	ProcessedAt       pgtype.Timestamptz `json:"processed_at"`
-	ErrorDetails      pgtype.Text        `json:"error_details"`
-	ProcessedByUserID int64              `json:"processed_by_user_id"`
-	RowsUpserted      pgtype.Int4        `json:"rows_upserted"`
-	RowsRemoved       pgtype.Int4        `json:"rows_removed"`
+	ID                      pgtype.UUID        `json:"id"`
+	StorageKey              string             `json:"storage_key"`
+	Filename                string             `json:"filename"`
+	ReportType              string             `json:"report_type"`
+	Status                  string             `json:"status"`
+	UploadedAt              pgtype.Timestamptz `json:"uploaded_at"`
+	ProcessedAt             pgtype.Timestamptz `json:"processed_at"`
+	ErrorDetails            pgtype.Text        `json:"error_details"`
+	ProcessedByUserID       int64              `json:"processed_by_user_id"`
+	RowsUpserted            pgtype.Int4        `json:"rows_upserted"`
+	RowsRemoved             pgtype.Int4        `json:"rows_removed"`
+	SheetName               pgtype.Text        `json:"sheet_name"`
+	DryRun                  bool               `json:"dry_run"`
+	Preview                 []byte             `json:"preview"`
+	StagedAt                pgtype.Timestamptz `json:"staged_at"`
+	ReviewedByUserID        pgtype.Int8        `json:"reviewed_by_user_id"`
+	ReviewedAt              pgtype.Timestamptz `json:"reviewed_at"`
+	ReviewDecision          pgtype.Text        `json:"review_decision"`
+	ReviewReason            pgtype.Text        `json:"review_reason"`
+	SanityCheck             []byte             `json:"sanity_check"`
+	SanityOverride          bool               `json:"sanity_override"`
+	SanityOverrideByUserID  pgtype.Int8        `json:"sanity_override_by_user_id"`
+	SanityOverrideAt        pgtype.Timestamptz `json:"sanity_override_at"`
+	ReprocessedFromUploadID pgtype.UUID        `json:"reprocessed_from_upload_id"`
+	RolledBackByUserID      pgtype.Int8        `json:"rolled_back_by_user_id"`
+	RolledBackAt            pgtype.Timestamptz `json:"rolled_back_at"`
+	ReportDefinitionVersion pgtype.Int4        `json:"report_definition_version"`
+	HeaderCheck             []byte             `json:"header_check"`
+	EncodingOverride        pgtype.Text        `json:"encoding_override"`
+	DelimiterOverride       pgtype.Text        `json:"delimiter_override"`
+	Dialect                 []byte             `json:"dialect"`
+	BundleID                pgtype.UUID        `json:"bundle_id"`
+	BundlePosition          pgtype.Int4        `json:"bundle_position"`
+	ContentSha256           pgtype.Text        `json:"content_sha256"`
+	CancelledByUserID       pgtype.Int8        `json:"cancelled_by_user_id"`
+	CancelledAt             pgtype.Timestamptz `json:"cancelled_at"`
+	RowsStaged              pgtype.Int4        `json:"rows_staged"`
+}
+
+type UploadBundle struct {
+	ID               pgtype.UUID        `json:"id"`
+	Filename         string             `json:"filename"`
+	UploadedByUserID int64              `json:"uploaded_by_user_id"`
+	UploadedAt       pgtype.Timestamptz `json:"uploaded_at"`
+}
+
+type UploadJob struct {
+	ID                      int64              `json:"id"`
+	UploadID                pgtype.UUID        `json:"upload_id"`
+	Status                  string             `json:"status"`
+	Attempts                int32              `json:"attempts"`
+	MaxAttempts             int32              `json:"max_attempts"`
+	RunAfter                pgtype.Timestamptz `json:"run_after"`
+	LockedBy                pgtype.Text        `json:"locked_by"`
+	LeaseExpiresAt          pgtype.Timestamptz `json:"lease_expires_at"`
+	HeartbeatAt             pgtype.Timestamptz `json:"heartbeat_at"`
+	LastError               pgtype.Text        `json:"last_error"`
+	CreatedAt               pgtype.Timestamptz `json:"created_at"`
+	UpdatedAt               pgtype.Timestamptz `json:"updated_at"`
+	CancelRequestedByUserID pgtype.Int8        `json:"cancel_requested_by_user_id"`
+	CancelRequestedAt       pgtype.Timestamptz `json:"cancel_requested_at"`
+}
+
+type UploadProgress struct {
+	UploadID  pgtype.UUID        `json:"upload_id"`
+	Event     []byte             `json:"event"`
+	UpdatedAt pgtype.Timestamptz `json:"updated_at"`
+}
+
+type UploadStagedRow struct {
+	UploadID pgtype.UUID `json:"upload_id"`
+	RowNum   int32       `json:"row_num"`
+	RowData  []byte      `json:"row_data"`
 }
 
 type UserBusinessLineAccess struct {
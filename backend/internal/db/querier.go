@@ -11,27 +11,89 @@ import (
 )
 
 type Querier interface {
+	// Wait for an exclusive advisory lock, held until the transaction ends
+	AcquireAdvisoryXactLock(ctx context.Context, lockKey int64) error
+	// Wait for a shared advisory lock, held until the transaction ends
+	AcquireAdvisoryXactLockShared(ctx context.Context, lockKey int64) error
 	// Updates the admin-modifiable fields of a specific chargeback record
 	AdminUpdateChargeback(ctx context.Context, arg AdminUpdateChargebackParams) (Chargeback, error)
 	// Updates the admin-modifiable fields of a specific delinquency record
 	AdminUpdateDelinquency(ctx context.Context, arg AdminUpdateDelinquencyParams) (Nonipac, error)
+	// Approve a staged upload and queue its merge in the same statement, so that an
+	// approved upload is never left without a job. The job that staged it is reset.
+	// The user who uploaded the file cannot approve it.
+	ApproveUpload(ctx context.Context, arg ApproveUploadParams) (UploadJob, error)
 	// Assigns a set of business lines to a user, replacing existing ones.
 	// This uses a CTE to first delete old assignments, then insert new ones.
 	AssignBusinessLinesToUser(ctx context.Context, arg AssignBusinessLinesToUserParams) error
 	// Assigns a specific role to a user.
 	AssignRoleToUser(ctx context.Context, arg AssignRoleToUserParams) error
+	// Cancel an upload that no worker has started on, along with its queued job
+	CancelWaitingUpload(ctx context.Context, arg CancelWaitingUploadParams) (Upload, error)
+	// Claim the next runnable job: a queued or retrying job whose backoff has elapsed,
+	// or a processing job whose lease expired because its worker died.
+	// A member of a bundle waits until the members before it have finished processing and,
+	// to merge, until they are no longer awaiting approval.
+	// SKIP LOCKED lets concurrent workers claim different jobs without blocking each other.
+	ClaimUploadJob(ctx context.Context, arg ClaimUploadJobParams) (ClaimUploadJobRow, error)
+	// Changes made by anything other than the upload to records the upload changed, after
+	// it ran. Rolling back over such changes would silently discard them
+	CountChangesSinceUpload(ctx context.Context, uploadID pgtype.UUID) (int64, error)
+	// Number of rows excluded from an upload during staging
+	CountRemovedRowsByUploadID(ctx context.Context, uploadID pgtype.UUID) (int64, error)
 	// Inserts a new chargeback record,from a manual UI entry.
 	// The 'reporting_source' is hardcoded to 'ApplicationCreated'.
 	CreateChargeback(ctx context.Context, arg CreateChargebackParams) (Chargeback, error)
+	// Inserts a comment, links it to a chargeback and records the users it mentions in one statement.
+	// The author is stamped into app.user_id so the audit trigger can attribute the insert.
+	CreateChargebackComment(ctx context.Context, arg CreateChargebackCommentParams) (Comment, error)
 	// Inserts a new delinquency (nonipac) record, from a manual UI entry.
 	// The 'reporting_source' is hardcoded to 'ApplicationCreated'.
 	CreateDelinquency(ctx context.Context, arg CreateDelinquencyParams) (Nonipac, error)
+	// Inserts a comment, links it to a delinquency and records the users it mentions in one statement.
+	// The author is stamped into app.user_id so the audit trigger can attribute the insert.
+	CreateDelinquencyComment(ctx context.Context, arg CreateDelinquencyCommentParams) (Comment, error)
+	// Add vendors known only by their code, flagged for review until a vendor directory
+	// report fills them in
+	CreatePlaceholderVendors(ctx context.Context, vendorCodes []string) (int64, error)
+	// Publish a new version of a report definition
+	CreateReportDefinition(ctx context.Context, arg CreateReportDefinitionParams) (ReportDefinition, error)
+	// Record a new upload that re-runs the stored file of an earlier one, optionally
+	// forcing a different encoding or delimiter, and queue it in the same statement so
+	// that it is never left without a job
+	CreateReprocessedUpload(ctx context.Context, arg CreateReprocessedUploadParams) (UploadJob, error)
 	// Create a record to track a new file upload
 	CreateUpload(ctx context.Context, arg CreateUploadParams) (Upload, error)
+	// Record a bundle of reports uploaded together
+	CreateUploadBundle(ctx context.Context, arg CreateUploadBundleParams) (UploadBundle, error)
 	CreateUserFromAuthProvider(ctx context.Context, arg CreateUserFromAuthProviderParams) (CdmsUser, error)
 	// Mark all existing chargebacks from a specific report source as inactive before an UPSERT
 	DeactivateChargebacksBySource(ctx context.Context, reportingSource ChargebackReportingSource) error
 	DeactivateNonIpacsBySource(ctx context.Context, reportingSource NonipacReportingSource) error
+	// Remove the agency bureaus the upload created
+	DeleteAgencyBureausInsertedByUpload(ctx context.Context, uploadID pgtype.UUID) (int64, error)
+	// Remove the chargebacks the upload created, along with the status history the
+	// insert logged for them
+	DeleteChargebacksInsertedByUpload(ctx context.Context, uploadID pgtype.UUID) (int64, error)
+	// Deletes a comment, its mentions and its chargeback/delinquency links, only when it belongs to the given author.
+	DeleteCommentByAuthor(ctx context.Context, arg DeleteCommentByAuthorParams) (int64, error)
+	// Remove the delinquencies the upload created, along with the status history the
+	// insert logged for them
+	DeleteNonIpacsInsertedByUpload(ctx context.Context, uploadID pgtype.UUID) (int64, error)
+	// Discard an upload's staged rows once it has been merged or rejected
+	DeleteUploadStagedRows(ctx context.Context, uploadID pgtype.UUID) error
+	// Queue an upload for processing and mark the upload as QUEUED.
+	// An upload whose job already finished gets that job reset.
+	EnqueueUploadJob(ctx context.Context, arg EnqueueUploadJobParams) (UploadJob, error)
+	// The latest upload of the same file content for a report type whose data is loaded or
+	// still on its way in. Dry runs and failed, rejected, rolled-back or cancelled uploads
+	// are ignored
+	FindUploadByContentSHA256(ctx context.Context, arg FindUploadByContentSHA256Params) (FindUploadByContentSHA256Row, error)
+	// Record that a job stopped because its cancellation was requested: the upload ends
+	// CANCELLED with who asked for it, and any rows staged for it are discarded
+	FinishCancelledUploadJob(ctx context.Context, id int64) error
+	// Record the terminal state of a job once the upload has succeeded or permanently failed
+	FinishUploadJob(ctx context.Context, arg FinishUploadJobParams) error
 	// Fetches a single active chargeback by business key
 	GetActiveChargebackByBusinessKey(ctx context.Context, arg GetActiveChargebackByBusinessKeyParams) (ActiveChargebacksWithVendorInfo, error)
 	// Fetches a single active chargeback by its primary key from the view.
@@ -42,24 +104,40 @@ type Querier interface {
 	GetActiveDelinquencyByID(ctx context.Context, id int64) (ActiveNonipacWithVendorInfo, error)
 	GetAverageDaysForPFSCompletionForWindow(ctx context.Context, arg GetAverageDaysForPFSCompletionForWindowParams) (string, error)
 	GetAverageDaysToPFSForWindow(ctx context.Context, arg GetAverageDaysToPFSForWindowParams) (string, error)
+	// Fetches a single comment only if it is attached to the given chargeback.
+	GetChargebackComment(ctx context.Context, arg GetChargebackCommentParams) (Comment, error)
 	// Fetches a single chargeback directly from the base table for updating.
 	GetChargebackForUpdate(ctx context.Context, id int64) (Chargeback, error)
 	// For a given list of bd_doc_nums, fetch the full business key and reporting source
 	// to check for cross-report conflicts in Go before an UPSERT.
 	GetChargebackSourcesByBDDocNums(ctx context.Context, dollar_1 []string) ([]GetChargebackSourcesByBDDocNumsRow, error)
 	// Gets the count, total value, and percentage of total value for each chargeback status for active items.
-	GetChargebackStatusSummary(ctx context.Context) ([]GetChargebackStatusSummaryRow, error)
+	// Limited to the caller's business lines unless all_business_lines is set.
+	GetChargebackStatusSummary(ctx context.Context, arg GetChargebackStatusSummaryParams) ([]GetChargebackStatusSummaryRow, error)
+	// Latest published version of a report definition
+	GetCurrentReportDefinition(ctx context.Context, reportType string) (ReportDefinition, error)
+	// Fetches a single comment only if it is attached to the given delinquency.
+	GetDelinquencyComment(ctx context.Context, arg GetDelinquencyCommentParams) (Comment, error)
 	// Fetches a single chargeback directly from the base table for updating.
 	GetDelinquencyForUpdate(ctx context.Context, id int64) (Nonipac, error)
+	// Rows staged by the most recent successful upload of a report type, the baseline for
+	// the row-count sanity check. rows_upserted is no baseline: upserts skip unchanged rows
+	GetLastSuccessfulUploadRowCount(ctx context.Context, reportType string) (pgtype.Int4, error)
+	// The most recent upload of a report type whose merge is still in effect
+	GetLatestMergedUploadID(ctx context.Context, reportType string) (pgtype.UUID, error)
 	// Gets the count and total value of new chargebacks created within a specific date window.
 	GetNewChargebackStatsForWindow(ctx context.Context, arg GetNewChargebackStatsForWindowParams) (GetNewChargebackStatsForWindowRow, error)
 	// Provides an aging schedule for active nonipac items, broken down by business line and age categories.
-	GetNonipacAgingScheduleByBusinessLine(ctx context.Context) ([]GetNonipacAgingScheduleByBusinessLineRow, error)
+	// Limited to the caller's business lines unless all_business_lines is set.
+	GetNonipacAgingScheduleByBusinessLine(ctx context.Context, arg GetNonipacAgingScheduleByBusinessLineParams) ([]GetNonipacAgingScheduleByBusinessLineRow, error)
 	// Gets the count, total value, and percentage of total value for each nonipac status for active items.
-	GetNonipacStatusSummary(ctx context.Context) ([]GetNonipacStatusSummaryRow, error)
+	// Limited to the caller's business lines unless all_business_lines is set.
+	GetNonipacStatusSummary(ctx context.Context, arg GetNonipacStatusSummaryParams) ([]GetNonipacStatusSummaryRow, error)
 	// Gets the count of chargebacks passed to PFS and completed by PFS within a specific date window.
 	// This version uses conditional aggregation for better performance and to avoid ambiguity.
 	GetPFSCountsForWindow(ctx context.Context, arg GetPFSCountsForWindowParams) (GetPFSCountsForWindowRow, error)
+	// Lock a removed row while it is being resolved
+	GetRemovedRowForUpdate(ctx context.Context, id pgtype.UUID) (RemovedRowsLog, error)
 	// Fetches all rows removed by processing of a particular upload
 	// most recent first
 	GetRemovedRowsByUploadID(ctx context.Context, uploadID pgtype.UUID) ([]RemovedRowsLog, error)
@@ -69,38 +147,171 @@ type Querier interface {
 	GetStatusHistoryForDelinquencies(ctx context.Context, nonipacID int64) ([]GetStatusHistoryForDelinquenciesRow, error)
 	// Retrieve a detailed summary for a specific upload
 	GetUpload(ctx context.Context, id pgtype.UUID) (GetUploadRow, error)
+	// Retrieve a bundle by ID
+	GetUploadBundle(ctx context.Context, id pgtype.UUID) (UploadBundle, error)
+	// The latest progress event recorded for an upload
+	GetUploadProgress(ctx context.Context, uploadID pgtype.UUID) ([]byte, error)
 	GetUserByAuthProviderSubject(ctx context.Context, authProviderSubject string) (CdmsUser, error)
 	GetUserByEmail(ctx context.Context, email string) (CdmsUser, error)
 	// Fetches a single user by their ID, including their roles, permissions, and business lines.
 	GetUserWithAuthorizationContext(ctx context.Context, id int64) (GetUserWithAuthorizationContextRow, error)
+	// Resolves @mention handles to active users, either by email or by first.last name (case-insensitive).
+	GetUsersForMentions(ctx context.Context, arg GetUsersForMentionsParams) ([]GetUsersForMentionsRow, error)
+	// Fetch a vendor's directory entry by its vendor code
+	GetVendor(ctx context.Context, vendorCode string) (AgencyBureau, error)
+	// Extend the lease on a job the worker still holds and report whether its cancellation
+	// has been requested; no row means the lease was lost
+	HeartbeatUploadJob(ctx context.Context, arg HeartbeatUploadJobParams) (bool, error)
 	// //go:generate mockery --name Querier --output ./mocks --outpkg mocks
 	// Fetches a paginated list from the active_chargebacks_with_vendor_info view.
 	// The view is already filtered by is_active = true.
+	// Results are limited to the caller's business lines unless all_business_lines is set.
 	ListActiveChargebacks(ctx context.Context, arg ListActiveChargebacksParams) ([]ListActiveChargebacksRow, error)
 	// Fetches a paginated list from the active_nonipac_with_vendor_info view.
 	// The view is already filtered by is_active = true.
+	// Results are limited to the caller's business lines unless all_business_lines is set.
 	ListActiveDelinquencies(ctx context.Context, arg ListActiveDelinquenciesParams) ([]ListActiveDelinquenciesRow, error)
 	// Fetches a paginated list of all users. For super_admins and global admins.
 	ListAllUsers(ctx context.Context, arg ListAllUsersParams) ([]ListAllUsersRow, error)
+	// The uploads of a bundle in the order they are processed
+	ListBundleUploads(ctx context.Context, bundleID pgtype.UUID) ([]ListBundleUploadsRow, error)
+	// Fetches all comments on a chargeback with their author and mentioned users, oldest first.
+	ListChargebackComments(ctx context.Context, chargebackID int64) ([]ListChargebackCommentsRow, error)
+	// Latest published version of each report definition
+	ListCurrentReportDefinitions(ctx context.Context) ([]ReportDefinition, error)
+	// Fetches all comments on a delinquency with their author and mentioned users, oldest first.
+	ListDelinquencyComments(ctx context.Context, nonipacID int64) ([]ListDelinquencyCommentsRow, error)
+	// Of the given vendor codes, the ones in the vendor directory or staged by a vendor
+	// directory report earlier in the upload's bundle, which is merged before it
+	ListKnownVendorCodes(ctx context.Context, arg ListKnownVendorCodesParams) ([]string, error)
+	// Every published version of a report definition, newest first
+	ListReportDefinitionVersions(ctx context.Context, reportType string) ([]ReportDefinition, error)
 	// Fetches all available roles in the system.
 	ListRoles(ctx context.Context) ([]Role, error)
+	// Vendor codes of staged chargebacks that are missing from the vendor directory
+	ListUnknownStagedChargebackVendors(ctx context.Context) ([]string, error)
+	// Vendor codes of staged delinquencies that are missing from the vendor directory
+	ListUnknownStagedNonIpacVendors(ctx context.Context) ([]string, error)
 	// Provides a paginated list of recent report uploads and their statuses
 	ListUploads(ctx context.Context, arg ListUploadsParams) ([]ListUploadsRow, error)
 	// Fetches a paginated list of users who are associated with a given set of business lines.
 	// This is for scoped admins.
 	ListUsersByBusinessLines(ctx context.Context, arg ListUsersByBusinessLinesParams) ([]ListUsersByBusinessLinesRow, error)
+	// Every recorded change to a vendor's directory entry, newest first, with the old and
+	// new value of each field that changed
+	ListVendorHistory(ctx context.Context, vendorCode string) ([]ListVendorHistoryRow, error)
+	// The vendor's active chargebacks in the caller's business lines, newest first
+	ListVendorOpenChargebacks(ctx context.Context, arg ListVendorOpenChargebacksParams) ([]ActiveChargebacksWithVendorInfo, error)
+	// The vendor's active delinquencies in the caller's business lines, newest first
+	ListVendorOpenDelinquencies(ctx context.Context, arg ListVendorOpenDelinquenciesParams) ([]ActiveNonipacWithVendorInfo, error)
+	// Page through the vendor directory, optionally narrowed to vendor codes starting with
+	// the search or names containing it, or to placeholder vendors awaiting review. Each
+	// vendor carries how many open chargebacks and delinquencies it has in the caller's
+	// business lines
+	ListVendors(ctx context.Context, arg ListVendorsParams) ([]ListVendorsRow, error)
+	// Record that a merged upload has been rolled back
+	MarkUploadRolledBack(ctx context.Context, arg MarkUploadRolledBackParams) (Upload, error)
+	// Record that an upload's rows are staged for review, with a preview of the merge and
+	// the number of rows staged
+	MarkUploadStaged(ctx context.Context, arg MarkUploadStagedParams) error
+	// Force an upload that failed the sanity check through, queueing it again in the same
+	// statement so that it is never left without a job
+	OverrideUploadSanityCheck(ctx context.Context, arg OverrideUploadSanityCheckParams) (Upload, error)
 	// Updates the user-modifiable fields of a specific chargeback record
 	PFSUpdateChargeback(ctx context.Context, arg PFSUpdateChargebackParams) (Chargeback, error)
 	// Updates the user-modifiable fields of a specific delinquency record
 	PFSUpdateDelinquency(ctx context.Context, arg PFSUpdateDelinquencyParams) (Nonipac, error)
+	// For existing vendor codes in the report, count how many would change in each field
+	PreviewAgencyBureauFieldChanges(ctx context.Context, compareColumns []string) ([]PreviewAgencyBureauFieldChangesRow, error)
+	// Count staged vendor codes that would be inserted
+	PreviewAgencyBureauMerge(ctx context.Context) (int64, error)
+	// Sample of existing vendor codes the merge would change, with old and new values per field.
+	// total_updated counts every changed vendor code, not just the sample
+	PreviewAgencyBureauUpdates(ctx context.Context, arg PreviewAgencyBureauUpdatesParams) ([]PreviewAgencyBureauUpdatesRow, error)
+	// For existing chargebacks in the report, count how many would change in each field
+	PreviewChargebackFieldChanges(ctx context.Context, compareColumns []string) ([]PreviewChargebackFieldChangesRow, error)
+	// Count staged chargebacks that would be inserted, active chargebacks from the same
+	// source that would be deactivated because they are missing from the report, and all
+	// currently active chargebacks from that source
+	PreviewChargebackMerge(ctx context.Context, reportingSource ChargebackReportingSource) (PreviewChargebackMergeRow, error)
+	// Sample of existing chargebacks the merge would change, with old and new values per field.
+	// total_updated counts every changed chargeback, not just the sample
+	PreviewChargebackUpdates(ctx context.Context, arg PreviewChargebackUpdatesParams) ([]PreviewChargebackUpdatesRow, error)
+	// For existing delinquencies in the report, count how many would change in each field
+	PreviewNonIpacFieldChanges(ctx context.Context, compareColumns []string) ([]PreviewNonIpacFieldChangesRow, error)
+	// Count staged delinquencies that would be inserted, active delinquencies from the same
+	// source that would be deactivated because they are missing from the report, and all
+	// currently active delinquencies from that source
+	PreviewNonIpacMerge(ctx context.Context, reportingSource NonipacReportingSource) (PreviewNonIpacMergeRow, error)
+	// Sample of existing delinquencies the merge would change, with old and new values per field.
+	// total_updated counts every changed delinquency, not just the sample
+	PreviewNonIpacUpdates(ctx context.Context, arg PreviewNonIpacUpdatesParams) ([]PreviewNonIpacUpdatesRow, error)
+	// Record an upload's latest progress event and send it to the servers listening on the
+	// upload_progress channel
+	PublishUploadProgress(ctx context.Context, arg PublishUploadProgressParams) error
+	// Move active chargebacks from a report source that are missing from the staged report
+	// to 'Reconciled - Off Report'. Runs before DeactivateChargebacksBySource
+	ReconcileOffReportChargebacks(ctx context.Context, reportingSource ChargebackReportingSource) (int64, error)
+	// Move active delinquencies from a report source that are missing from the staged report
+	// to 'Reconciled - Off Report' and date the reconciliation. Runs before DeactivateNonIpacsBySource
+	ReconcileOffReportNonIpacs(ctx context.Context, reportingSource NonipacReportingSource) (int64, error)
+	// Reject a staged upload; it will never be merged
+	RejectUpload(ctx context.Context, arg RejectUploadParams) (Upload, error)
 	// Removes all roles from a user.
 	RemoveAllRolesFromUser(ctx context.Context, userID int64) error
 	// Removes a specific role from a user.
 	RemoveRoleFromUser(ctx context.Context, arg RemoveRoleFromUserParams) error
+	// Reopen chargebacks reconciled off report that are back in the staged report
+	ReopenReconciledChargebacks(ctx context.Context) (int64, error)
+	// Reopen delinquencies reconciled off report that are back in the staged report
+	ReopenReconciledNonIpacs(ctx context.Context) (int64, error)
+	// Ask the worker processing an upload to stop, and tell the servers listening on the
+	// upload_progress channel so that whichever holds the job stops straight away.
+	// Repeating the request keeps the first requester.
+	RequestUploadJobCancel(ctx context.Context, arg RequestUploadJobCancelParams) (RequestUploadJobCancelRow, error)
+	// Close an open removed row as corrected or dismissed
+	ResolveRemovedRow(ctx context.Context, arg ResolveRemovedRowParams) (RemovedRowsLog, error)
+	// Put every agency bureau the upload updated back to its state before the upload
+	RestoreAgencyBureausFromAudit(ctx context.Context, uploadID pgtype.UUID) (int64, error)
+	// Put every chargeback the upload updated back to its state before the upload
+	RestoreChargebacksFromAudit(ctx context.Context, uploadID pgtype.UUID) (int64, error)
+	// Put every delinquency the upload updated back to its state before the upload
+	RestoreNonIpacsFromAudit(ctx context.Context, uploadID pgtype.UUID) (int64, error)
+	// Release a failed attempt back to the queue with a backoff delay
+	RetryUploadJob(ctx context.Context, arg RetryUploadJobParams) error
+	// Set the note the status history trigger records for status changes made by the
+	// rest of the transaction; an empty note restores the trigger's default
+	SetStatusHistoryNote(ctx context.Context, note string) error
+	// Store the encoding and delimiter an upload was read with
+	SetUploadDialect(ctx context.Context, arg SetUploadDialectParams) error
+	// Store how an upload's header row was matched to its report definition
+	SetUploadHeaderCheck(ctx context.Context, arg SetUploadHeaderCheckParams) error
+	// Store the dry-run preview computed for an upload
+	SetUploadPreview(ctx context.Context, arg SetUploadPreviewParams) error
+	// Record the definition version an upload was validated against
+	SetUploadReportDefinitionVersion(ctx context.Context, arg SetUploadReportDefinitionVersionParams) error
+	// Store the numbers computed by the mass-deactivation sanity check
+	SetUploadSanityCheck(ctx context.Context, arg SetUploadSanityCheckParams) error
+	// Show whether an upload being processed is waiting for another merge to finish.
+	// Runs outside the processing transaction so that the status is visible while it waits
+	SetUploadWaitingForLock(ctx context.Context, arg SetUploadWaitingForLockParams) error
+	// Count the validation issues of open removed rows by rule and column
+	SummarizeRemovedRowIssues(ctx context.Context, reportType string) ([]SummarizeRemovedRowIssuesRow, error)
+	// Take an exclusive advisory lock until the transaction ends if it is free right now
+	TryAdvisoryXactLock(ctx context.Context, lockKey int64) (bool, error)
+	// Take a shared advisory lock until the transaction ends if it is free right now
+	TryAdvisoryXactLockShared(ctx context.Context, lockKey int64) (bool, error)
+	// Updates a comment's text and the users it mentions only when it belongs to the given author.
+	// Mentions no longer present are removed and new ones are added; unchanged mentions are left in place.
+	// The author is stamped into app.user_id so the audit trigger can attribute the edit.
+	UpdateCommentByAuthor(ctx context.Context, arg UpdateCommentByAuthorParams) (Comment, error)
 	// Update the status of an upload record after processing is complete or has failed
 	UpdateUploadStatus(ctx context.Context, arg UpdateUploadStatusParams) error
 	// Updates a user's mutable details.
 	UpdateUser(ctx context.Context, arg UpdateUserParams) (CdmsUser, error)
+	// Insert new vendors from the staging table and update the ones whose details changed.
+	// Vendors the report repeats unchanged are left alone so that their audit history only
+	// records real changes. A placeholder vendor the report fills in no longer needs review
 	UpsertAgencyBureaus(ctx context.Context) (int64, error)
 	// Insert new records from the staging table, or update existing ones based on the business key
 	// The business key for chargebacks is BD Document Number + AL Number
@@ -319,13 +319,23 @@ SELECT
     sh.user_id,
     u.first_name AS user_first_name,
     u.last_name AS user_last_name,
-    u.email AS user_email
+    u.email AS user_email,
+    sh.upload_id,
+    sh.on_behalf_of_user_id,
+    obo.first_name AS on_behalf_of_first_name,
+    obo.last_name AS on_behalf_of_last_name,
+    (CASE
+        WHEN obo.id IS NULL THEN u.first_name || ' ' || u.last_name
+        ELSE u.first_name || ' ' || u.last_name || ' on behalf of ' || obo.first_name || ' ' || obo.last_name
+    END)::TEXT AS attributed_to
 FROM
     "status_history" sh
 JOIN
     "chargeback_status_merge" csm ON sh.id = csm.status_history_id
 JOIN
     "cdms_user" u ON sh.user_id = u.id
+LEFT JOIN
+    "cdms_user" obo ON sh.on_behalf_of_user_id = obo.id
 WHERE
     csm.chargeback_id = $1 
 ORDER BY
@@ -333,14 +343,19 @@ ORDER BY
 `
 
 type GetStatusHistoryForChargebackRow struct {
-	StatusHistoryID int64              `json:"status_history_id"`
-	Status          CdmsStatus         `json:"status"`
-	StatusDate      pgtype.Timestamptz `json:"status_date"`
-	Notes           pgtype.Text        `json:"notes"`
-	UserID          int64              `json:"user_id"`
-	UserFirstName   string             `json:"user_first_name"`
-	UserLastName    string             `json:"user_last_name"`
-	UserEmail       string             `json:"user_email"`
+	StatusHistoryID     int64              `json:"status_history_id"`
+	Status              CdmsStatus         `json:"status"`
+	StatusDate          pgtype.Timestamptz `json:"status_date"`
+	Notes               pgtype.Text        `json:"notes"`
+	UserID              int64              `json:"user_id"`
+	UserFirstName       string             `json:"user_first_name"`
+	UserLastName        string             `json:"user_last_name"`
+	UserEmail           string             `json:"user_email"`
+	UploadID            pgtype.UUID        `json:"upload_id"`
+	OnBehalfOfUserID    pgtype.Int8        `json:"on_behalf_of_user_id"`
+	OnBehalfOfFirstName pgtype.Text        `json:"on_behalf_of_first_name"`
+	OnBehalfOfLastName  pgtype.Text        `json:"on_behalf_of_last_name"`
+	AttributedTo        string             `json:"attributed_to"`
 }
 
 // Fetches Status History for Chargebacks
@@ -362,6 +377,11 @@ func (q *Queries) GetStatusHistoryForChargeback(ctx context.Context, chargebackI
 			&i.UserFirstName,
 			&i.UserLastName,
 			&i.UserEmail,
+			&i.UploadID,
+			&i.OnBehalfOfUserID,
+			&i.OnBehalfOfFirstName,
+			&i.OnBehalfOfLastName,
+			&i.AttributedTo,
 		); err != nil {
 			return nil, err
 		}
@@ -382,13 +402,23 @@ SELECT
     sh.user_id,
     u.first_name AS user_first_name,
     u.last_name AS user_last_name,
-    u.email AS user_email
+    u.email AS user_email,
+    sh.upload_id,
+    sh.on_behalf_of_user_id,
+    obo.first_name AS on_behalf_of_first_name,
+    obo.last_name AS on_behalf_of_last_name,
+    (CASE
+        WHEN obo.id IS NULL THEN u.first_name || ' ' || u.last_name
+        ELSE u.first_name || ' ' || u.last_name || ' on behalf of ' || obo.first_name || ' ' || obo.last_name
+    END)::TEXT AS attributed_to
 FROM
     "status_history" sh
 JOIN
     "nonipac_status_merge" nsm ON sh.id = nsm.status_history_id
 JOIN
     "cdms_user" u ON sh.user_id = u.id
+LEFT JOIN
+    "cdms_user" obo ON sh.on_behalf_of_user_id = obo.id
 WHERE
     nsm.nonipac_id = $1 
 ORDER BY
@@ -396,14 +426,19 @@ ORDER BY
 `
 
 type GetStatusHistoryForDelinquenciesRow struct {
-	StatusHistoryID int64              `json:"status_history_id"`
-	Status          CdmsStatus         `json:"status"`
-	StatusDate      pgtype.Timestamptz `json:"status_date"`
-	Notes           pgtype.Text        `json:"notes"`
-	UserID          int64              `json:"user_id"`
-	UserFirstName   string             `json:"user_first_name"`
-	UserLastName    string             `json:"user_last_name"`
-	UserEmail       string             `json:"user_email"`
+	StatusHistoryID     int64              `json:"status_history_id"`
+	Status              CdmsStatus         `json:"status"`
+	StatusDate          pgtype.Timestamptz `json:"status_date"`
+	Notes               pgtype.Text        `json:"notes"`
+	UserID              int64              `json:"user_id"`
+	UserFirstName       string             `json:"user_first_name"`
+	UserLastName        string             `json:"user_last_name"`
+	UserEmail           string             `json:"user_email"`
+	UploadID            pgtype.UUID        `json:"upload_id"`
+	OnBehalfOfUserID    pgtype.Int8        `json:"on_behalf_of_user_id"`
+	OnBehalfOfFirstName pgtype.Text        `json:"on_behalf_of_first_name"`
+	OnBehalfOfLastName  pgtype.Text        `json:"on_behalf_of_last_name"`
+	AttributedTo        string             `json:"attributed_to"`
 }
 
 // Fetches Status History for Delinquencies
@@ -425,6 +460,11 @@ func (q *Queries) GetStatusHistoryForDelinquencies(ctx context.Context, nonipacI
 			&i.UserFirstName,
 			&i.UserLastName,
 			&i.UserEmail,
+			&i.UploadID,
+			&i.OnBehalfOfUserID,
+			&i.OnBehalfOfFirstName,
+			&i.OnBehalfOfLastName,
+			&i.AttributedTo,
 		); err != nil {
 			return nil, err
 		}
@@ -481,14 +521,17 @@ func (q *Queries) GetUserByEmail(ctx context.Context, email string) (CdmsUser, e
 const listActiveChargebacks = `-- name: ListActiveChargebacks :many
 SELECT id, reporting_source, fund, business_line, region, location_system, program, al_num, source_num, agreement_num, title, alc, customer_tas, task_subtask, class_id, customer_name, org_code, document_date, accomp_date, assigned_rebill_drn, chargeback_amount, statement, bd_doc_num, vendor, articles_services, current_status, reason_code, action, alc_to_rebill, tas_to_rebill, line_of_accounting_rebill, special_instruction, new_ipac_document_ref, created_at, updated_at, is_active, days_old, agency_id, bureau_code, count(*) OVER() AS total_count
 FROM active_chargebacks_with_vendor_info
+WHERE ($1::boolean OR business_line = ANY($2::chargeback_business_line[]))
 ORDER BY document_date DESC
-LIMIT $1
-OFFSET $2
+LIMIT $3
+OFFSET $4
 `
 
 type ListActiveChargebacksParams struct {
-	Limit  int32 `json:"limit"`
-	Offset int32 `json:"offset"`
+	AllBusinessLines bool                     `json:"all_business_lines"`
+	BusinessLines    []ChargebackBusinessLine `json:"business_lines"`
+	PageLimit        int32                    `json:"page_limit"`
+	PageOffset       int32                    `json:"page_offset"`
 }
 
 type ListActiveChargebacksRow struct {
@@ -537,8 +580,14 @@ type ListActiveChargebacksRow struct {
 // //go:generate mockery --name Querier --output ./mocks --outpkg mocks
 // Fetches a paginated list from the active_chargebacks_with_vendor_info view.
 // The view is already filtered by is_active = true.
+// Results are limited to the caller's business lines unless all_business_lines is set.
 func (q *Queries) ListActiveChargebacks(ctx context.Context, arg ListActiveChargebacksParams) ([]ListActiveChargebacksRow, error) {
-	rows, err := q.db.Query(ctx, listActiveChargebacks, arg.Limit, arg.Offset)
+	rows, err := q.db.Query(ctx, listActiveChargebacks,
+		arg.AllBusinessLines,
+		arg.BusinessLines,
+		arg.PageLimit,
+		arg.PageOffset,
+	)
 	if err != nil {
 		return nil, err
 	}
@@ -601,14 +650,17 @@ func (q *Queries) ListActiveChargebacks(ctx context.Context, arg ListActiveCharg
 const listActiveDelinquencies = `-- name: ListActiveDelinquencies :many
 SELECT id, reporting_source, business_line, billed_total_amount, principle_amount, interest_amount, penalty_amount, administration_charges_amount, debit_outstanding_amount, credit_total_amount, credit_outstanding_amount, title, document_date, address_code, vendor, debt_appeal_forbearance, statement, document_number, vendor_code, collection_due_date, current_status, pfs_poc, gsa_poc, customer_poc, pfs_contacts, open_date, reconciled_date, created_at, updated_at, is_active, days_old, agency_id, bureau_code, count(*) OVER() AS total_count
 FROM active_nonipac_with_vendor_info
+WHERE ($1::boolean OR business_line = ANY($2::chargeback_business_line[]))
 ORDER BY document_date DESC
-LIMIT $1
-OFFSET $2
+LIMIT $3
+OFFSET $4
 `
 
 type ListActiveDelinquenciesParams struct {
-	Limit  int32 `json:"limit"`
-	Offset int32 `json:"offset"`
+	AllBusinessLines bool                     `json:"all_business_lines"`
+	BusinessLines    []ChargebackBusinessLine `json:"business_lines"`
+	PageLimit        int32                    `json:"page_limit"`
+	PageOffset       int32                    `json:"page_offset"`
 }
 
 type ListActiveDelinquenciesRow struct {
@@ -650,8 +702,14 @@ type ListActiveDelinquenciesRow struct {
 
 // Fetches a paginated list from the active_nonipac_with_vendor_info view.
 // The view is already filtered by is_active = true.
+// Results are limited to the caller's business lines unless all_business_lines is set.
 func (q *Queries) ListActiveDelinquencies(ctx context.Context, arg ListActiveDelinquenciesParams) ([]ListActiveDelinquenciesRow, error) {
-	rows, err := q.db.Query(ctx, listActiveDelinquencies, arg.Limit, arg.Offset)
+	rows, err := q.db.Query(ctx, listActiveDelinquencies,
+		arg.AllBusinessLines,
+		arg.BusinessLines,
+		arg.PageLimit,
+		arg.PageOffset,
+	)
 	if err != nil {
 		return nil, err
 	}
@@ -18,11 +18,18 @@ INSERT INTO uploads (
     filename,
     report_type,
     status,
-    processed_by_user_id
+    processed_by_user_id,
+    sheet_name,
+    dry_run,
+    encoding_override,
+    delimiter_override,
+    bundle_id,
+    bundle_position,
+    content_sha256
 ) VALUES (
-    $1, $2, $3, $4, $5, $6
+    $1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13
 )
-RETURNING id, storage_key, filename, report_type, status, uploaded_at, processed_at, error_details, processed_by_user_id, rows_upserted, rows_removed
+RETURNING id, storage_key, filename, report_type, status, uploaded_at, processed_at, error_details, processed_by_user_id, rows_upserted, rows_removed, sheet_name, dry_run, preview, staged_at, reviewed_by_user_id, reviewed_at, review_decision, review_reason, sanity_check, sanity_override, sanity_override_by_user_id, sanity_override_at, reprocessed_from_upload_id, rolled_back_by_user_id, rolled_back_at, report_definition_version, header_check, encoding_override, delimiter_override, dialect, bundle_id, bundle_position, content_sha256, cancelled_by_user_id, cancelled_at, rows_staged
 `
 
 type CreateUploadParams struct {
@@ -32,6 +39,13 @@ type CreateUploadParams struct {
 	ReportType        string      `json:"report_type"`
 	Status            string      `json:"status"`
 	ProcessedByUserID int64       `json:"processed_by_user_id"`
+	SheetName         pgtype.Text `json:"sheet_name"`
+	DryRun            bool        `json:"dry_run"`
+	EncodingOverride  pgtype.Text `json:"encoding_override"`
+	DelimiterOverride pgtype.Text `json:"delimiter_override"`
+	BundleID          pgtype.UUID `json:"bundle_id"`
+	BundlePosition    pgtype.Int4 `json:"bundle_position"`
+	ContentSha256     pgtype.Text `json:"content_sha256"`
 }
 
 // Create a record to track a new file upload
@@ -43,6 +57,13 @@ func (q *Queries) CreateUpload(ctx context.Context, arg CreateUploadParams) (Upl
 		arg.ReportType,
 		arg.Status,
 		arg.ProcessedByUserID,
+		arg.SheetName,
+		arg.DryRun,
+		arg.EncodingOverride,
+		arg.DelimiterOverride,
+		arg.BundleID,
+		arg.BundlePosition,
+		arg.ContentSha256,
 	)
 	var i Upload
 	err := row.Scan(
@@ -57,6 +78,71 @@ func (q *Queries) CreateUpload(ctx context.Context, arg CreateUploadParams) (Upl
 		&i.ProcessedByUserID,
 		&i.RowsUpserted,
 		&i.RowsRemoved,
+		&i.SheetName,
+		&i.DryRun,
+		&i.Preview,
+		&i.StagedAt,
+		&i.ReviewedByUserID,
+		&i.ReviewedAt,
+		&i.ReviewDecision,
+		&i.ReviewReason,
+		&i.SanityCheck,
+		&i.SanityOverride,
+		&i.SanityOverrideByUserID,
+		&i.SanityOverrideAt,
+		&i.ReprocessedFromUploadID,
+		&i.RolledBackByUserID,
+		&i.RolledBackAt,
+		&i.ReportDefinitionVersion,
+		&i.HeaderCheck,
+		&i.EncodingOverride,
+		&i.DelimiterOverride,
+		&i.Dialect,
+		&i.BundleID,
+		&i.BundlePosition,
+		&i.ContentSha256,
+		&i.CancelledByUserID,
+		&i.CancelledAt,
+		&i.RowsStaged,
+	)
+	return i, err
+}
+
+const findUploadByContentSHA256 = `-- name: FindUploadByContentSHA256 :one
+SELECT id, filename, status, uploaded_at
+FROM uploads
+WHERE report_type = $1
+  AND content_sha256 = $2
+  AND NOT dry_run
+  AND status NOT LIKE 'FAILED%'
+  AND status NOT IN ('REJECTED', 'ROLLED_BACK', 'CANCELLED')
+ORDER BY uploaded_at DESC
+LIMIT 1
+`
+
+type FindUploadByContentSHA256Params struct {
+	ReportType    string      `json:"report_type"`
+	ContentSha256 pgtype.Text `json:"content_sha256"`
+}
+
+type FindUploadByContentSHA256Row struct {
+	ID         pgtype.UUID        `json:"id"`
+	Filename   string             `json:"filename"`
+	Status     string             `json:"status"`
+	UploadedAt pgtype.Timestamptz `json:"uploaded_at"`
+}
+
+// The latest upload of the same file content for a report type whose data is loaded or
+// still on its way in. Dry runs and failed, rejected, rolled-back or cancelled uploads
+// are ignored
+func (q *Queries) FindUploadByContentSHA256(ctx context.Context, arg FindUploadByContentSHA256Params) (FindUploadByContentSHA256Row, error) {
+	row := q.db.QueryRow(ctx, findUploadByContentSHA256, arg.ReportType, arg.ContentSha256)
+	var i FindUploadByContentSHA256Row
+	err := row.Scan(
+		&i.ID,
+		&i.Filename,
+		&i.Status,
+		&i.UploadedAt,
 	)
 	return i, err
 }
@@ -73,26 +159,90 @@ SELECT
     u.error_details,
     u.rows_upserted,
     u.rows_removed,
+    u.sheet_name,
+    u.dry_run,
+    u.preview,
+    u.processed_by_user_id,
+    u.staged_at,
+    u.review_decision,
+    u.review_reason,
+    u.reviewed_at,
+    u.sanity_check,
+    u.sanity_override,
+    u.sanity_override_at,
+    u.reprocessed_from_upload_id,
+    u.rolled_back_at,
+    u.report_definition_version,
+    u.header_check,
+    u.encoding_override,
+    u.delimiter_override,
+    u.dialect,
+    u.bundle_id,
+    u.bundle_position,
+    u.content_sha256,
+    u.cancelled_by_user_id,
+    u.cancelled_at,
+    u.rows_staged,
     usr.first_name, 
-    usr.last_name
+    usr.last_name,
+    reviewer.first_name AS reviewer_first_name,
+    reviewer.last_name AS reviewer_last_name,
+    j.attempts,
+    j.max_attempts,
+    j.run_after AS next_attempt_at,
+    j.last_error,
+    (SELECT COUNT(*) FROM removed_rows_log r WHERE r.upload_id = u.id AND r.resolution_status = 'OPEN') AS open_removed_rows
 FROM uploads u
 LEFT JOIN "cdms_user" usr ON u.processed_by_user_id = usr.id
+LEFT JOIN "cdms_user" reviewer ON u.reviewed_by_user_id = reviewer.id
+LEFT JOIN upload_jobs j ON j.upload_id = u.id
 WHERE u.id = $1
 `
 
 type GetUploadRow struct {
-	ID           pgtype.UUID        `json:"id"`
-	StorageKey   string             `json:"storage_key"`
-	Filename     string             `json:"filename"`
-	ReportType   string             `json:"report_type"`
-	Status       string             `json:"status"`
-	UploadedAt   pgtype.Timestamptz `json:"uploaded_at"`
-	ProcessedAt  pgtype.Timestamptz `json:"processed_at"`
-	ErrorDetails pgtype.Text        `json:"error_details"`
-	RowsUpserted pgtype.Int4        `json:"rows_upserted"`
-	RowsRemoved  pgtype.Int4        `json:"rows_removed"`
-	FirstName    pgtype.Text        `json:"first_name"`
-	LastName     pgtype.Text        `json:"last_name"`
+	ID                      pgtype.UUID        `json:"id"`
+	StorageKey              string             `json:"storage_key"`
+	Filename                string             `json:"filename"`
+	ReportType              string             `json:"report_type"`
+	Status                  string             `json:"status"`
+	UploadedAt              pgtype.Timestamptz `json:"uploaded_at"`
+	ProcessedAt             pgtype.Timestamptz `json:"processed_at"`
+	ErrorDetails            pgtype.Text        `json:"error_details"`
+	RowsUpserted            pgtype.Int4        `json:"rows_upserted"`
+	RowsRemoved             pgtype.Int4        `json:"rows_removed"`
+	SheetName               pgtype.Text        `json:"sheet_name"`
+	DryRun                  bool               `json:"dry_run"`
+	Preview                 []byte             `json:"preview"`
+	ProcessedByUserID       int64              `json:"processed_by_user_id"`
+	StagedAt                pgtype.Timestamptz `json:"staged_at"`
+	ReviewDecision          pgtype.Text        `json:"review_decision"`
+	ReviewReason            pgtype.Text        `json:"review_reason"`
+	ReviewedAt              pgtype.Timestamptz `json:"reviewed_at"`
+	SanityCheck             []byte             `json:"sanity_check"`
+	SanityOverride          bool               `json:"sanity_override"`
+	SanityOverrideAt        pgtype.Timestamptz `json:"sanity_override_at"`
+	ReprocessedFromUploadID pgtype.UUID        `json:"reprocessed_from_upload_id"`
+	RolledBackAt            pgtype.Timestamptz `json:"rolled_back_at"`
+	ReportDefinitionVersion pgtype.Int4        `json:"report_definition_version"`
+	HeaderCheck             []byte             `json:"header_check"`
+	EncodingOverride        pgtype.Text        `json:"encoding_override"`
+	DelimiterOverride       pgtype.Text        `json:"delimiter_override"`
+	Dialect                 []byte             `json:"dialect"`
+	BundleID                pgtype.UUID        `json:"bundle_id"`
+	BundlePosition          pgtype.Int4        `json:"bundle_position"`
+	ContentSha256           pgtype.Text        `json:"content_sha256"`
+	CancelledByUserID       pgtype.Int8        `json:"cancelled_by_user_id"`
+	CancelledAt             pgtype.Timestamptz `json:"cancelled_at"`
+	RowsStaged              pgtype.Int4        `json:"rows_staged"`
+	FirstName               pgtype.Text        `json:"first_name"`
+	LastName                pgtype.Text        `json:"last_name"`
+	ReviewerFirstName       pgtype.Text        `json:"reviewer_first_name"`
+	ReviewerLastName        pgtype.Text        `json:"reviewer_last_name"`
+	Attempts                pgtype.Int4        `json:"attempts"`
+	MaxAttempts             pgtype.Int4        `json:"max_attempts"`
+	NextAttemptAt           pgtype.Timestamptz `json:"next_attempt_at"`
+	LastError               pgtype.Text        `json:"last_error"`
+	OpenRemovedRows         int64              `json:"open_removed_rows"`
 }
 
 // Retrieve a detailed summary for a specific upload
@@ -110,8 +260,39 @@ func (q *Queries) GetUpload(ctx context.Context, id pgtype.UUID) (GetUploadRow,
 		&i.ErrorDetails,
 		&i.RowsUpserted,
 		&i.RowsRemoved,
+		&i.SheetName,
+		&i.DryRun,
+		&i.Preview,
+		&i.ProcessedByUserID,
+		&i.StagedAt,
+		&i.ReviewDecision,
+		&i.ReviewReason,
+		&i.ReviewedAt,
+		&i.SanityCheck,
+		&i.SanityOverride,
+		&i.SanityOverrideAt,
+		&i.ReprocessedFromUploadID,
+		&i.RolledBackAt,
+		&i.ReportDefinitionVersion,
+		&i.HeaderCheck,
+		&i.EncodingOverride,
+		&i.DelimiterOverride,
+		&i.Dialect,
+		&i.BundleID,
+		&i.BundlePosition,
+		&i.ContentSha256,
+		&i.CancelledByUserID,
+		&i.CancelledAt,
+		&i.RowsStaged,
 		&i.FirstName,
 		&i.LastName,
+		&i.ReviewerFirstName,
+		&i.ReviewerLastName,
+		&i.Attempts,
+		&i.MaxAttempts,
+		&i.NextAttemptAt,
+		&i.LastError,
+		&i.OpenRemovedRows,
 	)
 	return i, err
 }
@@ -128,11 +309,40 @@ SELECT
     u.error_details,
     u.rows_upserted,
     u.rows_removed,
+    u.sheet_name,
+    u.dry_run,
+    u.processed_by_user_id,
+    u.staged_at,
+    u.review_decision,
+    u.review_reason,
+    u.reviewed_at,
+    u.reprocessed_from_upload_id,
+    u.rolled_back_at,
+    u.report_definition_version,
+    u.header_check,
+    u.encoding_override,
+    u.delimiter_override,
+    u.dialect,
+    u.bundle_id,
+    u.bundle_position,
+    u.content_sha256,
+    u.cancelled_by_user_id,
+    u.cancelled_at,
+    u.rows_staged,
     usr.first_name, 
-    usr.last_name
+    usr.last_name,
+    reviewer.first_name AS reviewer_first_name,
+    reviewer.last_name AS reviewer_last_name,
+    j.attempts,
+    j.max_attempts,
+    j.run_after AS next_attempt_at,
+    j.last_error,
+    (SELECT COUNT(*) FROM removed_rows_log r WHERE r.upload_id = u.id AND r.resolution_status = 'OPEN') AS open_removed_rows
 FROM uploads u
 LEFT JOIN "cdms_user" usr ON u.processed_by_user_id = usr.id
-ORDER BY uploaded_at DESC
+LEFT JOIN "cdms_user" reviewer ON u.reviewed_by_user_id = reviewer.id
+LEFT JOIN upload_jobs j ON j.upload_id = u.id
+ORDER BY u.uploaded_at DESC
 LIMIT $1
 OFFSET $2
 `
@@ -143,18 +353,45 @@ type ListUploadsParams struct {
 }
 
 type ListUploadsRow struct {
-	ID           pgtype.UUID        `json:"id"`
-	StorageKey   string             `json:"storage_key"`
-	Filename     string             `json:"filename"`
-	ReportType   string             `json:"report_type"`
-	Status       string             `json:"status"`
-	UploadedAt   pgtype.Timestamptz `json:"uploaded_at"`
-	ProcessedAt  pgtype.Timestamptz `json:"processed_at"`
-	ErrorDetails pgtype.Text        `json:"error_details"`
-	RowsUpserted pgtype.Int4        `json:"rows_upserted"`
-	RowsRemoved  pgtype.Int4        `json:"rows_removed"`
-	FirstName    pgtype.Text        `json:"first_name"`
-	LastName     pgtype.Text        `json:"last_name"`
+	ID                      pgtype.UUID        `json:"id"`
+	StorageKey              string             `json:"storage_key"`
+	Filename                string             `json:"filename"`
+	ReportType              string             `json:"report_type"`
+	Status                  string             `json:"status"`
+	UploadedAt              pgtype.Timestamptz `json:"uploaded_at"`
+	ProcessedAt             pgtype.Timestamptz `json:"processed_at"`
+	ErrorDetails            pgtype.Text        `json:"error_details"`
+	RowsUpserted            pgtype.Int4        `json:"rows_upserted"`
+	RowsRemoved             pgtype.Int4        `json:"rows_removed"`
+	SheetName               pgtype.Text        `json:"sheet_name"`
+	DryRun                  bool               `json:"dry_run"`
+	ProcessedByUserID       int64              `json:"processed_by_user_id"`
+	StagedAt                pgtype.Timestamptz `json:"staged_at"`
+	ReviewDecision          pgtype.Text        `json:"review_decision"`
+	ReviewReason            pgtype.Text        `json:"review_reason"`
+	ReviewedAt              pgtype.Timestamptz `json:"reviewed_at"`
+	ReprocessedFromUploadID pgtype.UUID        `json:"reprocessed_from_upload_id"`
+	RolledBackAt            pgtype.Timestamptz `json:"rolled_back_at"`
+	ReportDefinitionVersion pgtype.Int4        `json:"report_definition_version"`
+	HeaderCheck             []byte             `json:"header_check"`
+	EncodingOverride        pgtype.Text        `json:"encoding_override"`
+	DelimiterOverride       pgtype.Text        `json:"delimiter_override"`
+	Dialect                 []byte             `json:"dialect"`
+	BundleID                pgtype.UUID        `json:"bundle_id"`
+	BundlePosition          pgtype.Int4        `json:"bundle_position"`
+	ContentSha256           pgtype.Text        `json:"content_sha256"`
+	CancelledByUserID       pgtype.Int8        `json:"cancelled_by_user_id"`
+	CancelledAt             pgtype.Timestamptz `json:"cancelled_at"`
+	RowsStaged              pgtype.Int4        `json:"rows_staged"`
+	FirstName               pgtype.Text        `json:"first_name"`
+	LastName                pgtype.Text        `json:"last_name"`
+	ReviewerFirstName       pgtype.Text        `json:"reviewer_first_name"`
+	ReviewerLastName        pgtype.Text        `json:"reviewer_last_name"`
+	Attempts                pgtype.Int4        `json:"attempts"`
+	MaxAttempts             pgtype.Int4        `json:"max_attempts"`
+	NextAttemptAt           pgtype.Timestamptz `json:"next_attempt_at"`
+	LastError               pgtype.Text        `json:"last_error"`
+	OpenRemovedRows         int64              `json:"open_removed_rows"`
 }
 
 // Provides a paginated list of recent report uploads and their statuses
@@ -178,8 +415,35 @@ func (q *Queries) ListUploads(ctx context.Context, arg ListUploadsParams) ([]Lis
 			&i.ErrorDetails,
 			&i.RowsUpserted,
 			&i.RowsRemoved,
+			&i.SheetName,
+			&i.DryRun,
+			&i.ProcessedByUserID,
+			&i.StagedAt,
+			&i.ReviewDecision,
+			&i.ReviewReason,
+			&i.ReviewedAt,
+			&i.ReprocessedFromUploadID,
+			&i.RolledBackAt,
+			&i.ReportDefinitionVersion,
+			&i.HeaderCheck,
+			&i.EncodingOverride,
+			&i.DelimiterOverride,
+			&i.Dialect,
+			&i.BundleID,
+			&i.BundlePosition,
+			&i.ContentSha256,
+			&i.CancelledByUserID,
+			&i.CancelledAt,
+			&i.RowsStaged,
 			&i.FirstName,
 			&i.LastName,
+			&i.ReviewerFirstName,
+			&i.ReviewerLastName,
+			&i.Attempts,
+			&i.MaxAttempts,
+			&i.NextAttemptAt,
+			&i.LastError,
+			&i.OpenRemovedRows,
 		); err != nil {
 			return nil, err
 		}
@@ -191,6 +455,57 @@ func (q *Queries) ListUploads(ctx context.Context, arg ListUploadsParams) ([]Lis
 	return items, nil
 }
 
+const setUploadDialect = `-- name: SetUploadDialect :exec
+UPDATE uploads
+SET dialect = $2
+WHERE id = $1
+`
+
+type SetUploadDialectParams struct {
+	ID      pgtype.UUID `json:"id"`
+	Dialect []byte      `json:"dialect"`
+}
+
+// Store the encoding and delimiter an upload was read with
+func (q *Queries) SetUploadDialect(ctx context.Context, arg SetUploadDialectParams) error {
+	_, err := q.db.Exec(ctx, setUploadDialect, arg.ID, arg.Dialect)
+	return err
+}
+
+const setUploadHeaderCheck = `-- name: SetUploadHeaderCheck :exec
+UPDATE uploads
+SET header_check = $2
+WHERE id = $1
+`
+
+type SetUploadHeaderCheckParams struct {
+	ID          pgtype.UUID `json:"id"`
+	HeaderCheck []byte      `json:"header_check"`
+}
+
+// Store how an upload's header row was matched to its report definition
+func (q *Queries) SetUploadHeaderCheck(ctx context.Context, arg SetUploadHeaderCheckParams) error {
+	_, err := q.db.Exec(ctx, setUploadHeaderCheck, arg.ID, arg.HeaderCheck)
+	return err
+}
+
+const setUploadPreview = `-- name: SetUploadPreview :exec
+UPDATE uploads
+SET preview = $2
+WHERE id = $1
+`
+
+type SetUploadPreviewParams struct {
+	ID      pgtype.UUID `json:"id"`
+	Preview []byte      `json:"preview"`
+}
+
+// Store the dry-run preview computed for an upload
+func (q *Queries) SetUploadPreview(ctx context.Context, arg SetUploadPreviewParams) error {
+	_, err := q.db.Exec(ctx, setUploadPreview, arg.ID, arg.Preview)
+	return err
+}
+
 const updateUploadStatus = `-- name: UpdateUploadStatus :exec
 UPDATE uploads
 SET
@@ -12,7 +12,7 @@ import (
 )
 
 const getRemovedRowsByUploadID = `-- name: GetRemovedRowsByUploadID :many
-SELECT id, upload_id, timestamp, report_type, original_row_data, reason_for_removal FROM removed_rows_log
+SELECT id, upload_id, timestamp, report_type, original_row_data, reason_for_removal, resolution_status, corrected_row_data, resolution_note, resolved_by_user_id, resolved_at, issues FROM removed_rows_log
 WHERE upload_id = $1
 ORDER BY timestamp DESC
 `
@@ -35,6 +35,12 @@ func (q *Queries) GetRemovedRowsByUploadID(ctx context.Context, uploadID pgtype.
 			&i.ReportType,
 			&i.OriginalRowData,
 			&i.ReasonForRemoval,
+			&i.ResolutionStatus,
+			&i.CorrectedRowData,
+			&i.ResolutionNote,
+			&i.ResolvedByUserID,
+			&i.ResolvedAt,
+			&i.Issues,
 		); err != nil {
 			return nil, err
 		}
@@ -0,0 +1,163 @@
+package dialect
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// windows1252High maps bytes 0x80-0x9F, where Windows-1252 differs from Latin-1.
+// Undefined bytes map to the replacement character.
+var windows1252High = [32]rune{
+	'€', utf8.RuneError, '‚', 'ƒ', '„', '…', '†', '‡', 'ˆ', '‰', 'Š', '‹', 'Œ', utf8.RuneError, 'Ž', utf8.RuneError,
+	utf8.RuneError, '‘', '’', '“', '”', '•', '–', '—', '˜', '™', 'š', '›', 'œ', utf8.RuneError, 'ž', 'Ÿ',
+}
+
+func windows1252Rune(b byte) rune {
+	if b >= 0x80 && b < 0xA0 {
+		return windows1252High[b-0x80]
+	}
+	return rune(b)
+}
+
+// NewReader returns the contents of r as UTF-8, dropping the byte order mark if the
+// dialect has one.
+func NewReader(r io.Reader, d Dialect) (io.Reader, error) {
+	br := bufio.NewReader(r)
+	if d.BOM {
+		for _, b := range boms {
+			if b.encoding == d.Encoding {
+				if _, err := br.Discard(len(b.mark)); err != nil && err != io.EOF {
+					return nil, fmt.Errorf("dialect: skipping byte order mark: %w", err)
+				}
+			}
+		}
+	}
+
+	switch d.Encoding {
+	case UTF8:
+		return br, nil
+	case Windows1252:
+		return &windows1252Reader{src: br}, nil
+	case UTF16LE, UTF16BE:
+		return &utf16Reader{src: br, bigEndian: d.Encoding == UTF16BE}, nil
+	}
+	return nil, fmt.Errorf("%w encoding %q", ErrUnsupported, d.Encoding)
+}
+
+// decodeSample decodes as much of a sample as is complete, for sniffing.
+func decodeSample(sample []byte, encoding string, bom bool) string {
+	r, err := NewReader(bytes.NewReader(sample), Dialect{Encoding: encoding, BOM: bom})
+	if err != nil {
+		return ""
+	}
+	var sb strings.Builder
+	io.Copy(&sb, r)
+	return sb.String()
+}
+
+// windows1252Reader transcodes Windows-1252 to UTF-8.
+type windows1252Reader struct {
+	src     *bufio.Reader
+	pending []byte
+}
+
+func (r *windows1252Reader) Read(p []byte) (int, error) {
+	n := 0
+	for n < len(p) {
+		if len(r.pending) > 0 {
+			c := copy(p[n:], r.pending)
+			r.pending = r.pending[c:]
+			n += c
+			continue
+		}
+		b, err := r.src.ReadByte()
+		if err != nil {
+			if n > 0 {
+				return n, nil
+			}
+			return 0, err
+		}
+		if b < utf8.RuneSelf {
+			p[n] = b
+			n++
+			continue
+		}
+		r.pending = utf8.AppendRune(r.pending[:0], windows1252Rune(b))
+	}
+	return n, nil
+}
+
+// utf16Reader transcodes UTF-16 to UTF-8. Unpaired surrogates and a trailing odd
+// byte become the replacement character.
+type utf16Reader struct {
+	src       *bufio.Reader
+	bigEndian bool
+	pending   []byte
+	// next is a unit read while looking for a low surrogate that turned out not to be one.
+	next    uint16
+	hasNext bool
+}
+
+func (r *utf16Reader) readUnit() (uint16, error) {
+	if r.hasNext {
+		r.hasNext = false
+		return r.next, nil
+	}
+	var buf [2]byte
+	n, err := io.ReadFull(r.src, buf[:])
+	if err == io.ErrUnexpectedEOF && n == 1 {
+		return utf8.RuneError, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	if r.bigEndian {
+		return uint16(buf[0])<<8 | uint16(buf[1]), nil
+	}
+	return uint16(buf[1])<<8 | uint16(buf[0]), nil
+}
+
+func (r *utf16Reader) readRune() (rune, error) {
+	unit, err := r.readUnit()
+	if err != nil {
+		return 0, err
+	}
+	if !utf16.IsSurrogate(rune(unit)) {
+		return rune(unit), nil
+	}
+	low, err := r.readUnit()
+	if err != nil {
+		return utf8.RuneError, nil
+	}
+	if decoded := utf16.DecodeRune(rune(unit), rune(low)); decoded != utf8.RuneError {
+		return decoded, nil
+	}
+	r.next, r.hasNext = low, true
+	return utf8.RuneError, nil
+}
+
+func (r *utf16Reader) Read(p []byte) (int, error) {
+	n := 0
+	for n < len(p) {
+		if len(r.pending) > 0 {
+			c := copy(p[n:], r.pending)
+			r.pending = r.pending[c:]
+			n += c
+			continue
+		}
+		ru, err := r.readRune()
+		if err != nil {
+			if n > 0 {
+				return n, nil
+			}
+			return 0, err
+		}
+		r.pending = utf8.AppendRune(r.pending[:0], ru)
+	}
+	return n, nil
+}
@@ -0,0 +1,231 @@
+// Package dialect works out how a delimited text report was written: its character
+// encoding, whether it starts with a byte order mark and which delimiter separates its
+// fields. Reports arrive as UTF-8, as UTF-16 saved by Excel's "Unicode Text" option, as
+// Windows-1252 from older exports, and semicolon-delimited from European-locale Excel.
+//
+// Detection works on a sample from the start of the file. NewReader then turns the
+// whole file into UTF-8 without its byte order mark, ready for encoding/csv.
+package dialect
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// Encodings a report can be read as.
+const (
+	UTF8        = "utf-8"
+	UTF16LE     = "utf-16le"
+	UTF16BE     = "utf-16be"
+	Windows1252 = "windows-1252"
+)
+
+// ErrUnsupported is returned for an encoding or delimiter this package cannot handle.
+var ErrUnsupported = errors.New("dialect: unsupported")
+
+// Sources of a dialect setting.
+const (
+	SourceDetected = "detected"
+	SourceOverride = "override"
+	SourceDefault  = "default"
+)
+
+// SampleSize is how much of the file Detect should be given.
+const SampleSize = 64 * 1024
+
+// Dialect is how a report file is encoded and delimited.
+type Dialect struct {
+	Encoding string `json:"encoding"`
+	// BOM is set when the file starts with the byte order mark of its encoding.
+	BOM       bool   `json:"bom"`
+	Delimiter string `json:"delimiter"`
+	// EncodingSource and DelimiterSource say whether each setting was detected,
+	// overridden by the uploader or, for a delimiter that could not be sniffed, the default.
+	EncodingSource  string `json:"encoding_source"`
+	DelimiterSource string `json:"delimiter_source"`
+}
+
+// Comma returns the delimiter as the rune encoding/csv expects.
+func (d Dialect) Comma() rune {
+	r, _ := utf8.DecodeRuneInString(d.Delimiter)
+	return r
+}
+
+var boms = []struct {
+	encoding string
+	mark     []byte
+}{
+	{UTF8, []byte{0xEF, 0xBB, 0xBF}},
+	{UTF16LE, []byte{0xFF, 0xFE}},
+	{UTF16BE, []byte{0xFE, 0xFF}},
+}
+
+// candidateDelimiters are tried in order of preference when sniffing.
+var candidateDelimiters = []string{",", ";", "\t", "|"}
+
+// Detect inspects the start of a file. encoding and delimiter override detection when
+// set; they must already be normalised by ParseEncoding and ParseDelimiter.
+func Detect(sample []byte, encoding, delimiter string) Dialect {
+	d := Dialect{Encoding: encoding, EncodingSource: SourceOverride}
+
+	bomEncoding := ""
+	for _, b := range boms {
+		if bytes.HasPrefix(sample, b.mark) {
+			bomEncoding = b.encoding
+			break
+		}
+	}
+	if d.Encoding == "" {
+		d.EncodingSource = SourceDetected
+		d.Encoding = bomEncoding
+		if d.Encoding == "" {
+			d.Encoding = detectEncoding(sample)
+		}
+	}
+	d.BOM = bomEncoding != "" && bomEncoding == d.Encoding
+
+	d.Delimiter, d.DelimiterSource = delimiter, SourceOverride
+	if d.Delimiter == "" {
+		text := decodeSample(sample, d.Encoding, d.BOM)
+		d.Delimiter, d.DelimiterSource = sniffDelimiter(text), SourceDetected
+		if d.Delimiter == "" {
+			d.Delimiter, d.DelimiterSource = ",", SourceDefault
+		}
+	}
+	return d
+}
+
+// detectEncoding guesses the encoding of a sample without a byte order mark. Text
+// whose every other byte is zero is UTF-16; valid UTF-8 is taken as UTF-8; anything
+// else is treated as Windows-1252, the usual encoding of non-Unicode exports.
+func detectEncoding(sample []byte) string {
+	if len(sample) >= 4 {
+		var evenZeros, oddZeros int
+		for i, b := range sample {
+			if b != 0 {
+				continue
+			}
+			if i%2 == 0 {
+				evenZeros++
+			} else {
+				oddZeros++
+			}
+		}
+		half := len(sample) / 2
+		switch {
+		case oddZeros > half*3/4 && evenZeros == 0:
+			return UTF16LE
+		case evenZeros > half*3/4 && oddZeros == 0:
+			return UTF16BE
+		}
+	}
+	if utf8.Valid(trimPartialRune(sample)) {
+		return UTF8
+	}
+	return Windows1252
+}
+
+// trimPartialRune drops a UTF-8 sequence cut off by the end of the sample.
+func trimPartialRune(sample []byte) []byte {
+	for i := 1; i <= utf8.UTFMax && i <= len(sample); i++ {
+		if utf8.RuneStart(sample[len(sample)-i]) {
+			if !utf8.FullRune(sample[len(sample)-i:]) {
+				return sample[:len(sample)-i]
+			}
+			break
+		}
+	}
+	return sample
+}
+
+// sniffDelimiter picks the candidate that appears the same, non-zero number of times
+// outside quotes on each of the first lines, preferring the most frequent. A file with
+// a single line uses the most frequent candidate on it.
+func sniffDelimiter(text string) string {
+	lines := strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n")
+	// The sample usually ends part way through a line.
+	if len(lines) > 1 {
+		lines = lines[:len(lines)-1]
+	}
+	if len(lines) > 10 {
+		lines = lines[:10]
+	}
+
+	best, bestCount := "", 0
+	for _, delim := range candidateDelimiters {
+		count := -1
+		for _, line := range lines {
+			if strings.TrimSpace(line) == "" {
+				continue
+			}
+			n := countOutsideQuotes(line, delim)
+			if count == -1 {
+				count = n
+			} else if n != count {
+				count = 0
+				break
+			}
+		}
+		if count > bestCount {
+			best, bestCount = delim, count
+		}
+	}
+	return best
+}
+
+func countOutsideQuotes(line, delim string) int {
+	count, quoted := 0, false
+	for _, r := range line {
+		switch {
+		case r == '"':
+			quoted = !quoted
+		case !quoted && string(r) == delim:
+			count++
+		}
+	}
+	return count
+}
+
+// ParseEncoding normalises an encoding name given by an uploader. An empty name means
+// the encoding should be detected.
+func ParseEncoding(name string) (string, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "":
+		return "", nil
+	case "utf-8", "utf8":
+		return UTF8, nil
+	case "utf-16le", "utf16le", "utf-16", "utf16", "unicode":
+		return UTF16LE, nil
+	case "utf-16be", "utf16be":
+		return UTF16BE, nil
+	case "windows-1252", "cp1252", "latin1", "latin-1", "iso-8859-1":
+		return Windows1252, nil
+	}
+	return "", fmt.Errorf("%w encoding %q (use utf-8, utf-16le, utf-16be or windows-1252)", ErrUnsupported, name)
+}
+
+// ParseDelimiter normalises a delimiter given by an uploader, either the character
+// itself or one of "comma", "semicolon", "tab" and "pipe". An empty delimiter means it
+// should be sniffed.
+func ParseDelimiter(name string) (string, error) {
+	switch strings.ToLower(name) {
+	case "":
+		return "", nil
+	case "comma":
+		return ",", nil
+	case "semicolon":
+		return ";", nil
+	case "tab", `\t`:
+		return "\t", nil
+	case "pipe":
+		return "|", nil
+	}
+	r, size := utf8.DecodeRuneInString(name)
+	if size != len(name) || r == '"' || r == '\r' || r == '\n' || r == utf8.RuneError {
+		return "", fmt.Errorf("%w delimiter %q", ErrUnsupported, name)
+	}
+	return name, nil
+}
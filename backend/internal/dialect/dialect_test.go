@@ -0,0 +1,138 @@
+package dialect
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+	"unicode/utf16"
+)
+
+func encodeUTF16LE(s string, bom bool) []byte {
+	var buf bytes.Buffer
+	if bom {
+		buf.Write([]byte{0xFF, 0xFE})
+	}
+	for _, u := range utf16.Encode([]rune(s)) {
+		buf.WriteByte(byte(u))
+		buf.WriteByte(byte(u >> 8))
+	}
+	return buf.Bytes()
+}
+
+func readAll(t *testing.T, data []byte, d Dialect) [][]string {
+	t.Helper()
+	text, err := NewReader(bytes.NewReader(data), d)
+	if err != nil {
+		t.Fatalf("NewReader: %v", err)
+	}
+	r := csv.NewReader(text)
+	r.Comma = d.Comma()
+	records, err := r.ReadAll()
+	if err != nil {
+		t.Fatalf("reading records: %v", err)
+	}
+	return records
+}
+
+func TestDetect(t *testing.T) {
+	testCases := []struct {
+		name     string
+		data     []byte
+		expected Dialect
+		records  [][]string
+	}{
+		{
+			name:     "UTF-8 with BOM",
+			data:     []byte("\xEF\xBB\xBFName,City\nJosé,Zürich\n"),
+			expected: Dialect{Encoding: UTF8, BOM: true, Delimiter: ",", EncodingSource: SourceDetected, DelimiterSource: SourceDetected},
+			records:  [][]string{{"Name", "City"}, {"José", "Zürich"}},
+		},
+		{
+			name:     "UTF-16LE with BOM, tab delimited",
+			data:     encodeUTF16LE("Name\tCity\r\nJosé\tZürich 😀\r\n", true),
+			expected: Dialect{Encoding: UTF16LE, BOM: true, Delimiter: "\t", EncodingSource: SourceDetected, DelimiterSource: SourceDetected},
+			records:  [][]string{{"Name", "City"}, {"José", "Zürich 😀"}},
+		},
+		{
+			name:     "UTF-16LE without BOM",
+			data:     encodeUTF16LE("Name;City\nAna;Lyon\n", false),
+			expected: Dialect{Encoding: UTF16LE, Delimiter: ";", EncodingSource: SourceDetected, DelimiterSource: SourceDetected},
+			records:  [][]string{{"Name", "City"}, {"Ana", "Lyon"}},
+		},
+		{
+			name:     "Windows-1252 semicolon delimited with quoted commas",
+			data:     []byte("Name;Amount\n\"Caf\xe9, Inc.\";\x801,50\n"),
+			expected: Dialect{Encoding: Windows1252, Delimiter: ";", EncodingSource: SourceDetected, DelimiterSource: SourceDetected},
+			records:  [][]string{{"Name", "Amount"}, {"Café, Inc.", "€1,50"}},
+		},
+		{
+			name:     "Single column falls back to a comma",
+			data:     []byte("Name\nAna\n"),
+			expected: Dialect{Encoding: UTF8, Delimiter: ",", EncodingSource: SourceDetected, DelimiterSource: SourceDefault},
+			records:  [][]string{{"Name"}, {"Ana"}},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			d := Detect(tc.data, "", "")
+			if d != tc.expected {
+				t.Fatalf("got %+v, want %+v", d, tc.expected)
+			}
+			if got := readAll(t, tc.data, d); !reflect.DeepEqual(got, tc.records) {
+				t.Errorf("got records %q, want %q", got, tc.records)
+			}
+		})
+	}
+}
+
+func TestDetectOverrides(t *testing.T) {
+	data := []byte("\xEF\xBB\xBFa|b\n")
+
+	d := Detect(data, Windows1252, ";")
+	expected := Dialect{Encoding: Windows1252, Delimiter: ";", EncodingSource: SourceOverride, DelimiterSource: SourceOverride}
+	if d != expected {
+		t.Fatalf("got %+v, want %+v", d, expected)
+	}
+
+	d = Detect(data, UTF8, "")
+	if !d.BOM || d.Delimiter != "|" {
+		t.Errorf("expected the BOM to be kept with a matching override and the delimiter sniffed, got %+v", d)
+	}
+}
+
+func TestDetectIgnoresRuneCutBySample(t *testing.T) {
+	sample := []byte("Name,City\nJos\xc3")
+	if got := Detect(sample, "", "").Encoding; got != UTF8 {
+		t.Errorf("expected a truncated UTF-8 sequence at the end of the sample to be ignored, got %s", got)
+	}
+}
+
+func TestUTF16UnpairedSurrogate(t *testing.T) {
+	data := []byte{0x00, 0xD8, 'A', 0x00}
+	text, _ := NewReader(bytes.NewReader(data), Dialect{Encoding: UTF16LE})
+	got, _ := io.ReadAll(text)
+	if string(got) != "�A" {
+		t.Errorf("got %q", got)
+	}
+}
+
+func TestParseOverrides(t *testing.T) {
+	if enc, err := ParseEncoding("CP1252"); err != nil || enc != Windows1252 {
+		t.Errorf("ParseEncoding(CP1252) = %q, %v", enc, err)
+	}
+	if _, err := ParseEncoding("ebcdic"); !errors.Is(err, ErrUnsupported) {
+		t.Errorf("expected ErrUnsupported, got %v", err)
+	}
+	if delim, err := ParseDelimiter("tab"); err != nil || delim != "\t" {
+		t.Errorf("ParseDelimiter(tab) = %q, %v", delim, err)
+	}
+	for _, bad := range []string{`"`, "\n", ";;"} {
+		if _, err := ParseDelimiter(bad); !errors.Is(err, ErrUnsupported) {
+			t.Errorf("ParseDelimiter(%q): expected ErrUnsupported, got %v", bad, err)
+		}
+	}
+}
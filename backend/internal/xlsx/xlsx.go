@@ -0,0 +1,520 @@
+// Package xlsx reads worksheets from Office Open XML (.xlsx) workbooks as rows of
+// strings, streaming the sheet XML so that only one row is decoded at a time.
+//
+// Cell values are rendered from their stored value rather than their display format:
+// numbers are written in plain decimal form and cells with a date number format are
+// converted from Excel serial dates to "2006-01-02" (or RFC 3339 when a time is present).
+package xlsx
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrSheetNotFound is returned by Rows when the workbook has no sheet with the requested name.
+var ErrSheetNotFound = errors.New("xlsx: worksheet not found")
+
+var zipMagic = []byte("PK\x03\x04")
+
+// IsXLSX reports whether a file looks like an .xlsx workbook, judging by its leading
+// bytes or, failing that, its name.
+func IsXLSX(head []byte, filename string) bool {
+	if bytes.HasPrefix(head, zipMagic) {
+		return true
+	}
+	return strings.EqualFold(path.Ext(filename), ".xlsx")
+}
+
+type sheet struct {
+	name string
+	path string
+}
+
+// File is an opened workbook.
+type File struct {
+	zr            *zip.Reader
+	sheets        []sheet
+	sharedStrings []string
+	dateStyles    []bool // indexed by cell style (xf) index
+	date1904      bool
+}
+
+// Open reads the workbook structure, shared strings and styles. Worksheet data is
+// only read when Rows is called.
+func Open(r io.ReaderAt, size int64) (*File, error) {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, fmt.Errorf("xlsx: not a valid workbook: %w", err)
+	}
+
+	f := &File{zr: zr}
+	if err := f.readWorkbook(); err != nil {
+		return nil, err
+	}
+	if err := f.readSharedStrings(); err != nil {
+		return nil, err
+	}
+	if err := f.readStyles(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// SheetNames returns the worksheet names in workbook order.
+func (f *File) SheetNames() []string {
+	names := make([]string, len(f.sheets))
+	for i, s := range f.sheets {
+		names[i] = s.name
+	}
+	return names
+}
+
+// Rows opens the named worksheet for reading, or the first worksheet when name is empty.
+// Sheet names are matched case-insensitively.
+func (f *File) Rows(name string) (*RowReader, error) {
+	if len(f.sheets) == 0 {
+		return nil, fmt.Errorf("xlsx: workbook has no worksheets")
+	}
+
+	target := f.sheets[0]
+	if name != "" {
+		found := false
+		for _, s := range f.sheets {
+			if strings.EqualFold(strings.TrimSpace(s.name), strings.TrimSpace(name)) {
+				target, found = s, true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("%w: %q (available: %s)", ErrSheetNotFound, name, strings.Join(f.SheetNames(), ", "))
+		}
+	}
+
+	rc, err := f.open(target.path)
+	if err != nil {
+		return nil, err
+	}
+	return &RowReader{file: f, rc: rc, dec: xml.NewDecoder(rc), width: -1}, nil
+}
+
+func (f *File) open(name string) (io.ReadCloser, error) {
+	for _, zf := range f.zr.File {
+		if zf.Name == name {
+			return zf.Open()
+		}
+	}
+	return nil, fmt.Errorf("xlsx: missing part %s: %w", name, errPartNotFound)
+}
+
+var errPartNotFound = errors.New("part not found")
+
+func (f *File) decodePart(name string, v interface{}) error {
+	rc, err := f.open(name)
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	if err := xml.NewDecoder(rc).Decode(v); err != nil {
+		return fmt.Errorf("xlsx: failed to parse %s: %w", name, err)
+	}
+	return nil
+}
+
+func (f *File) readWorkbook() error {
+	var wb struct {
+		WorkbookPr struct {
+			Date1904 string `xml:"date1904,attr"`
+		} `xml:"workbookPr"`
+		Sheets []struct {
+			Name string `xml:"name,attr"`
+			RID  string `xml:"http://schemas.openxmlformats.org/officeDocument/2006/relationships id,attr"`
+		} `xml:"sheets>sheet"`
+	}
+	if err := f.decodePart("xl/workbook.xml", &wb); err != nil {
+		return err
+	}
+	f.date1904 = wb.WorkbookPr.Date1904 == "1" || wb.WorkbookPr.Date1904 == "true"
+
+	var rels struct {
+		Relationships []struct {
+			ID     string `xml:"Id,attr"`
+			Target string `xml:"Target,attr"`
+		} `xml:"Relationship"`
+	}
+	if err := f.decodePart("xl/_rels/workbook.xml.rels", &rels); err != nil {
+		return err
+	}
+	targets := make(map[string]string, len(rels.Relationships))
+	for _, rel := range rels.Relationships {
+		target := rel.Target
+		if strings.HasPrefix(target, "/") {
+			target = strings.TrimPrefix(target, "/")
+		} else {
+			target = path.Join("xl", target)
+		}
+		targets[rel.ID] = target
+	}
+
+	for _, s := range wb.Sheets {
+		target, ok := targets[s.RID]
+		if !ok {
+			return fmt.Errorf("xlsx: worksheet %q has no relationship target", s.Name)
+		}
+		f.sheets = append(f.sheets, sheet{name: s.Name, path: target})
+	}
+	return nil
+}
+
+func (f *File) readSharedStrings() error {
+	rc, err := f.open("xl/sharedStrings.xml")
+	if errors.Is(err, errPartNotFound) {
+		return nil // workbooks without text cells have no shared string table
+	}
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	dec := xml.NewDecoder(rc)
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("xlsx: failed to parse shared strings: %w", err)
+		}
+		if se, ok := tok.(xml.StartElement); ok && se.Name.Local == "si" {
+			text, err := readText(dec, "si")
+			if err != nil {
+				return fmt.Errorf("xlsx: failed to parse shared strings: %w", err)
+			}
+			f.sharedStrings = append(f.sharedStrings, text)
+		}
+	}
+}
+
+func (f *File) readStyles() error {
+	var styles struct {
+		NumFmts []struct {
+			ID   int    `xml:"numFmtId,attr"`
+			Code string `xml:"formatCode,attr"`
+		} `xml:"numFmts>numFmt"`
+		CellXfs []struct {
+			NumFmtID int `xml:"numFmtId,attr"`
+		} `xml:"cellXfs>xf"`
+	}
+	err := f.decodePart("xl/styles.xml", &styles)
+	if errors.Is(err, errPartNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	customDates := make(map[int]bool, len(styles.NumFmts))
+	for _, nf := range styles.NumFmts {
+		customDates[nf.ID] = isDateFormatCode(nf.Code)
+	}
+	f.dateStyles = make([]bool, len(styles.CellXfs))
+	for i, xf := range styles.CellXfs {
+		if isDate, ok := customDates[xf.NumFmtID]; ok {
+			f.dateStyles[i] = isDate
+		} else {
+			f.dateStyles[i] = isBuiltInDateFormat(xf.NumFmtID)
+		}
+	}
+	return nil
+}
+
+// readText collects the text of <t> elements up to the closing tag named end,
+// skipping phonetic (<rPh>) runs.
+func readText(dec *xml.Decoder, end string) (string, error) {
+	var sb strings.Builder
+	inText, phonetic := false, 0
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return "", err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "t":
+				inText = true
+			case "rPh":
+				phonetic++
+			}
+		case xml.EndElement:
+			switch t.Name.Local {
+			case "t":
+				inText = false
+			case "rPh":
+				phonetic--
+			case end:
+				return sb.String(), nil
+			}
+		case xml.CharData:
+			if inText && phonetic == 0 {
+				sb.Write(t)
+			}
+		}
+	}
+}
+
+// RowReader streams the rows of one worksheet. Its Read method has the same shape as
+// csv.Reader.Read: it returns io.EOF after the last row.
+type RowReader struct {
+	file  *File
+	rc    io.ReadCloser
+	dec   *xml.Decoder
+	width int // number of columns in the first row; later rows are padded to it
+}
+
+// Read returns the next non-empty row. Missing cells are returned as empty strings.
+func (r *RowReader) Read() ([]string, error) {
+	for {
+		tok, err := r.dec.Token()
+		if err == io.EOF {
+			return nil, io.EOF
+		}
+		if err != nil {
+			return nil, fmt.Errorf("xlsx: failed to parse worksheet: %w", err)
+		}
+		se, ok := tok.(xml.StartElement)
+		if !ok || se.Name.Local != "row" {
+			continue
+		}
+
+		row, err := r.readRow()
+		if err != nil {
+			return nil, err
+		}
+		if isBlank(row) {
+			continue
+		}
+		if r.width < 0 {
+			r.width = len(row)
+		}
+		for len(row) < r.width {
+			row = append(row, "")
+		}
+		return row, nil
+	}
+}
+
+// Close releases the underlying worksheet stream.
+func (r *RowReader) Close() error {
+	return r.rc.Close()
+}
+
+func (r *RowReader) readRow() ([]string, error) {
+	var row []string
+	next := 0
+	for {
+		tok, err := r.dec.Token()
+		if err != nil {
+			return nil, fmt.Errorf("xlsx: failed to parse worksheet row: %w", err)
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			if t.Name.Local != "c" {
+				continue
+			}
+			col := next
+			if ref := attr(t, "r"); ref != "" {
+				if c, ok := columnIndex(ref); ok {
+					col = c
+				}
+			}
+			value, err := r.readCell(t)
+			if err != nil {
+				return nil, err
+			}
+			for len(row) < col {
+				row = append(row, "")
+			}
+			if col < len(row) {
+				row[col] = value
+			} else {
+				row = append(row, value)
+			}
+			next = col + 1
+		case xml.EndElement:
+			if t.Name.Local == "row" {
+				return row, nil
+			}
+		}
+	}
+}
+
+func (r *RowReader) readCell(start xml.StartElement) (string, error) {
+	cellType := attr(start, "t")
+	style := -1
+	if s := attr(start, "s"); s != "" {
+		if v, err := strconv.Atoi(s); err == nil {
+			style = v
+		}
+	}
+
+	var raw string
+	if cellType == "inlineStr" {
+		text, err := readText(r.dec, "c")
+		if err != nil {
+			return "", fmt.Errorf("xlsx: failed to parse inline string: %w", err)
+		}
+		return text, nil
+	}
+
+	inValue := false
+	for done := false; !done; {
+		tok, err := r.dec.Token()
+		if err != nil {
+			return "", fmt.Errorf("xlsx: failed to parse cell: %w", err)
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			inValue = t.Name.Local == "v"
+		case xml.EndElement:
+			if t.Name.Local == "v" {
+				inValue = false
+			}
+			done = t.Name.Local == "c"
+		case xml.CharData:
+			if inValue {
+				raw += string(t)
+			}
+		}
+	}
+
+	switch cellType {
+	case "s":
+		idx, err := strconv.Atoi(strings.TrimSpace(raw))
+		if err != nil || idx < 0 || idx >= len(r.file.sharedStrings) {
+			return "", fmt.Errorf("xlsx: invalid shared string index %q", raw)
+		}
+		return r.file.sharedStrings[idx], nil
+	case "b", "str", "e", "d":
+		return raw, nil
+	default:
+		return r.file.formatNumber(raw, style), nil
+	}
+}
+
+// formatNumber renders a numeric cell. Cells with a date format become ISO dates;
+// other numbers keep their full stored precision without grouping or currency symbols.
+func (f *File) formatNumber(raw string, style int) string {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return ""
+	}
+	v, err := strconv.ParseFloat(raw, 64)
+	if err != nil {
+		return raw
+	}
+	// Excel cannot display negative serial dates, so those stay numbers.
+	if style >= 0 && style < len(f.dateStyles) && f.dateStyles[style] && v >= 0 {
+		t := SerialToTime(v, f.date1904)
+		if t.Hour() == 0 && t.Minute() == 0 && t.Second() == 0 {
+			return t.Format("2006-01-02")
+		}
+		return t.Format(time.RFC3339)
+	}
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+// SerialToTime converts an Excel serial date to a UTC time. In the default 1900 date
+// system Excel treats 1900 as a leap year, so serials before 1 March 1900 are shifted
+// by a day to compensate.
+func SerialToTime(serial float64, date1904 bool) time.Time {
+	var epoch time.Time
+	if date1904 {
+		epoch = time.Date(1904, 1, 1, 0, 0, 0, 0, time.UTC)
+	} else {
+		epoch = time.Date(1899, 12, 30, 0, 0, 0, 0, time.UTC)
+		if serial < 61 {
+			serial++
+		}
+	}
+	days := math.Floor(serial)
+	secs := math.Round((serial - days) * 86400)
+	return epoch.AddDate(0, 0, int(days)).Add(time.Duration(secs) * time.Second)
+}
+
+// isBuiltInDateFormat reports whether a built-in number format id is a date or time format.
+func isBuiltInDateFormat(id int) bool {
+	return (id >= 14 && id <= 22) || (id >= 27 && id <= 36) || (id >= 45 && id <= 47) || (id >= 50 && id <= 58)
+}
+
+// isDateFormatCode reports whether a custom number format renders a date or time,
+// ignoring quoted literals, escaped characters and bracketed sections such as colours.
+func isDateFormatCode(code string) bool {
+	inQuote, inBracket, escaped := false, false, false
+	for _, r := range strings.ToLower(code) {
+		switch {
+		case escaped:
+			escaped = false
+		case inQuote:
+			inQuote = r != '"'
+		case inBracket:
+			inBracket = r != ']'
+		case r == '\\':
+			escaped = true
+		case r == '"':
+			inQuote = true
+		case r == '[':
+			inBracket = true
+		case strings.ContainsRune("ymdhs", r):
+			return true
+		}
+	}
+	return false
+}
+
+// columnIndex converts a cell reference such as "C12" to a zero-based column index.
+func columnIndex(ref string) (int, bool) {
+	col := 0
+	n := 0
+	for _, r := range ref {
+		if r >= 'A' && r <= 'Z' {
+			col = col*26 + int(r-'A'+1)
+			n++
+		} else if r >= 'a' && r <= 'z' {
+			col = col*26 + int(r-'a'+1)
+			n++
+		} else {
+			break
+		}
+	}
+	if n == 0 {
+		return 0, false
+	}
+	return col - 1, true
+}
+
+func attr(se xml.StartElement, name string) string {
+	for _, a := range se.Attr {
+		if a.Name.Local == name {
+			return a.Value
+		}
+	}
+	return ""
+}
+
+func isBlank(row []string) bool {
+	for _, v := range row {
+		if strings.TrimSpace(v) != "" {
+			return false
+		}
+	}
+	return true
+}
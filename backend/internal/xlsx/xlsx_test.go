@@ -0,0 +1,139 @@
+package xlsx
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testWorkbook = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">
+<workbookPr/>
+<sheets><sheet name="Summary" sheetId="1" r:id="rId1"/><sheet name="BC1048 Detail" sheetId="2" r:id="rId2"/></sheets>
+</workbook>`
+	testRels = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/>
+<Relationship Id="rId2" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="/xl/worksheets/sheet2.xml"/>
+</Relationships>`
+	testSharedStrings = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<sst xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">
+<si><t>BD Doc Num</t></si><si><t>Doc Date</t></si><si><t>Chargeback Amount</t></si>
+<si><r><t>BD</t></r><r><t>123</t></r><rPh><t>ignored</t></rPh></si>
+</sst>`
+	testStyles = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<styleSheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">
+<numFmts><numFmt numFmtId="164" formatCode="&quot;$&quot;#,##0.00"/><numFmt numFmtId="165" formatCode="[Red]mm/dd/yyyy"/></numFmts>
+<cellXfs><xf numFmtId="0"/><xf numFmtId="14"/><xf numFmtId="164"/><xf numFmtId="165"/></cellXfs>
+</styleSheet>`
+	testSheet1 = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>
+<row r="1"><c r="A1" t="inlineStr"><is><t>Summary</t></is></c></row>
+</sheetData></worksheet>`
+	testSheet2 = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>
+<row r="1"><c r="A1" t="s"><v>0</v></c><c r="B1" t="s"><v>1</v></c><c r="C1" t="s"><v>2</v></c><c r="D1" t="inlineStr"><is><t>Flag</t></is></c></row>
+<row r="2"><c r="A2" t="s"><v>3</v></c><c r="B2" s="1"><v>45474</v></c><c r="C2" s="2"><v>1234.5</v></c><c r="D2" t="b"><v>1</v></c></row>
+<row r="3"></row>
+<row r="5"><c r="A5" t="str"><f>CONCAT("BD","9")</f><v>BD9</v></c><c r="C5" s="2"><v>-42.125</v></c></row>
+<row r="6"><c r="B6" s="3"><v>45474.5</v></c></row>
+</sheetData></worksheet>`
+)
+
+func buildWorkbook(t *testing.T, parts map[string]string) *bytes.Reader {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, content := range parts {
+		w, err := zw.Create(name)
+		require.NoError(t, err)
+		_, err = w.Write([]byte(content))
+		require.NoError(t, err)
+	}
+	require.NoError(t, zw.Close())
+	return bytes.NewReader(buf.Bytes())
+}
+
+func testParts() map[string]string {
+	return map[string]string{
+		"xl/workbook.xml":            testWorkbook,
+		"xl/_rels/workbook.xml.rels": testRels,
+		"xl/sharedStrings.xml":       testSharedStrings,
+		"xl/styles.xml":              testStyles,
+		"xl/worksheets/sheet1.xml":   testSheet1,
+		"xl/worksheets/sheet2.xml":   testSheet2,
+	}
+}
+
+func readAll(t *testing.T, r *RowReader) [][]string {
+	t.Helper()
+	var rows [][]string
+	for {
+		row, err := r.Read()
+		if errors.Is(err, io.EOF) {
+			return rows
+		}
+		require.NoError(t, err)
+		rows = append(rows, row)
+	}
+}
+
+func TestRowsFromNamedSheet(t *testing.T) {
+	data := buildWorkbook(t, testParts())
+	f, err := Open(data, int64(data.Len()))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Summary", "BC1048 Detail"}, f.SheetNames())
+
+	rows, err := f.Rows("bc1048 detail")
+	require.NoError(t, err)
+	defer rows.Close()
+
+	assert.Equal(t, [][]string{
+		{"BD Doc Num", "Doc Date", "Chargeback Amount", "Flag"},
+		{"BD123", "2024-07-01", "1234.5", "1"},
+		{"BD9", "", "-42.125", ""},
+		{"", "2024-07-01T12:00:00Z", "", ""},
+	}, readAll(t, rows))
+}
+
+func TestRowsDefaultsToFirstSheet(t *testing.T) {
+	data := buildWorkbook(t, testParts())
+	f, err := Open(data, int64(data.Len()))
+	require.NoError(t, err)
+
+	rows, err := f.Rows("")
+	require.NoError(t, err)
+	defer rows.Close()
+	assert.Equal(t, [][]string{{"Summary"}}, readAll(t, rows))
+
+	_, err = f.Rows("Missing")
+	assert.ErrorIs(t, err, ErrSheetNotFound)
+}
+
+func TestSerialToTime(t *testing.T) {
+	assert.Equal(t, time.Date(1900, 1, 1, 0, 0, 0, 0, time.UTC), SerialToTime(1, false))
+	assert.Equal(t, time.Date(1900, 3, 1, 0, 0, 0, 0, time.UTC), SerialToTime(61, false))
+	assert.Equal(t, time.Date(2024, 7, 1, 0, 0, 0, 0, time.UTC), SerialToTime(45474, false))
+	assert.Equal(t, time.Date(2028, 7, 2, 0, 0, 0, 0, time.UTC), SerialToTime(45474, true))
+}
+
+func TestIsDateFormatCode(t *testing.T) {
+	assert.True(t, isDateFormatCode("m/d/yyyy"))
+	assert.True(t, isDateFormatCode("[$-409]d-mmm-yy;@"))
+	assert.False(t, isDateFormatCode(`"$"#,##0.00`))
+	assert.False(t, isDateFormatCode(`0.00" days"`))
+	assert.False(t, isDateFormatCode("[Red]0.00"))
+}
+
+func TestIsXLSX(t *testing.T) {
+	assert.True(t, IsXLSX([]byte("PK\x03\x04rest"), "report.bin"))
+	assert.True(t, IsXLSX([]byte("Fund,Business"), "Report.XLSX"))
+	assert.False(t, IsXLSX([]byte("Fund,Business"), "report.csv"))
+}
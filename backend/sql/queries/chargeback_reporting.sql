@@ -1,5 +1,6 @@
 -- name: GetChargebackStatusSummary :many
 -- Gets the count, total value, and percentage of total value for each chargeback status for active items.
+-- Limited to the caller's business lines unless all_business_lines is set.
 SELECT
     current_status,
     COUNT(*) AS status_count,
@@ -9,6 +10,7 @@ FROM
     historical_chargebacks_with_vendor_info
 WHERE
     current_status != 'Reconciled - Off Report' -- Exclude reconciled items from this summary
+    AND (@all_business_lines::boolean OR business_line = ANY(@business_lines::chargeback_business_line[]))
 GROUP BY
     current_status;
 
@@ -20,17 +22,20 @@ SELECT
 FROM
     chargeback
 WHERE
-    created_at BETWEEN $1 AND $2;
+    created_at BETWEEN @window_start AND @window_end
+    AND (@all_business_lines::boolean OR business_line = ANY(@business_lines::chargeback_business_line[]));
 
 
 -- name: GetPFSCountsForWindow :one
 -- Gets the count of chargebacks passed to PFS and completed by PFS within a specific date window.
 -- This version uses conditional aggregation for better performance and to avoid ambiguity.
 SELECT
-    COUNT(*) FILTER (WHERE passed_to_pfs_date BETWEEN $1 AND $2) AS passed_to_pfs_count,
-    COUNT(*) FILTER (WHERE pfs_completion_date BETWEEN $1 AND $2) AS completed_by_pfs_count
+    COUNT(*) FILTER (WHERE passed_to_pfs_date BETWEEN @window_start AND @window_end) AS passed_to_pfs_count,
+    COUNT(*) FILTER (WHERE pfs_completion_date BETWEEN @window_start AND @window_end) AS completed_by_pfs_count
 FROM
-    historical_chargebacks_with_vendor_info;
+    historical_chargebacks_with_vendor_info
+WHERE
+    (@all_business_lines::boolean OR business_line = ANY(@business_lines::chargeback_business_line[]));
 
 -- name: GetAverageDaysToPFSForWindow :one
 SELECT
@@ -38,7 +43,8 @@ SELECT
 FROM
     historical_chargebacks_with_vendor_info
 WHERE
-    passed_to_pfs_date BETWEEN $1 AND $2;
+    passed_to_pfs_date BETWEEN @window_start AND @window_end
+    AND (@all_business_lines::boolean OR business_line = ANY(@business_lines::chargeback_business_line[]));
 
 -- name: GetAverageDaysForPFSCompletionForWindow :one
 SELECT
@@ -46,4 +52,5 @@ SELECT
 FROM
     historical_chargebacks_with_vendor_info
 WHERE
-    pfs_completion_date BETWEEN $1 AND $2;
+    pfs_completion_date BETWEEN @window_start AND @window_end
+    AND (@all_business_lines::boolean OR business_line = ANY(@business_lines::chargeback_business_line[]));
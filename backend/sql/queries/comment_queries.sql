@@ -0,0 +1,146 @@
+-- name: ListChargebackComments :many
+-- Fetches all comments on a chargeback with their author and mentioned users, oldest first.
+SELECT
+    c.id,
+    c.comment,
+    c.comment_date,
+    c.updated_at,
+    c.user_id,
+    u.first_name AS author_first_name,
+    u.last_name AS author_last_name,
+    u.email AS author_email,
+    COALESCE((SELECT array_agg(cm.user_id ORDER BY cm.user_id) FROM comment_mentions cm WHERE cm.comment_id = c.id), '{}')::BIGINT[] AS mentioned_user_ids
+FROM
+    "comments" c
+JOIN
+    "chargeback_comments_merge" ccm ON c.id = ccm.comment_id
+JOIN
+    "cdms_user" u ON c.user_id = u.id
+WHERE
+    ccm.chargeback_id = $1
+ORDER BY
+    c.comment_date ASC;
+
+-- name: ListDelinquencyComments :many
+-- Fetches all comments on a delinquency with their author and mentioned users, oldest first.
+SELECT
+    c.id,
+    c.comment,
+    c.comment_date,
+    c.updated_at,
+    c.user_id,
+    u.first_name AS author_first_name,
+    u.last_name AS author_last_name,
+    u.email AS author_email,
+    COALESCE((SELECT array_agg(cm.user_id ORDER BY cm.user_id) FROM comment_mentions cm WHERE cm.comment_id = c.id), '{}')::BIGINT[] AS mentioned_user_ids
+FROM
+    "comments" c
+JOIN
+    "non_ipac_comments_merge" nicm ON c.id = nicm.comment_id
+JOIN
+    "cdms_user" u ON c.user_id = u.id
+WHERE
+    nicm.nonipac_id = $1
+ORDER BY
+    c.comment_date ASC;
+
+-- name: GetChargebackComment :one
+-- Fetches a single comment only if it is attached to the given chargeback.
+SELECT c.id, c.comment, c.comment_date, c.user_id, c.updated_at
+FROM "comments" c
+JOIN "chargeback_comments_merge" ccm ON c.id = ccm.comment_id
+WHERE ccm.chargeback_id = $1 AND c.id = $2;
+
+-- name: GetDelinquencyComment :one
+-- Fetches a single comment only if it is attached to the given delinquency.
+SELECT c.id, c.comment, c.comment_date, c.user_id, c.updated_at
+FROM "comments" c
+JOIN "non_ipac_comments_merge" nicm ON c.id = nicm.comment_id
+WHERE nicm.nonipac_id = $1 AND c.id = $2;
+
+-- name: CreateChargebackComment :one
+-- Inserts a comment, links it to a chargeback and records the users it mentions in one statement.
+-- The author is stamped into app.user_id so the audit trigger can attribute the insert.
+WITH actor AS (
+    SELECT set_config('app.user_id', @user_id::BIGINT::TEXT, true)
+), new_comment AS (
+    INSERT INTO "comments" (comment, user_id)
+    SELECT @comment, @user_id FROM actor
+    RETURNING id, comment, comment_date, user_id, updated_at
+), link AS (
+    INSERT INTO "chargeback_comments_merge" (chargeback_id, comment_id)
+    SELECT @chargeback_id, id FROM new_comment
+), mentions AS (
+    INSERT INTO "comment_mentions" (comment_id, user_id)
+    SELECT id, unnest(@mentioned_user_ids::BIGINT[]) FROM new_comment
+)
+SELECT id, comment, comment_date, user_id, updated_at FROM new_comment;
+
+-- name: CreateDelinquencyComment :one
+-- Inserts a comment, links it to a delinquency and records the users it mentions in one statement.
+-- The author is stamped into app.user_id so the audit trigger can attribute the insert.
+WITH actor AS (
+    SELECT set_config('app.user_id', @user_id::BIGINT::TEXT, true)
+), new_comment AS (
+    INSERT INTO "comments" (comment, user_id)
+    SELECT @comment, @user_id FROM actor
+    RETURNING id, comment, comment_date, user_id, updated_at
+), link AS (
+    INSERT INTO "non_ipac_comments_merge" (nonipac_id, comment_id)
+    SELECT @nonipac_id, id FROM new_comment
+), mentions AS (
+    INSERT INTO "comment_mentions" (comment_id, user_id)
+    SELECT id, unnest(@mentioned_user_ids::BIGINT[]) FROM new_comment
+)
+SELECT id, comment, comment_date, user_id, updated_at FROM new_comment;
+
+-- name: UpdateCommentByAuthor :one
+-- Updates a comment's text and the users it mentions only when it belongs to the given author.
+-- Mentions no longer present are removed and new ones are added; unchanged mentions are left in place.
+-- The author is stamped into app.user_id so the audit trigger can attribute the edit.
+WITH actor AS (
+    SELECT set_config('app.user_id', @user_id::BIGINT::TEXT, true)
+), updated AS (
+    UPDATE "comments"
+    SET
+        comment = @comment
+    FROM
+        actor
+    WHERE
+        comments.id = @id AND comments.user_id = @user_id
+    RETURNING comments.id, comments.comment, comments.comment_date, comments.user_id, comments.updated_at
+), removed_mentions AS (
+    DELETE FROM "comment_mentions"
+    WHERE comment_mentions.comment_id IN (SELECT id FROM updated)
+      AND NOT (comment_mentions.user_id = ANY(@mentioned_user_ids::BIGINT[]))
+), added_mentions AS (
+    INSERT INTO "comment_mentions" (comment_id, user_id)
+    SELECT id, unnest(@mentioned_user_ids::BIGINT[]) FROM updated
+    ON CONFLICT (comment_id, user_id) DO NOTHING
+)
+SELECT id, comment, comment_date, user_id, updated_at FROM updated;
+
+-- name: DeleteCommentByAuthor :execrows
+-- Deletes a comment, its mentions and its chargeback/delinquency links, only when it belongs to the given author.
+WITH actor AS (
+    SELECT set_config('app.user_id', @user_id::BIGINT::TEXT, true)
+), target AS (
+    SELECT comments.id FROM "comments", actor WHERE comments.id = @id AND comments.user_id = @user_id
+), mentions AS (
+    DELETE FROM "comment_mentions" WHERE comment_id IN (SELECT id FROM target)
+), chargeback_links AS (
+    DELETE FROM "chargeback_comments_merge" WHERE comment_id IN (SELECT id FROM target)
+), delinquency_links AS (
+    DELETE FROM "non_ipac_comments_merge" WHERE comment_id IN (SELECT id FROM target)
+)
+DELETE FROM "comments" WHERE comments.id IN (SELECT id FROM target);
+
+-- name: GetUsersForMentions :many
+-- Resolves @mention handles to active users, either by email or by first.last name (case-insensitive).
+SELECT id, email, first_name, last_name
+FROM "cdms_user"
+WHERE is_active = TRUE
+  AND (
+    lower(email) = ANY(@emails::text[])
+    OR lower(first_name || '.' || last_name) = ANY(@names::text[])
+  );
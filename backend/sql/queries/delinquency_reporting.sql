@@ -1,5 +1,6 @@
 -- name: GetNonipacStatusSummary :many
 -- Gets the count, total value, and percentage of total value for each nonipac status for active items.
+-- Limited to the caller's business lines unless all_business_lines is set.
 SELECT
     current_status,
     COUNT(*) AS status_count,
@@ -9,6 +10,7 @@ FROM
     historical_nonipac_with_vendor_info
 WHERE
     is_active = TRUE AND current_status != 'Reconciled - Off Report' -- Exclude inactive and reconciled items
+    AND (@all_business_lines::boolean OR business_line = ANY(@business_lines::chargeback_business_line[]))
 GROUP BY
     current_status
 ORDER BY
@@ -16,6 +18,7 @@ ORDER BY
 
 -- name: GetNonipacAgingScheduleByBusinessLine :many
 -- Provides an aging schedule for active nonipac items, broken down by business line and age categories.
+-- Limited to the caller's business lines unless all_business_lines is set.
 SELECT
     business_line,
     COUNT(*) FILTER (WHERE days_old <= 180) AS "less_than_180_days_count",
@@ -32,6 +35,7 @@ FROM
     historical_nonipac_with_vendor_info
 WHERE
     is_active = TRUE AND current_status != 'Reconciled - Off Report' -- Filter for active, non-reconciled items
+    AND (@all_business_lines::boolean OR business_line = ANY(@business_lines::chargeback_business_line[]))
 GROUP BY
     business_line
 ORDER BY
@@ -0,0 +1,23 @@
+-- name: AcquireAdvisoryXactLock :exec
+-- Wait for an exclusive advisory lock, held until the transaction ends
+SELECT pg_advisory_xact_lock(@lock_key::BIGINT);
+
+-- name: AcquireAdvisoryXactLockShared :exec
+-- Wait for a shared advisory lock, held until the transaction ends
+SELECT pg_advisory_xact_lock_shared(@lock_key::BIGINT);
+
+-- name: TryAdvisoryXactLock :one
+-- Take an exclusive advisory lock until the transaction ends if it is free right now
+SELECT pg_try_advisory_xact_lock(@lock_key::BIGINT) AS acquired;
+
+-- name: TryAdvisoryXactLockShared :one
+-- Take a shared advisory lock until the transaction ends if it is free right now
+SELECT pg_try_advisory_xact_lock_shared(@lock_key::BIGINT) AS acquired;
+
+-- name: SetUploadWaitingForLock :exec
+-- Show whether an upload being processed is waiting for another merge to finish.
+-- Runs outside the processing transaction so that the status is visible while it waits
+UPDATE uploads
+SET status = CASE WHEN @waiting::BOOLEAN THEN 'WAITING_FOR_LOCK' ELSE 'PROCESSING' END
+WHERE id = @id
+  AND status IN ('PROCESSING', 'WAITING_FOR_LOCK');
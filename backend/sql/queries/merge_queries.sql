@@ -93,16 +93,106 @@ ON CONFLICT (document_number) DO UPDATE SET
     updated_at = NOW();
 
 -- name: UpsertAgencyBureaus :execrows
-INSERT INTO agency_bureau (agency, bureau_code, vendor_code, updated_at)
-SELECT agency, bureau_code, vendor_code, NOW()
+-- Insert new vendors from the staging table and update the ones whose details changed.
+-- Vendors the report repeats unchanged are left alone so that their audit history only
+-- records real changes. A placeholder vendor the report fills in no longer needs review
+INSERT INTO agency_bureau (
+    agency, bureau_code, vendor_code, agency_location_code, vendor_address_code, name,
+    address_line_1, address_line_2, address_line_3, city, state, zip, status, vendor_type,
+    reporting_attribute, security_org, transmit_to_vcss_flag, updated_at
+)
+SELECT
+    agency, bureau_code, vendor_code, agency_location_code, vendor_address_code, name,
+    address_line_1, address_line_2, address_line_3, city, state, zip, status, vendor_type,
+    reporting_attribute, security_org, transmit_to_vcss_flag, NOW()
 FROM temp_agency_bureau_staging
 ON CONFLICT (vendor_code) DO UPDATE SET
     agency = EXCLUDED.agency,
     bureau_code = EXCLUDED.bureau_code,
-    updated_at = NOW();
+    agency_location_code = EXCLUDED.agency_location_code,
+    vendor_address_code = EXCLUDED.vendor_address_code,
+    name = EXCLUDED.name,
+    address_line_1 = EXCLUDED.address_line_1,
+    address_line_2 = EXCLUDED.address_line_2,
+    address_line_3 = EXCLUDED.address_line_3,
+    city = EXCLUDED.city,
+    state = EXCLUDED.state,
+    zip = EXCLUDED.zip,
+    status = EXCLUDED.status,
+    vendor_type = EXCLUDED.vendor_type,
+    reporting_attribute = EXCLUDED.reporting_attribute,
+    security_org = EXCLUDED.security_org,
+    transmit_to_vcss_flag = EXCLUDED.transmit_to_vcss_flag,
+    needs_review = FALSE,
+    updated_at = NOW()
+WHERE (
+    agency_bureau.agency, agency_bureau.bureau_code, agency_bureau.agency_location_code,
+    agency_bureau.vendor_address_code, agency_bureau.name, agency_bureau.address_line_1,
+    agency_bureau.address_line_2, agency_bureau.address_line_3, agency_bureau.city,
+    agency_bureau.state, agency_bureau.zip, agency_bureau.status, agency_bureau.vendor_type,
+    agency_bureau.reporting_attribute, agency_bureau.security_org,
+    agency_bureau.transmit_to_vcss_flag, agency_bureau.needs_review
+) IS DISTINCT FROM (
+    EXCLUDED.agency, EXCLUDED.bureau_code, EXCLUDED.agency_location_code,
+    EXCLUDED.vendor_address_code, EXCLUDED.name, EXCLUDED.address_line_1,
+    EXCLUDED.address_line_2, EXCLUDED.address_line_3, EXCLUDED.city,
+    EXCLUDED.state, EXCLUDED.zip, EXCLUDED.status, EXCLUDED.vendor_type,
+    EXCLUDED.reporting_attribute, EXCLUDED.security_org,
+    EXCLUDED.transmit_to_vcss_flag, FALSE
+);
 
 -- name: GetChargebackSourcesByBDDocNums :many
 -- For a given list of bd_doc_nums, fetch the full business key and reporting source
 -- to check for cross-report conflicts in Go before an UPSERT.
 SELECT bd_doc_num, al_num, reporting_source FROM chargeback
 WHERE bd_doc_num = ANY($1::text[]);
+
+-- name: SetStatusHistoryNote :exec
+-- Set the note the status history trigger records for status changes made by the
+-- rest of the transaction; an empty note restores the trigger's default
+SELECT set_config('app.status_note', @note::TEXT, true);
+
+-- name: ReconcileOffReportChargebacks :execrows
+-- Move active chargebacks from a report source that are missing from the staged report
+-- to 'Reconciled - Off Report'. Runs before DeactivateChargebacksBySource
+UPDATE chargeback c
+SET current_status = 'Reconciled - Off Report'
+WHERE c.reporting_source = $1
+  AND c.is_active
+  AND c.current_status <> 'Reconciled - Off Report'
+  AND NOT EXISTS (
+    SELECT 1 FROM temp_chargeback_staging s WHERE s.bd_doc_num = c.bd_doc_num AND s.al_num = c.al_num
+  );
+
+-- name: ReopenReconciledChargebacks :execrows
+-- Reopen chargebacks reconciled off report that are back in the staged report
+UPDATE chargeback c
+SET current_status = 'Open'
+FROM temp_chargeback_staging s
+WHERE s.bd_doc_num = c.bd_doc_num
+  AND s.al_num = c.al_num
+  AND c.current_status = 'Reconciled - Off Report';
+
+-- name: ReconcileOffReportNonIpacs :execrows
+-- Move active delinquencies from a report source that are missing from the staged report
+-- to 'Reconciled - Off Report' and date the reconciliation. Runs before DeactivateNonIpacsBySource
+UPDATE "nonipac" n
+SET
+    current_status = 'Reconciled - Off Report',
+    reconciled_date = CURRENT_DATE
+WHERE n.reporting_source = $1
+  AND n.is_active
+  AND n.current_status <> 'Reconciled - Off Report'
+  AND NOT EXISTS (
+    SELECT 1 FROM temp_nonipac_staging s WHERE s.document_number = n.document_number
+  );
+
+-- name: ReopenReconciledNonIpacs :execrows
+-- Reopen delinquencies reconciled off report that are back in the staged report
+UPDATE "nonipac" n
+SET
+    current_status = 'Open',
+    reconciled_date = NULL
+FROM temp_nonipac_staging s
+WHERE s.document_number = n.document_number
+  AND n.current_status = 'Reconciled - Off Report';
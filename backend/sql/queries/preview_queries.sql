@@ -0,0 +1,153 @@
+-- These queries describe what merging a staging table into its live table would do.
+-- They run inside the processing transaction after staging and before the merge.
+
+-- name: PreviewChargebackMerge :one
+-- Count staged chargebacks that would be inserted, active chargebacks from the same
+-- source that would be deactivated because they are missing from the report, and all
+-- currently active chargebacks from that source
+SELECT
+    (SELECT COUNT(*) FROM temp_chargeback_staging s
+     WHERE NOT EXISTS (
+        SELECT 1 FROM chargeback c WHERE c.bd_doc_num = s.bd_doc_num AND c.al_num = s.al_num
+     ))::BIGINT AS new_rows,
+    (SELECT COUNT(*) FROM chargeback c
+     WHERE c.reporting_source = @reporting_source::chargeback_reporting_source
+       AND c.is_active
+       AND NOT EXISTS (
+        SELECT 1 FROM temp_chargeback_staging s WHERE s.bd_doc_num = c.bd_doc_num AND s.al_num = c.al_num
+     ))::BIGINT AS deactivated_rows,
+    (SELECT COUNT(*) FROM chargeback c
+     WHERE c.reporting_source = @reporting_source::chargeback_reporting_source
+       AND c.is_active)::BIGINT AS active_rows;
+
+-- name: PreviewChargebackFieldChanges :many
+-- For existing chargebacks in the report, count how many would change in each field
+SELECT d.field::TEXT AS field, COUNT(*)::BIGINT AS changed_rows
+FROM temp_chargeback_staging s
+JOIN chargeback c ON c.bd_doc_num = s.bd_doc_num AND c.al_num = s.al_num
+CROSS JOIN LATERAL (
+    SELECT n.key AS field
+    FROM jsonb_each(to_jsonb(s)) n
+    JOIN jsonb_each(to_jsonb(c)) o ON o.key = n.key
+    WHERE n.key = ANY(@compare_columns::text[])
+      AND n.value IS DISTINCT FROM o.value
+) d
+GROUP BY d.field
+ORDER BY d.field;
+
+-- name: PreviewChargebackUpdates :many
+-- Sample of existing chargebacks the merge would change, with old and new values per field.
+-- total_updated counts every changed chargeback, not just the sample
+SELECT
+    (s.bd_doc_num || '-' || s.al_num)::TEXT AS business_key,
+    d.changes::JSONB AS changes,
+    COUNT(*) OVER ()::BIGINT AS total_updated
+FROM temp_chargeback_staging s
+JOIN chargeback c ON c.bd_doc_num = s.bd_doc_num AND c.al_num = s.al_num
+CROSS JOIN LATERAL (
+    SELECT jsonb_object_agg(n.key, jsonb_build_object('old', o.value, 'new', n.value)) AS changes
+    FROM jsonb_each(to_jsonb(s)) n
+    JOIN jsonb_each(to_jsonb(c)) o ON o.key = n.key
+    WHERE n.key = ANY(@compare_columns::text[])
+      AND n.value IS DISTINCT FROM o.value
+) d
+WHERE d.changes IS NOT NULL
+ORDER BY business_key
+LIMIT @sample_limit;
+
+-- name: PreviewNonIpacMerge :one
+-- Count staged delinquencies that would be inserted, active delinquencies from the same
+-- source that would be deactivated because they are missing from the report, and all
+-- currently active delinquencies from that source
+SELECT
+    (SELECT COUNT(*) FROM temp_nonipac_staging s
+     WHERE NOT EXISTS (
+        SELECT 1 FROM "nonipac" n WHERE n.document_number = s.document_number
+     ))::BIGINT AS new_rows,
+    (SELECT COUNT(*) FROM "nonipac" n
+     WHERE n.reporting_source = @reporting_source::nonipac_reporting_source
+       AND n.is_active
+       AND NOT EXISTS (
+        SELECT 1 FROM temp_nonipac_staging s WHERE s.document_number = n.document_number
+     ))::BIGINT AS deactivated_rows,
+    (SELECT COUNT(*) FROM "nonipac" n
+     WHERE n.reporting_source = @reporting_source::nonipac_reporting_source
+       AND n.is_active)::BIGINT AS active_rows;
+
+-- name: PreviewNonIpacFieldChanges :many
+-- For existing delinquencies in the report, count how many would change in each field
+SELECT d.field::TEXT AS field, COUNT(*)::BIGINT AS changed_rows
+FROM temp_nonipac_staging s
+JOIN "nonipac" c ON c.document_number = s.document_number
+CROSS JOIN LATERAL (
+    SELECT n.key AS field
+    FROM jsonb_each(to_jsonb(s)) n
+    JOIN jsonb_each(to_jsonb(c)) o ON o.key = n.key
+    WHERE n.key = ANY(@compare_columns::text[])
+      AND n.value IS DISTINCT FROM o.value
+) d
+GROUP BY d.field
+ORDER BY d.field;
+
+-- name: PreviewNonIpacUpdates :many
+-- Sample of existing delinquencies the merge would change, with old and new values per field.
+-- total_updated counts every changed delinquency, not just the sample
+SELECT
+    s.document_number::TEXT AS business_key,
+    d.changes::JSONB AS changes,
+    COUNT(*) OVER ()::BIGINT AS total_updated
+FROM temp_nonipac_staging s
+JOIN "nonipac" c ON c.document_number = s.document_number
+CROSS JOIN LATERAL (
+    SELECT jsonb_object_agg(n.key, jsonb_build_object('old', o.value, 'new', n.value)) AS changes
+    FROM jsonb_each(to_jsonb(s)) n
+    JOIN jsonb_each(to_jsonb(c)) o ON o.key = n.key
+    WHERE n.key = ANY(@compare_columns::text[])
+      AND n.value IS DISTINCT FROM o.value
+) d
+WHERE d.changes IS NOT NULL
+ORDER BY business_key
+LIMIT @sample_limit;
+
+-- name: PreviewAgencyBureauMerge :one
+-- Count staged vendor codes that would be inserted
+SELECT COUNT(*)::BIGINT AS new_rows
+FROM temp_agency_bureau_staging s
+WHERE NOT EXISTS (
+    SELECT 1 FROM agency_bureau a WHERE a.vendor_code = s.vendor_code
+);
+
+-- name: PreviewAgencyBureauFieldChanges :many
+-- For existing vendor codes in the report, count how many would change in each field
+SELECT d.field::TEXT AS field, COUNT(*)::BIGINT AS changed_rows
+FROM temp_agency_bureau_staging s
+JOIN agency_bureau c ON c.vendor_code = s.vendor_code
+CROSS JOIN LATERAL (
+    SELECT n.key AS field
+    FROM jsonb_each(to_jsonb(s)) n
+    JOIN jsonb_each(to_jsonb(c)) o ON o.key = n.key
+    WHERE n.key = ANY(@compare_columns::text[])
+      AND n.value IS DISTINCT FROM o.value
+) d
+GROUP BY d.field
+ORDER BY d.field;
+
+-- name: PreviewAgencyBureauUpdates :many
+-- Sample of existing vendor codes the merge would change, with old and new values per field.
+-- total_updated counts every changed vendor code, not just the sample
+SELECT
+    s.vendor_code::TEXT AS business_key,
+    d.changes::JSONB AS changes,
+    COUNT(*) OVER ()::BIGINT AS total_updated
+FROM temp_agency_bureau_staging s
+JOIN agency_bureau c ON c.vendor_code = s.vendor_code
+CROSS JOIN LATERAL (
+    SELECT jsonb_object_agg(n.key, jsonb_build_object('old', o.value, 'new', n.value)) AS changes
+    FROM jsonb_each(to_jsonb(s)) n
+    JOIN jsonb_each(to_jsonb(c)) o ON o.key = n.key
+    WHERE n.key = ANY(@compare_columns::text[])
+      AND n.value IS DISTINCT FROM o.value
+) d
+WHERE d.changes IS NOT NULL
+ORDER BY business_key
+LIMIT @sample_limit;
@@ -0,0 +1,33 @@
+-- name: GetRemovedRowForUpdate :one
+-- Lock a removed row while it is being resolved
+SELECT * FROM removed_rows_log
+WHERE id = $1
+FOR UPDATE;
+
+-- name: ResolveRemovedRow :one
+-- Close an open removed row as corrected or dismissed
+UPDATE removed_rows_log
+SET
+    resolution_status = @resolution_status,
+    corrected_row_data = @corrected_row_data,
+    resolution_note = @resolution_note,
+    resolved_by_user_id = @resolved_by_user_id::BIGINT,
+    resolved_at = NOW()
+WHERE id = @id
+  AND resolution_status = 'OPEN'
+RETURNING *;
+
+-- name: SummarizeRemovedRowIssues :many
+-- Count the validation issues of open removed rows by rule and column
+SELECT
+    r.report_type,
+    (i.issue ->> 'rule')::TEXT AS rule,
+    (i.issue ->> 'column')::TEXT AS column_name,
+    COUNT(*) AS issue_count,
+    COUNT(DISTINCT r.upload_id) AS upload_count
+FROM removed_rows_log r
+CROSS JOIN LATERAL jsonb_array_elements(r.issues) AS i(issue)
+WHERE r.resolution_status = 'OPEN'
+  AND (@report_type::TEXT = '' OR r.report_type = @report_type::TEXT)
+GROUP BY r.report_type, rule, column_name
+ORDER BY issue_count DESC, r.report_type, rule, column_name;
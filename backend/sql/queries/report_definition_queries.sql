@@ -0,0 +1,29 @@
+-- name: CreateReportDefinition :one
+-- Publish a new version of a report definition
+INSERT INTO report_definitions (report_type, version, definition, created_by_user_id)
+VALUES ($1, $2, $3, $4)
+RETURNING *;
+
+-- name: GetCurrentReportDefinition :one
+-- Latest published version of a report definition
+SELECT * FROM report_definitions
+WHERE report_type = $1
+ORDER BY version DESC
+LIMIT 1;
+
+-- name: ListCurrentReportDefinitions :many
+-- Latest published version of each report definition
+SELECT DISTINCT ON (report_type) * FROM report_definitions
+ORDER BY report_type, version DESC;
+
+-- name: ListReportDefinitionVersions :many
+-- Every published version of a report definition, newest first
+SELECT * FROM report_definitions
+WHERE report_type = $1
+ORDER BY version DESC;
+
+-- name: SetUploadReportDefinitionVersion :exec
+-- Record the definition version an upload was validated against
+UPDATE uploads
+SET report_definition_version = $2
+WHERE id = $1;
@@ -2,20 +2,24 @@
 -- name: ListActiveChargebacks :many
 -- Fetches a paginated list from the active_chargebacks_with_vendor_info view.
 -- The view is already filtered by is_active = true.
+-- Results are limited to the caller's business lines unless all_business_lines is set.
 SELECT *, count(*) OVER() AS total_count
 FROM active_chargebacks_with_vendor_info
+WHERE (@all_business_lines::boolean OR business_line = ANY(@business_lines::chargeback_business_line[]))
 ORDER BY document_date DESC
-LIMIT $1
-OFFSET $2;
+LIMIT @page_limit
+OFFSET @page_offset;
 
 -- name: ListActiveDelinquencies :many
 -- Fetches a paginated list from the active_nonipac_with_vendor_info view.
 -- The view is already filtered by is_active = true.
+-- Results are limited to the caller's business lines unless all_business_lines is set.
 SELECT *, count(*) OVER() AS total_count
 FROM active_nonipac_with_vendor_info
+WHERE (@all_business_lines::boolean OR business_line = ANY(@business_lines::chargeback_business_line[]))
 ORDER BY document_date DESC
-LIMIT $1
-OFFSET $2;
+LIMIT @page_limit
+OFFSET @page_offset;
 
 -- name: GetActiveChargebackByID :one
 -- Fetches a single active chargeback by its primary key from the view.
@@ -63,13 +67,23 @@ SELECT
     sh.user_id,
     u.first_name AS user_first_name,
     u.last_name AS user_last_name,
-    u.email AS user_email
+    u.email AS user_email,
+    sh.upload_id,
+    sh.on_behalf_of_user_id,
+    obo.first_name AS on_behalf_of_first_name,
+    obo.last_name AS on_behalf_of_last_name,
+    (CASE
+        WHEN obo.id IS NULL THEN u.first_name || ' ' || u.last_name
+        ELSE u.first_name || ' ' || u.last_name || ' on behalf of ' || obo.first_name || ' ' || obo.last_name
+    END)::TEXT AS attributed_to
 FROM
     "status_history" sh
 JOIN
     "chargeback_status_merge" csm ON sh.id = csm.status_history_id
 JOIN
     "cdms_user" u ON sh.user_id = u.id
+LEFT JOIN
+    "cdms_user" obo ON sh.on_behalf_of_user_id = obo.id
 WHERE
     csm.chargeback_id = $1 
 ORDER BY
@@ -85,13 +99,23 @@ SELECT
     sh.user_id,
     u.first_name AS user_first_name,
     u.last_name AS user_last_name,
-    u.email AS user_email
+    u.email AS user_email,
+    sh.upload_id,
+    sh.on_behalf_of_user_id,
+    obo.first_name AS on_behalf_of_first_name,
+    obo.last_name AS on_behalf_of_last_name,
+    (CASE
+        WHEN obo.id IS NULL THEN u.first_name || ' ' || u.last_name
+        ELSE u.first_name || ' ' || u.last_name || ' on behalf of ' || obo.first_name || ' ' || obo.last_name
+    END)::TEXT AS attributed_to
 FROM
     "status_history" sh
 JOIN
     "nonipac_status_merge" nsm ON sh.id = nsm.status_history_id
 JOIN
     "cdms_user" u ON sh.user_id = u.id
+LEFT JOIN
+    "cdms_user" obo ON sh.on_behalf_of_user_id = obo.id
 WHERE
     nsm.nonipac_id = $1 
 ORDER BY
@@ -0,0 +1,65 @@
+-- name: MarkUploadStaged :exec
+-- Record that an upload's rows are staged for review, with a preview of the merge and
+-- the number of rows staged
+UPDATE uploads
+SET
+    staged_at = NOW(),
+    preview = @preview,
+    rows_staged = @rows_staged
+WHERE id = @id;
+
+-- name: ApproveUpload :one
+-- Approve a staged upload and queue its merge in the same statement, so that an
+-- approved upload is never left without a job. The job that staged it is reset.
+-- The user who uploaded the file cannot approve it.
+WITH approved AS (
+    UPDATE uploads
+    SET
+        status = 'QUEUED',
+        review_decision = 'APPROVED',
+        reviewed_by_user_id = @reviewer_id::BIGINT,
+        reviewed_at = NOW(),
+        review_reason = @review_reason
+    WHERE id = @id
+      AND status = 'STAGED'
+      AND processed_by_user_id <> @reviewer_id::BIGINT
+    RETURNING id
+)
+INSERT INTO upload_jobs (upload_id, max_attempts)
+SELECT id, @max_attempts::INTEGER FROM approved
+ON CONFLICT (upload_id) DO UPDATE
+SET
+    status = 'QUEUED',
+    attempts = 0,
+    max_attempts = EXCLUDED.max_attempts,
+    run_after = NOW(),
+    locked_by = NULL,
+    lease_expires_at = NULL,
+    heartbeat_at = NULL,
+    last_error = NULL,
+    cancel_requested_by_user_id = NULL,
+    cancel_requested_at = NULL
+RETURNING *;
+
+-- name: RejectUpload :one
+-- Reject a staged upload; it will never be merged
+UPDATE uploads
+SET
+    status = 'REJECTED',
+    review_decision = 'REJECTED',
+    reviewed_by_user_id = @reviewer_id::BIGINT,
+    reviewed_at = NOW(),
+    review_reason = @review_reason::TEXT
+WHERE id = @id
+  AND status = 'STAGED'
+RETURNING *;
+
+-- name: DeleteUploadStagedRows :exec
+-- Discard an upload's staged rows once it has been merged or rejected
+DELETE FROM upload_staged_rows
+WHERE upload_id = $1;
+
+-- name: CountRemovedRowsByUploadID :one
+-- Number of rows excluded from an upload during staging
+SELECT COUNT(*) FROM removed_rows_log
+WHERE upload_id = $1;
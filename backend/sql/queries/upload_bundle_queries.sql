@@ -0,0 +1,27 @@
+-- name: CreateUploadBundle :one
+-- Record a bundle of reports uploaded together
+INSERT INTO upload_bundles (id, filename, uploaded_by_user_id)
+VALUES ($1, $2, $3)
+RETURNING *;
+
+-- name: GetUploadBundle :one
+-- Retrieve a bundle by ID
+SELECT * FROM upload_bundles
+WHERE id = $1;
+
+-- name: ListBundleUploads :many
+-- The uploads of a bundle in the order they are processed
+SELECT
+    u.id,
+    u.filename,
+    u.report_type,
+    u.status,
+    u.bundle_position,
+    u.error_details,
+    u.rows_upserted,
+    u.rows_removed,
+    u.uploaded_at,
+    u.processed_at
+FROM uploads u
+WHERE u.bundle_id = $1
+ORDER BY u.bundle_position;
@@ -0,0 +1,69 @@
+-- name: CancelWaitingUpload :one
+-- Cancel an upload that no worker has started on, along with its queued job
+WITH job AS (
+    UPDATE upload_jobs
+    SET
+        status = 'CANCELLED',
+        cancel_requested_by_user_id = @cancelled_by::BIGINT,
+        cancel_requested_at = NOW(),
+        locked_by = NULL,
+        lease_expires_at = NULL
+    WHERE upload_id = @id
+      AND status IN ('QUEUED', 'RETRYING')
+)
+UPDATE uploads
+SET
+    status = 'CANCELLED',
+    cancelled_by_user_id = @cancelled_by::BIGINT,
+    cancelled_at = NOW()
+WHERE id = @id
+  AND status IN ('UPLOADED', 'QUEUED', 'RETRYING')
+RETURNING *;
+
+-- name: RequestUploadJobCancel :one
+-- Ask the worker processing an upload to stop, and tell the servers listening on the
+-- upload_progress channel so that whichever holds the job stops straight away.
+-- Repeating the request keeps the first requester.
+WITH requested AS (
+    UPDATE upload_jobs
+    SET
+        cancel_requested_by_user_id = COALESCE(cancel_requested_by_user_id, @requested_by::BIGINT),
+        cancel_requested_at = COALESCE(cancel_requested_at, NOW())
+    WHERE upload_id = @upload_id
+      AND status = 'PROCESSING'
+    RETURNING upload_id, cancel_requested_by_user_id, cancel_requested_at
+)
+SELECT
+    requested.cancel_requested_by_user_id,
+    requested.cancel_requested_at
+FROM requested
+CROSS JOIN LATERAL pg_notify('upload_progress', json_build_object(
+    'type', 'cancel',
+    'upload_id', requested.upload_id,
+    'at', requested.cancel_requested_at
+)::TEXT) AS notified;
+
+-- name: FinishCancelledUploadJob :exec
+-- Record that a job stopped because its cancellation was requested: the upload ends
+-- CANCELLED with who asked for it, and any rows staged for it are discarded
+WITH job AS (
+    UPDATE upload_jobs
+    SET
+        status = 'CANCELLED',
+        last_error = NULL,
+        locked_by = NULL,
+        lease_expires_at = NULL
+    WHERE upload_jobs.id = $1
+    RETURNING upload_id, cancel_requested_by_user_id
+), discarded AS (
+    DELETE FROM upload_staged_rows
+    WHERE upload_id IN (SELECT upload_id FROM job)
+)
+UPDATE uploads
+SET
+    status = 'CANCELLED',
+    cancelled_by_user_id = job.cancel_requested_by_user_id,
+    cancelled_at = NOW(),
+    processed_at = NOW()
+FROM job
+WHERE uploads.id = job.upload_id;
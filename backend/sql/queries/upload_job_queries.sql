@@ -0,0 +1,135 @@
+-- name: EnqueueUploadJob :one
+-- Queue an upload for processing and mark the upload as QUEUED.
+-- An upload whose job already finished gets that job reset.
+WITH queued AS (
+    UPDATE uploads
+    SET status = 'QUEUED'
+    WHERE id = @upload_id
+    RETURNING id
+)
+INSERT INTO upload_jobs (upload_id, max_attempts)
+SELECT id, @max_attempts::INTEGER FROM queued
+ON CONFLICT (upload_id) DO UPDATE
+SET
+    status = 'QUEUED',
+    attempts = 0,
+    max_attempts = EXCLUDED.max_attempts,
+    run_after = NOW(),
+    locked_by = NULL,
+    lease_expires_at = NULL,
+    heartbeat_at = NULL,
+    last_error = NULL,
+    cancel_requested_by_user_id = NULL,
+    cancel_requested_at = NULL
+RETURNING *;
+
+-- name: ClaimUploadJob :one
+-- Claim the next runnable job: a queued or retrying job whose backoff has elapsed,
+-- or a processing job whose lease expired because its worker died.
+-- A member of a bundle waits until the members before it have finished processing and,
+-- to merge, until they are no longer awaiting approval.
+-- SKIP LOCKED lets concurrent workers claim different jobs without blocking each other.
+WITH next_job AS (
+    SELECT j.id
+    FROM upload_jobs j
+    WHERE ((j.status IN ('QUEUED', 'RETRYING') AND j.run_after <= NOW())
+       OR (j.status = 'PROCESSING' AND j.lease_expires_at < NOW()))
+      AND NOT EXISTS (
+          SELECT 1
+          FROM uploads u
+          JOIN uploads earlier ON earlier.bundle_id = u.bundle_id
+                              AND earlier.bundle_position < u.bundle_position
+          WHERE u.id = j.upload_id
+            AND (earlier.status IN ('UPLOADED', 'QUEUED', 'PROCESSING', 'WAITING_FOR_LOCK', 'RETRYING')
+                 OR (earlier.status = 'STAGED' AND u.review_decision IS NOT DISTINCT FROM 'APPROVED'))
+      )
+    ORDER BY j.run_after
+    LIMIT 1
+    FOR UPDATE SKIP LOCKED
+), claimed AS (
+    UPDATE upload_jobs j
+    SET
+        status = 'PROCESSING',
+        attempts = j.attempts + 1,
+        locked_by = @worker_id::TEXT,
+        lease_expires_at = NOW() + make_interval(secs => @lease_seconds::INTEGER),
+        heartbeat_at = NOW()
+    FROM next_job
+    WHERE j.id = next_job.id
+    RETURNING j.id, j.upload_id, j.attempts, j.max_attempts, (j.cancel_requested_at IS NOT NULL) AS cancel_requested
+), marked AS (
+    UPDATE uploads u
+    SET status = 'PROCESSING'
+    FROM claimed
+    WHERE u.id = claimed.upload_id
+    RETURNING
+        u.id,
+        u.storage_key,
+        u.report_type,
+        u.sheet_name,
+        u.dry_run,
+        u.encoding_override,
+        u.delimiter_override,
+        (u.review_decision IS NOT DISTINCT FROM 'APPROVED') AS approved,
+        u.sanity_override,
+        u.processed_by_user_id
+)
+SELECT
+    claimed.id,
+    claimed.upload_id,
+    claimed.attempts,
+    claimed.max_attempts,
+    marked.storage_key,
+    marked.report_type,
+    marked.sheet_name,
+    marked.dry_run,
+    marked.encoding_override,
+    marked.delimiter_override,
+    marked.approved,
+    marked.sanity_override,
+    marked.processed_by_user_id,
+    claimed.cancel_requested
+FROM claimed
+JOIN marked ON marked.id = claimed.upload_id;
+
+-- name: HeartbeatUploadJob :one
+-- Extend the lease on a job the worker still holds and report whether its cancellation
+-- has been requested; no row means the lease was lost
+UPDATE upload_jobs
+SET
+    heartbeat_at = NOW(),
+    lease_expires_at = NOW() + make_interval(secs => @lease_seconds::INTEGER)
+WHERE id = @id
+  AND locked_by = @worker_id::TEXT
+  AND status = 'PROCESSING'
+RETURNING (cancel_requested_at IS NOT NULL) AS cancel_requested;
+
+-- name: RetryUploadJob :exec
+-- Release a failed attempt back to the queue with a backoff delay
+WITH job AS (
+    UPDATE upload_jobs
+    SET
+        status = 'RETRYING',
+        run_after = @run_after,
+        last_error = @last_error,
+        locked_by = NULL,
+        lease_expires_at = NULL
+    WHERE upload_jobs.id = @id
+    RETURNING upload_id
+)
+UPDATE uploads
+SET
+    status = 'RETRYING',
+    error_details = @last_error
+FROM job
+WHERE uploads.id = job.upload_id;
+
+-- name: FinishUploadJob :exec
+-- Record the terminal state of a job once the upload has succeeded or permanently failed
+UPDATE upload_jobs
+SET
+    status = @status,
+    last_error = @last_error,
+    locked_by = NULL,
+    lease_expires_at = NULL
+WHERE id = @id;
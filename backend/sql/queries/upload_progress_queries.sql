@@ -0,0 +1,15 @@
+-- name: GetUploadProgress :one
+-- The latest progress event recorded for an upload
+SELECT event FROM upload_progress
+WHERE upload_id = $1;
+
+-- name: PublishUploadProgress :exec
+-- Record an upload's latest progress event and send it to the servers listening on the
+-- upload_progress channel
+WITH recorded AS (
+    INSERT INTO upload_progress (upload_id, event, updated_at)
+    VALUES (@upload_id, @event::JSONB, NOW())
+    ON CONFLICT (upload_id) DO UPDATE
+    SET event = EXCLUDED.event, updated_at = NOW()
+)
+SELECT pg_notify('upload_progress', @event::JSONB::TEXT);
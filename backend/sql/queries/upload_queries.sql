@@ -6,9 +6,16 @@ INSERT INTO uploads (
     filename,
     report_type,
     status,
-    processed_by_user_id
+    processed_by_user_id,
+    sheet_name,
+    dry_run,
+    encoding_override,
+    delimiter_override,
+    bundle_id,
+    bundle_position,
+    content_sha256
 ) VALUES (
-    $1, $2, $3, $4, $5, $6
+    $1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13
 )
 RETURNING *;
 
@@ -23,6 +30,20 @@ SET
     processed_at = NOW()
 WHERE id = $1;
 
+-- name: FindUploadByContentSHA256 :one
+-- The latest upload of the same file content for a report type whose data is loaded or
+-- still on its way in. Dry runs and failed, rejected, rolled-back or cancelled uploads
+-- are ignored
+SELECT id, filename, status, uploaded_at
+FROM uploads
+WHERE report_type = $1
+  AND content_sha256 = $2
+  AND NOT dry_run
+  AND status NOT LIKE 'FAILED%'
+  AND status NOT IN ('REJECTED', 'ROLLED_BACK', 'CANCELLED')
+ORDER BY uploaded_at DESC
+LIMIT 1;
+
 -- name: GetUpload :one
 -- Retrieve a detailed summary for a specific upload 
 SELECT
@@ -36,10 +57,43 @@ SELECT
     u.error_details,
     u.rows_upserted,
     u.rows_removed,
+    u.sheet_name,
+    u.dry_run,
+    u.preview,
+    u.processed_by_user_id,
+    u.staged_at,
+    u.review_decision,
+    u.review_reason,
+    u.reviewed_at,
+    u.sanity_check,
+    u.sanity_override,
+    u.sanity_override_at,
+    u.reprocessed_from_upload_id,
+    u.rolled_back_at,
+    u.report_definition_version,
+    u.header_check,
+    u.encoding_override,
+    u.delimiter_override,
+    u.dialect,
+    u.bundle_id,
+    u.bundle_position,
+    u.content_sha256,
+    u.cancelled_by_user_id,
+    u.cancelled_at,
+    u.rows_staged,
     usr.first_name, 
-    usr.last_name
+    usr.last_name,
+    reviewer.first_name AS reviewer_first_name,
+    reviewer.last_name AS reviewer_last_name,
+    j.attempts,
+    j.max_attempts,
+    j.run_after AS next_attempt_at,
+    j.last_error,
+    (SELECT COUNT(*) FROM removed_rows_log r WHERE r.upload_id = u.id AND r.resolution_status = 'OPEN') AS open_removed_rows
 FROM uploads u
 LEFT JOIN "cdms_user" usr ON u.processed_by_user_id = usr.id
+LEFT JOIN "cdms_user" reviewer ON u.reviewed_by_user_id = reviewer.id
+LEFT JOIN upload_jobs j ON j.upload_id = u.id
 WHERE u.id = $1;
 
 -- name: ListUploads :many
@@ -55,10 +109,57 @@ SELECT
     u.error_details,
     u.rows_upserted,
     u.rows_removed,
+    u.sheet_name,
+    u.dry_run,
+    u.processed_by_user_id,
+    u.staged_at,
+    u.review_decision,
+    u.review_reason,
+    u.reviewed_at,
+    u.reprocessed_from_upload_id,
+    u.rolled_back_at,
+    u.report_definition_version,
+    u.header_check,
+    u.encoding_override,
+    u.delimiter_override,
+    u.dialect,
+    u.bundle_id,
+    u.bundle_position,
+    u.content_sha256,
+    u.cancelled_by_user_id,
+    u.cancelled_at,
+    u.rows_staged,
     usr.first_name, 
-    usr.last_name
+    usr.last_name,
+    reviewer.first_name AS reviewer_first_name,
+    reviewer.last_name AS reviewer_last_name,
+    j.attempts,
+    j.max_attempts,
+    j.run_after AS next_attempt_at,
+    j.last_error,
+    (SELECT COUNT(*) FROM removed_rows_log r WHERE r.upload_id = u.id AND r.resolution_status = 'OPEN') AS open_removed_rows
 FROM uploads u
 LEFT JOIN "cdms_user" usr ON u.processed_by_user_id = usr.id
-ORDER BY uploaded_at DESC
+LEFT JOIN "cdms_user" reviewer ON u.reviewed_by_user_id = reviewer.id
+LEFT JOIN upload_jobs j ON j.upload_id = u.id
+ORDER BY u.uploaded_at DESC
 LIMIT $1
 OFFSET $2;
+
+-- name: SetUploadDialect :exec
+-- Store the encoding and delimiter an upload was read with
+UPDATE uploads
+SET dialect = $2
+WHERE id = $1;
+
+-- name: SetUploadHeaderCheck :exec
+-- Store how an upload's header row was matched to its report definition
+UPDATE uploads
+SET header_check = $2
+WHERE id = $1;
+
+-- name: SetUploadPreview :exec
+-- Store the dry-run preview computed for an upload
+UPDATE uploads
+SET preview = $2
+WHERE id = $1;
@@ -0,0 +1,243 @@
+-- name: CreateReprocessedUpload :one
+-- Record a new upload that re-runs the stored file of an earlier one, optionally
+-- forcing a different encoding or delimiter, and queue it in the same statement so
+-- that it is never left without a job
+WITH reprocessed AS (
+    INSERT INTO uploads (
+        id,
+        storage_key,
+        filename,
+        report_type,
+        status,
+        processed_by_user_id,
+        sheet_name,
+        dry_run,
+        encoding_override,
+        delimiter_override,
+        content_sha256,
+        reprocessed_from_upload_id
+    )
+    SELECT
+        @id,
+        src.storage_key,
+        src.filename,
+        src.report_type,
+        'QUEUED',
+        @processed_by_user_id,
+        src.sheet_name,
+        src.dry_run,
+        COALESCE(sqlc.narg(encoding_override), src.encoding_override),
+        COALESCE(sqlc.narg(delimiter_override), src.delimiter_override),
+        src.content_sha256,
+        src.id
+    FROM uploads src
+    WHERE src.id = @source_upload_id
+    RETURNING id
+)
+INSERT INTO upload_jobs (upload_id, max_attempts)
+SELECT id, @max_attempts::INTEGER FROM reprocessed
+RETURNING *;
+
+-- name: GetLatestMergedUploadID :one
+-- The most recent upload of a report type whose merge is still in effect
+SELECT id FROM uploads
+WHERE report_type = $1
+  AND status IN ('COMPLETE', 'COMPLETE_WITH_ISSUES')
+ORDER BY processed_at DESC
+LIMIT 1;
+
+-- name: CountChangesSinceUpload :one
+-- Changes made by anything other than the upload to records the upload changed, after
+-- it ran. Rolling back over such changes would silently discard them
+SELECT (
+    (SELECT COUNT(*) FROM audit.chargeback_changes later
+     WHERE later.target_id IN (SELECT target_id FROM audit.chargeback_changes WHERE upload_id = @upload_id)
+       AND later.audit_id > (SELECT MAX(audit_id) FROM audit.chargeback_changes WHERE upload_id = @upload_id)
+       AND later.upload_id IS DISTINCT FROM @upload_id)
+    + (SELECT COUNT(*) FROM audit.nonipac_changes later
+     WHERE later.target_id IN (SELECT target_id FROM audit.nonipac_changes WHERE upload_id = @upload_id)
+       AND later.audit_id > (SELECT MAX(audit_id) FROM audit.nonipac_changes WHERE upload_id = @upload_id)
+       AND later.upload_id IS DISTINCT FROM @upload_id)
+    + (SELECT COUNT(*) FROM audit.agency_bureau_changes later
+     WHERE later.vendor_code IN (SELECT vendor_code FROM audit.agency_bureau_changes WHERE upload_id = @upload_id)
+       AND later.audit_id > (SELECT MAX(audit_id) FROM audit.agency_bureau_changes WHERE upload_id = @upload_id)
+       AND later.upload_id IS DISTINCT FROM @upload_id)
+)::BIGINT AS changes;
+
+-- name: RestoreChargebacksFromAudit :execrows
+-- Put every chargeback the upload updated back to its state before the upload
+WITH first_change AS (
+    SELECT DISTINCT ON (target_id) target_id, operation, old_data
+    FROM audit.chargeback_changes
+    WHERE upload_id = $1
+    ORDER BY target_id, audit_id
+)
+UPDATE chargeback c
+SET
+    reporting_source = r.reporting_source,
+    fund = r.fund,
+    business_line = r.business_line,
+    region = r.region,
+    location_system = r.location_system,
+    program = r.program,
+    al_num = r.al_num,
+    source_num = r.source_num,
+    agreement_num = r.agreement_num,
+    title = r.title,
+    alc = r.alc,
+    customer_tas = r.customer_tas,
+    task_subtask = r.task_subtask,
+    class_id = r.class_id,
+    customer_name = r.customer_name,
+    org_code = r.org_code,
+    document_date = r.document_date,
+    accomp_date = r.accomp_date,
+    assigned_rebill_drn = r.assigned_rebill_drn,
+    chargeback_amount = r.chargeback_amount,
+    statement = r.statement,
+    bd_doc_num = r.bd_doc_num,
+    vendor = r.vendor,
+    articles_services = r.articles_services,
+    current_status = r.current_status,
+    reason_code = r.reason_code,
+    action = r.action,
+    alc_to_rebill = r.alc_to_rebill,
+    tas_to_rebill = r.tas_to_rebill,
+    line_of_accounting_rebill = r.line_of_accounting_rebill,
+    special_instruction = r.special_instruction,
+    new_ipac_document_ref = r.new_ipac_document_ref,
+    is_active = r.is_active
+FROM first_change f, jsonb_populate_record(NULL::chargeback, f.old_data) r
+WHERE f.operation = 'U'
+  AND c.id = f.target_id;
+
+-- name: DeleteChargebacksInsertedByUpload :execrows
+-- Remove the chargebacks the upload created, along with the status history the
+-- insert logged for them
+WITH inserted AS (
+    SELECT target_id FROM audit.chargeback_changes
+    WHERE upload_id = $1 AND operation = 'I'
+), unlinked AS (
+    DELETE FROM chargeback_status_merge m
+    USING inserted i
+    WHERE m.chargeback_id = i.target_id
+    RETURNING m.status_history_id
+), history AS (
+    DELETE FROM status_history h
+    USING unlinked u
+    WHERE h.id = u.status_history_id
+)
+DELETE FROM chargeback c
+USING inserted i
+WHERE c.id = i.target_id;
+
+-- name: RestoreNonIpacsFromAudit :execrows
+-- Put every delinquency the upload updated back to its state before the upload
+WITH first_change AS (
+    SELECT DISTINCT ON (target_id) target_id, operation, old_data
+    FROM audit.nonipac_changes
+    WHERE upload_id = $1
+    ORDER BY target_id, audit_id
+)
+UPDATE "nonipac" n
+SET
+    reporting_source = r.reporting_source,
+    business_line = r.business_line,
+    billed_total_amount = r.billed_total_amount,
+    principle_amount = r.principle_amount,
+    interest_amount = r.interest_amount,
+    penalty_amount = r.penalty_amount,
+    administration_charges_amount = r.administration_charges_amount,
+    debit_outstanding_amount = r.debit_outstanding_amount,
+    credit_total_amount = r.credit_total_amount,
+    credit_outstanding_amount = r.credit_outstanding_amount,
+    title = r.title,
+    document_date = r.document_date,
+    address_code = r.address_code,
+    vendor = r.vendor,
+    debt_appeal_forbearance = r.debt_appeal_forbearance,
+    statement = r.statement,
+    document_number = r.document_number,
+    vendor_code = r.vendor_code,
+    collection_due_date = r.collection_due_date,
+    current_status = r.current_status,
+    pfs_poc = r.pfs_poc,
+    gsa_poc = r.gsa_poc,
+    customer_poc = r.customer_poc,
+    pfs_contacts = r.pfs_contacts,
+    open_date = r.open_date,
+    reconciled_date = r.reconciled_date,
+    is_active = r.is_active
+FROM first_change f, jsonb_populate_record(NULL::"nonipac", f.old_data) r
+WHERE f.operation = 'U'
+  AND n.id = f.target_id;
+
+-- name: DeleteNonIpacsInsertedByUpload :execrows
+-- Remove the delinquencies the upload created, along with the status history the
+-- insert logged for them
+WITH inserted AS (
+    SELECT target_id FROM audit.nonipac_changes
+    WHERE upload_id = $1 AND operation = 'I'
+), unlinked AS (
+    DELETE FROM nonipac_status_merge m
+    USING inserted i
+    WHERE m.nonipac_id = i.target_id
+    RETURNING m.status_history_id
+), history AS (
+    DELETE FROM status_history h
+    USING unlinked u
+    WHERE h.id = u.status_history_id
+)
+DELETE FROM "nonipac" n
+USING inserted i
+WHERE n.id = i.target_id;
+
+-- name: RestoreAgencyBureausFromAudit :execrows
+-- Put every agency bureau the upload updated back to its state before the upload
+WITH first_change AS (
+    SELECT DISTINCT ON (vendor_code) vendor_code, operation, old_data
+    FROM audit.agency_bureau_changes
+    WHERE upload_id = $1
+    ORDER BY vendor_code, audit_id
+)
+UPDATE agency_bureau ab
+SET
+    agency = r.agency,
+    bureau_code = r.bureau_code,
+    agency_location_code = r.agency_location_code,
+    vendor_address_code = r.vendor_address_code,
+    name = r.name,
+    address_line_1 = r.address_line_1,
+    address_line_2 = r.address_line_2,
+    address_line_3 = r.address_line_3,
+    city = r.city,
+    state = r.state,
+    zip = r.zip,
+    status = r.status,
+    vendor_type = r.vendor_type,
+    reporting_attribute = r.reporting_attribute,
+    security_org = r.security_org,
+    transmit_to_vcss_flag = r.transmit_to_vcss_flag,
+    needs_review = r.needs_review
+FROM first_change f, jsonb_populate_record(NULL::agency_bureau, f.old_data) r
+WHERE f.operation = 'U'
+  AND ab.vendor_code = f.vendor_code;
+
+-- name: DeleteAgencyBureausInsertedByUpload :execrows
+-- Remove the agency bureaus the upload created
+DELETE FROM agency_bureau ab
+USING audit.agency_bureau_changes a
+WHERE a.upload_id = $1
+  AND a.operation = 'I'
+  AND ab.vendor_code = a.vendor_code;
+
+-- name: MarkUploadRolledBack :one
+-- Record that a merged upload has been rolled back
+UPDATE uploads
+SET
+    status = 'ROLLED_BACK',
+    rolled_back_by_user_id = @user_id::BIGINT,
+    rolled_back_at = NOW()
+WHERE id = @id
+  AND status IN ('COMPLETE', 'COMPLETE_WITH_ISSUES')
+RETURNING *;
@@ -0,0 +1,48 @@
+-- name: GetLastSuccessfulUploadRowCount :one
+-- Rows staged by the most recent successful upload of a report type, the baseline for
+-- the row-count sanity check. rows_upserted is no baseline: upserts skip unchanged rows
+SELECT rows_staged FROM uploads
+WHERE report_type = $1
+  AND status IN ('COMPLETE', 'COMPLETE_WITH_ISSUES')
+ORDER BY processed_at DESC
+LIMIT 1;
+
+-- name: SetUploadSanityCheck :exec
+-- Store the numbers computed by the mass-deactivation sanity check
+UPDATE uploads
+SET sanity_check = @sanity_check
+WHERE id = @id;
+
+-- name: OverrideUploadSanityCheck :one
+-- Force an upload that failed the sanity check through, queueing it again in the same
+-- statement so that it is never left without a job
+WITH overridden AS (
+    SELECT id FROM uploads
+    WHERE id = @id
+      AND status = 'FAILED_SANITY_CHECK'
+    FOR UPDATE
+), queued AS (
+    INSERT INTO upload_jobs (upload_id, max_attempts)
+    SELECT id, @max_attempts::INTEGER FROM overridden
+    ON CONFLICT (upload_id) DO UPDATE
+    SET
+        status = 'QUEUED',
+        attempts = 0,
+        max_attempts = EXCLUDED.max_attempts,
+        run_after = NOW(),
+        locked_by = NULL,
+        lease_expires_at = NULL,
+        heartbeat_at = NULL,
+        last_error = NULL,
+        cancel_requested_by_user_id = NULL,
+        cancel_requested_at = NULL
+)
+UPDATE uploads
+SET
+    status = 'QUEUED',
+    sanity_override = true,
+    sanity_override_by_user_id = @user_id::BIGINT,
+    sanity_override_at = NOW()
+FROM overridden
+WHERE uploads.id = overridden.id
+RETURNING uploads.*;
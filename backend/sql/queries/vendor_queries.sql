@@ -0,0 +1,110 @@
+-- name: ListVendors :many
+-- Page through the vendor directory, optionally narrowed to vendor codes starting with
+-- the search or names containing it, or to placeholder vendors awaiting review. Each
+-- vendor carries how many open chargebacks and delinquencies it has in the caller's
+-- business lines
+SELECT
+    ab.*,
+    (
+        SELECT COUNT(*) FROM chargeback cb
+        WHERE cb.vendor = ab.vendor_code
+          AND cb.is_active
+          AND (@all_business_lines::boolean OR cb.business_line = ANY(@business_lines::chargeback_business_line[]))
+    )::BIGINT AS open_chargebacks,
+    (
+        SELECT COUNT(*) FROM nonipac ni
+        WHERE ni.address_code = ab.vendor_code
+          AND ni.is_active
+          AND (@all_business_lines::boolean OR ni.business_line = ANY(@business_lines::chargeback_business_line[]))
+    )::BIGINT AS open_delinquencies,
+    COUNT(*) OVER () AS total_count
+FROM agency_bureau ab
+WHERE (@search::TEXT = ''
+       OR ab.vendor_code ILIKE @search::TEXT || '%'
+       OR ab.name ILIKE '%' || @search::TEXT || '%')
+  AND (NOT @needs_review_only::boolean OR ab.needs_review)
+ORDER BY ab.vendor_code
+LIMIT @page_limit
+OFFSET @page_offset;
+
+-- name: GetVendor :one
+-- Fetch a vendor's directory entry by its vendor code
+SELECT * FROM agency_bureau
+WHERE vendor_code = $1;
+
+-- name: ListVendorOpenChargebacks :many
+-- The vendor's active chargebacks in the caller's business lines, newest first
+SELECT * FROM active_chargebacks_with_vendor_info
+WHERE vendor = @vendor_code
+  AND (@all_business_lines::boolean OR business_line = ANY(@business_lines::chargeback_business_line[]))
+ORDER BY document_date DESC;
+
+-- name: ListVendorOpenDelinquencies :many
+-- The vendor's active delinquencies in the caller's business lines, newest first
+SELECT * FROM active_nonipac_with_vendor_info
+WHERE address_code = @vendor_code
+  AND (@all_business_lines::boolean OR business_line = ANY(@business_lines::chargeback_business_line[]))
+ORDER BY document_date DESC;
+
+-- name: ListVendorHistory :many
+-- Every recorded change to a vendor's directory entry, newest first, with the old and
+-- new value of each field that changed
+SELECT
+    a.audit_id,
+    a.operation,
+    a.changed_by,
+    a.changed_at,
+    a.upload_id,
+    a.on_behalf_of,
+    COALESCE(d.changes, '{}')::JSONB AS changes
+FROM audit.agency_bureau_changes a
+CROSS JOIN LATERAL (
+    SELECT jsonb_object_agg(k.key, jsonb_build_object('old', a.old_data -> k.key, 'new', a.new_data -> k.key)) AS changes
+    FROM jsonb_object_keys(COALESCE(a.new_data, a.old_data)) AS k(key)
+    WHERE k.key NOT IN ('created_at', 'updated_at')
+      AND (a.old_data -> k.key) IS DISTINCT FROM (a.new_data -> k.key)
+) d
+WHERE a.vendor_code = $1
+ORDER BY a.audit_id DESC;
+
+-- name: ListKnownVendorCodes :many
+-- Of the given vendor codes, the ones in the vendor directory or staged by a vendor
+-- directory report earlier in the upload's bundle, which is merged before it
+SELECT vendor_code::TEXT AS vendor_code
+FROM agency_bureau
+WHERE vendor_code = ANY(@vendor_codes::text[])
+UNION
+SELECT s.row_data->>'vendor_code'
+FROM uploads u
+JOIN uploads earlier ON earlier.bundle_id = u.bundle_id
+                    AND earlier.bundle_position < u.bundle_position
+JOIN upload_staged_rows s ON s.upload_id = earlier.id
+WHERE u.id = @upload_id
+  AND earlier.report_type = ANY(@directory_report_types::text[])
+  AND s.row_data->>'vendor_code' = ANY(@vendor_codes::text[]);
+
+-- name: ListUnknownStagedChargebackVendors :many
+-- Vendor codes of staged chargebacks that are missing from the vendor directory
+SELECT DISTINCT s.vendor::TEXT AS vendor_code
+FROM temp_chargeback_staging s
+WHERE NOT EXISTS (
+    SELECT 1 FROM agency_bureau ab WHERE ab.vendor_code = s.vendor
+)
+ORDER BY vendor_code;
+
+-- name: ListUnknownStagedNonIpacVendors :many
+-- Vendor codes of staged delinquencies that are missing from the vendor directory
+SELECT DISTINCT s.address_code::TEXT AS vendor_code
+FROM temp_nonipac_staging s
+WHERE NOT EXISTS (
+    SELECT 1 FROM agency_bureau ab WHERE ab.vendor_code = s.address_code
+)
+ORDER BY vendor_code;
+
+-- name: CreatePlaceholderVendors :execrows
+-- Add vendors known only by their code, flagged for review until a vendor directory
+-- report fills them in
+INSERT INTO agency_bureau (agency, bureau_code, vendor_code, needs_review)
+SELECT '', '', code, TRUE
+FROM unnest(@vendor_codes::text[]) AS code
+ON CONFLICT (vendor_code) DO NOTHING;
@@ -0,0 +1,24 @@
+-- +goose Up
+-- Create the audit table for comment changes and attach the generic audit trigger.
+-- The trigger function writes to audit.<table>_changes, so the table name must match "comments".
+
+CREATE TABLE audit.comments_changes (
+    audit_id BIGSERIAL PRIMARY KEY,
+    target_id BIGINT NOT NULL, -- The ID of the comment record being audited
+    operation CHAR(1) NOT NULL, -- 'I' (Insert), 'U' (Update), 'D' (Delete)
+    changed_by BIGINT, -- The user who made the change (taken from app.user_id)
+    changed_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    old_data JSONB, -- Full row snapshot BEFORE the change (for UPDATE/DELETE)
+    new_data JSONB   -- Full row snapshot AFTER the change (for INSERT/UPDATE)
+);
+
+CREATE INDEX idx_audit_comments_target_id ON audit.comments_changes (target_id);
+CREATE INDEX idx_audit_comments_changed_at ON audit.comments_changes (changed_at DESC);
+
+CREATE TRIGGER comments_audit_trigger
+AFTER INSERT OR UPDATE OR DELETE ON "comments"
+FOR EACH ROW EXECUTE FUNCTION audit.if_modified_func();
+
+-- +goose Down
+DROP TRIGGER IF EXISTS comments_audit_trigger ON "comments";
+DROP TABLE IF EXISTS audit.comments_changes;
@@ -0,0 +1,17 @@
+-- +goose Up
+-- Chargeback, delinquency and dashboard reads are scoped to a user's assigned business lines.
+-- This permission lifts that scope so the holder can see every business line.
+INSERT INTO "permissions" (action, description) VALUES
+('data:view_all_business_lines', 'Ability to view chargeback, delinquency, and dashboard data across all business lines.');
+
+-- Super admins, admins and maintainers (which includes the system user) work across every business line
+INSERT INTO "role_permissions" (role_id, permission_id)
+SELECT r.id, p.id
+FROM roles r, permissions p
+WHERE r.name IN ('super_admin', 'admin', 'maintainer')
+  AND p.action = 'data:view_all_business_lines';
+
+-- +goose Down
+DELETE FROM "role_permissions"
+WHERE permission_id = (SELECT id FROM permissions WHERE action = 'data:view_all_business_lines');
+DELETE FROM "permissions" WHERE action = 'data:view_all_business_lines';
@@ -0,0 +1,36 @@
+-- +goose Up
+-- Durable work queue for processing uploaded report files. Workers claim jobs with
+-- SELECT ... FOR UPDATE SKIP LOCKED and hold a lease that they renew by heartbeat;
+-- a job whose lease has expired is picked up again by another worker.
+CREATE TABLE "upload_jobs" (
+    "id" BIGSERIAL PRIMARY KEY,
+    "upload_id" UUID NOT NULL UNIQUE REFERENCES "uploads" ("id") ON DELETE CASCADE,
+    "status" TEXT NOT NULL DEFAULT 'QUEUED', -- QUEUED, PROCESSING, RETRYING, SUCCEEDED, FAILED
+    "attempts" INTEGER NOT NULL DEFAULT 0,
+    "max_attempts" INTEGER NOT NULL,
+    "run_after" TIMESTAMPTZ NOT NULL DEFAULT NOW(), -- earliest time the job may be claimed
+    "locked_by" TEXT, -- worker currently holding the lease
+    "lease_expires_at" TIMESTAMPTZ,
+    "heartbeat_at" TIMESTAMPTZ,
+    "last_error" TEXT,
+    "created_at" TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    "updated_at" TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CONSTRAINT "upload_jobs_status_check" CHECK ("status" IN ('QUEUED', 'PROCESSING', 'RETRYING', 'SUCCEEDED', 'FAILED'))
+);
+
+CREATE INDEX "idx_upload_jobs_claimable" ON "upload_jobs" ("run_after") WHERE "status" IN ('QUEUED', 'RETRYING');
+CREATE INDEX "idx_upload_jobs_lease" ON "upload_jobs" ("lease_expires_at") WHERE "status" = 'PROCESSING';
+
+CREATE TRIGGER set_upload_jobs_updated_at
+BEFORE UPDATE ON "upload_jobs"
+FOR EACH ROW EXECUTE FUNCTION set_updated_at_timestamp_func();
+
+-- Uploads that were accepted but never processed (e.g. the server restarted mid-run) are queued.
+INSERT INTO "upload_jobs" (upload_id, max_attempts)
+SELECT id, 3 FROM "uploads" WHERE status = 'UPLOADED';
+
+UPDATE "uploads" SET status = 'QUEUED' WHERE status = 'UPLOADED';
+
+-- +goose Down
+UPDATE "uploads" SET status = 'UPLOADED' WHERE status IN ('QUEUED', 'PROCESSING', 'RETRYING');
+DROP TABLE "upload_jobs";
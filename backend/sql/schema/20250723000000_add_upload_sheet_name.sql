@@ -0,0 +1,6 @@
+-- +goose Up
+-- Worksheet to read when the uploaded file is an .xlsx workbook; NULL means the first sheet.
+ALTER TABLE "uploads" ADD COLUMN "sheet_name" TEXT;
+
+-- +goose Down
+ALTER TABLE "uploads" DROP COLUMN "sheet_name";
@@ -0,0 +1,9 @@
+-- +goose Up
+-- A dry-run upload runs the full merge inside a transaction that is rolled back.
+-- The preview records what the merge would have done so it can be reviewed first.
+ALTER TABLE "uploads" ADD COLUMN "dry_run" BOOLEAN NOT NULL DEFAULT false;
+ALTER TABLE "uploads" ADD COLUMN "preview" JSONB;
+
+-- +goose Down
+ALTER TABLE "uploads" DROP COLUMN "preview";
+ALTER TABLE "uploads" DROP COLUMN "dry_run";
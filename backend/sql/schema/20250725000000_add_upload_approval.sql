@@ -0,0 +1,42 @@
+-- +goose Up
+-- Uploads are staged for review instead of being merged straight away. A second user
+-- holding reports:approve approves the upload, which queues the merge, or rejects it.
+INSERT INTO "permissions" (action, description) VALUES
+('reports:approve', 'Ability to approve or reject staged report uploads before they are merged.');
+
+INSERT INTO "role_permissions" (role_id, permission_id)
+SELECT r.id, p.id
+FROM roles r, permissions p
+WHERE r.name IN ('super_admin', 'admin')
+  AND p.action = 'reports:approve';
+
+ALTER TABLE "uploads" ADD COLUMN "staged_at" TIMESTAMPTZ;
+ALTER TABLE "uploads" ADD COLUMN "reviewed_by_user_id" BIGINT REFERENCES "cdms_user" ("id");
+ALTER TABLE "uploads" ADD COLUMN "reviewed_at" TIMESTAMPTZ;
+ALTER TABLE "uploads" ADD COLUMN "review_decision" TEXT;
+ALTER TABLE "uploads" ADD COLUMN "review_reason" TEXT;
+ALTER TABLE "uploads" ADD CONSTRAINT "uploads_review_decision_check" CHECK ("review_decision" IN ('APPROVED', 'REJECTED'));
+
+-- The converted rows of a staged upload, one JSON object per staging-table row. They are
+-- loaded back into the staging table when the upload is approved and deleted once it is
+-- merged or rejected.
+CREATE TABLE "upload_staged_rows" (
+    "upload_id" UUID NOT NULL REFERENCES "uploads" ("id") ON DELETE CASCADE,
+    "row_num" INTEGER NOT NULL,
+    "row_data" JSONB NOT NULL,
+    PRIMARY KEY ("upload_id", "row_num")
+);
+
+-- +goose Down
+DROP TABLE "upload_staged_rows";
+
+ALTER TABLE "uploads" DROP CONSTRAINT "uploads_review_decision_check";
+ALTER TABLE "uploads" DROP COLUMN "review_reason";
+ALTER TABLE "uploads" DROP COLUMN "review_decision";
+ALTER TABLE "uploads" DROP COLUMN "reviewed_at";
+ALTER TABLE "uploads" DROP COLUMN "reviewed_by_user_id";
+ALTER TABLE "uploads" DROP COLUMN "staged_at";
+
+DELETE FROM "role_permissions"
+WHERE permission_id = (SELECT id FROM permissions WHERE action = 'reports:approve');
+DELETE FROM "permissions" WHERE action = 'reports:approve';
@@ -0,0 +1,29 @@
+-- +goose Up
+-- A merge deactivates every active record from the report's source that is missing from
+-- the file, so a truncated report can wipe out the active list. Uploads that would
+-- deactivate or drop too many records fail with FAILED_SANITY_CHECK; sanity_check keeps
+-- the numbers behind the decision. Holders of reports:override_sanity_check can force
+-- such an upload through.
+INSERT INTO "permissions" (action, description) VALUES
+('reports:override_sanity_check', 'Ability to force through an upload that failed the mass-deactivation sanity check.');
+
+INSERT INTO "role_permissions" (role_id, permission_id)
+SELECT r.id, p.id
+FROM roles r, permissions p
+WHERE r.name IN ('super_admin', 'admin')
+  AND p.action = 'reports:override_sanity_check';
+
+ALTER TABLE "uploads" ADD COLUMN "sanity_check" JSONB;
+ALTER TABLE "uploads" ADD COLUMN "sanity_override" BOOLEAN NOT NULL DEFAULT false;
+ALTER TABLE "uploads" ADD COLUMN "sanity_override_by_user_id" BIGINT REFERENCES "cdms_user" ("id");
+ALTER TABLE "uploads" ADD COLUMN "sanity_override_at" TIMESTAMPTZ;
+
+-- +goose Down
+ALTER TABLE "uploads" DROP COLUMN "sanity_override_at";
+ALTER TABLE "uploads" DROP COLUMN "sanity_override_by_user_id";
+ALTER TABLE "uploads" DROP COLUMN "sanity_override";
+ALTER TABLE "uploads" DROP COLUMN "sanity_check";
+
+DELETE FROM "role_permissions"
+WHERE permission_id = (SELECT id FROM permissions WHERE action = 'reports:override_sanity_check');
+DELETE FROM "permissions" WHERE action = 'reports:override_sanity_check';
@@ -0,0 +1,88 @@
+-- +goose Up
+-- Merges move records that drop off a report to 'Reconciled - Off Report' and reopen
+-- them when they come back. The status history trigger now takes its note from the
+-- app.status_note setting when one is set, so those entries can say why the status
+-- changed and which upload changed it.
+
+-- +goose StatementBegin
+CREATE OR REPLACE FUNCTION log_status_history_func()
+RETURNS TRIGGER AS $$
+DECLARE
+    last_status_history_id BIGINT;
+    current_user_id BIGINT;
+    system_user_id BIGINT;
+    status_note TEXT;
+BEGIN
+    IF (TG_OP = 'UPDATE' AND OLD.current_status IS NOT DISTINCT FROM NEW.current_status) THEN
+        RETURN NEW;
+    END IF;
+
+    SELECT id INTO system_user_id FROM "cdms_user" WHERE email = 'system@cdms.local';
+
+    BEGIN
+        current_user_id := current_setting('app.user_id')::BIGINT;
+    EXCEPTION WHEN OTHERS THEN
+        current_user_id := system_user_id;
+    END;
+
+    status_note := NULLIF(current_setting('app.status_note', true), '');
+
+    INSERT INTO "status_history" (status, notes, user_id, status_date)
+    VALUES (
+        NEW.current_status,
+        COALESCE(status_note, 'Status ' || NEW.current_status || ' logged via trigger for ' || TG_TABLE_NAME),
+        current_user_id,
+        NOW()
+    )
+    RETURNING id INTO last_status_history_id;
+
+    IF TG_TABLE_NAME = 'chargeback' THEN
+        INSERT INTO "chargeback_status_merge" (chargeback_id, status_history_id)
+        VALUES (NEW.id, last_status_history_id);
+    ELSIF TG_TABLE_NAME = 'nonipac' THEN
+        INSERT INTO "nonipac_status_merge" (nonipac_id, status_history_id)
+        VALUES (NEW.id, last_status_history_id);
+    END IF;
+
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+CREATE OR REPLACE FUNCTION log_status_history_func()
+RETURNS TRIGGER AS $$
+DECLARE
+    last_status_history_id BIGINT;
+    current_user_id BIGINT;
+    system_user_id BIGINT;
+BEGIN
+    IF (TG_OP = 'UPDATE' AND OLD.current_status IS NOT DISTINCT FROM NEW.current_status) THEN
+        RETURN NEW;
+    END IF;
+
+    SELECT id INTO system_user_id FROM "cdms_user" WHERE email = 'system@cdms.local';
+
+    BEGIN
+        current_user_id := current_setting('app.user_id')::BIGINT;
+    EXCEPTION WHEN OTHERS THEN
+        current_user_id := system_user_id;
+    END;
+
+    INSERT INTO "status_history" (status, notes, user_id, status_date)
+    VALUES (NEW.current_status, 'Status ' || NEW.current_status || ' logged via trigger for ' || TG_TABLE_NAME, current_user_id, NOW())
+    RETURNING id INTO last_status_history_id;
+
+    IF TG_TABLE_NAME = 'chargeback' THEN
+        INSERT INTO "chargeback_status_merge" (chargeback_id, status_history_id)
+        VALUES (NEW.id, last_status_history_id);
+    ELSIF TG_TABLE_NAME = 'nonipac' THEN
+        INSERT INTO "nonipac_status_merge" (nonipac_id, status_history_id)
+        VALUES (NEW.id, last_status_history_id);
+    END IF;
+
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
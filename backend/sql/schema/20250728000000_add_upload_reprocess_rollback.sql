@@ -0,0 +1,199 @@
+-- +goose Up
+-- Uploads can be reprocessed from their stored file as a new, linked upload, and the
+-- latest merged upload of a report can be rolled back. Rollback replays the audit trail
+-- in reverse, so every audit row now records the upload whose transaction made the
+-- change (taken from the app.upload_id setting). agency_bureau had no audit trail and is
+-- keyed by vendor_code, so it gets its own audit table and trigger function.
+INSERT INTO "permissions" (action, description) VALUES
+('reports:rollback', 'Ability to roll back the most recent merged upload of a report.');
+
+INSERT INTO "role_permissions" (role_id, permission_id)
+SELECT r.id, p.id
+FROM roles r, permissions p
+WHERE r.name IN ('super_admin', 'admin')
+  AND p.action = 'reports:rollback';
+
+ALTER TABLE "uploads" ADD COLUMN "reprocessed_from_upload_id" UUID REFERENCES "uploads" ("id");
+ALTER TABLE "uploads" ADD COLUMN "rolled_back_by_user_id" BIGINT REFERENCES "cdms_user" ("id");
+ALTER TABLE "uploads" ADD COLUMN "rolled_back_at" TIMESTAMPTZ;
+
+ALTER TABLE audit.chargeback_changes ADD COLUMN upload_id UUID;
+ALTER TABLE audit.nonipac_changes ADD COLUMN upload_id UUID;
+ALTER TABLE audit.cdms_user_changes ADD COLUMN upload_id UUID;
+ALTER TABLE audit.comments_changes ADD COLUMN upload_id UUID;
+
+CREATE INDEX idx_audit_chargeback_upload_id ON audit.chargeback_changes (upload_id) WHERE upload_id IS NOT NULL;
+CREATE INDEX idx_audit_nonipac_upload_id ON audit.nonipac_changes (upload_id) WHERE upload_id IS NOT NULL;
+
+CREATE TABLE audit.agency_bureau_changes (
+    audit_id BIGSERIAL PRIMARY KEY,
+    vendor_code VARCHAR(8) NOT NULL, -- The vendor code of the agency_bureau record being audited
+    operation CHAR(1) NOT NULL, -- 'I' (Insert), 'U' (Update), 'D' (Delete)
+    changed_by BIGINT, -- The user who made the change (taken from app.user_id)
+    changed_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    old_data JSONB, -- Full row snapshot BEFORE the change (for UPDATE/DELETE)
+    new_data JSONB, -- Full row snapshot AFTER the change (for INSERT/UPDATE)
+    upload_id UUID -- The upload whose transaction made the change (taken from app.upload_id)
+);
+
+CREATE INDEX idx_audit_agency_bureau_vendor_code ON audit.agency_bureau_changes (vendor_code);
+CREATE INDEX idx_audit_agency_bureau_upload_id ON audit.agency_bureau_changes (upload_id) WHERE upload_id IS NOT NULL;
+
+-- +goose StatementBegin
+CREATE OR REPLACE FUNCTION audit.if_modified_func() RETURNS TRIGGER AS $$
+DECLARE
+    audit_table_name TEXT;
+    target_id_value BIGINT;
+    old_row_jsonb JSONB := NULL;
+    new_row_jsonb JSONB := NULL;
+    current_user_id BIGINT := NULL;
+    current_upload_id UUID := NULL;
+BEGIN
+    audit_table_name := TG_TABLE_NAME || '_changes';
+
+    IF TG_OP = 'UPDATE' THEN
+        old_row_jsonb := to_jsonb(OLD);
+        new_row_jsonb := to_jsonb(NEW);
+        target_id_value := NEW.id;
+    ELSIF TG_OP = 'INSERT' THEN
+        new_row_jsonb := to_jsonb(NEW);
+        target_id_value := NEW.id;
+    ELSIF TG_OP = 'DELETE' THEN
+        old_row_jsonb := to_jsonb(OLD);
+        target_id_value := OLD.id;
+    END IF;
+
+    BEGIN
+        current_user_id := current_setting('app.user_id', true)::BIGINT;
+    EXCEPTION WHEN OTHERS THEN
+        current_user_id := NULL;
+    END;
+
+    BEGIN
+        current_upload_id := NULLIF(current_setting('app.upload_id', true), '')::UUID;
+    EXCEPTION WHEN OTHERS THEN
+        current_upload_id := NULL;
+    END;
+
+    EXECUTE format('INSERT INTO audit.%I ('
+                   'target_id, operation, changed_by, changed_at, old_data, new_data, upload_id)'
+                   ' VALUES ($1, $2, $3, $4, $5, $6, $7)', audit_table_name)
+    USING target_id_value,
+          substring(TG_OP, 1, 1),
+          current_user_id,
+          NOW(),
+          old_row_jsonb,
+          new_row_jsonb,
+          current_upload_id;
+
+    RETURN NEW;
+END;
+$$
+LANGUAGE plpgsql
+SECURITY DEFINER;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE OR REPLACE FUNCTION audit.agency_bureau_modified_func() RETURNS TRIGGER AS $$
+DECLARE
+    current_user_id BIGINT := NULL;
+    current_upload_id UUID := NULL;
+BEGIN
+    BEGIN
+        current_user_id := current_setting('app.user_id', true)::BIGINT;
+    EXCEPTION WHEN OTHERS THEN
+        current_user_id := NULL;
+    END;
+
+    BEGIN
+        current_upload_id := NULLIF(current_setting('app.upload_id', true), '')::UUID;
+    EXCEPTION WHEN OTHERS THEN
+        current_upload_id := NULL;
+    END;
+
+    INSERT INTO audit.agency_bureau_changes (vendor_code, operation, changed_by, changed_at, old_data, new_data, upload_id)
+    VALUES (
+        CASE WHEN TG_OP = 'DELETE' THEN OLD.vendor_code ELSE NEW.vendor_code END,
+        substring(TG_OP, 1, 1),
+        current_user_id,
+        NOW(),
+        CASE WHEN TG_OP = 'INSERT' THEN NULL ELSE to_jsonb(OLD) END,
+        CASE WHEN TG_OP = 'DELETE' THEN NULL ELSE to_jsonb(NEW) END,
+        current_upload_id
+    );
+
+    RETURN NEW;
+END;
+$$
+LANGUAGE plpgsql
+SECURITY DEFINER;
+-- +goose StatementEnd
+
+CREATE TRIGGER agency_bureau_audit_trigger
+AFTER INSERT OR UPDATE OR DELETE ON "agency_bureau"
+FOR EACH ROW EXECUTE FUNCTION audit.agency_bureau_modified_func();
+
+-- +goose Down
+DROP TRIGGER IF EXISTS agency_bureau_audit_trigger ON "agency_bureau";
+DROP FUNCTION IF EXISTS audit.agency_bureau_modified_func();
+
+-- +goose StatementBegin
+CREATE OR REPLACE FUNCTION audit.if_modified_func() RETURNS TRIGGER AS $$
+DECLARE
+    audit_table_name TEXT;
+    target_id_value BIGINT;
+    old_row_jsonb JSONB := NULL;
+    new_row_jsonb JSONB := NULL;
+    current_user_id BIGINT := NULL;
+BEGIN
+    audit_table_name := TG_TABLE_NAME || '_changes';
+
+    IF TG_OP = 'UPDATE' THEN
+        old_row_jsonb := to_jsonb(OLD);
+        new_row_jsonb := to_jsonb(NEW);
+        target_id_value := NEW.id;
+    ELSIF TG_OP = 'INSERT' THEN
+        new_row_jsonb := to_jsonb(NEW);
+        target_id_value := NEW.id;
+    ELSIF TG_OP = 'DELETE' THEN
+        old_row_jsonb := to_jsonb(OLD);
+        target_id_value := OLD.id;
+    END IF;
+
+    BEGIN
+        current_user_id := current_setting('app.user_id', true)::BIGINT;
+    EXCEPTION WHEN OTHERS THEN
+        current_user_id := NULL;
+    END;
+
+    EXECUTE format('INSERT INTO audit.%I ('
+                   'target_id, operation, changed_by, changed_at, old_data, new_data)'
+                   ' VALUES ($1, $2, $3, $4, $5, $6)', audit_table_name)
+    USING target_id_value,
+          substring(TG_OP, 1, 1),
+          current_user_id,
+          NOW(),
+          old_row_jsonb,
+          new_row_jsonb;
+
+    RETURN NEW;
+END;
+$$
+LANGUAGE plpgsql
+SECURITY DEFINER;
+-- +goose StatementEnd
+
+DROP TABLE IF EXISTS audit.agency_bureau_changes;
+
+ALTER TABLE audit.comments_changes DROP COLUMN upload_id;
+ALTER TABLE audit.cdms_user_changes DROP COLUMN upload_id;
+ALTER TABLE audit.nonipac_changes DROP COLUMN upload_id;
+ALTER TABLE audit.chargeback_changes DROP COLUMN upload_id;
+
+ALTER TABLE "uploads" DROP COLUMN "rolled_back_at";
+ALTER TABLE "uploads" DROP COLUMN "rolled_back_by_user_id";
+ALTER TABLE "uploads" DROP COLUMN "reprocessed_from_upload_id";
+
+DELETE FROM "role_permissions"
+WHERE permission_id = (SELECT id FROM permissions WHERE action = 'reports:rollback');
+DELETE FROM "permissions" WHERE action = 'reports:rollback';
@@ -0,0 +1,21 @@
+-- +goose Up
+-- Rows rejected during processing can be worked to zero: a user either corrects the
+-- row's fields and resubmits it through the normal validation and upsert path, or
+-- dismisses it with a note. corrected_row_data keeps the row as it was resubmitted.
+ALTER TABLE "removed_rows_log" ADD COLUMN "resolution_status" TEXT NOT NULL DEFAULT 'OPEN';
+ALTER TABLE "removed_rows_log" ADD COLUMN "corrected_row_data" JSONB;
+ALTER TABLE "removed_rows_log" ADD COLUMN "resolution_note" TEXT;
+ALTER TABLE "removed_rows_log" ADD COLUMN "resolved_by_user_id" BIGINT REFERENCES "cdms_user" ("id");
+ALTER TABLE "removed_rows_log" ADD COLUMN "resolved_at" TIMESTAMPTZ;
+ALTER TABLE "removed_rows_log" ADD CONSTRAINT "removed_rows_log_resolution_status_check" CHECK ("resolution_status" IN ('OPEN', 'CORRECTED', 'DISMISSED'));
+
+CREATE INDEX "idx_removed_rows_log_open" ON "removed_rows_log" ("upload_id") WHERE "resolution_status" = 'OPEN';
+
+-- +goose Down
+DROP INDEX IF EXISTS "idx_removed_rows_log_open";
+ALTER TABLE "removed_rows_log" DROP CONSTRAINT "removed_rows_log_resolution_status_check";
+ALTER TABLE "removed_rows_log" DROP COLUMN "resolved_at";
+ALTER TABLE "removed_rows_log" DROP COLUMN "resolved_by_user_id";
+ALTER TABLE "removed_rows_log" DROP COLUMN "resolution_note";
+ALTER TABLE "removed_rows_log" DROP COLUMN "corrected_row_data";
+ALTER TABLE "removed_rows_log" DROP COLUMN "resolution_status";
@@ -0,0 +1,17 @@
+-- +goose Up
+-- Each removed row carries the reasons it was rejected as a list of issues (column,
+-- raw value, rule code and message), so rejections can be grouped by rule and column
+-- instead of by parsing reason_for_removal. Rows logged before this have a single
+-- UNCLASSIFIED issue holding the free-text reason.
+ALTER TABLE "removed_rows_log" ADD COLUMN "issues" JSONB NOT NULL DEFAULT '[]';
+
+UPDATE "removed_rows_log"
+SET "issues" = jsonb_build_array(jsonb_build_object(
+    'column', '',
+    'raw_value', '',
+    'rule', 'UNCLASSIFIED',
+    'message', "reason_for_removal"
+));
+
+-- +goose Down
+ALTER TABLE "removed_rows_log" DROP COLUMN "issues";
@@ -0,0 +1,30 @@
+-- +goose Up
+-- Report layouts ship with the application as versioned definition files. Publishing a
+-- newer version here supersedes the shipped one without a deploy, e.g. when Finance
+-- renames a header. Versions are never edited, so every upload records the version it
+-- was validated against.
+INSERT INTO "permissions" (action, description) VALUES
+('reports:define', 'Ability to publish new versions of report definitions.');
+
+INSERT INTO "role_permissions" (role_id, permission_id)
+SELECT r.id, p.id
+FROM roles r, permissions p
+WHERE r.name IN ('super_admin', 'admin')
+  AND p.action = 'reports:define';
+
+CREATE TABLE "report_definitions" (
+    "report_type" TEXT NOT NULL,
+    "version" INTEGER NOT NULL,
+    "definition" JSONB NOT NULL,
+    "created_by_user_id" BIGINT NOT NULL REFERENCES "cdms_user" ("id"),
+    "created_at" TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY ("report_type", "version")
+);
+
+ALTER TABLE "uploads" ADD COLUMN "report_definition_version" INTEGER;
+
+-- +goose Down
+ALTER TABLE "uploads" DROP COLUMN "report_definition_version";
+DROP TABLE IF EXISTS "report_definitions";
+DELETE FROM "role_permissions" WHERE permission_id = (SELECT id FROM permissions WHERE action = 'reports:define');
+DELETE FROM "permissions" WHERE action = 'reports:define';
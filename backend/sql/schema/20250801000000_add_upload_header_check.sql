@@ -0,0 +1,8 @@
+-- +goose Up
+-- Files are matched to their report definition by header name rather than position, so
+-- each upload records how its header row was matched: the file position of every
+-- column, and which headers were missing, unexpected or matched through an alias.
+ALTER TABLE "uploads" ADD COLUMN "header_check" JSONB;
+
+-- +goose Down
+ALTER TABLE "uploads" DROP COLUMN "header_check";
@@ -0,0 +1,11 @@
+-- +goose Up
+-- Delimited reports are sniffed for their encoding and delimiter before being read.
+-- The uploader can force either one; the dialect actually used is recorded.
+ALTER TABLE "uploads" ADD COLUMN "encoding_override" TEXT;
+ALTER TABLE "uploads" ADD COLUMN "delimiter_override" TEXT;
+ALTER TABLE "uploads" ADD COLUMN "dialect" JSONB;
+
+-- +goose Down
+ALTER TABLE "uploads" DROP COLUMN "dialect";
+ALTER TABLE "uploads" DROP COLUMN "delimiter_override";
+ALTER TABLE "uploads" DROP COLUMN "encoding_override";
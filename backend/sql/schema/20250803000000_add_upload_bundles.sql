@@ -0,0 +1,22 @@
+-- +goose Up
+-- A bundle is a .zip or .csv.gz holding several reports uploaded at once, e.g. the
+-- month-end set. Each member is stored as its own upload; bundle_position is the order
+-- they are processed in, with VENDOR_CODE first because chargeback and nonipac rows
+-- reference agency_bureau.
+CREATE TABLE "upload_bundles" (
+    "id" UUID PRIMARY KEY,
+    "filename" TEXT NOT NULL,
+    "uploaded_by_user_id" BIGINT NOT NULL REFERENCES "cdms_user" ("id"),
+    "uploaded_at" TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+ALTER TABLE "uploads" ADD COLUMN "bundle_id" UUID REFERENCES "upload_bundles" ("id");
+ALTER TABLE "uploads" ADD COLUMN "bundle_position" INTEGER;
+
+CREATE INDEX "idx_uploads_bundle_id" ON "uploads" ("bundle_id", "bundle_position");
+
+-- +goose Down
+DROP INDEX IF EXISTS "idx_uploads_bundle_id";
+ALTER TABLE "uploads" DROP COLUMN "bundle_position";
+ALTER TABLE "uploads" DROP COLUMN "bundle_id";
+DROP TABLE IF EXISTS "upload_bundles";
@@ -0,0 +1,13 @@
+-- +goose Up
+-- The SHA-256 of each uploaded file, hashed as it is streamed into storage. Uploading
+-- the same content again for a report type would re-run the whole deactivate/upsert
+-- cycle for nothing and fill status_history with no-op changes, so the importer looks
+-- the hash up and refuses the file unless the uploader forces it. Uploads from before
+-- this column have no hash and never match.
+ALTER TABLE "uploads" ADD COLUMN "content_sha256" TEXT;
+
+CREATE INDEX "idx_uploads_content_sha256" ON "uploads" ("report_type", "content_sha256");
+
+-- +goose Down
+DROP INDEX IF EXISTS "idx_uploads_content_sha256";
+ALTER TABLE "uploads" DROP COLUMN "content_sha256";
@@ -0,0 +1,326 @@
+-- +goose Up
+-- Uploads are staged and merged by the system user, so audit rows and status history
+-- from an import could not be traced to the person who uploaded the file. Processing
+-- transactions now also set app.on_behalf_of to the uploader, and the audit and status
+-- history triggers record it next to the system user, so those changes read as "system
+-- on behalf of <user>". Status history also records the upload from app.upload_id.
+ALTER TABLE audit.chargeback_changes ADD COLUMN on_behalf_of BIGINT;
+ALTER TABLE audit.nonipac_changes ADD COLUMN on_behalf_of BIGINT;
+ALTER TABLE audit.cdms_user_changes ADD COLUMN on_behalf_of BIGINT;
+ALTER TABLE audit.comments_changes ADD COLUMN on_behalf_of BIGINT;
+ALTER TABLE audit.agency_bureau_changes ADD COLUMN on_behalf_of BIGINT;
+
+ALTER TABLE "status_history" ADD COLUMN "on_behalf_of_user_id" BIGINT REFERENCES "cdms_user" ("id");
+ALTER TABLE "status_history" ADD COLUMN "upload_id" UUID;
+
+-- +goose StatementBegin
+CREATE OR REPLACE FUNCTION audit.if_modified_func() RETURNS TRIGGER AS $$
+DECLARE
+    audit_table_name TEXT;
+    target_id_value BIGINT;
+    old_row_jsonb JSONB := NULL;
+    new_row_jsonb JSONB := NULL;
+    current_user_id BIGINT := NULL;
+    current_upload_id UUID := NULL;
+    on_behalf_of_id BIGINT := NULL;
+BEGIN
+    audit_table_name := TG_TABLE_NAME || '_changes';
+
+    IF TG_OP = 'UPDATE' THEN
+        old_row_jsonb := to_jsonb(OLD);
+        new_row_jsonb := to_jsonb(NEW);
+        target_id_value := NEW.id;
+    ELSIF TG_OP = 'INSERT' THEN
+        new_row_jsonb := to_jsonb(NEW);
+        target_id_value := NEW.id;
+    ELSIF TG_OP = 'DELETE' THEN
+        old_row_jsonb := to_jsonb(OLD);
+        target_id_value := OLD.id;
+    END IF;
+
+    BEGIN
+        current_user_id := current_setting('app.user_id', true)::BIGINT;
+    EXCEPTION WHEN OTHERS THEN
+        current_user_id := NULL;
+    END;
+
+    BEGIN
+        current_upload_id := NULLIF(current_setting('app.upload_id', true), '')::UUID;
+    EXCEPTION WHEN OTHERS THEN
+        current_upload_id := NULL;
+    END;
+
+    BEGIN
+        on_behalf_of_id := NULLIF(current_setting('app.on_behalf_of', true), '')::BIGINT;
+    EXCEPTION WHEN OTHERS THEN
+        on_behalf_of_id := NULL;
+    END;
+
+    EXECUTE format('INSERT INTO audit.%I ('
+                   'target_id, operation, changed_by, changed_at, old_data, new_data, upload_id, on_behalf_of)'
+                   ' VALUES ($1, $2, $3, $4, $5, $6, $7, $8)', audit_table_name)
+    USING target_id_value,
+          substring(TG_OP, 1, 1),
+          current_user_id,
+          NOW(),
+          old_row_jsonb,
+          new_row_jsonb,
+          current_upload_id,
+          on_behalf_of_id;
+
+    RETURN NEW;
+END;
+$$
+LANGUAGE plpgsql
+SECURITY DEFINER;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE OR REPLACE FUNCTION audit.agency_bureau_modified_func() RETURNS TRIGGER AS $$
+DECLARE
+    current_user_id BIGINT := NULL;
+    current_upload_id UUID := NULL;
+    on_behalf_of_id BIGINT := NULL;
+BEGIN
+    BEGIN
+        current_user_id := current_setting('app.user_id', true)::BIGINT;
+    EXCEPTION WHEN OTHERS THEN
+        current_user_id := NULL;
+    END;
+
+    BEGIN
+        current_upload_id := NULLIF(current_setting('app.upload_id', true), '')::UUID;
+    EXCEPTION WHEN OTHERS THEN
+        current_upload_id := NULL;
+    END;
+
+    BEGIN
+        on_behalf_of_id := NULLIF(current_setting('app.on_behalf_of', true), '')::BIGINT;
+    EXCEPTION WHEN OTHERS THEN
+        on_behalf_of_id := NULL;
+    END;
+
+    INSERT INTO audit.agency_bureau_changes (vendor_code, operation, changed_by, changed_at, old_data, new_data, upload_id, on_behalf_of)
+    VALUES (
+        CASE WHEN TG_OP = 'DELETE' THEN OLD.vendor_code ELSE NEW.vendor_code END,
+        substring(TG_OP, 1, 1),
+        current_user_id,
+        NOW(),
+        CASE WHEN TG_OP = 'INSERT' THEN NULL ELSE to_jsonb(OLD) END,
+        CASE WHEN TG_OP = 'DELETE' THEN NULL ELSE to_jsonb(NEW) END,
+        current_upload_id,
+        on_behalf_of_id
+    );
+
+    RETURN NEW;
+END;
+$$
+LANGUAGE plpgsql
+SECURITY DEFINER;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE OR REPLACE FUNCTION log_status_history_func()
+RETURNS TRIGGER AS $$
+DECLARE
+    last_status_history_id BIGINT;
+    current_user_id BIGINT;
+    system_user_id BIGINT;
+    status_note TEXT;
+    current_upload_id UUID := NULL;
+    on_behalf_of_id BIGINT := NULL;
+BEGIN
+    IF (TG_OP = 'UPDATE' AND OLD.current_status IS NOT DISTINCT FROM NEW.current_status) THEN
+        RETURN NEW;
+    END IF;
+
+    SELECT id INTO system_user_id FROM "cdms_user" WHERE email = 'system@cdms.local';
+
+    BEGIN
+        current_user_id := current_setting('app.user_id')::BIGINT;
+    EXCEPTION WHEN OTHERS THEN
+        current_user_id := system_user_id;
+    END;
+
+    BEGIN
+        current_upload_id := NULLIF(current_setting('app.upload_id', true), '')::UUID;
+    EXCEPTION WHEN OTHERS THEN
+        current_upload_id := NULL;
+    END;
+
+    BEGIN
+        on_behalf_of_id := NULLIF(current_setting('app.on_behalf_of', true), '')::BIGINT;
+    EXCEPTION WHEN OTHERS THEN
+        on_behalf_of_id := NULL;
+    END;
+
+    status_note := NULLIF(current_setting('app.status_note', true), '');
+
+    INSERT INTO "status_history" (status, notes, user_id, status_date, on_behalf_of_user_id, upload_id)
+    VALUES (
+        NEW.current_status,
+        COALESCE(status_note, 'Status ' || NEW.current_status || ' logged via trigger for ' || TG_TABLE_NAME),
+        current_user_id,
+        NOW(),
+        on_behalf_of_id,
+        current_upload_id
+    )
+    RETURNING id INTO last_status_history_id;
+
+    IF TG_TABLE_NAME = 'chargeback' THEN
+        INSERT INTO "chargeback_status_merge" (chargeback_id, status_history_id)
+        VALUES (NEW.id, last_status_history_id);
+    ELSIF TG_TABLE_NAME = 'nonipac' THEN
+        INSERT INTO "nonipac_status_merge" (nonipac_id, status_history_id)
+        VALUES (NEW.id, last_status_history_id);
+    END IF;
+
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+CREATE OR REPLACE FUNCTION log_status_history_func()
+RETURNS TRIGGER AS $$
+DECLARE
+    last_status_history_id BIGINT;
+    current_user_id BIGINT;
+    system_user_id BIGINT;
+    status_note TEXT;
+BEGIN
+    IF (TG_OP = 'UPDATE' AND OLD.current_status IS NOT DISTINCT FROM NEW.current_status) THEN
+        RETURN NEW;
+    END IF;
+
+    SELECT id INTO system_user_id FROM "cdms_user" WHERE email = 'system@cdms.local';
+
+    BEGIN
+        current_user_id := current_setting('app.user_id')::BIGINT;
+    EXCEPTION WHEN OTHERS THEN
+        current_user_id := system_user_id;
+    END;
+
+    status_note := NULLIF(current_setting('app.status_note', true), '');
+
+    INSERT INTO "status_history" (status, notes, user_id, status_date)
+    VALUES (
+        NEW.current_status,
+        COALESCE(status_note, 'Status ' || NEW.current_status || ' logged via trigger for ' || TG_TABLE_NAME),
+        current_user_id,
+        NOW()
+    )
+    RETURNING id INTO last_status_history_id;
+
+    IF TG_TABLE_NAME = 'chargeback' THEN
+        INSERT INTO "chargeback_status_merge" (chargeback_id, status_history_id)
+        VALUES (NEW.id, last_status_history_id);
+    ELSIF TG_TABLE_NAME = 'nonipac' THEN
+        INSERT INTO "nonipac_status_merge" (nonipac_id, status_history_id)
+        VALUES (NEW.id, last_status_history_id);
+    END IF;
+
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE OR REPLACE FUNCTION audit.agency_bureau_modified_func() RETURNS TRIGGER AS $$
+DECLARE
+    current_user_id BIGINT := NULL;
+    current_upload_id UUID := NULL;
+BEGIN
+    BEGIN
+        current_user_id := current_setting('app.user_id', true)::BIGINT;
+    EXCEPTION WHEN OTHERS THEN
+        current_user_id := NULL;
+    END;
+
+    BEGIN
+        current_upload_id := NULLIF(current_setting('app.upload_id', true), '')::UUID;
+    EXCEPTION WHEN OTHERS THEN
+        current_upload_id := NULL;
+    END;
+
+    INSERT INTO audit.agency_bureau_changes (vendor_code, operation, changed_by, changed_at, old_data, new_data, upload_id)
+    VALUES (
+        CASE WHEN TG_OP = 'DELETE' THEN OLD.vendor_code ELSE NEW.vendor_code END,
+        substring(TG_OP, 1, 1),
+        current_user_id,
+        NOW(),
+        CASE WHEN TG_OP = 'INSERT' THEN NULL ELSE to_jsonb(OLD) END,
+        CASE WHEN TG_OP = 'DELETE' THEN NULL ELSE to_jsonb(NEW) END,
+        current_upload_id
+    );
+
+    RETURN NEW;
+END;
+$$
+LANGUAGE plpgsql
+SECURITY DEFINER;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE OR REPLACE FUNCTION audit.if_modified_func() RETURNS TRIGGER AS $$
+DECLARE
+    audit_table_name TEXT;
+    target_id_value BIGINT;
+    old_row_jsonb JSONB := NULL;
+    new_row_jsonb JSONB := NULL;
+    current_user_id BIGINT := NULL;
+    current_upload_id UUID := NULL;
+BEGIN
+    audit_table_name := TG_TABLE_NAME || '_changes';
+
+    IF TG_OP = 'UPDATE' THEN
+        old_row_jsonb := to_jsonb(OLD);
+        new_row_jsonb := to_jsonb(NEW);
+        target_id_value := NEW.id;
+    ELSIF TG_OP = 'INSERT' THEN
+        new_row_jsonb := to_jsonb(NEW);
+        target_id_value := NEW.id;
+    ELSIF TG_OP = 'DELETE' THEN
+        old_row_jsonb := to_jsonb(OLD);
+        target_id_value := OLD.id;
+    END IF;
+
+    BEGIN
+        current_user_id := current_setting('app.user_id', true)::BIGINT;
+    EXCEPTION WHEN OTHERS THEN
+        current_user_id := NULL;
+    END;
+
+    BEGIN
+        current_upload_id := NULLIF(current_setting('app.upload_id', true), '')::UUID;
+    EXCEPTION WHEN OTHERS THEN
+        current_upload_id := NULL;
+    END;
+
+    EXECUTE format('INSERT INTO audit.%I ('
+                   'target_id, operation, changed_by, changed_at, old_data, new_data, upload_id)'
+                   ' VALUES ($1, $2, $3, $4, $5, $6, $7)', audit_table_name)
+    USING target_id_value,
+          substring(TG_OP, 1, 1),
+          current_user_id,
+          NOW(),
+          old_row_jsonb,
+          new_row_jsonb,
+          current_upload_id;
+
+    RETURN NEW;
+END;
+$$
+LANGUAGE plpgsql
+SECURITY DEFINER;
+-- +goose StatementEnd
+
+ALTER TABLE "status_history" DROP COLUMN "upload_id";
+ALTER TABLE "status_history" DROP COLUMN "on_behalf_of_user_id";
+
+ALTER TABLE audit.agency_bureau_changes DROP COLUMN on_behalf_of;
+ALTER TABLE audit.comments_changes DROP COLUMN on_behalf_of;
+ALTER TABLE audit.cdms_user_changes DROP COLUMN on_behalf_of;
+ALTER TABLE audit.nonipac_changes DROP COLUMN on_behalf_of;
+ALTER TABLE audit.chargeback_changes DROP COLUMN on_behalf_of;
@@ -0,0 +1,40 @@
+-- +goose Up
+-- Live upload progress. The processor records its latest progress event per upload and
+-- sends it on the upload_progress channel; a trigger sends each change of an upload's
+-- status on the same channel. Every server instance LISTENs on the channel and relays
+-- events to the clients following an upload, whichever instance is processing it.
+-- Progress is kept out of "uploads" because the processing transaction holds that row
+-- locked while it stages. Error details are cut short to stay within NOTIFY's payload
+-- limit; the full text stays on the upload.
+CREATE TABLE "upload_progress" (
+    "upload_id" UUID PRIMARY KEY REFERENCES "uploads" ("id") ON DELETE CASCADE,
+    "event" JSONB NOT NULL,
+    "updated_at" TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- +goose StatementBegin
+CREATE OR REPLACE FUNCTION notify_upload_status_func()
+RETURNS TRIGGER AS $$
+BEGIN
+    PERFORM pg_notify('upload_progress', json_build_object(
+        'type', 'status',
+        'upload_id', NEW.id,
+        'status', NEW.status,
+        'error', left(NEW.error_details, 2000),
+        'at', NOW()
+    )::TEXT);
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+CREATE TRIGGER notify_upload_status_change
+AFTER UPDATE OF status ON "uploads"
+FOR EACH ROW
+WHEN (OLD.status IS DISTINCT FROM NEW.status)
+EXECUTE FUNCTION notify_upload_status_func();
+
+-- +goose Down
+DROP TRIGGER IF EXISTS notify_upload_status_change ON "uploads";
+DROP FUNCTION IF EXISTS notify_upload_status_func();
+DROP TABLE IF EXISTS "upload_progress";
@@ -0,0 +1,27 @@
+-- +goose Up
+-- Cancelling an upload before it is committed. An upload still waiting for a worker is
+-- cancelled outright. For one being processed the request is recorded on its job,
+-- because the processing transaction holds the upload row locked, and announced on the
+-- upload_progress channel; the worker holding the job stops and records the CANCELLED
+-- status along with who asked for it.
+ALTER TABLE "uploads" ADD COLUMN "cancelled_by_user_id" BIGINT REFERENCES "cdms_user" ("id");
+ALTER TABLE "uploads" ADD COLUMN "cancelled_at" TIMESTAMPTZ;
+
+ALTER TABLE "upload_jobs" ADD COLUMN "cancel_requested_by_user_id" BIGINT REFERENCES "cdms_user" ("id");
+ALTER TABLE "upload_jobs" ADD COLUMN "cancel_requested_at" TIMESTAMPTZ;
+
+ALTER TABLE "upload_jobs" DROP CONSTRAINT "upload_jobs_status_check";
+ALTER TABLE "upload_jobs" ADD CONSTRAINT "upload_jobs_status_check"
+    CHECK ("status" IN ('QUEUED', 'PROCESSING', 'RETRYING', 'SUCCEEDED', 'FAILED', 'CANCELLED'));
+
+-- +goose Down
+UPDATE "upload_jobs" SET "status" = 'FAILED' WHERE "status" = 'CANCELLED';
+ALTER TABLE "upload_jobs" DROP CONSTRAINT "upload_jobs_status_check";
+ALTER TABLE "upload_jobs" ADD CONSTRAINT "upload_jobs_status_check"
+    CHECK ("status" IN ('QUEUED', 'PROCESSING', 'RETRYING', 'SUCCEEDED', 'FAILED'));
+
+ALTER TABLE "upload_jobs" DROP COLUMN "cancel_requested_at";
+ALTER TABLE "upload_jobs" DROP COLUMN "cancel_requested_by_user_id";
+
+ALTER TABLE "uploads" DROP COLUMN "cancelled_at";
+ALTER TABLE "uploads" DROP COLUMN "cancelled_by_user_id";
@@ -0,0 +1,43 @@
+-- +goose Up
+-- The vendor directory. VENDOR_CODE reports carry each vendor's name, address and
+-- registration details alongside the agency and bureau, but only those two were kept.
+-- The remaining columns are stored on agency_bureau itself so that chargebacks and
+-- non-ipac rows keep referencing it, and its audit trail records how a vendor's
+-- details change from one upload to the next. Everything but the original columns is
+-- optional, as older reports and manually created vendors lack them.
+ALTER TABLE "agency_bureau"
+    ADD COLUMN "agency_location_code" TEXT,
+    ADD COLUMN "vendor_address_code" TEXT,
+    ADD COLUMN "name" TEXT,
+    ADD COLUMN "address_line_1" TEXT,
+    ADD COLUMN "address_line_2" TEXT,
+    ADD COLUMN "address_line_3" TEXT,
+    ADD COLUMN "city" TEXT,
+    ADD COLUMN "state" TEXT,
+    ADD COLUMN "zip" TEXT,
+    ADD COLUMN "status" TEXT,
+    ADD COLUMN "vendor_type" TEXT,
+    ADD COLUMN "reporting_attribute" TEXT,
+    ADD COLUMN "security_org" TEXT,
+    ADD COLUMN "transmit_to_vcss_flag" TEXT;
+
+CREATE INDEX "idx_agency_bureau_name" ON "agency_bureau" (LOWER("name"));
+
+-- +goose Down
+DROP INDEX IF EXISTS "idx_agency_bureau_name";
+
+ALTER TABLE "agency_bureau"
+    DROP COLUMN "transmit_to_vcss_flag",
+    DROP COLUMN "security_org",
+    DROP COLUMN "reporting_attribute",
+    DROP COLUMN "vendor_type",
+    DROP COLUMN "status",
+    DROP COLUMN "zip",
+    DROP COLUMN "state",
+    DROP COLUMN "city",
+    DROP COLUMN "address_line_3",
+    DROP COLUMN "address_line_2",
+    DROP COLUMN "address_line_1",
+    DROP COLUMN "name",
+    DROP COLUMN "vendor_address_code",
+    DROP COLUMN "agency_location_code";
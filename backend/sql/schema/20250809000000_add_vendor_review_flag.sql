@@ -0,0 +1,12 @@
+-- +goose Up
+-- Chargeback and non-ipac rows reference agency_bureau, so a row whose vendor code is
+-- not in the directory cannot be merged. Such rows are normally set aside as removed
+-- rows. With UNKNOWN_VENDORS=placeholder the merge instead creates a placeholder vendor
+-- with no agency or bureau, flagged for review until a VENDOR_CODE report fills it in.
+ALTER TABLE "agency_bureau" ADD COLUMN "needs_review" BOOLEAN NOT NULL DEFAULT FALSE;
+
+CREATE INDEX "idx_agency_bureau_needs_review" ON "agency_bureau" ("vendor_code") WHERE "needs_review";
+
+-- +goose Down
+DROP INDEX IF EXISTS "idx_agency_bureau_needs_review";
+ALTER TABLE "agency_bureau" DROP COLUMN "needs_review";
@@ -0,0 +1,14 @@
+-- +goose Up
+-- The row-count sanity check compares a report's rows against those of the last
+-- successful upload of its report type. rows_upserted cannot serve as that baseline,
+-- since the vendor directory upsert skips unchanged vendors, so the staged row count
+-- is recorded as well. Merged uploads take it from their stored sanity check, or from
+-- rows_upserted where they predate one, when every staged row was upserted.
+ALTER TABLE "uploads" ADD COLUMN "rows_staged" INTEGER;
+
+UPDATE "uploads"
+SET "rows_staged" = COALESCE(("sanity_check" ->> 'rows_staged')::INTEGER, "rows_upserted")
+WHERE "status" IN ('COMPLETE', 'COMPLETE_WITH_ISSUES', 'ROLLED_BACK');
+
+-- +goose Down
+ALTER TABLE "uploads" DROP COLUMN "rows_staged";
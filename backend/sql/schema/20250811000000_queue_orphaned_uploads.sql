@@ -0,0 +1,14 @@
+-- +goose Up
+-- An upload used to be recorded before its job was queued, in a separate statement, so
+-- a failure in between left it UPLOADED with no job and nothing would ever process it.
+-- Both are now written in one transaction; uploads already orphaned are queued here.
+INSERT INTO "upload_jobs" (upload_id, max_attempts)
+SELECT u.id, 3
+FROM "uploads" u
+WHERE u.status = 'UPLOADED'
+  AND NOT EXISTS (SELECT 1 FROM "upload_jobs" j WHERE j.upload_id = u.id);
+
+UPDATE "uploads" SET status = 'QUEUED' WHERE status = 'UPLOADED';
+
+-- +goose Down
+-- The uploads queued above are processed like any other; there is nothing to undo.
@@ -0,0 +1,23 @@
+-- +goose Up
+-- An upload used to be approved before its merge was queued, in a separate statement,
+-- so a failure in between left it APPROVED with nothing to merge it and no way to
+-- reject it. Approval now queues the merge in the same statement and never leaves an
+-- upload APPROVED; uploads already stuck there are queued here.
+INSERT INTO "upload_jobs" (upload_id, max_attempts)
+SELECT id, 3 FROM "uploads" WHERE status = 'APPROVED'
+ON CONFLICT (upload_id) DO UPDATE
+SET
+    status = 'QUEUED',
+    attempts = 0,
+    run_after = NOW(),
+    locked_by = NULL,
+    lease_expires_at = NULL,
+    heartbeat_at = NULL,
+    last_error = NULL,
+    cancel_requested_by_user_id = NULL,
+    cancel_requested_at = NULL;
+
+UPDATE "uploads" SET status = 'QUEUED' WHERE status = 'APPROVED';
+
+-- +goose Down
+-- The uploads queued above are merged like any other; there is nothing to undo.
@@ -4,6 +4,7 @@ import { Button } from "@/components/ui/button";
 import { Select, SelectContent, SelectItem, SelectTrigger, SelectValue } from "@/components/ui/select";
 import { Accordion, AccordionContent, AccordionItem, AccordionTrigger } from "@/components/ui/accordion";
 import { StatusHistory } from "./StatusHistory";
+import { VendorDetails } from "./VendorDetails";
 import React, { useState, useEffect } from "react";
 
 interface Field<TData> {
@@ -24,9 +25,10 @@ interface DetailsDrawerProps<TData> {
   onCancel: () => void;
   id?: number;
   type?: 'chargeback' | 'delinquency';
+  vendorCode?: string;
 }
 
-export function DetailsDrawer<TData extends object>({ data, fields, onSave, onCancel, id, type }: DetailsDrawerProps<TData>) {
+export function DetailsDrawer<TData extends object>({ data, fields, onSave, onCancel, id, type, vendorCode }: DetailsDrawerProps<TData>) {
   const [editableData, setEditableData] = useState(data);
   const [hasChanges, setHasChanges] = useState(false);
 
@@ -117,6 +119,14 @@ export function DetailsDrawer<TData extends object>({ data, fields, onSave, onCa
       </div>
       <div className="mt-4 pt-4 border-t flex-grow">
         <Accordion type="single" collapsible defaultValue="status-history">
+          {vendorCode && (
+            <AccordionItem value="vendor">
+              <AccordionTrigger>Vendor</AccordionTrigger>
+              <AccordionContent>
+                <VendorDetails code={vendorCode} />
+              </AccordionContent>
+            </AccordionItem>
+          )}
           <AccordionItem value="comments">
             <AccordionTrigger>Comments</AccordionTrigger>
             <AccordionContent>
@@ -9,6 +9,8 @@ interface StatusHistoryEntry {
   user_first_name: string;
   user_last_name: string;
   user_email: string;
+  upload_id: string | null;
+  attributed_to: string;
 }
 
 interface StatusHistoryProps {
@@ -63,7 +65,8 @@ export function StatusHistory({ id, type }: StatusHistoryProps) {
         <div key={entry.status_history_id} className="p-2 border rounded-md">
           <p><strong>Status:</strong> {entry.status}</p>
           <p><strong>Date:</strong> {new Date(entry.status_date).toLocaleString()}</p>
-          <p><strong>User:</strong> {entry.user_first_name} {entry.user_last_name} ({entry.user_email})</p>
+          <p><strong>User:</strong> {entry.attributed_to} ({entry.user_email})</p>
+          {entry.upload_id && <p><strong>Upload:</strong> {entry.upload_id}</p>}
           <p><strong>Notes:</strong> {entry.notes}</p>
         </div>
       ))}
@@ -5,6 +5,7 @@ import { Label } from "@/components/ui/label";
 import { Select, SelectContent, SelectItem, SelectTrigger, SelectValue } from "@/components/ui/select";
 import toast from "react-hot-toast";
 import { useAuth0 } from "@auth0/auth0-react";
+import { UploadProgress } from "@/components/uploads/UploadProgress";
 
 interface UploadReportModalProps {
   onClose: () => void;
@@ -22,6 +23,7 @@ export function UploadReportModal({ onClose, onUploadSuccess }: UploadReportModa
   const [selectedReportType, setSelectedReportType] = useState<string | null>(null);
   const [selectedFile, setSelectedFile] = useState<File | null>(null);
   const [isUploading, setIsUploading] = useState(false);
+  const [uploadId, setUploadId] = useState<string | null>(null);
   const { getAccessTokenSilently } = useAuth0();
 
   const handleFileChange = (event: React.ChangeEvent<HTMLInputElement>) => {
@@ -66,9 +68,10 @@ export function UploadReportModal({ onClose, onUploadSuccess }: UploadReportModa
       });
 
       if (response.ok) {
+        const data = await response.json();
         toast.success("Upload successful! Processing has begun.");
         onUploadSuccess();
-        onClose();
+        setUploadId(data.upload_id);
       } else {
         const errorData = await response.json();
         toast.error(`Upload failed: ${errorData.message || response.statusText}`);
@@ -80,6 +83,17 @@ export function UploadReportModal({ onClose, onUploadSuccess }: UploadReportModa
     }
   };
 
+  if (uploadId) {
+    return (
+      <div className="grid gap-4 py-4">
+        <UploadProgress uploadId={uploadId} onFinished={onUploadSuccess} />
+        <Button onClick={onClose} variant="outline" className="w-full">
+          Close
+        </Button>
+      </div>
+    );
+  }
+
   return (
     <div className="grid gap-4 py-4">
       <div className="grid grid-cols-4 items-center gap-4">
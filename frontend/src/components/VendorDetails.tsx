@@ -0,0 +1,81 @@
+import { useState, useEffect } from 'react';
+import { useAuth0 } from '@auth0/auth0-react';
+import { apiClient } from '@/lib/api';
+
+interface Vendor {
+  vendor_code: string;
+  agency: string;
+  bureau_code: string;
+  name: string | null;
+  address_line_1: string | null;
+  address_line_2: string | null;
+  address_line_3: string | null;
+  city: string | null;
+  state: string | null;
+  zip: string | null;
+  status: string | null;
+  needs_review: boolean;
+}
+
+interface VendorDetailsProps {
+  code: string;
+}
+
+export function VendorDetails({ code }: VendorDetailsProps) {
+  const [vendor, setVendor] = useState<Vendor | null>(null);
+  const [isLoading, setIsLoading] = useState(true);
+  const { getAccessTokenSilently } = useAuth0();
+
+  useEffect(() => {
+    const fetchVendor = async () => {
+      try {
+        setIsLoading(true);
+        const token = await getAccessTokenSilently({
+          authorizationParams: {
+            audience: import.meta.env.VITE_AUTH0_AUDIENCE,
+          },
+        });
+        const data = await apiClient.get(`/api/vendors/${encodeURIComponent(code)}`, token);
+        setVendor(data.vendor);
+      } catch (error) {
+        console.error('VendorDetails: Failed to fetch vendor:', error);
+        setVendor(null);
+      } finally {
+        setIsLoading(false);
+      }
+    };
+
+    if (code) {
+      fetchVendor();
+    }
+  }, [code, getAccessTokenSilently]);
+
+  if (isLoading) {
+    return <p>Loading vendor...</p>;
+  }
+
+  if (!vendor) {
+    return <p>No vendor details available.</p>;
+  }
+
+  const cityLine = [vendor.city, [vendor.state, vendor.zip].filter(Boolean).join(' ')].filter(Boolean).join(', ');
+  const addressLines = [vendor.address_line_1, vendor.address_line_2, vendor.address_line_3, cityLine].filter(Boolean);
+
+  return (
+    <div className="space-y-1">
+      {vendor.needs_review && (
+        <p className="text-sm text-muted-foreground">Placeholder vendor created from an upload; needs review.</p>
+      )}
+      <p><strong>Name:</strong> {vendor.name ?? vendor.vendor_code}</p>
+      <p><strong>Vendor Code:</strong> {vendor.vendor_code}</p>
+      <p><strong>Agency / Bureau:</strong> {vendor.agency} / {vendor.bureau_code}</p>
+      {addressLines.length > 0 && (
+        <div>
+          <strong>Address:</strong>
+          {addressLines.map((line, i) => <p key={i}>{line}</p>)}
+        </div>
+      )}
+      {vendor.status && <p><strong>Status:</strong> {vendor.status}</p>}
+    </div>
+  );
+}
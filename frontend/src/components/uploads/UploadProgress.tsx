@@ -0,0 +1,87 @@
+import { useEffect, useState } from 'react';
+import { useAuth0 } from '@auth0/auth0-react';
+import { streamUploadEvents, UploadEvent } from '@/lib/uploadEvents';
+
+const PHASE_LABELS: Record<string, string> = {
+  download: 'Downloading file',
+  validate: 'Checking headers',
+  stage: 'Staging rows',
+  log_removed_rows: 'Logging removed rows',
+  upsert: 'Merging',
+};
+
+// Statuses of an upload that is still being processed, as on the server.
+const IN_FLIGHT_STATUSES = ['UPLOADED', 'QUEUED', 'PROCESSING', 'WAITING_FOR_LOCK', 'RETRYING'];
+
+interface UploadProgressProps {
+  uploadId: string;
+  onFinished?: (status: string) => void;
+}
+
+export function UploadProgress({ uploadId, onFinished }: UploadProgressProps) {
+  const [progress, setProgress] = useState<UploadEvent | null>(null);
+  const [status, setStatus] = useState<UploadEvent | null>(null);
+  const [streamError, setStreamError] = useState<string | null>(null);
+  const { getAccessTokenSilently } = useAuth0();
+
+  useEffect(() => {
+    const controller = new AbortController();
+
+    const follow = async () => {
+      try {
+        const token = await getAccessTokenSilently({
+          authorizationParams: {
+            audience: import.meta.env.VITE_AUTH0_AUDIENCE,
+          },
+        });
+        await streamUploadEvents(uploadId, token, (event) => {
+          if (event.type === 'progress') {
+            setProgress(event);
+            return;
+          }
+          setStatus(event);
+          if (event.status && !IN_FLIGHT_STATUSES.includes(event.status)) {
+            onFinished?.(event.status);
+          }
+        }, controller.signal);
+      } catch (error: any) {
+        if (!controller.signal.aborted) {
+          console.error('UploadProgress: Failed to follow upload:', error);
+          setStreamError(error.message);
+        }
+      }
+    };
+
+    follow();
+    return () => controller.abort();
+  }, [uploadId, getAccessTokenSilently]);
+
+  // The whole file has been read by the time staged rows are merged, when the server
+  // stops reporting bytes.
+  const finished = status?.status !== undefined && !IN_FLIGHT_STATUSES.includes(status.status);
+  let percent = 0;
+  if (finished || progress?.phase === 'upsert') {
+    percent = 100;
+  } else if (progress && progress.bytes_total > 0) {
+    percent = Math.min(100, Math.round((progress.bytes_read / progress.bytes_total) * 100));
+  }
+
+  return (
+    <div className="space-y-2">
+      <div className="flex justify-between text-sm">
+        <span>{finished ? status?.status : PHASE_LABELS[progress?.phase ?? ''] ?? status?.status ?? 'Waiting'}</span>
+        <span>{percent}%</span>
+      </div>
+      <div className="h-2 w-full overflow-hidden rounded-full bg-muted">
+        <div className="h-full bg-primary transition-all" style={{ width: `${percent}%` }} />
+      </div>
+      {progress && (
+        <p className="text-xs text-muted-foreground">
+          {progress.rows_read.toLocaleString()} rows read, {progress.rows_rejected.toLocaleString()} removed
+        </p>
+      )}
+      {status?.error && <p className="text-sm text-destructive">{status.error}</p>}
+      {streamError && <p className="text-xs text-muted-foreground">Live progress unavailable: {streamError}</p>}
+    </div>
+  );
+}
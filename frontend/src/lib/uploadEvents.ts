@@ -0,0 +1,59 @@
+export interface UploadEvent {
+  type: 'progress' | 'status';
+  upload_id: string;
+  phase?: string;
+  rows_read: number;
+  rows_converted: number;
+  rows_rejected: number;
+  bytes_read: number;
+  bytes_total: number;
+  status?: string;
+  error?: string;
+  at: string;
+}
+
+// The stream of /api/uploads/:id/events is read with fetch rather than EventSource,
+// which cannot send the Authorization header the API authenticates every request by.
+// The bearer token is sent as for any other call, and never put in the URL, where it
+// would end up in proxy and access logs.
+export async function streamUploadEvents(
+  uploadId: string,
+  token: string,
+  onEvent: (event: UploadEvent) => void,
+  signal: AbortSignal,
+): Promise<void> {
+  const response = await fetch(`${import.meta.env.VITE_API_BASE_URL}/api/uploads/${uploadId}/events`, {
+    headers: {
+      Accept: 'text/event-stream',
+      Authorization: `Bearer ${token}`,
+    },
+    signal,
+  });
+  if (!response.ok || !response.body) {
+    throw new Error(`HTTP error! status: ${response.status}`);
+  }
+
+  const reader = response.body.pipeThrough(new TextDecoderStream()).getReader();
+  let buffer = '';
+  for (;;) {
+    const { value, done } = await reader.read();
+    if (done) {
+      return;
+    }
+    buffer += value.replace(/\r\n?/g, '\n');
+
+    // Events are separated by a blank line; the last part may still be incomplete.
+    const frames = buffer.split('\n\n');
+    buffer = frames.pop() ?? '';
+    for (const frame of frames) {
+      const data = frame
+        .split('\n')
+        .filter((line) => line.startsWith('data:'))
+        .map((line) => line.slice(5).trimStart())
+        .join('\n');
+      if (data) {
+        onEvent(JSON.parse(data));
+      }
+    }
+  }
+}
@@ -166,6 +166,7 @@ export function ChargebacksPage({ onUploadSuccess }: ChargebacksPageProps) {
               onCancel={handleCancelChargeback}
               id={selectedChargeback.id}
               type="chargeback"
+              vendorCode={selectedChargeback.vendor}
             />
           )}
         </SheetContent>